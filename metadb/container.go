@@ -0,0 +1,756 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2017-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package metadb
+
+import (
+	oio "github.com/jfsmig/oio-go/sdk"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// The policy applied when none is requested
+	DefaultPolicy = "SINGLE"
+
+	defaultChunkSize = 1024 * 1024
+)
+
+type bucket struct {
+	// The versions of each content, the oldest first
+	contents   map[string][]oio.Content
+	properties map[string]string
+}
+
+// Chooses the addresses of the rawx services for the <count> chunks of a
+// metachunk, or fails with ErrorNoService.
+type RawxSelector func(count int) ([]string, error)
+
+// Container is an oio.Container for a single namespace. The contents are
+// versioned like in the real containers: each upload gets a new version, and
+// when the versioning is enabled the deletion of the latest version only
+// adds a deletion marker.
+type Container struct {
+	ns        string
+	directory *Directory
+	journal   *Journal
+
+	lock        sync.Mutex
+	buckets     map[oio.ContainerId]*bucket
+	policies    map[string]string
+	chunkSize   uint64
+	rawx        []string
+	selector    RawxSelector
+	versioning  bool
+	lastVersion uint64
+}
+
+// Builds an empty container service for the namespace. When <d> is not nil,
+// the containers can only be created for the users known by <d>, unless the
+// autocreation is requested.
+func MakeContainer(ns string, d *Directory) *Container {
+	return &Container{
+		ns:        ns,
+		directory: d,
+		buckets:   make(map[oio.ContainerId]*bucket),
+		policies: map[string]string{
+			DefaultPolicy: "plain/nb_copy=1",
+			"THREECOPIES": "plain/nb_copy=3",
+		},
+		chunkSize: defaultChunkSize,
+	}
+}
+
+// Declares the storage policy with the chunk method of its contents, e.g.
+// "plain/nb_copy=2".
+func (c *Container) SetPolicy(name, chunkMethod string) {
+	c.lock.Lock()
+	c.policies[name] = chunkMethod
+	c.lock.Unlock()
+}
+
+// Sets the size of the metachunks of the next contents
+func (c *Container) SetChunkSize(size uint64) {
+	c.lock.Lock()
+	c.chunkSize = size
+	c.lock.Unlock()
+}
+
+// Sets the addresses of the rawx services the chunks are placed on, in turn
+func (c *Container) SetRawx(addrs []string) {
+	c.lock.Lock()
+	c.rawx = append(make([]string, 0, len(addrs)), addrs...)
+	c.lock.Unlock()
+}
+
+// Makes the chunks placed by <sel> instead of on the services set with
+// SetRawx(). A nil selector restores the placement on those services.
+func (c *Container) SetRawxSelector(sel RawxSelector) {
+	c.lock.Lock()
+	c.selector = sel
+	c.lock.Unlock()
+}
+
+// Keeps the old versions of the contents and the deletion markers, instead
+// of only the latest version.
+func (c *Container) SetVersioning(enabled bool) {
+	c.lock.Lock()
+	c.versioning = enabled
+	c.lock.Unlock()
+}
+
+// Returns the rawx services set with SetRawx()
+func (c *Container) Rawx() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append(make([]string, 0, len(c.rawx)), c.rawx...)
+}
+
+// Describes the namespace as the conscience does: the chunk size and the
+// storage policies with their chunk method.
+func (c *Container) NamespaceInfo() oio.NamespaceInfo {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	info := oio.NamespaceInfo{
+		Name:            c.ns,
+		ChunkSize:       int64(c.chunkSize),
+		Options:         make(map[string]string),
+		StoragePolicies: make(map[string]string),
+		DataSecurities:  make(map[string]string),
+		ServicePools:    make(map[string]string),
+	}
+	for name, method := range c.policies {
+		info.StoragePolicies[name] = "NONE:" + name
+		info.DataSecurities[name] = method
+	}
+	return info
+}
+
+func (c *Container) check(n oio.ContainerName) error {
+	if n.NS() != c.ns {
+		return oio.ErrorNsNotManaged
+	}
+	return nil
+}
+
+// Returns a version greater than all the previous, close to the current
+// time in microseconds like the versions generated by the services. The
+// lock must be held.
+func (c *Container) nextVersion() uint64 {
+	v := uint64(time.Now().UnixNano() / 1000)
+	if v <= c.lastVersion {
+		v = c.lastVersion + 1
+	}
+	c.lastVersion = v
+	return v
+}
+
+// Saves the change in the journal, then applies it. The lock must be held.
+func (c *Container) commit(r record) error {
+	if c.journal != nil {
+		if err := c.journal.append(&r); err != nil {
+			return err
+		}
+	}
+	c.apply(&r)
+	return nil
+}
+
+// Applies a change. The lock must be held.
+func (c *Container) apply(r *record) {
+	if r.Op == opBucketCreate {
+		c.buckets[r.Cid] = &bucket{
+			contents:   make(map[string][]oio.Content),
+			properties: make(map[string]string),
+		}
+		return
+	}
+	b, ok := c.buckets[r.Cid]
+	if !ok {
+		return
+	}
+	switch r.Op {
+	case opBucketDelete:
+		delete(c.buckets, r.Cid)
+	case opBucketPropsSet:
+		for k, v := range r.Properties {
+			b.properties[k] = v
+		}
+	case opBucketPropsDel:
+		for _, k := range r.Keys {
+			delete(b.properties, k)
+		}
+	case opContentPut:
+		content := *r.Content
+		if content.Header.Version > c.lastVersion {
+			c.lastVersion = content.Header.Version
+		}
+		var versions []oio.Content
+		if !r.Replace {
+			versions = b.contents[content.Header.Name]
+			if idx := findVersion(versions, content.Header.Version); idx >= 0 && content.Header.Version != 0 {
+				versions = append(versions[:idx], versions[idx+1:]...)
+			}
+		}
+		versions = append(versions, content)
+		sort.Slice(versions, func(i, j int) bool {
+			return versions[i].Header.Version < versions[j].Header.Version
+		})
+		b.contents[content.Header.Name] = versions
+	case opContentDelete:
+		if r.Version == 0 {
+			delete(b.contents, r.Path)
+			return
+		}
+		versions := b.contents[r.Path]
+		for i, v := range versions {
+			if v.Header.Version == r.Version {
+				versions = append(versions[:i], versions[i+1:]...)
+				break
+			}
+		}
+		if len(versions) > 0 {
+			b.contents[r.Path] = versions
+		} else {
+			delete(b.contents, r.Path)
+		}
+	case opContentProps:
+		for i, v := range b.contents[r.Path] {
+			if v.Header.Version == r.Version {
+				b.contents[r.Path][i].Properties = sortedProperties(r.Properties)
+			}
+		}
+	}
+}
+
+// Returns the container, creating it if <auto> is set. The lock must be
+// held.
+func (c *Container) get(n oio.ContainerName, auto bool) (*bucket, error) {
+	cid := oio.ComputeContainerId(n)
+	if b, ok := c.buckets[cid]; ok {
+		return b, nil
+	}
+	if !auto {
+		return nil, oio.ErrorNotFound
+	}
+	if c.directory != nil {
+		if _, err := c.directory.ensure(n); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.commit(record{Op: opBucketCreate, Cid: cid}); err != nil {
+		return nil, err
+	}
+	return c.buckets[cid], nil
+}
+
+// Returns the index of the version in the slice, -1 if absent. With version
+// 0, the latest version is designated.
+func findVersion(versions []oio.Content, version uint64) int {
+	if version == 0 {
+		return len(versions) - 1
+	}
+	for i, v := range versions {
+		if v.Header.Version == version {
+			return i
+		}
+	}
+	return -1
+}
+
+func copyContent(in oio.Content) oio.Content {
+	out := in
+	out.Properties = append(make([]oio.Property, 0), in.Properties...)
+	out.System = append(make([]oio.Property, 0), in.System...)
+	out.Chunks = append(make([]oio.Chunk, 0), in.Chunks...)
+	return out
+}
+
+func (c *Container) CreateContainer(n oio.ContainerName, auto bool) (bool, error) {
+	if err := c.check(n); err != nil {
+		return false, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, err := c.get(n, false); err == nil {
+		return false, nil
+	}
+	if !auto && c.directory != nil && !c.directory.has(n) {
+		return false, oio.ErrorNotFound
+	}
+	if _, err := c.get(n, true); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Fails with ErrorConflict if the container still holds contents
+func (c *Container) DeleteContainer(n oio.ContainerName) (bool, error) {
+	if err := c.check(n); err != nil {
+		return false, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	b, err := c.get(n, false)
+	if err != nil {
+		return false, err
+	}
+	if len(b.contents) > 0 {
+		return false, ErrorConflict
+	}
+	if err = c.commit(record{Op: opBucketDelete, Cid: oio.ComputeContainerId(n)}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (c *Container) HasContainer(n oio.ContainerName) (bool, error) {
+	if err := c.check(n); err != nil {
+		return false, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, err := c.get(n, false)
+	return err == nil, nil
+}
+
+func (c *Container) GetContainerProperties(n oio.ContainerName) (map[string]string, error) {
+	if err := c.check(n); err != nil {
+		return nil, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	b, err := c.get(n, false)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string)
+	for k, v := range b.properties {
+		out[k] = v
+	}
+	return out, nil
+}
+
+func (c *Container) SetContainerProperties(n oio.ContainerName, props map[string]string) (bool, error) {
+	if err := c.check(n); err != nil {
+		return false, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, err := c.get(n, false); err != nil {
+		return false, err
+	}
+	r := record{Op: opBucketPropsSet, Cid: oio.ComputeContainerId(n), Properties: props}
+	if err := c.commit(r); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (c *Container) DeleteContainerProperties(n oio.ContainerName, keys []string) (bool, error) {
+	if err := c.check(n); err != nil {
+		return false, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, err := c.get(n, false); err != nil {
+		return false, err
+	}
+	r := record{Op: opBucketPropsDel, Cid: oio.ComputeContainerId(n), Keys: keys}
+	if err := c.commit(r); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (c *Container) ListContents(n oio.ContainerName) (oio.ContainerListing, error) {
+	return c.ListContentsWithParams(n, oio.ListParams{})
+}
+
+// Lists the contents sorted by path, then by version (the latest first),
+// with the same filters than the container services.
+func (c *Container) ListContentsWithParams(n oio.ContainerName, p oio.ListParams) (oio.ContainerListing, error) {
+	out := oio.ContainerListing{
+		Objects:    make([]oio.ContentHeader, 0),
+		Properties: make([]oio.Property, 0),
+		Prefixes:   make([]string, 0),
+	}
+	if err := c.check(n); err != nil {
+		return out, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	b, err := c.get(n, false)
+	if err != nil {
+		return out, err
+	}
+
+	// A marker on a common prefix skips all the contents under it
+	skipped := len(p.Delimiter) > 0 && strings.HasSuffix(p.Marker, p.Delimiter)
+	paths := make([]string, 0, len(b.contents))
+	for path := range b.contents {
+		if !strings.HasPrefix(path, p.Prefix) || path <= p.Marker {
+			continue
+		}
+		if skipped && strings.HasPrefix(path, p.Marker) {
+			continue
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	count := 0
+	full := func(marker string) bool {
+		if p.Max > 0 && count >= p.Max {
+			out.Truncated = true
+			out.NextMarker = marker
+			return true
+		}
+		return false
+	}
+	last := ""
+	for _, path := range paths {
+		if len(p.Delimiter) > 0 {
+			tail := path[len(p.Prefix):]
+			if idx := strings.Index(tail, p.Delimiter); idx >= 0 {
+				prefix := p.Prefix + tail[:idx+len(p.Delimiter)]
+				if prefix != last {
+					if full(last) {
+						return out, nil
+					}
+					out.Prefixes = append(out.Prefixes, prefix)
+					count++
+					last = prefix
+				}
+				continue
+			}
+		}
+
+		versions := b.contents[path]
+		if !p.Versions {
+			latest := versions[len(versions)-1]
+			if latest.Header.Deleted {
+				continue
+			}
+			versions = versions[len(versions)-1:]
+		}
+		if full(last) {
+			return out, nil
+		}
+		for i := len(versions) - 1; i >= 0; i-- {
+			out.Objects = append(out.Objects, versions[i].Header)
+		}
+		count++
+		last = path
+	}
+	return out, nil
+}
+
+// Returns the content with the version asked, or ErrorNotFound. A deletion
+// marker is not found. The lock must be held.
+func (c *Container) getContent(n oio.ObjectName) (oio.Content, error) {
+	content, err := c.findContent(n)
+	if err != nil {
+		return oio.Content{}, err
+	}
+	return copyContent(*content), nil
+}
+
+func (c *Container) GetContent(n oio.ObjectName) (oio.Content, error) {
+	if err := c.check(n); err != nil {
+		return oio.Content{}, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.getContent(n)
+}
+
+func (c *Container) StatContent(n oio.ObjectName) (oio.ContentHeader, error) {
+	if err := c.check(n); err != nil {
+		return oio.ContentHeader{}, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	content, err := c.getContent(n)
+	return content.Header, err
+}
+
+func (c *Container) HasContent(n oio.ObjectName) (bool, error) {
+	if err := c.check(n); err != nil {
+		return false, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, err := c.getContent(n)
+	if err == oio.ErrorNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (c *Container) GenerateContent(n oio.ObjectName, size uint64, auto bool) (oio.Content, error) {
+	return c.GenerateContentWithPolicy(n, size, "", auto)
+}
+
+// Prepares a content with new chunks on the rawx services: one chunk per
+// copy for a plain chunk method, k+m fragments for EC.
+func (c *Container) GenerateContentWithPolicy(n oio.ObjectName, size uint64, policy string, auto bool) (oio.Content, error) {
+	var content oio.Content
+	if err := c.check(n); err != nil {
+		return content, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, err := c.get(n, auto); err != nil {
+		return content, err
+	}
+	if len(policy) <= 0 {
+		policy = DefaultPolicy
+	}
+	raw, ok := c.policies[policy]
+	if !ok {
+		return content, oio.ErrorInvalidPolicy
+	}
+	cm, err := oio.ParseChunkMethod(raw)
+	if err != nil {
+		return content, err
+	}
+
+	content.Header = oio.ContentHeader{
+		Name:        n.Path(),
+		Id:          n.Id(),
+		Version:     n.Version(),
+		Size:        size,
+		CTime:       uint64(time.Now().Unix()),
+		Policy:      policy,
+		ChunkMethod: raw,
+		MimeType:    "application/octet-stream",
+	}
+	if len(content.Header.Id) <= 0 {
+		content.Header.Id = oio.GenerateContentId()
+	}
+	if content.Header.Version == 0 {
+		content.Header.Version = c.nextVersion()
+	}
+
+	count := 1
+	if size > 0 {
+		count = int((size + c.chunkSize - 1) / c.chunkSize)
+	}
+	content.Properties = make([]oio.Property, 0)
+	content.System = make([]oio.Property, 0)
+	content.Chunks = make([]oio.Chunk, 0, count*cm.Width())
+	for meta := 0; meta < count; meta++ {
+		hosts, err := c.place(meta, cm.Width())
+		if err != nil {
+			return oio.Content{}, err
+		}
+		for i := 0; i < cm.Width(); i++ {
+			pos := strconv.Itoa(meta)
+			if cm.IsEc() {
+				pos = pos + "." + strconv.Itoa(i)
+			}
+			cu := oio.ChunkUrl{Host: hosts[i], Id: oio.GenerateChunkId()}
+			content.Chunks = append(content.Chunks, oio.Chunk{
+				Url:      cu.String(),
+				Position: pos,
+				Size:     c.chunkSize,
+			})
+		}
+	}
+	return content, nil
+}
+
+// Returns the hosts of the <width> chunks of the metachunk, from the
+// selector if any, else from the rawx services in turn. The lock must be
+// held.
+func (c *Container) place(meta, width int) ([]string, error) {
+	if c.selector != nil {
+		hosts, err := c.selector(width)
+		if err != nil {
+			return nil, err
+		}
+		if len(hosts) < width {
+			return nil, ErrorNoService
+		}
+		return hosts, nil
+	}
+	if len(c.rawx) <= 0 {
+		return nil, ErrorNoService
+	}
+	hosts := make([]string, width)
+	for i := range hosts {
+		hosts[i] = c.rawx[(meta+i)%len(c.rawx)]
+	}
+	return hosts, nil
+}
+
+// Saves the content. Without versioning, the previous versions are
+// replaced. Saving twice the same version fails with ErrorConflict.
+func (c *Container) PutContent(n oio.ContainerName, content oio.Content, auto bool) error {
+	if err := c.check(n); err != nil {
+		return err
+	}
+	if len(content.Header.Name) <= 0 {
+		return oio.NameError{Component: "path", Problem: "empty"}
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	b, err := c.get(n, auto)
+	if err != nil {
+		return err
+	}
+	content = copyContent(content)
+	sort.Slice(content.Properties, func(i, j int) bool {
+		return content.Properties[i].Key < content.Properties[j].Key
+	})
+	if content.Header.Version == 0 {
+		content.Header.Version = c.nextVersion()
+	}
+	if findVersion(b.contents[content.Header.Name], content.Header.Version) >= 0 {
+		return ErrorConflict
+	}
+	r := record{Op: opContentPut, Cid: oio.ComputeContainerId(n), Content: &content, Replace: !c.versioning}
+	return c.commit(r)
+}
+
+// Without version, the latest version is deleted, or hidden by a deletion
+// marker when the versioning is enabled. With a version, only that version
+// is removed.
+func (c *Container) DeleteContent(n oio.ObjectName) (bool, error) {
+	if err := c.check(n); err != nil {
+		return false, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	b, err := c.get(n, false)
+	if err != nil {
+		return false, err
+	}
+	versions := b.contents[n.Path()]
+	idx := findVersion(versions, n.Version())
+	if idx < 0 {
+		return false, oio.ErrorNotFound
+	}
+
+	cid := oio.ComputeContainerId(n)
+	r := record{Op: opContentDelete, Cid: cid, Path: n.Path(), Version: n.Version()}
+	if n.Version() == 0 {
+		if versions[idx].Header.Deleted {
+			return false, oio.ErrorNotFound
+		}
+		if c.versioning {
+			marker := oio.Content{Header: oio.ContentHeader{
+				Name:    n.Path(),
+				Version: c.nextVersion(),
+				CTime:   uint64(time.Now().Unix()),
+				Deleted: true,
+			}}
+			r = record{Op: opContentPut, Cid: cid, Content: &marker}
+		}
+	}
+	if err = c.commit(r); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Returns the stored content with the version asked. The lock must be held.
+func (c *Container) findContent(n oio.ObjectName) (*oio.Content, error) {
+	b, err := c.get(n, false)
+	if err != nil {
+		return nil, err
+	}
+	versions := b.contents[n.Path()]
+	idx := findVersion(versions, n.Version())
+	if idx < 0 || versions[idx].Header.Deleted {
+		return nil, oio.ErrorNotFound
+	}
+	return &versions[idx], nil
+}
+
+func (c *Container) GetContentProperties(n oio.ObjectName) (map[string]string, error) {
+	if err := c.check(n); err != nil {
+		return nil, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	content, err := c.findContent(n)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string)
+	for _, p := range content.Properties {
+		out[p.Key] = p.Value
+	}
+	return out, nil
+}
+
+// Saves the new properties of the content, computed by <update> from the
+// current ones.
+func (c *Container) updateContentProperties(n oio.ObjectName, update func(map[string]string)) (bool, error) {
+	if err := c.check(n); err != nil {
+		return false, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	content, err := c.findContent(n)
+	if err != nil {
+		return false, err
+	}
+	props := make(map[string]string)
+	for _, p := range content.Properties {
+		props[p.Key] = p.Value
+	}
+	update(props)
+	r := record{Op: opContentProps, Cid: oio.ComputeContainerId(n), Path: n.Path(),
+		Version: content.Header.Version, Properties: props}
+	if err = c.commit(r); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (c *Container) SetContentProperties(n oio.ObjectName, props map[string]string) (bool, error) {
+	return c.updateContentProperties(n, func(current map[string]string) {
+		for k, v := range props {
+			current[k] = v
+		}
+	})
+}
+
+func (c *Container) DeleteContentProperties(n oio.ObjectName, keys []string) (bool, error) {
+	return c.updateContentProperties(n, func(current map[string]string) {
+		for _, k := range keys {
+			delete(current, k)
+		}
+	})
+}
+
+func sortedProperties(props map[string]string) []oio.Property {
+	out := make([]oio.Property, 0, len(props))
+	for k, v := range props {
+		out = append(out, oio.Property{Key: k, Value: v})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
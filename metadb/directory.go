@@ -0,0 +1,413 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2017-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+// Package metadb keeps the references, the containers and the contents of a
+// namespace on a single node, and serves them with the HTTP API of the
+// oio-proxy. The changes are written to a journal before they are applied,
+// so that a reply is only sent for a change that survives a crash.
+package metadb
+
+import (
+	"errors"
+	oio "github.com/jfsmig/oio-go/sdk"
+	"sort"
+	"sync"
+)
+
+// Returned when the operation conflicts with the current state, e.g. the
+// deletion of a container that still holds contents.
+var ErrorConflict = errors.New("Conflict")
+
+// Returned when too few services are available to place the chunks, or to
+// link a reference.
+var ErrorNoService = errors.New("Not enough services available")
+
+// Chooses the address of a service of the type, for a reference, or fails
+// with ErrorNoService.
+type ServiceAllocator func(srvtype string) (string, error)
+
+// Returns an allocator cycling over the addresses declared for each type
+func StaticServices(byType map[string][]string) ServiceAllocator {
+	var lock sync.Mutex
+	next := make(map[string]int)
+	return func(srvtype string) (string, error) {
+		addrs := byType[srvtype]
+		if len(addrs) <= 0 {
+			return "", ErrorNoService
+		}
+		lock.Lock()
+		i := next[srvtype]
+		next[srvtype] = i + 1
+		lock.Unlock()
+		return addrs[i%len(addrs)], nil
+	}
+}
+
+type user struct {
+	services   map[string][]oio.Service
+	properties map[string]string
+}
+
+// Directory is an oio.Directory for a single namespace. The services linked
+// to the references are chosen by its ServiceAllocator, there are none
+// without allocator.
+type Directory struct {
+	ns       string
+	allocate ServiceAllocator
+	journal  *Journal
+
+	lock  sync.Mutex
+	users map[string]*user
+}
+
+// Builds an empty directory for the namespace. The calls for any other
+// namespace fail with oio.ErrorNsNotManaged.
+func MakeDirectory(ns string) *Directory {
+	return &Directory{ns: ns, users: make(map[string]*user)}
+}
+
+// Sets how the services are chosen when they are linked to a reference
+func (d *Directory) SetAllocator(a ServiceAllocator) {
+	d.lock.Lock()
+	d.allocate = a
+	d.lock.Unlock()
+}
+
+func userKey(n oio.UserName) string {
+	return n.Account() + "\x00" + n.User()
+}
+
+func (d *Directory) check(n oio.UserName) error {
+	if n.NS() != d.ns {
+		return oio.ErrorNsNotManaged
+	}
+	return nil
+}
+
+// Returns the user or ErrorNotFound. The lock must be held.
+func (d *Directory) get(n oio.UserName) (*user, error) {
+	u, ok := d.users[userKey(n)]
+	if !ok {
+		return nil, oio.ErrorNotFound
+	}
+	return u, nil
+}
+
+// Saves the change in the journal, then applies it. The lock must be held.
+func (d *Directory) commit(r record) error {
+	if d.journal != nil {
+		if err := d.journal.append(&r); err != nil {
+			return err
+		}
+	}
+	d.apply(&r)
+	return nil
+}
+
+// Applies a change. The lock must be held.
+func (d *Directory) apply(r *record) {
+	if r.Op == opUserCreate {
+		d.users[r.User] = &user{
+			services:   make(map[string][]oio.Service),
+			properties: make(map[string]string),
+		}
+		return
+	}
+	u, ok := d.users[r.User]
+	if !ok {
+		return
+	}
+	switch r.Op {
+	case opUserDelete:
+		delete(d.users, r.User)
+	case opUserServices:
+		if len(r.Services) > 0 {
+			u.services[r.Type] = r.Services
+		} else {
+			delete(u.services, r.Type)
+		}
+	case opUserForce:
+		byType := make(map[string][]oio.Service)
+		for _, s := range r.Services {
+			byType[s.Type] = append(byType[s.Type], s)
+		}
+		for t, tab := range byType {
+			u.services[t] = tab
+		}
+	case opUserPropsSet:
+		for k, v := range r.Properties {
+			u.properties[k] = v
+		}
+	case opUserPropsDel:
+		for _, k := range r.Keys {
+			delete(u.properties, k)
+		}
+	}
+}
+
+// Creates the user if missing, and tells if it was created. Used by the
+// Container on autocreation.
+func (d *Directory) ensure(n oio.UserName) (bool, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if _, err := d.get(n); err == nil {
+		return false, nil
+	}
+	if err := d.commit(record{Op: opUserCreate, User: userKey(n)}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (d *Directory) has(n oio.UserName) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	_, err := d.get(n)
+	return err == nil
+}
+
+// Returns a service of the given type, chosen by the allocator. The lock
+// must be held.
+func (d *Directory) makeService(srvtype string, seq uint64) (oio.Service, error) {
+	if d.allocate == nil {
+		return oio.Service{}, ErrorNoService
+	}
+	addr, err := d.allocate(srvtype)
+	if err != nil {
+		return oio.Service{}, err
+	}
+	return oio.Service{Seq: seq, Type: srvtype, Url: addr}, nil
+}
+
+func copyServices(tab []oio.Service) []oio.Service {
+	out := make([]oio.Service, len(tab))
+	copy(out, tab)
+	return out
+}
+
+func (d *Directory) HasUser(n oio.UserName) (bool, error) {
+	if err := d.check(n); err != nil {
+		return false, err
+	}
+	return d.has(n), nil
+}
+
+func (d *Directory) CreateUser(n oio.UserName) (bool, error) {
+	if err := d.check(n); err != nil {
+		return false, err
+	}
+	return d.ensure(n)
+}
+
+// Fails with ErrorConflict if the user is still linked to services or still
+// carries properties.
+func (d *Directory) DeleteUser(n oio.UserName) (bool, error) {
+	if err := d.check(n); err != nil {
+		return false, err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	u, err := d.get(n)
+	if err != nil {
+		return false, err
+	}
+	if len(u.services) > 0 || len(u.properties) > 0 {
+		return false, ErrorConflict
+	}
+	if err = d.commit(record{Op: opUserDelete, User: userKey(n)}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (d *Directory) DumpUser(n oio.UserName) (oio.RefDump, error) {
+	var dump oio.RefDump
+	if err := d.check(n); err != nil {
+		return dump, err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	u, err := d.get(n)
+	if err != nil {
+		return dump, err
+	}
+	dump.Directory = make([]oio.Service, 0)
+	dump.Services = make([]oio.Service, 0)
+	types := make([]string, 0, len(u.services))
+	for t := range u.services {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		dump.Services = append(dump.Services, u.services[t]...)
+	}
+	dump.Properties = make([]oio.Property, 0, len(u.properties))
+	for k, v := range u.properties {
+		dump.Properties = append(dump.Properties, oio.Property{Key: k, Value: v})
+	}
+	sort.Slice(dump.Properties, func(i, j int) bool {
+		return dump.Properties[i].Key < dump.Properties[j].Key
+	})
+	return dump, nil
+}
+
+// Binds a service of the type, if none is already bound
+func (d *Directory) LinkServices(n oio.UserName, srvtype string) ([]oio.Service, error) {
+	if err := d.check(n); err != nil {
+		return nil, err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	u, err := d.get(n)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := u.services[srvtype]; !ok {
+		s, err := d.makeService(srvtype, 1)
+		if err != nil {
+			return nil, err
+		}
+		r := record{Op: opUserServices, User: userKey(n), Type: srvtype, Services: []oio.Service{s}}
+		if err = d.commit(r); err != nil {
+			return nil, err
+		}
+	}
+	return copyServices(u.services[srvtype]), nil
+}
+
+// Replaces the services of the type with a new one, with a new sequence
+// number.
+func (d *Directory) RenewServices(n oio.UserName, srvtype string) ([]oio.Service, error) {
+	if err := d.check(n); err != nil {
+		return nil, err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	u, err := d.get(n)
+	if err != nil {
+		return nil, err
+	}
+	var seq uint64 = 1
+	for _, s := range u.services[srvtype] {
+		if s.Seq >= seq {
+			seq = s.Seq + 1
+		}
+	}
+	s, err := d.makeService(srvtype, seq)
+	if err != nil {
+		return nil, err
+	}
+	r := record{Op: opUserServices, User: userKey(n), Type: srvtype, Services: []oio.Service{s}}
+	if err = d.commit(r); err != nil {
+		return nil, err
+	}
+	return copyServices(u.services[srvtype]), nil
+}
+
+func (d *Directory) ForceServices(n oio.UserName, srv []oio.Service) ([]oio.Service, error) {
+	if err := d.check(n); err != nil {
+		return nil, err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if _, err := d.get(n); err != nil {
+		return nil, err
+	}
+	r := record{Op: opUserForce, User: userKey(n), Services: copyServices(srv)}
+	if err := d.commit(r); err != nil {
+		return nil, err
+	}
+	return copyServices(srv), nil
+}
+
+func (d *Directory) ListServices(n oio.UserName, srvtype string) ([]oio.Service, error) {
+	if err := d.check(n); err != nil {
+		return nil, err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	u, err := d.get(n)
+	if err != nil {
+		return nil, err
+	}
+	return copyServices(u.services[srvtype]), nil
+}
+
+func (d *Directory) UnlinkServices(n oio.UserName, srvtype string) (bool, error) {
+	if err := d.check(n); err != nil {
+		return false, err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	u, err := d.get(n)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := u.services[srvtype]; !ok {
+		return false, nil
+	}
+	if err = d.commit(record{Op: opUserServices, User: userKey(n), Type: srvtype}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (d *Directory) GetAllProperties(n oio.UserName) (map[string]string, error) {
+	if err := d.check(n); err != nil {
+		return nil, err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	u, err := d.get(n)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string)
+	for k, v := range u.properties {
+		out[k] = v
+	}
+	return out, nil
+}
+
+func (d *Directory) SetProperties(n oio.UserName, props map[string]string) (bool, error) {
+	if err := d.check(n); err != nil {
+		return false, err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if _, err := d.get(n); err != nil {
+		return false, err
+	}
+	if err := d.commit(record{Op: opUserPropsSet, User: userKey(n), Properties: props}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (d *Directory) DeleteProperties(n oio.UserName, keys []string) (bool, error) {
+	if err := d.check(n); err != nil {
+		return false, err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if _, err := d.get(n); err != nil {
+		return false, err
+	}
+	if err := d.commit(record{Op: opUserPropsDel, User: userKey(n), Keys: keys}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,353 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2017-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package metadb
+
+import (
+	"bufio"
+	"encoding/json"
+	oio "github.com/jfsmig/oio-go/sdk"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// The kinds of changes saved in the journal
+const (
+	opUserCreate     = "user.create"
+	opUserDelete     = "user.delete"
+	opUserServices   = "user.services"
+	opUserForce      = "user.force"
+	opUserPropsSet   = "user.props.set"
+	opUserPropsDel   = "user.props.del"
+	opBucketCreate   = "bucket.create"
+	opBucketDelete   = "bucket.delete"
+	opBucketPropsSet = "bucket.props.set"
+	opBucketPropsDel = "bucket.props.del"
+	opContentPut     = "content.put"
+	opContentDelete  = "content.delete"
+	opContentProps   = "content.props"
+)
+
+// A change of the directory or of the containers, as saved in the journal.
+// It carries the resulting values, so that applying it twice is harmless.
+type record struct {
+	Seq        uint64            `json:"seq"`
+	Op         string            `json:"op"`
+	User       string            `json:"user,omitempty"`
+	Cid        oio.ContainerId   `json:"cid,omitempty"`
+	Type       string            `json:"type,omitempty"`
+	Services   []oio.Service     `json:"services,omitempty"`
+	Properties map[string]string `json:"properties,omitempty"`
+	Keys       []string          `json:"keys,omitempty"`
+	Path       string            `json:"path,omitempty"`
+	Version    uint64            `json:"version,omitempty"`
+	Content    *oio.Content      `json:"content,omitempty"`
+	Replace    bool              `json:"replace,omitempty"`
+}
+
+type userState struct {
+	Services   map[string][]oio.Service `json:"services"`
+	Properties map[string]string        `json:"properties"`
+}
+
+type bucketState struct {
+	Contents   map[string][]oio.Content `json:"contents"`
+	Properties map[string]string        `json:"properties,omitempty"`
+}
+
+// The content of a Directory and a Container, as saved on disk, with the
+// sequence number of the last change of the journal it includes.
+type snapshot struct {
+	Users       map[string]userState            `json:"users"`
+	Buckets     map[oio.ContainerId]bucketState `json:"buckets"`
+	LastVersion uint64                          `json:"last_version"`
+	Seq         uint64                          `json:"seq,omitempty"`
+}
+
+// Journal saves the changes of a Directory and a Container in a log file
+// next to the database file, synced before each change is applied. The log
+// is folded into the database file by Compact().
+type Journal struct {
+	path string
+	d    *Directory
+	c    *Container
+
+	lock    sync.Mutex
+	log     *os.File
+	size    int64
+	seq     uint64
+	pending int
+}
+
+// Loads the database file at <path> and replays the log of the changes
+// after it, then saves the changes of <d> and <c>. Both are optional. A
+// missing file leaves them empty.
+func OpenJournal(path string, d *Directory, c *Container) (*Journal, error) {
+	j := &Journal{path: path, d: d, c: c}
+	seq, err := LoadSnapshot(path, d, c)
+	if err != nil {
+		return nil, err
+	}
+	j.seq = seq
+	if err = j.replay(); err != nil {
+		return nil, err
+	}
+	if err = j.Compact(); err != nil {
+		return nil, err
+	}
+	if d != nil {
+		d.journal = j
+	}
+	if c != nil {
+		c.journal = j
+	}
+	return j, nil
+}
+
+func (j *Journal) logPath() string {
+	return j.path + ".log"
+}
+
+// Applies the changes of the log newer than the database file. A record cut
+// by a crash, without its newline, is ignored.
+func (j *Journal) replay() error {
+	f, err := os.Open(j.logPath())
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	rd := bufio.NewReader(f)
+	for {
+		line, err := rd.ReadBytes('\n')
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		var r record
+		if err = json.Unmarshal(line, &r); err != nil {
+			return err
+		}
+		if r.Seq <= j.seq {
+			continue
+		}
+		j.seq = r.Seq
+		if strings.HasPrefix(r.Op, "user.") {
+			if j.d != nil {
+				j.d.apply(&r)
+			}
+		} else if j.c != nil {
+			j.c.apply(&r)
+		}
+	}
+}
+
+// Appends the change to the log and syncs it
+func (j *Journal) append(r *record) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	r.Seq = j.seq + 1
+	encoded, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err = j.log.Write(append(encoded, '\n')); err == nil {
+		err = j.log.Sync()
+	}
+	if err != nil {
+		// Drop what may have been written, so that the next record is not
+		// glued to a partial one.
+		j.log.Truncate(j.size)
+		return err
+	}
+	j.size += int64(len(encoded) + 1)
+	j.seq = r.Seq
+	j.pending++
+	return nil
+}
+
+// Tells how many changes were saved in the log since the last Compact()
+func (j *Journal) Pending() int {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j.pending
+}
+
+// Rewrites the database file with the current state and empties the log
+func (j *Journal) Compact() error {
+	// Same locking order than the changes
+	if j.c != nil {
+		j.c.lock.Lock()
+		defer j.c.lock.Unlock()
+	}
+	if j.d != nil {
+		j.d.lock.Lock()
+		defer j.d.lock.Unlock()
+	}
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if err := writeSnapshot(j.path, j.d, j.c, j.seq); err != nil {
+		return err
+	}
+	log, err := os.OpenFile(j.logPath(), os.O_CREATE|os.O_TRUNC|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if j.log != nil {
+		j.log.Close()
+	}
+	j.log = log
+	j.size = 0
+	j.pending = 0
+	return nil
+}
+
+// Closes the log. The changes are refused afterwards.
+func (j *Journal) Close() error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j.log.Close()
+}
+
+// Saves the users of <d> and the containers of <c> in the file at <path>,
+// replaced atomically. Both are optional.
+func SaveSnapshot(path string, d *Directory, c *Container) error {
+	// Same locking order than the Container autocreating users
+	if c != nil {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+	}
+	if d != nil {
+		d.lock.Lock()
+		defer d.lock.Unlock()
+	}
+	return writeSnapshot(path, d, c, 0)
+}
+
+// The locks must be held
+func writeSnapshot(path string, d *Directory, c *Container, seq uint64) error {
+	st := snapshot{
+		Users:   make(map[string]userState),
+		Buckets: make(map[oio.ContainerId]bucketState),
+		Seq:     seq,
+	}
+	if c != nil {
+		for cid, b := range c.buckets {
+			st.Buckets[cid] = bucketState{Contents: b.contents, Properties: b.properties}
+		}
+		st.LastVersion = c.lastVersion
+	}
+	if d != nil {
+		for k, u := range d.users {
+			st.Users[k] = userState{Services: u.services, Properties: u.properties}
+		}
+	}
+
+	encoded, err := json.Marshal(&st)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(encoded); err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// Makes the renaming of a file of the directory durable
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	err = dir.Sync()
+	if cerr := dir.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Replaces the users of <d> and the containers of <c> with those saved in
+// the file at <path>, and returns the sequence number of the last change of
+// the journal the file includes. A missing file leaves them empty.
+func LoadSnapshot(path string, d *Directory, c *Container) (uint64, error) {
+	var st snapshot
+	encoded, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	if err = json.Unmarshal(encoded, &st); err != nil {
+		return 0, err
+	}
+
+	if d != nil {
+		d.lock.Lock()
+		d.users = make(map[string]*user)
+		for k, u := range st.Users {
+			fu := &user{services: u.Services, properties: u.Properties}
+			if fu.services == nil {
+				fu.services = make(map[string][]oio.Service)
+			}
+			if fu.properties == nil {
+				fu.properties = make(map[string]string)
+			}
+			d.users[k] = fu
+		}
+		d.lock.Unlock()
+	}
+	if c != nil {
+		c.lock.Lock()
+		c.buckets = make(map[oio.ContainerId]*bucket)
+		for cid, b := range st.Buckets {
+			fb := &bucket{contents: b.Contents, properties: b.Properties}
+			if fb.contents == nil {
+				fb.contents = make(map[string][]oio.Content)
+			}
+			if fb.properties == nil {
+				fb.properties = make(map[string]string)
+			}
+			c.buckets[cid] = fb
+		}
+		if st.LastVersion > c.lastVersion {
+			c.lastVersion = st.LastVersion
+		}
+		c.lock.Unlock()
+	}
+	return st.Seq, nil
+}
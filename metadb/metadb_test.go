@@ -0,0 +1,210 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2017-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package metadb
+
+import (
+	oio "github.com/jfsmig/oio-go/sdk"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func openStore(t *testing.T, path string) (*Directory, *Container, *Journal) {
+	d := MakeDirectory("NS")
+	d.SetAllocator(StaticServices(map[string][]string{"meta2": {"127.0.0.1:6000"}}))
+	c := MakeContainer("NS", d)
+	c.SetVersioning(true)
+	c.SetRawx([]string{"127.0.0.1:6010", "127.0.0.1:6011"})
+	j, err := OpenJournal(path, d, c)
+	if err != nil {
+		t.Fatal("Journal not opened: ", err)
+	}
+	return d, c, j
+}
+
+func tempDb(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "metadb-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "meta.json"), func() { os.RemoveAll(dir) }
+}
+
+func TestJournal_Replay(t *testing.T) {
+	path, clean := tempDb(t)
+	defer clean()
+	d, c, j := openStore(t, path)
+
+	n := oio.FlatName{N: "NS", A: "ACCT", U: "JFS", P: "obj"}
+	if _, err := d.CreateUser(&n); err != nil {
+		t.Fatal("CreateUser failed: ", err)
+	}
+	if srv, err := d.LinkServices(&n, "meta2"); err != nil || srv[0].Url != "127.0.0.1:6000" {
+		t.Fatal("LinkServices failed: ", srv, err)
+	}
+	content, err := c.GenerateContent(&n, 0, true)
+	if err != nil {
+		t.Fatal("GenerateContent failed: ", err)
+	}
+	if err = c.PutContent(&n, content, true); err != nil {
+		t.Fatal("PutContent failed: ", err)
+	}
+	c.SetContentProperties(&n, map[string]string{"k": "v"})
+	c.SetContainerProperties(&n, map[string]string{"a": "1"})
+	other := n
+	other.P = "gone"
+	content.Header.Name, content.Header.Version = other.P, 0
+	c.PutContent(&other, content, true)
+	if _, err = c.DeleteContent(&other); err != nil {
+		t.Fatal("DeleteContent failed: ", err)
+	}
+	if j.Pending() <= 0 {
+		t.Fatal("No change in the journal")
+	}
+	j.Close()
+
+	// The changes come back from the log alone, a partial record is ignored
+	f, _ := os.OpenFile(path+".log", os.O_WRONLY|os.O_APPEND, 0644)
+	f.Write([]byte(`{"seq":1000,"op":"user.del`))
+	f.Close()
+	d2, c2, j2 := openStore(t, path)
+	defer j2.Close()
+	if srv, _ := d2.ListServices(&n, "meta2"); len(srv) != 1 {
+		t.Fatal("Services not restored")
+	}
+	if props, err := c2.GetContentProperties(&n); err != nil || props["k"] != "v" {
+		t.Fatal("Content not restored: ", props, err)
+	}
+	if props, _ := c2.GetContainerProperties(&n); props["a"] != "1" {
+		t.Fatal("Container properties not restored: ", props)
+	}
+	l, _ := c2.ListContentsWithParams(&n, oio.ListParams{Versions: true})
+	if len(l.Objects) != 3 || !l.Objects[0].Deleted {
+		t.Fatal("Versions not restored: ", l.Objects)
+	}
+	if j2.Pending() != 0 {
+		t.Fatal("Journal not compacted at the opening")
+	}
+
+	// After a compaction, the database file alone holds the state
+	os.Remove(path + ".log")
+	_, c3, j3 := openStore(t, path)
+	defer j3.Close()
+	if ok, _ := c3.HasContent(&n); !ok {
+		t.Fatal("Content not in the database file")
+	}
+}
+
+func TestJournal_CorruptedLog(t *testing.T) {
+	path, clean := tempDb(t)
+	defer clean()
+	ioutil.WriteFile(path+".log", []byte("{nope\n"), 0644)
+	if _, err := OpenJournal(path, MakeDirectory("NS"), nil); err == nil {
+		t.Fatal("Corrupted journal accepted")
+	}
+}
+
+func TestServer_FailedSave(t *testing.T) {
+	path, clean := tempDb(t)
+	defer clean()
+	d, c, j := openStore(t, path)
+	srv := httptest.NewServer(MakeServer("NS", d, c))
+	defer srv.Close()
+
+	create := func() int {
+		rep, err := http.Post(srv.URL+"/v3.0/NS/container/create?acct=ACCT&ref=JFS", "application/json", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rep.Body.Close()
+		return rep.StatusCode
+	}
+	n := oio.FlatName{N: "NS", A: "ACCT", U: "JFS"}
+	d.CreateUser(&n)
+
+	// The change is refused when it cannot be saved
+	j.Close()
+	if code := create(); code != http.StatusInternalServerError {
+		t.Fatal("Unexpected status: ", code)
+	}
+	if ok, _ := c.HasContainer(&n); ok {
+		t.Fatal("Unsaved container created")
+	}
+}
+
+func TestStore_NoMadeUpServices(t *testing.T) {
+	d := MakeDirectory("NS")
+	c := MakeContainer("NS", d)
+	n := oio.FlatName{N: "NS", A: "ACCT", U: "JFS", P: "obj"}
+	d.CreateUser(&n)
+	if _, err := d.LinkServices(&n, "meta2"); err != ErrorNoService {
+		t.Fatal("Service linked without allocator: ", err)
+	}
+	d.SetAllocator(StaticServices(map[string][]string{"meta2": {"127.0.0.1:6000"}}))
+	if _, err := d.LinkServices(&n, "echo"); err != ErrorNoService {
+		t.Fatal("Undeclared service linked: ", err)
+	}
+	if _, err := c.GenerateContent(&n, 1, true); err != ErrorNoService {
+		t.Fatal("Chunks placed without rawx: ", err)
+	}
+}
+
+// The contents prepared and never created are forgotten after a while
+func TestServer_PendingExpires(t *testing.T) {
+	d := MakeDirectory("NS")
+	d.SetAllocator(StaticServices(map[string][]string{"meta2": {"127.0.0.1:6000"}}))
+	c := MakeContainer("NS", d)
+	c.SetRawx([]string{"127.0.0.1:6010"})
+	p := MakeServer("NS", d, c)
+	srv := httptest.NewServer(p)
+	defer srv.Close()
+
+	n := oio.FlatName{N: "NS", A: "ACCT", U: "JFS"}
+	d.CreateUser(&n)
+	c.CreateContainer(&n, false)
+	prepare := func() {
+		rep, err := http.Post(srv.URL+"/v3.0/NS/content/prepare?acct=ACCT&ref=JFS&path=obj",
+			"application/json", strings.NewReader(`{"size":"1"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rep.Body.Close()
+		if rep.StatusCode != http.StatusOK {
+			t.Fatal("Unexpected status: ", rep.StatusCode)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		prepare()
+	}
+	if len(p.pending) != 3 {
+		t.Fatal("Prepared contents not remembered: ", len(p.pending))
+	}
+	for k, v := range p.pending {
+		v.deadline = time.Now().Add(-time.Second)
+		p.pending[k] = v
+	}
+	prepare()
+	if len(p.pending) != 1 {
+		t.Fatal("Expired contents not purged: ", len(p.pending))
+	}
+}
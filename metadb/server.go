@@ -0,0 +1,558 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2017-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package metadb
+
+import (
+	"encoding/json"
+	"errors"
+	oio "github.com/jfsmig/oio-go/sdk"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const contentMetaPrefix = "X-oio-content-meta-"
+
+// How long a prepared content is remembered, waiting for its creation
+const pendingTtl = time.Hour
+
+var errBadRequest = errors.New("Bad request")
+
+// The containers served by a Server, with the description of the namespace
+// and the rawx services the chunks are placed on.
+type ContainerBackend interface {
+	oio.Container
+	NamespaceInfo() oio.NamespaceInfo
+	Rawx() []string
+}
+
+// Server serves the subset of the HTTP API of the oio-proxy used by the SDK
+// (the reference, container and content endpoints, and the namespace info),
+// with the given directory and containers as the storage.
+type Server struct {
+	ns        string
+	directory oio.Directory
+	container ContainerBackend
+
+	// Called after each request that modified the storage, once replied
+	OnChange func()
+
+	// Lists the rawx services with their score, for the conscience. By
+	// default, the services of the ContainerBackend with a perfect score.
+	Rawx func() []oio.ServiceInfo
+
+	lock sync.Mutex
+	// The contents prepared and not created yet, to recall the fields the
+	// SDK doesn't send again at the creation.
+	pending map[string]pendingContent
+}
+
+// A prepared content, forgotten after its deadline if never created, e.g.
+// when the upload failed or was abandoned.
+type pendingContent struct {
+	header   oio.ContentHeader
+	deadline time.Time
+}
+
+// Builds a server of the namespace with the given storage
+func MakeServer(ns string, d oio.Directory, c ContainerBackend) *Server {
+	return &Server{
+		ns:        ns,
+		directory: d,
+		container: c,
+		pending:   make(map[string]pendingContent),
+	}
+}
+
+type proxyRequest struct {
+	rep  http.ResponseWriter
+	req  *http.Request
+	name oio.FlatName
+}
+
+func statusOf(err error) int {
+	switch err {
+	case oio.ErrorNotFound:
+		return http.StatusNotFound
+	case ErrorConflict:
+		return http.StatusConflict
+	case ErrorNoService:
+		return http.StatusServiceUnavailable
+	case oio.ErrorNsNotManaged, oio.ErrorInvalidPolicy, errBadRequest:
+		return http.StatusBadRequest
+	}
+	if _, ok := err.(oio.NameError); ok {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func (pr *proxyRequest) replyError(err error) {
+	code := statusOf(err)
+	pr.replyJson(code, map[string]interface{}{"status": code, "message": err.Error()})
+}
+
+func (pr *proxyRequest) replyJson(code int, v interface{}) {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		code, encoded = http.StatusInternalServerError, []byte("{}")
+	}
+	pr.rep.Header().Set("Content-Type", "application/json")
+	pr.rep.WriteHeader(code)
+	pr.rep.Write(encoded)
+}
+
+func (pr *proxyRequest) replyCode(code int) {
+	pr.rep.WriteHeader(code)
+}
+
+// Replies (true,nil) with 201, (false,nil) with 202, as the oio-proxy does
+// for the creations.
+func (pr *proxyRequest) replyCreated(created bool, err error) {
+	if err != nil {
+		pr.replyError(err)
+	} else if created {
+		pr.replyCode(http.StatusCreated)
+	} else {
+		pr.replyCode(http.StatusAccepted)
+	}
+}
+
+func (pr *proxyRequest) replyDone(_ bool, err error) {
+	if err != nil {
+		pr.replyError(err)
+	} else {
+		pr.replyCode(http.StatusNoContent)
+	}
+}
+
+func (pr *proxyRequest) autocreate() bool {
+	return strings.Contains(pr.req.Header.Get("X-oio-action-mode"), "autocreate")
+}
+
+func (pr *proxyRequest) decode(v interface{}) error {
+	if err := json.NewDecoder(pr.req.Body).Decode(v); err != nil {
+		return errBadRequest
+	}
+	return nil
+}
+
+func writeContentHeader(h http.Header, hdr oio.ContentHeader) {
+	h.Set(contentMetaPrefix+"id", hdr.Id)
+	h.Set(contentMetaPrefix+"name", hdr.Name)
+	h.Set(contentMetaPrefix+"version", strconv.FormatUint(hdr.Version, 10))
+	h.Set(contentMetaPrefix+"length", strconv.FormatUint(hdr.Size, 10))
+	h.Set(contentMetaPrefix+"ctime", strconv.FormatUint(hdr.CTime, 10))
+	h.Set(contentMetaPrefix+"hash", hdr.Hash)
+	h.Set(contentMetaPrefix+"policy", hdr.Policy)
+	h.Set(contentMetaPrefix+"chunk-method", hdr.ChunkMethod)
+	h.Set(contentMetaPrefix+"mime-type", hdr.MimeType)
+	h.Set(contentMetaPrefix+"deleted", strconv.FormatBool(hdr.Deleted))
+}
+
+// Overrides the fields of <hdr> with the headers present in the request
+func readContentHeader(h http.Header, hdr *oio.ContentHeader) error {
+	str := func(k string, out *string) {
+		if v := h.Get(contentMetaPrefix + k); len(v) > 0 {
+			*out = v
+		}
+	}
+	var err error
+	num := func(k string, out *uint64) {
+		if v := h.Get(contentMetaPrefix + k); len(v) > 0 && err == nil {
+			if *out, err = strconv.ParseUint(v, 10, 64); err != nil {
+				err = errBadRequest
+			}
+		}
+	}
+	str("id", &hdr.Id)
+	str("hash", &hdr.Hash)
+	str("policy", &hdr.Policy)
+	str("chunk-method", &hdr.ChunkMethod)
+	str("mime-type", &hdr.MimeType)
+	num("version", &hdr.Version)
+	num("length", &hdr.Size)
+	num("ctime", &hdr.CTime)
+	return err
+}
+
+func (p *Server) ServeHTTP(rep http.ResponseWriter, req *http.Request) {
+	pr := &proxyRequest{rep: rep, req: req}
+	tokens := strings.Split(strings.TrimPrefix(req.URL.Path, "/v3.0/"), "/")
+	if len(tokens) != 3 || !strings.HasPrefix(req.URL.Path, "/v3.0/") {
+		pr.replyError(errBadRequest)
+		return
+	}
+	if tokens[0] != p.ns {
+		pr.replyError(oio.ErrorNsNotManaged)
+		return
+	}
+	kind, action := tokens[1], tokens[2]
+
+	if kind == "conscience" {
+		p.serveConscience(pr, action)
+		return
+	}
+
+	q := req.URL.Query()
+	pr.name = oio.FlatName{N: p.ns, A: q.Get("acct"), U: q.Get("ref"), P: q.Get("path")}
+	if v := q.Get("version"); len(v) > 0 {
+		var err error
+		if pr.name.V, err = strconv.ParseUint(v, 10, 64); err != nil {
+			pr.replyError(errBadRequest)
+			return
+		}
+	}
+	if err := oio.ValidateContainerName(&pr.name); err != nil {
+		pr.replyError(err)
+		return
+	}
+
+	changed := false
+	switch kind {
+	case "reference":
+		changed = p.serveReference(pr, action, q.Get("type"))
+	case "container":
+		changed = p.serveContainer(pr, action)
+	case "content":
+		if len(pr.name.P) <= 0 {
+			pr.replyError(oio.NameError{Component: "path", Problem: "empty"})
+			return
+		}
+		changed = p.serveContent(pr, action)
+	default:
+		pr.replyError(errBadRequest)
+	}
+	if changed && p.OnChange != nil {
+		p.OnChange()
+	}
+}
+
+// Returns true if the request is a modification
+func (p *Server) serveReference(pr *proxyRequest, action, srvtype string) bool {
+	n := &pr.name
+	switch action {
+	case "show":
+		if len(srvtype) > 0 {
+			srv, err := p.directory.ListServices(n, srvtype)
+			if err != nil {
+				pr.replyError(err)
+			} else {
+				pr.replyJson(http.StatusOK, srv)
+			}
+		} else {
+			dump, err := p.directory.DumpUser(n)
+			if err != nil {
+				pr.replyError(err)
+			} else {
+				pr.replyJson(http.StatusOK, dump)
+			}
+		}
+		return false
+	case "create":
+		pr.replyCreated(p.directory.CreateUser(n))
+	case "destroy":
+		pr.replyDone(p.directory.DeleteUser(n))
+	case "link", "renew", "force":
+		var srv []oio.Service
+		var err error
+		switch action {
+		case "link":
+			srv, err = p.directory.LinkServices(n, srvtype)
+		case "renew":
+			srv, err = p.directory.RenewServices(n, srvtype)
+		default:
+			if err = pr.decode(&srv); err == nil {
+				srv, err = p.directory.ForceServices(n, srv)
+			}
+		}
+		if err != nil {
+			pr.replyError(err)
+		} else {
+			pr.replyJson(http.StatusOK, srv)
+		}
+	case "unlink":
+		pr.replyDone(p.directory.UnlinkServices(n, srvtype))
+	case "get_properties":
+		props, err := p.directory.GetAllProperties(n)
+		if err != nil {
+			pr.replyError(err)
+		} else {
+			pr.replyJson(http.StatusOK, props)
+		}
+		return false
+	case "set_properties":
+		props := make(map[string]string)
+		if err := pr.decode(&props); err != nil {
+			pr.replyError(err)
+			return false
+		}
+		pr.replyDone(p.directory.SetProperties(n, props))
+	case "del_properties":
+		keys := make([]string, 0)
+		if err := pr.decode(&keys); err != nil {
+			pr.replyError(err)
+			return false
+		}
+		pr.replyDone(p.directory.DeleteProperties(n, keys))
+	default:
+		pr.replyError(errBadRequest)
+		return false
+	}
+	return true
+}
+
+func (p *Server) serveContainer(pr *proxyRequest, action string) bool {
+	n := &pr.name
+	switch action {
+	case "create":
+		pr.replyCreated(p.container.CreateContainer(n, pr.autocreate()))
+		return true
+	case "destroy":
+		pr.replyDone(p.container.DeleteContainer(n))
+		return true
+	case "show":
+		ok, err := p.container.HasContainer(n)
+		if err == nil && !ok {
+			err = oio.ErrorNotFound
+		}
+		if err != nil {
+			pr.replyError(err)
+		} else {
+			pr.replyJson(http.StatusOK, map[string]string{})
+		}
+	case "list":
+		q := pr.req.URL.Query()
+		params := oio.ListParams{
+			Prefix:    q.Get("prefix"),
+			Marker:    q.Get("marker"),
+			Delimiter: q.Get("delimiter"),
+		}
+		params.Max, _ = strconv.Atoi(q.Get("max"))
+		params.Versions, _ = strconv.ParseBool(q.Get("all"))
+		l, err := p.container.ListContentsWithParams(n, params)
+		if err != nil {
+			pr.replyError(err)
+			return false
+		}
+		pr.rep.Header().Set("X-oio-list-truncated", strconv.FormatBool(l.Truncated))
+		if l.Truncated {
+			pr.rep.Header().Set("X-oio-list-marker", l.NextMarker)
+		}
+		pr.replyJson(http.StatusOK, l)
+	case "get_properties":
+		props, err := p.container.GetContainerProperties(n)
+		if err != nil {
+			pr.replyError(err)
+		} else {
+			pr.replyJson(http.StatusOK, map[string]interface{}{
+				"properties": props,
+				"system":     map[string]string{},
+			})
+		}
+	case "set_properties":
+		args := struct {
+			Properties map[string]string `json:"properties"`
+		}{}
+		if err := pr.decode(&args); err != nil {
+			pr.replyError(err)
+			return false
+		}
+		pr.replyDone(p.container.SetContainerProperties(n, args.Properties))
+		return true
+	case "del_properties":
+		keys := make([]string, 0)
+		if err := pr.decode(&keys); err != nil {
+			pr.replyError(err)
+			return false
+		}
+		pr.replyDone(p.container.DeleteContainerProperties(n, keys))
+		return true
+	default:
+		pr.replyError(errBadRequest)
+	}
+	return false
+}
+
+func pendingKey(n oio.ObjectName, version uint64) string {
+	return string(oio.ComputeContainerId(n)) + "/" + n.Path() + "/" +
+		strconv.FormatUint(version, 10)
+}
+
+func (p *Server) serveContent(pr *proxyRequest, action string) bool {
+	n := &pr.name
+	switch action {
+	case "show":
+		content, err := p.container.GetContent(n)
+		if err != nil {
+			pr.replyError(err)
+			return false
+		}
+		writeContentHeader(pr.rep.Header(), content.Header)
+		if pr.req.Method == "HEAD" {
+			pr.replyCode(http.StatusOK)
+		} else {
+			pr.replyJson(http.StatusOK, content.Chunks)
+		}
+		return false
+
+	case "prepare":
+		args := struct {
+			Policy string `json:"policy"`
+			Size   string `json:"size"`
+		}{}
+		if err := pr.decode(&args); err != nil {
+			pr.replyError(err)
+			return false
+		}
+		size, err := strconv.ParseUint(args.Size, 10, 64)
+		if err != nil {
+			pr.replyError(errBadRequest)
+			return false
+		}
+		content, err := p.container.GenerateContentWithPolicy(n, size, args.Policy, pr.autocreate())
+		if err != nil {
+			pr.replyError(err)
+			// Only the autocreation may have left a container behind
+			return pr.autocreate()
+		}
+		now := time.Now()
+		p.lock.Lock()
+		for k, v := range p.pending {
+			if now.After(v.deadline) {
+				delete(p.pending, k)
+			}
+		}
+		p.pending[pendingKey(n, content.Header.Version)] = pendingContent{
+			header:   content.Header,
+			deadline: now.Add(pendingTtl),
+		}
+		p.lock.Unlock()
+		writeContentHeader(pr.rep.Header(), content.Header)
+		pr.replyJson(http.StatusOK, content.Chunks)
+		return true
+
+	case "create":
+		// Either the list of the chunks, or the chunks with the properties
+		var raw json.RawMessage
+		if err := pr.decode(&raw); err != nil {
+			pr.replyError(err)
+			return false
+		}
+		var content oio.Content
+		args := struct {
+			Chunks     []oio.Chunk       `json:"chunks"`
+			Properties map[string]string `json:"properties"`
+		}{}
+		var err error
+		if trimmed := strings.TrimSpace(string(raw)); strings.HasPrefix(trimmed, "[") {
+			err = json.Unmarshal(raw, &content.Chunks)
+		} else if err = json.Unmarshal(raw, &args); err == nil {
+			content.Chunks = args.Chunks
+			content.Properties = sortedProperties(args.Properties)
+		}
+		if err != nil {
+			pr.replyError(errBadRequest)
+			return false
+		}
+		key := pendingKey(n, n.V)
+		p.lock.Lock()
+		content.Header = p.pending[key].header
+		delete(p.pending, key)
+		p.lock.Unlock()
+		content.Header.Name = n.P
+		content.Header.Version = n.V
+		if err := readContentHeader(pr.req.Header, &content.Header); err != nil {
+			pr.replyError(err)
+			return false
+		}
+		pr.replyDone(true, p.container.PutContent(n, content, pr.autocreate()))
+		return true
+
+	case "delete":
+		pr.replyDone(p.container.DeleteContent(n))
+		return true
+
+	case "get_properties":
+		props, err := p.container.GetContentProperties(n)
+		if err != nil {
+			pr.replyError(err)
+		} else {
+			pr.replyJson(http.StatusOK, map[string]interface{}{
+				"properties": props,
+				"system":     map[string]string{},
+			})
+		}
+		return false
+
+	case "set_properties":
+		args := struct {
+			Properties map[string]string `json:"properties"`
+		}{}
+		if err := pr.decode(&args); err != nil {
+			pr.replyError(err)
+			return false
+		}
+		pr.replyDone(p.container.SetContentProperties(n, args.Properties))
+		return true
+
+	case "del_properties":
+		keys := make([]string, 0)
+		if err := pr.decode(&keys); err != nil {
+			pr.replyError(err)
+			return false
+		}
+		pr.replyDone(p.container.DeleteContentProperties(n, keys))
+		return true
+	}
+	pr.replyError(errBadRequest)
+	return false
+}
+
+func (p *Server) serveConscience(pr *proxyRequest, action string) {
+	switch action {
+	case "info":
+		pr.replyJson(http.StatusOK, p.container.NamespaceInfo())
+	case "list":
+		out := make([]oio.ServiceInfo, 0)
+		if pr.req.URL.Query().Get("type") == "rawx" {
+			out = p.listRawx()
+		}
+		pr.replyJson(http.StatusOK, out)
+	default:
+		pr.replyError(errBadRequest)
+	}
+}
+
+func (p *Server) listRawx() []oio.ServiceInfo {
+	if p.Rawx != nil {
+		return p.Rawx()
+	}
+	addrs := p.container.Rawx()
+	out := make([]oio.ServiceInfo, 0, len(addrs))
+	for _, addr := range addrs {
+		out = append(out, oio.ServiceInfo{
+			Type:  "rawx",
+			Addr:  addr,
+			Score: 100,
+			Tags:  map[string]interface{}{"tag.up": true},
+		})
+	}
+	return out
+}
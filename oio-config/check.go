@@ -0,0 +1,187 @@
+/*
+OpenIO SDS Go client SDK
+Copyright (C) 2017 OpenIO
+
+This library is free software; you can redistribute it and/or
+modify it under the terms of the GNU Lesser General Public
+License as published by the Free Software Foundation; either
+version 3.0 of the License, or (at your option) any later version.
+
+This library is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Lesser General Public License for more details.
+
+You should have received a copy of the GNU Lesser General Public
+License along with this library.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	oio "github.com/jfsmig/oio-go/sdk"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// The outcome of a single probe
+type probe struct {
+	NS       string  `json:"ns"`
+	Check    string  `json:"check"`
+	Endpoint string  `json:"endpoint"`
+	Ok       bool    `json:"ok"`
+	Latency  float64 `json:"latency_ms"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// The endpoints resolved from the configuration, with the same fallback on
+// the main proxy than the SDK.
+var checkedKeys = []string{
+	oio.KeyProxy,
+	oio.KeyProxyDirectory,
+	oio.KeyProxyContainer,
+	oio.KeyProxyConscience,
+}
+
+func timeIt(p *probe, action func() error) {
+	pre := time.Now()
+	err := action()
+	p.Latency = float64(time.Since(pre).Nanoseconds()) / 1e6
+	if err != nil {
+		p.Error = err.Error()
+	} else {
+		p.Ok = true
+	}
+}
+
+func resolveEndpoint(cfg oio.Config, ns, key string) (string, error) {
+	if v, err := cfg.GetString(ns, key); err == nil {
+		return v, nil
+	}
+	return cfg.GetString(ns, oio.KeyProxy)
+}
+
+func checkNamespace(cfg oio.Config, ns string, timeout time.Duration) []probe {
+	out := make([]probe, 0)
+
+	// Raw TCP reachability of the proxies
+	for _, key := range checkedKeys {
+		p := probe{NS: ns, Check: key}
+		endpoint, err := resolveEndpoint(cfg, ns, key)
+		if err != nil {
+			p.Error = "not configured"
+			out = append(out, p)
+			continue
+		}
+		p.Endpoint = endpoint
+		timeIt(&p, func() error {
+			conn, err := net.DialTimeout("tcp", endpoint, timeout)
+			if err == nil {
+				conn.Close()
+			}
+			return err
+		})
+		out = append(out, p)
+	}
+
+	// The namespace info proves the proxy reaches the conscience
+	cs, _ := oio.MakeConscienceClient(ns, cfg)
+	p := probe{NS: ns, Check: "nsinfo"}
+	p.Endpoint, _ = resolveEndpoint(cfg, ns, oio.KeyProxyConscience)
+	timeIt(&p, func() error {
+		_, err := cs.GetNamespaceInfo(ns)
+		return err
+	})
+	out = append(out, p)
+
+	// Then each rawx known by the conscience
+	var rawx []oio.ServiceInfo
+	p = probe{NS: ns, Check: "rawx-list", Endpoint: p.Endpoint}
+	timeIt(&p, func() error {
+		var err error
+		rawx, err = cs.ListServices(ns, "rawx")
+		return err
+	})
+	out = append(out, p)
+
+	client := http.Client{Timeout: timeout}
+	for _, srv := range rawx {
+		p := probe{NS: ns, Check: "rawx-stat", Endpoint: srv.Addr}
+		timeIt(&p, func() error {
+			rep, err := client.Head("http://" + srv.Addr + "/stat")
+			if err != nil {
+				return err
+			}
+			rep.Body.Close()
+			if rep.StatusCode/100 != 2 {
+				return fmt.Errorf("HTTP status %d", rep.StatusCode)
+			}
+			return nil
+		})
+		out = append(out, p)
+	}
+	return out
+}
+
+func printProbes(probes []probe, format string) error {
+	switch format {
+	case "json":
+		encoded, err := json.MarshalIndent(probes, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(encoded))
+		return nil
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "NS\tCHECK\tENDPOINT\tSTATUS\tLATENCY\tERROR")
+		for _, p := range probes {
+			status := "KO"
+			if p.Ok {
+				status = "OK"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%.3fms\t%s\n",
+				p.NS, p.Check, p.Endpoint, status, p.Latency, p.Error)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown format %s", format)
+	}
+}
+
+func doCheck(args []string) int {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	format := fs.String("format", "table", "Output format: table or json")
+	args = parseInterspersed(fs, args)
+
+	cfg := loadConfig()
+	if len(args) <= 0 {
+		args = cfg.Namespaces()
+	}
+
+	probes := make([]probe, 0)
+	for _, ns := range args {
+		timeout, err := oio.GetDuration(cfg, ns, oio.KeyConnectTimeout)
+		if err != nil || timeout <= 0 {
+			timeout = time.Second
+		}
+		probes = append(probes, checkNamespace(cfg, ns, timeout)...)
+	}
+
+	if err := printProbes(probes, *format); err != nil {
+		log.Fatal("check: ", err)
+	}
+	for _, p := range probes {
+		if !p.Ok {
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,102 @@
+/*
+OpenIO SDS Go client SDK
+Copyright (C) 2017-2018 OpenIO
+
+This library is free software; you can redistribute it and/or
+modify it under the terms of the GNU Lesser General Public
+License as published by the Free Software Foundation; either
+version 3.0 of the License, or (at your option) any later version.
+
+This library is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Lesser General Public License for more details.
+
+You should have received a copy of the GNU Lesser General Public
+License along with this library.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	oio "github.com/jfsmig/oio-go/sdk"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Serves the conscience requests of the proxy, with a single rawx
+func serveConscience(ns, rawx string) http.Handler {
+	return http.HandlerFunc(func(rep http.ResponseWriter, req *http.Request) {
+		var out interface{}
+		switch req.URL.Path {
+		case "/v3.0/" + ns + "/conscience/info":
+			out = oio.NamespaceInfo{Name: ns, ChunkSize: 1048576}
+		case "/v3.0/" + ns + "/conscience/list":
+			out = []oio.ServiceInfo{{Type: "rawx", Addr: rawx, Score: 100}}
+		default:
+			rep.WriteHeader(http.StatusNotFound)
+			return
+		}
+		rep.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rep).Encode(out)
+	})
+}
+
+func TestCheck_Probes(t *testing.T) {
+	cases := []struct {
+		name       string
+		rawxStatus int
+		proxyDown  bool
+		rc         int
+		failed     []string
+	}{
+		{"healthy", http.StatusOK, false, 0, []string{}},
+		{"rawx failing", http.StatusInternalServerError, false, 1, []string{"rawx-stat"}},
+		{"proxy down", http.StatusOK, true, 1, []string{
+			oio.KeyProxy, oio.KeyProxyDirectory, oio.KeyProxyContainer,
+			oio.KeyProxyConscience, "nsinfo", "rawx-list"}},
+	}
+	for _, c := range cases {
+		rawx := httptest.NewServer(http.HandlerFunc(func(rep http.ResponseWriter, req *http.Request) {
+			if req.URL.Path != "/stat" {
+				rep.WriteHeader(http.StatusNotFound)
+				return
+			}
+			rep.WriteHeader(c.rawxStatus)
+		}))
+		proxy := httptest.NewServer(serveConscience("CHECK", strings.TrimPrefix(rawx.URL, "http://")))
+		addr := strings.TrimPrefix(proxy.URL, "http://")
+		if c.proxyDown {
+			proxy.Close()
+		}
+
+		cfg := oio.MakeStaticConfig()
+		cfg.Set("CHECK", oio.KeyProxy, addr)
+		failed := make([]string, 0)
+		for _, p := range checkNamespace(cfg, "CHECK", time.Second) {
+			if !p.Ok {
+				failed = append(failed, p.Check)
+			}
+		}
+		if strings.Join(failed, "|") != strings.Join(c.failed, "|") {
+			t.Fatal("Unexpected failed probes (", c.name, "): ", failed)
+		}
+
+		// The exit status tells whether all the probes succeeded
+		env := oio.EnvVarName("CHECK", oio.KeyProxy)
+		os.Setenv(env, addr)
+		rc := doCheck([]string{"CHECK", "--format", "json"})
+		os.Unsetenv(env)
+		if rc != c.rc {
+			t.Fatal("Unexpected exit status (", c.name, "): ", rc)
+		}
+
+		proxy.Close()
+		rawx.Close()
+	}
+}
@@ -0,0 +1,127 @@
+/*
+OpenIO SDS Go client SDK
+Copyright (C) 2017 OpenIO
+
+This library is free software; you can redistribute it and/or
+modify it under the terms of the GNU Lesser General Public
+License as published by the Free Software Foundation; either
+version 3.0 of the License, or (at your option) any later version.
+
+This library is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Lesser General Public License for more details.
+
+You should have received a copy of the GNU Lesser General Public
+License along with this library.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	oio "github.com/jfsmig/oio-go/sdk"
+	"io"
+	"sort"
+	"strings"
+)
+
+func exportJson(out io.Writer, namespaces []string, values map[string][]oio.ConfigValue) error {
+	tree := make(map[string]map[string]string)
+	for _, ns := range namespaces {
+		tree[ns] = make(map[string]string)
+		for _, cv := range values[ns] {
+			tree[ns][cv.Key] = cv.Value
+		}
+	}
+	encoded, err := json.MarshalIndent(tree, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, string(encoded))
+	return err
+}
+
+func exportIni(out io.Writer, namespaces []string, values map[string][]oio.ConfigValue) error {
+	for i, ns := range namespaces {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		fmt.Fprintf(out, "[%s]\n", ns)
+		for _, cv := range values[ns] {
+			if _, err := fmt.Fprintf(out, "%s=%s\n", cv.Key, cv.Value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Quotes the value for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", "'\\''", -1) + "'"
+}
+
+func exportEnv(out io.Writer, namespaces []string, values map[string][]oio.ConfigValue) error {
+	for _, ns := range namespaces {
+		for _, cv := range values[ns] {
+			_, err := fmt.Fprintf(out, "export %s=%s\n",
+				oio.EnvVarName(ns, cv.Key), shellQuote(cv.Value))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the differences between two configurations, one line per key:
+// "- NS KEY VALUE" when only in the first, "+ NS KEY VALUE" when only in the
+// second, both lines when the values differ.
+func diffConfigs(c0, c1 *oio.StaticConfig) []string {
+	out := make([]string, 0)
+
+	nsSet := make(map[string]bool)
+	for _, ns := range c0.Namespaces() {
+		nsSet[ns] = true
+	}
+	for _, ns := range c1.Namespaces() {
+		nsSet[ns] = true
+	}
+	namespaces := make([]string, 0, len(nsSet))
+	for ns := range nsSet {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+
+	for _, ns := range namespaces {
+		keySet := make(map[string]bool)
+		for _, k := range c0.Keys(ns) {
+			keySet[k] = true
+		}
+		for _, k := range c1.Keys(ns) {
+			keySet[k] = true
+		}
+		keys := make([]string, 0, len(keySet))
+		for k := range keySet {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			v0, err0 := c0.GetString(ns, k)
+			v1, err1 := c1.GetString(ns, k)
+			if err0 == nil && err1 == nil && v0 == v1 {
+				continue
+			}
+			if err0 == nil {
+				out = append(out, fmt.Sprintf("- %s %s %s", ns, k, v0))
+			}
+			if err1 == nil {
+				out = append(out, fmt.Sprintf("+ %s %s %s", ns, k, v1))
+			}
+		}
+	}
+	return out
+}
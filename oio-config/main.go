@@ -19,24 +19,242 @@ License along with this library.
 package main
 
 import (
+	"flag"
 	"fmt"
 	oio "github.com/jfsmig/oio-go/sdk"
 	"log"
+	"os"
+	"sort"
 )
 
-func main() {
-	cfg := oio.MakeStaticConfig()
-	if err := cfg.LoadWithSystem(); err != nil {
-		log.Println("Failed to load the system configuration: ", err)
+type command struct {
+	name string
+	args string
+	help string
+	run  func(args []string) int
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"get", "NS KEY", "Print the effective value of the key", doGet},
+		{"set", "NS KEY VALUE [--file PATH]", "Set the key in the file (default: the local file)", doSet},
+		{"unset", "NS KEY [--file PATH]", "Remove the key from the file (default: the local file)", doUnset},
+		{"list-namespaces", "", "Print the namespaces configured", doListNamespaces},
+		{"validate", "[NS...]", "Check the keys and the values of the namespaces", doValidate},
+		{"export", "[NS...] [--format json|ini|env] [--defaults]", "Print the effective configuration", doExport},
+		{"diff", "FILE1 FILE2", "Print the differences between two files", doDiff},
+		{"check", "[NS...] [--format table|json]", "Probe the services configured for the namespaces", doCheck},
+	}
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintln(out, "Usage: oio-config [COMMAND [ARGS...]]")
+	fmt.Fprintln(out, "Without command, print all the 'NS KEY VALUE' triples.")
+	fmt.Fprintln(out, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(out, "  %s %s\n", c.name, c.args)
+		fmt.Fprintf(out, "\t%s\n", c.help)
+	}
+}
+
+// Parses the flags wherever they are among the positional arguments, and
+// returns the positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	positional := make([]string, 0)
+	for {
+		if err := fs.Parse(args); err != nil {
+			os.Exit(2)
+		}
+		args = fs.Args()
+		if len(args) <= 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
 	}
-	if err := cfg.LoadWithLocal(); err != nil {
-		log.Println("Failed to load the local configuration: ", err)
+}
+
+func loadConfig() *oio.LayeredConfig {
+	return oio.MakeDefaultConfig()
+}
+
+func localFile() string {
+	path, err := oio.LocalConfigFile()
+	if err != nil {
+		log.Fatal("No local configuration file: ", err)
 	}
+	return path
+}
 
+func doDump() int {
+	cfg := loadConfig()
 	for _, ns := range cfg.Namespaces() {
-		for _, k := range cfg.Keys(ns) {
-			v, _ := cfg.GetString(ns, k)
-			fmt.Println(ns, k, v)
+		for _, cv := range cfg.Describe(ns) {
+			if cv.Origin != oio.LayerDefaults {
+				fmt.Println(ns, cv.Key, cv.Value)
+			}
+		}
+	}
+	return 0
+}
+
+func doGet(args []string) int {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	verbose := fs.Bool("v", false, "Also print where the value comes from")
+	args = parseInterspersed(fs, args)
+	if len(args) != 2 {
+		log.Fatal("get: expected NS KEY")
+	}
+	cfg := loadConfig()
+	v, err := cfg.GetString(args[0], args[1])
+	if err != nil {
+		log.Println("get: ", err)
+		return 1
+	}
+	if *verbose {
+		origin, _ := cfg.Origin(args[0], args[1])
+		fmt.Println(v, origin)
+	} else {
+		fmt.Println(v)
+	}
+	return 0
+}
+
+func doSet(args []string) int {
+	fs := flag.NewFlagSet("set", flag.ExitOnError)
+	path := fs.String("file", "", "Path to the configuration file to modify")
+	args = parseInterspersed(fs, args)
+	if len(args) != 3 {
+		log.Fatal("set: expected NS KEY VALUE")
+	}
+	if len(*path) <= 0 {
+		*path = localFile()
+	}
+	if _, ok := oio.LookupKey(args[1]); !ok {
+		log.Printf("set: warning, key [%s] unknown to the SDK", args[1])
+	}
+	err := oio.UpdateConfigFile(*path, args[0], map[string]string{args[1]: args[2]}, nil)
+	if err != nil {
+		log.Println("set: ", err)
+		return 1
+	}
+	return 0
+}
+
+func doUnset(args []string) int {
+	fs := flag.NewFlagSet("unset", flag.ExitOnError)
+	path := fs.String("file", "", "Path to the configuration file to modify")
+	args = parseInterspersed(fs, args)
+	if len(args) != 2 {
+		log.Fatal("unset: expected NS KEY")
+	}
+	if len(*path) <= 0 {
+		*path = localFile()
+	}
+	if err := oio.UpdateConfigFile(*path, args[0], nil, []string{args[1]}); err != nil {
+		log.Println("unset: ", err)
+		return 1
+	}
+	return 0
+}
+
+func doListNamespaces(args []string) int {
+	for _, ns := range loadConfig().Namespaces() {
+		fmt.Println(ns)
+	}
+	return 0
+}
+
+func doValidate(args []string) int {
+	cfg := loadConfig()
+	if len(args) <= 0 {
+		args = cfg.Namespaces()
+	}
+	rc := 0
+	for _, ns := range args {
+		for _, e := range cfg.Validate(ns) {
+			fmt.Println(e.Error())
+			rc = 1
+		}
+	}
+	return rc
+}
+
+func doExport(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", "ini", "Output format: json, ini or env")
+	defaults := fs.Bool("defaults", false, "Also export the default values of the SDK")
+	args = parseInterspersed(fs, args)
+
+	cfg := loadConfig()
+	if len(args) <= 0 {
+		args = cfg.Namespaces()
+	}
+	sort.Strings(args)
+	values := make(map[string][]oio.ConfigValue)
+	for _, ns := range args {
+		values[ns] = make([]oio.ConfigValue, 0)
+		for _, cv := range cfg.Describe(ns) {
+			if *defaults || cv.Origin != oio.LayerDefaults {
+				values[ns] = append(values[ns], cv)
+			}
+		}
+	}
+
+	var err error
+	switch *format {
+	case "json":
+		err = exportJson(os.Stdout, args, values)
+	case "ini":
+		err = exportIni(os.Stdout, args, values)
+	case "env":
+		err = exportEnv(os.Stdout, args, values)
+	default:
+		log.Fatal("export: unknown format ", *format)
+	}
+	if err != nil {
+		log.Println("export: ", err)
+		return 1
+	}
+	return 0
+}
+
+func doDiff(args []string) int {
+	if len(args) != 2 {
+		log.Fatal("diff: expected FILE1 FILE2")
+	}
+	cfgs := make([]*oio.StaticConfig, 2)
+	for i, path := range args {
+		cfgs[i] = oio.MakeStaticConfig()
+		if err := cfgs[i].LoadWithFile(path); err != nil {
+			log.Fatal("diff: ", err)
+		}
+	}
+	rc := 0
+	for _, d := range diffConfigs(cfgs[0], cfgs[1]) {
+		fmt.Println(d)
+		rc = 1
+	}
+	return rc
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() <= 0 {
+		os.Exit(doDump())
+	}
+
+	name := flag.Arg(0)
+	for _, c := range commands {
+		if c.name == name {
+			os.Exit(c.run(flag.Args()[1:]))
 		}
 	}
+	usage()
+	os.Exit(2)
 }
@@ -0,0 +1,165 @@
+/*
+OpenIO SDS Go client SDK
+Copyright (C) 2017-2018 OpenIO
+
+This library is free software; you can redistribute it and/or
+modify it under the terms of the GNU Lesser General Public
+License as published by the Free Software Foundation; either
+version 3.0 of the License, or (at your option) any later version.
+
+This library is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Lesser General Public License for more details.
+
+You should have received a copy of the GNU Lesser General Public
+License along with this library.
+*/
+
+package main
+
+import (
+	"bytes"
+	oio "github.com/jfsmig/oio-go/sdk"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExport_Formats(t *testing.T) {
+	namespaces := []string{"NS", "OTHER"}
+	values := map[string][]oio.ConfigValue{
+		"NS": {
+			{Key: oio.KeyProxy, Value: "127.0.0.1:6000"},
+			{Key: oio.KeyAutocreate, Value: "true"},
+		},
+		"OTHER": {
+			{Key: oio.KeyProxy, Value: "it's"},
+		},
+	}
+
+	cases := []struct {
+		format string
+		export func(io.Writer, []string, map[string][]oio.ConfigValue) error
+		want   string
+	}{
+		{"json", exportJson, `{
+  "NS": {
+    "autocreate": "true",
+    "proxy": "127.0.0.1:6000"
+  },
+  "OTHER": {
+    "proxy": "it's"
+  }
+}
+`},
+		{"ini", exportIni, `[NS]
+proxy=127.0.0.1:6000
+autocreate=true
+
+[OTHER]
+proxy=it's
+`},
+		{"env", exportEnv, `export OIO_NS_PROXY='127.0.0.1:6000'
+export OIO_NS_AUTOCREATE='true'
+export OIO_OTHER_PROXY='it'\''s'
+`},
+	}
+	for _, c := range cases {
+		var out bytes.Buffer
+		if err := c.export(&out, namespaces, values); err != nil {
+			t.Fatal(c.format, " export failed: ", err)
+		}
+		if out.String() != c.want {
+			t.Fatalf("Unexpected %s export:\n%s", c.format, out.String())
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oio-config-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		c0, c1 string
+		want   []string
+	}{
+		{"[NS]\nproxy=A\n", "[NS]\nproxy=A\n", []string{}},
+		{"[NS]\nproxy=A\n", "[NS]\nproxy=B\n", []string{"- NS proxy A", "+ NS proxy B"}},
+		{"[NS]\nproxy=A\n", "[NS]\nproxy=A\nautocreate=true\n", []string{"+ NS autocreate true"}},
+		{"[NS]\nproxy=A\n\n[OLD]\nproxy=C\n", "[NS]\nproxy=A\n", []string{"- OLD proxy C"}},
+	}
+	for i, c := range cases {
+		cfgs := make([]*oio.StaticConfig, 2)
+		for j, content := range []string{c.c0, c.c1} {
+			path := filepath.Join(dir, "sds.conf")
+			if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			cfgs[j] = oio.MakeStaticConfig()
+			if err := cfgs[j].LoadWithFile(path); err != nil {
+				t.Fatal("LoadWithFile failed: ", err)
+			}
+		}
+		got := diffConfigs(cfgs[0], cfgs[1])
+		if strings.Join(got, "|") != strings.Join(c.want, "|") {
+			t.Fatal("Unexpected diff for case ", i, ": ", got)
+		}
+	}
+}
+
+// The changes are written through UpdateConfigFile, that replaces the file
+// without leaving anything else in its directory.
+func TestSetUnset(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oio-config-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "sds.conf")
+
+	cases := []struct {
+		run  func([]string) int
+		args []string
+		want map[string]string
+	}{
+		{doSet, []string{"NS", oio.KeyProxy, "127.0.0.1:6000", "--file", path},
+			map[string]string{oio.KeyProxy: "127.0.0.1:6000"}},
+		{doSet, []string{"--file", path, "NS", oio.KeyAutocreate, "true"},
+			map[string]string{oio.KeyProxy: "127.0.0.1:6000", oio.KeyAutocreate: "true"}},
+		{doSet, []string{"NS", oio.KeyProxy, "127.0.0.1:6001", "--file", path},
+			map[string]string{oio.KeyProxy: "127.0.0.1:6001", oio.KeyAutocreate: "true"}},
+		{doUnset, []string{"NS", oio.KeyProxy, "--file", path},
+			map[string]string{oio.KeyAutocreate: "true"}},
+		{doUnset, []string{"NS", oio.KeyAutocreate, "--file", path},
+			map[string]string{}},
+	}
+	for i, c := range cases {
+		if rc := c.run(c.args); rc != 0 {
+			t.Fatal("Command failed for case ", i, ": ", rc)
+		}
+		cfg := oio.MakeStaticConfig()
+		if err := cfg.LoadWithFile(path); err != nil {
+			t.Fatal("LoadWithFile failed: ", err)
+		}
+		keys := cfg.Keys("NS")
+		if len(keys) != len(c.want) {
+			t.Fatal("Unexpected keys for case ", i, ": ", keys)
+		}
+		for k, v := range c.want {
+			if got, _ := cfg.GetString("NS", k); got != v {
+				t.Fatal("Unexpected value for case ", i, ": ", k, "=", got)
+			}
+		}
+		entries, _ := ioutil.ReadDir(dir)
+		if len(entries) != 1 || entries[0].Name() != "sds.conf" {
+			t.Fatal("Leftovers of the update for case ", i, ": ", len(entries))
+		}
+	}
+}
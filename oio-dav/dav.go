@@ -0,0 +1,901 @@
+/*
+OpenIO SDS Go client SDK
+Copyright (C) 2017-2018 OpenIO
+
+This library is free software; you can redistribute it and/or
+modify it under the terms of the GNU Lesser General Public
+License as published by the Free Software Foundation; either
+version 3.0 of the License, or (at your option) any later version.
+
+This library is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Lesser General Public License for more details.
+
+You should have received a copy of the GNU Lesser General Public
+License along with this library.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	oio "github.com/jfsmig/oio-go/sdk"
+	"html"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const allowedMethods = "OPTIONS, GET, HEAD, PUT, DELETE, MKCOL, COPY, MOVE, PROPFIND, PROPPATCH, LOCK, UNLOCK"
+
+// The kinds of resources
+const (
+	kindNone = iota
+	kindFile
+	kindDir
+)
+
+// An error carrying the status of the reply
+type statusError int
+
+func (e statusError) Error() string { return http.StatusText(int(e)) }
+
+// A tree of contents served under a path of the server. The collections are
+// the common prefixes of the paths of the contents, with an optional empty
+// content ending with a slash to keep them when they are empty.
+type davRoot struct {
+	mount  string
+	name   oio.FlatName
+	prefix string
+}
+
+// Parses a root given as MOUNT=oio://NS/ACCOUNT/USER[/TYPE/PREFIX]
+func parseRoot(s string) (*davRoot, error) {
+	idx := strings.IndexByte(s, '=')
+	if idx < 0 {
+		return nil, errors.New("Expected MOUNT=URL")
+	}
+	n, err := oio.ParseName(s[idx+1:])
+	if err != nil {
+		return nil, err
+	}
+	if n.V != 0 || len(n.I) > 0 {
+		return nil, errors.New("Unexpected version or id")
+	}
+	r := &davRoot{mount: path.Clean("/" + s[:idx]), prefix: n.P}
+	r.name = oio.FlatName{N: n.N, A: n.A, U: n.U, S: n.S}
+	if len(r.prefix) > 0 && !strings.HasSuffix(r.prefix, "/") {
+		r.prefix += "/"
+	}
+	return r, nil
+}
+
+// Returns the URL path of the content with the given name, in the root
+func (r *davRoot) href(name string) string {
+	p := path.Join(r.mount, strings.TrimPrefix(name, r.prefix))
+	if strings.HasSuffix(name, "/") && !strings.HasSuffix(p, "/") {
+		p += "/"
+	}
+	return escapePath(p)
+}
+
+func escapePath(p string) string {
+	return (&url.URL{Path: p}).EscapedPath()
+}
+
+// A resource targeted by a request
+type resource struct {
+	root *davRoot
+	// The path under the root, without leading nor trailing slash
+	rel string
+	// The cleaned URL path, that identifies the resource in the locks
+	path string
+}
+
+func (r *resource) container() *oio.FlatName {
+	n := r.root.name
+	return &n
+}
+
+func (r *resource) object(p string) *oio.FlatName {
+	n := r.root.name
+	n.P = p
+	return &n
+}
+
+// The name of the content standing for the resource, when it is a file
+func (r *resource) objectPath() string { return r.root.prefix + r.rel }
+
+// The prefix of the contents under the resource, when it is a collection
+func (r *resource) dirPath() string {
+	if len(r.rel) <= 0 {
+		return r.root.prefix
+	}
+	return r.root.prefix + r.rel + "/"
+}
+
+func (r *resource) href(dir bool) string {
+	p := path.Join(r.root.mount, r.rel)
+	if dir && !strings.HasSuffix(p, "/") {
+		p += "/"
+	}
+	return escapePath(p)
+}
+
+func (r *resource) child(name string) *resource {
+	rel := name
+	if len(r.rel) > 0 {
+		rel = r.rel + "/" + name
+	}
+	return &resource{root: r.root, rel: rel, path: path.Join(r.path, name)}
+}
+
+// Returns nil for the root
+func (r *resource) parent() *resource {
+	if len(r.rel) <= 0 {
+		return nil
+	}
+	rel := ""
+	if idx := strings.LastIndexByte(r.rel, '/'); idx >= 0 {
+		rel = r.rel[:idx]
+	}
+	return &resource{root: r.root, rel: rel, path: path.Dir(r.path)}
+}
+
+// Serves WebDAV (class 2) on the roots. The locks are only known by the
+// server, they do not protect the contents from the other clients of the
+// storage.
+type davServer struct {
+	roots     []*davRoot
+	policy    string
+	tmpdir    string
+	directory oio.Directory
+	container oio.Container
+	storage   oio.ObjectStorage
+	rawx      map[string]*oio.RawxClient
+	locks     *lockTable
+}
+
+func makeDavServer(roots []*davRoot, cfg oio.Config) (*davServer, error) {
+	s := &davServer{
+		roots: roots,
+		rawx:  make(map[string]*oio.RawxClient),
+		locks: makeLockTable(),
+	}
+	var err error
+	if s.directory, err = oio.MakeMultiDirectoryClient(cfg); err != nil {
+		return nil, err
+	}
+	if s.container, err = oio.MakeMultiContainerClient(cfg); err != nil {
+		return nil, err
+	}
+	if s.storage, err = oio.MakeMultiObjectStorageClient(cfg); err != nil {
+		return nil, err
+	}
+	for _, r := range roots {
+		if _, ok := s.rawx[r.name.N]; !ok {
+			if s.rawx[r.name.N], err = oio.MakeRawxClient(r.name.N, cfg); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s, nil
+}
+
+// Finds the root with the longest mount point matching the URL path
+func (s *davServer) resolve(urlPath string) *resource {
+	p := path.Clean("/" + urlPath)
+	var best *davRoot
+	for _, r := range s.roots {
+		if p == r.mount || r.mount == "/" || strings.HasPrefix(p, r.mount+"/") {
+			if best == nil || len(r.mount) > len(best.mount) {
+				best = r
+			}
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return &resource{root: best, rel: strings.Trim(p[len(best.mount):], "/"), path: p}
+}
+
+// Tells what the resource is. A collection exists as soon as a content has
+// its path under it, the root always exists.
+func (s *davServer) stat(r *resource) (int, oio.ContentHeader, error) {
+	if len(r.rel) <= 0 {
+		return kindDir, oio.ContentHeader{}, nil
+	}
+	hdr, err := s.container.StatContent(r.object(r.objectPath()))
+	if err == nil && !hdr.Deleted {
+		return kindFile, hdr, nil
+	}
+	if err != nil && err != oio.ErrorNotFound {
+		return kindNone, hdr, err
+	}
+	l, err := s.container.ListContentsWithParams(r.container(), oio.ListParams{Prefix: r.dirPath(), Max: 1})
+	if err == oio.ErrorNotFound {
+		return kindNone, oio.ContentHeader{}, nil
+	} else if err != nil {
+		return kindNone, oio.ContentHeader{}, err
+	}
+	if len(l.Objects) > 0 || len(l.Prefixes) > 0 {
+		return kindDir, oio.ContentHeader{}, nil
+	}
+	return kindNone, oio.ContentHeader{}, nil
+}
+
+// Calls <fn> on each content whose path starts with <prefix>, page by page.
+// With a delimiter, the common prefixes are reported with a nil header. A
+// missing container is empty.
+func (s *davServer) walk(c *oio.FlatName, prefix, delimiter string, fn func(name string, hdr *oio.ContentHeader) error) error {
+	p := oio.ListParams{Prefix: prefix, Delimiter: delimiter}
+	for {
+		l, err := s.container.ListContentsWithParams(c, p)
+		if err == oio.ErrorNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		last := ""
+		for i := range l.Objects {
+			hdr := &l.Objects[i]
+			if hdr.Name > last {
+				last = hdr.Name
+			}
+			if hdr.Deleted {
+				continue
+			}
+			if err = fn(hdr.Name, hdr); err != nil {
+				return err
+			}
+		}
+		for _, pfx := range l.Prefixes {
+			if pfx > last {
+				last = pfx
+			}
+			if err = fn(pfx, nil); err != nil {
+				return err
+			}
+		}
+		if !l.Truncated {
+			return nil
+		}
+		if p.Marker = l.NextMarker; len(p.Marker) <= 0 {
+			p.Marker = last
+		}
+	}
+}
+
+var lockTokenPattern = regexp.MustCompile("<(" + lockTokenPrefix + "[^>]+)>")
+
+// Extracts the lock tokens submitted in the If header. The conditions are
+// not evaluated, a token is enough to act on the locked resources.
+func submittedTokens(req *http.Request) []string {
+	out := make([]string, 0)
+	for _, m := range lockTokenPattern.FindAllStringSubmatch(req.Header.Get("If"), -1) {
+		out = append(out, m[1])
+	}
+	return out
+}
+
+func (s *davServer) checkLocks(req *http.Request, p string, infinite bool) error {
+	if err := s.locks.check(p, infinite, submittedTokens(req)); err == errLocked {
+		return statusError(http.StatusLocked)
+	} else {
+		return err
+	}
+}
+
+// A resource may only be created in an existing collection
+func (s *davServer) checkParent(r *resource) error {
+	p := r.parent()
+	if p == nil || len(p.rel) <= 0 {
+		return nil
+	}
+	kind, _, err := s.stat(p)
+	if err != nil {
+		return err
+	}
+	if kind != kindDir {
+		return statusError(http.StatusConflict)
+	}
+	return nil
+}
+
+func replyError(rep http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	if e, ok := err.(statusError); ok {
+		code = int(e)
+	} else if err == oio.ErrorNotFound {
+		code = http.StatusNotFound
+	} else {
+		log.Println("Request failed:", err)
+	}
+	http.Error(rep, http.StatusText(code), code)
+}
+
+func replyCode(rep http.ResponseWriter, code int) {
+	rep.Header().Set("Content-Length", "0")
+	rep.WriteHeader(code)
+}
+
+func (s *davServer) ServeHTTP(rep http.ResponseWriter, req *http.Request) {
+	rep.Header().Set("Server", "oio-dav")
+	if req.Method == "OPTIONS" {
+		rep.Header().Set("DAV", "1, 2")
+		rep.Header().Set("MS-Author-Via", "DAV")
+		rep.Header().Set("Allow", allowedMethods)
+		replyCode(rep, http.StatusOK)
+		return
+	}
+
+	r := s.resolve(req.URL.Path)
+	if r == nil {
+		replyError(rep, statusError(http.StatusNotFound))
+		return
+	}
+	var err error
+	switch req.Method {
+	case "GET", "HEAD":
+		err = s.get(rep, req, r)
+	case "PUT":
+		err = s.put(rep, req, r)
+	case "DELETE":
+		err = s.delete(rep, req, r)
+	case "MKCOL":
+		err = s.mkcol(rep, req, r)
+	case "COPY":
+		err = s.copyMove(rep, req, r, false)
+	case "MOVE":
+		err = s.copyMove(rep, req, r, true)
+	case "PROPFIND":
+		err = s.propfind(rep, req, r)
+	case "PROPPATCH":
+		err = s.proppatch(rep, req, r)
+	case "LOCK":
+		err = s.lock(rep, req, r)
+	case "UNLOCK":
+		err = s.unlock(rep, req, r)
+	default:
+		rep.Header().Set("Allow", allowedMethods)
+		err = statusError(http.StatusMethodNotAllowed)
+	}
+	if err != nil {
+		replyError(rep, err)
+	}
+}
+
+func etag(hdr *oio.ContentHeader) string {
+	if len(hdr.Hash) > 0 {
+		return "\"" + strings.ToLower(hdr.Hash) + "\""
+	}
+	return "\"" + hdr.Id + "-" + strconv.FormatUint(hdr.Version, 10) + "\""
+}
+
+// The type saved with the content, else guessed from the extension
+func contentType(hdr *oio.ContentHeader) string {
+	if len(hdr.MimeType) > 0 && hdr.MimeType != "application/octet-stream" {
+		return hdr.MimeType
+	}
+	if t := mime.TypeByExtension(path.Ext(hdr.Name)); len(t) > 0 {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// Stands for the data of the contents in the replies to HEAD
+type noData struct{}
+
+func (noData) ReadAt(p []byte, off int64) (int, error) { return 0, io.ErrUnexpectedEOF }
+
+// Serves the data of a file, with the ranges and the conditions managed by
+// the standard library, or an index of a collection.
+func (s *davServer) get(rep http.ResponseWriter, req *http.Request, r *resource) error {
+	kind, hdr, err := s.stat(r)
+	if err != nil {
+		return err
+	}
+	switch kind {
+	case kindNone:
+		return statusError(http.StatusNotFound)
+	case kindDir:
+		return s.index(rep, r)
+	}
+
+	var content io.ReadSeeker
+	if req.Method == "HEAD" {
+		content = io.NewSectionReader(noData{}, 0, int64(hdr.Size))
+	} else {
+		cr, err := oio.OpenContent(s.container, s.rawx[r.root.name.N], r.object(r.objectPath()))
+		if err != nil {
+			return err
+		}
+		defer cr.Close()
+		hdr, content = cr.Header(), cr
+	}
+	rep.Header().Set("ETag", etag(&hdr))
+	rep.Header().Set("Content-Type", contentType(&hdr))
+	http.ServeContent(rep, req, "", time.Unix(int64(hdr.CTime), 0), content)
+	return nil
+}
+
+func (s *davServer) index(rep http.ResponseWriter, r *resource) error {
+	var b bytes.Buffer
+	title := html.EscapeString(r.path)
+	b.WriteString("<!DOCTYPE html>\n<html><head><title>" + title + "</title></head><body>\n")
+	b.WriteString("<h1>" + title + "</h1>\n<ul>\n")
+	dir := r.dirPath()
+	err := s.walk(r.container(), dir, "/", func(name string, hdr *oio.ContentHeader) error {
+		if name != dir {
+			b.WriteString("<li><a href=\"" + html.EscapeString(r.root.href(name)) + "\">" +
+				html.EscapeString(name[len(dir):]) + "</a></li>\n")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	b.WriteString("</ul>\n</body></html>\n")
+	rep.Header().Set("Content-Type", "text/html; charset=utf-8")
+	rep.Header().Set("Content-Length", strconv.Itoa(b.Len()))
+	rep.WriteHeader(http.StatusOK)
+	rep.Write(b.Bytes())
+	return nil
+}
+
+// Uploads a content, and creates its container when the namespace does not
+// autocreate it. The upload fails before reading <in> when the container is
+// missing, so that it can be retried.
+func (s *davServer) putContent(n *oio.FlatName, size uint64, policy string, in io.ReadSeeker) error {
+	err := s.storage.PutContentWithPolicy(n, size, policy, true, in)
+	if err != oio.ErrorNotFound {
+		return err
+	}
+	c := &oio.FlatName{N: n.N, A: n.A, U: n.U, S: n.S}
+	if _, err = s.directory.CreateUser(c); err != nil {
+		return err
+	}
+	if _, err = s.container.CreateContainer(c, false); err != nil {
+		return err
+	}
+	return s.storage.PutContentWithPolicy(n, size, policy, true, in)
+}
+
+// Saves the body in a temporary file, since the SDK needs to know the size
+// and the clients often send the files in chunks.
+func (s *davServer) spool(req *http.Request) (*os.File, int64, error) {
+	f, err := ioutil.TempFile(s.tmpdir, "oio-dav-")
+	if err != nil {
+		return nil, 0, err
+	}
+	os.Remove(f.Name())
+	n, err := io.Copy(f, req.Body)
+	if err == nil && req.ContentLength >= 0 && n != req.ContentLength {
+		err = statusError(http.StatusBadRequest)
+	}
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, n, nil
+}
+
+func (s *davServer) put(rep http.ResponseWriter, req *http.Request, r *resource) error {
+	if len(r.rel) <= 0 {
+		return statusError(http.StatusMethodNotAllowed)
+	}
+	if err := s.checkLocks(req, r.path, false); err != nil {
+		return err
+	}
+	kind, _, err := s.stat(r)
+	if err != nil {
+		return err
+	}
+	if kind == kindDir {
+		return statusError(http.StatusMethodNotAllowed)
+	}
+	if err = s.checkParent(r); err != nil {
+		return err
+	}
+
+	f, size, err := s.spool(req)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err = s.putContent(r.object(r.objectPath()), uint64(size), s.policy, f); err != nil {
+		return err
+	}
+	if kind == kindNone {
+		replyCode(rep, http.StatusCreated)
+	} else {
+		replyCode(rep, http.StatusNoContent)
+	}
+	return nil
+}
+
+// Removes a file or a whole collection. Only the failed deletions are
+// returned.
+func (s *davServer) remove(r *resource, kind int) ([]oio.DeleteResult, error) {
+	if kind == kindFile {
+		return nil, s.storage.DeleteContent(r.object(r.objectPath()))
+	}
+	results, err := s.storage.DeleteContentsWithPrefix(r.container(), r.dirPath(), 0)
+	if err == oio.ErrorNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	failed := make([]oio.DeleteResult, 0)
+	for _, res := range results {
+		if res.Err != nil && res.Err != oio.ErrorNotFound {
+			failed = append(failed, res)
+		}
+	}
+	return failed, nil
+}
+
+func (s *davServer) delete(rep http.ResponseWriter, req *http.Request, r *resource) error {
+	if len(r.rel) <= 0 {
+		return statusError(http.StatusForbidden)
+	}
+	kind, _, err := s.stat(r)
+	if err != nil {
+		return err
+	}
+	if kind == kindNone {
+		return statusError(http.StatusNotFound)
+	}
+	if err = s.checkLocks(req, r.path, true); err != nil {
+		return err
+	}
+
+	failed, err := s.remove(r, kind)
+	if err != nil {
+		return err
+	}
+	s.locks.drop(r.path)
+	if len(failed) > 0 {
+		ms := makeMultistatus()
+		for _, res := range failed {
+			log.Println("Deletion of", res.Path, "failed:", res.Err)
+			ms.status(r.root.href(res.Path), http.StatusInternalServerError)
+		}
+		ms.reply(rep)
+		return nil
+	}
+	replyCode(rep, http.StatusNoContent)
+	return nil
+}
+
+// Creates a collection as an empty content named after it, with a trailing
+// slash.
+func (s *davServer) mkcol(rep http.ResponseWriter, req *http.Request, r *resource) error {
+	if n, _ := io.Copy(ioutil.Discard, io.LimitReader(req.Body, 1)); n > 0 {
+		return statusError(http.StatusUnsupportedMediaType)
+	}
+	if len(r.rel) <= 0 {
+		return statusError(http.StatusMethodNotAllowed)
+	}
+	if err := s.checkLocks(req, r.path, false); err != nil {
+		return err
+	}
+	kind, _, err := s.stat(r)
+	if err != nil {
+		return err
+	}
+	if kind != kindNone {
+		return statusError(http.StatusMethodNotAllowed)
+	}
+	if err = s.checkParent(r); err != nil {
+		return err
+	}
+	if err = s.makeMarker(r); err != nil {
+		return err
+	}
+	replyCode(rep, http.StatusCreated)
+	return nil
+}
+
+func (s *davServer) makeMarker(r *resource) error {
+	return s.putContent(r.object(r.dirPath()), 0, s.policy, bytes.NewReader(nil))
+}
+
+// Resolves the Destination header of a COPY or a MOVE
+func (s *davServer) destination(req *http.Request) (*resource, error) {
+	v := req.Header.Get("Destination")
+	if len(v) <= 0 {
+		return nil, statusError(http.StatusBadRequest)
+	}
+	u, err := url.Parse(v)
+	if err != nil {
+		return nil, statusError(http.StatusBadRequest)
+	}
+	dst := s.resolve(u.Path)
+	if dst == nil {
+		return nil, statusError(http.StatusBadGateway)
+	}
+	return dst, nil
+}
+
+// Copies the data and the properties of a content. The storage policy is
+// kept in the same namespace.
+func (s *davServer) copyFile(src, dst *oio.FlatName) error {
+	cr, err := oio.OpenContent(s.container, s.rawx[src.N], src)
+	if err != nil {
+		return err
+	}
+	defer cr.Close()
+	hdr := cr.Header()
+	policy := s.policy
+	if src.N == dst.N {
+		policy = hdr.Policy
+	}
+	if err = s.putContent(dst, hdr.Size, policy, cr); err != nil {
+		return err
+	}
+	props, err := s.container.GetContentProperties(src)
+	if err == nil && len(props) > 0 {
+		_, err = s.container.SetContentProperties(dst, props)
+	}
+	return err
+}
+
+// Copies a collection, with its members when <infinite>, and returns the
+// paths written in the container of the destination.
+func (s *davServer) copyTree(src, dst *resource, infinite bool) (map[string]bool, error) {
+	written := make(map[string]bool)
+	if len(dst.rel) > 0 {
+		if err := s.makeMarker(dst); err != nil {
+			return written, err
+		}
+		written[dst.dirPath()] = true
+	}
+	if !infinite {
+		return written, nil
+	}
+	srcDir, dstDir := src.dirPath(), dst.dirPath()
+	err := s.walk(src.container(), srcDir, "", func(name string, hdr *oio.ContentHeader) error {
+		if name == srcDir {
+			return nil
+		}
+		p := dstDir + name[len(srcDir):]
+		if err := s.copyFile(src.object(name), dst.object(p)); err != nil {
+			return err
+		}
+		written[p] = true
+		return nil
+	})
+	return written, err
+}
+
+// Removes what remains of the former destination of a copy, of kind
+// <dkind>, once the copy of a resource of kind <kind> succeeded. <written>
+// holds the paths written by the copy of a collection.
+func (s *davServer) removeLeftovers(dst *resource, dkind, kind int, written map[string]bool) error {
+	var failed []oio.DeleteResult
+	var err error
+	switch {
+	case dkind == kindFile && kind != kindFile:
+		err = s.storage.DeleteContent(dst.object(dst.objectPath()))
+		if err == oio.ErrorNotFound {
+			err = nil
+		}
+	case dkind == kindDir && kind == kindFile:
+		failed, err = s.remove(dst, dkind)
+	case dkind == kindDir:
+		paths := make([]string, 0)
+		err = s.walk(dst.container(), dst.dirPath(), "", func(name string, hdr *oio.ContentHeader) error {
+			if !written[name] {
+				paths = append(paths, name)
+			}
+			return nil
+		})
+		if err == nil && len(paths) > 0 {
+			var results []oio.DeleteResult
+			results, err = s.storage.DeleteContents(dst.container(), paths, 0)
+			for _, res := range results {
+				if res.Err != nil && res.Err != oio.ErrorNotFound {
+					failed = append(failed, res)
+				}
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("Destination not cleaned: %s: %v", failed[0].Path, failed[0].Err)
+	}
+	return nil
+}
+
+// COPY and MOVE. A MOVE is a copy followed by the deletion of the source,
+// since the contents cannot be renamed. The former destination is replaced
+// by the copy, then what remains of it is removed, so that a failed copy
+// does not lose it. Neither is atomic: a failure may leave a destination
+// partly overwritten.
+func (s *davServer) copyMove(rep http.ResponseWriter, req *http.Request, r *resource, move bool) error {
+	dst, err := s.destination(req)
+	if err != nil {
+		return err
+	}
+	infinite := true
+	if !move {
+		switch req.Header.Get("Depth") {
+		case "", "infinity":
+		case "0":
+			infinite = false
+		default:
+			return statusError(http.StatusBadRequest)
+		}
+	}
+	if dst.path == r.path || isUnder(dst.path, r.path) || isUnder(r.path, dst.path) {
+		return statusError(http.StatusForbidden)
+	}
+	if len(dst.rel) <= 0 || (move && len(r.rel) <= 0) {
+		return statusError(http.StatusForbidden)
+	}
+
+	kind, _, err := s.stat(r)
+	if err != nil {
+		return err
+	}
+	if kind == kindNone {
+		return statusError(http.StatusNotFound)
+	}
+	if move {
+		if err = s.checkLocks(req, r.path, true); err != nil {
+			return err
+		}
+	}
+	if err = s.checkLocks(req, dst.path, true); err != nil {
+		return err
+	}
+	if err = s.checkParent(dst); err != nil {
+		return err
+	}
+	dkind, _, err := s.stat(dst)
+	if err != nil {
+		return err
+	}
+	if dkind != kindNone && req.Header.Get("Overwrite") == "F" {
+		return statusError(http.StatusPreconditionFailed)
+	}
+
+	var written map[string]bool
+	if kind == kindFile {
+		err = s.copyFile(r.object(r.objectPath()), dst.object(dst.objectPath()))
+	} else {
+		written, err = s.copyTree(r, dst, infinite)
+	}
+	if err != nil {
+		return err
+	}
+	if err = s.removeLeftovers(dst, dkind, kind, written); err != nil {
+		return err
+	}
+	if move {
+		if failed, err := s.remove(r, kind); err != nil {
+			return err
+		} else if len(failed) > 0 {
+			return fmt.Errorf("Source not removed: %s: %v", failed[0].Path, failed[0].Err)
+		}
+		s.locks.drop(r.path)
+	}
+
+	if dkind == kindNone {
+		replyCode(rep, http.StatusCreated)
+	} else {
+		replyCode(rep, http.StatusNoContent)
+	}
+	return nil
+}
+
+// Reads the first acceptable value of the Timeout header
+func parseTimeout(h string) time.Duration {
+	for _, v := range strings.Split(h, ",") {
+		v = strings.TrimSpace(v)
+		if v == "Infinite" {
+			return maxLockTimeout
+		}
+		if strings.HasPrefix(v, "Second-") {
+			if sec, err := strconv.ParseUint(v[7:], 10, 32); err == nil {
+				if d := time.Duration(sec) * time.Second; d < maxLockTimeout {
+					return d
+				}
+				return maxLockTimeout
+			}
+		}
+	}
+	return maxLockTimeout
+}
+
+// Creates a lock or, without body, refreshes one. Locking a missing resource
+// creates an empty file.
+func (s *davServer) lock(rep http.ResponseWriter, req *http.Request, r *resource) error {
+	timeout := parseTimeout(req.Header.Get("Timeout"))
+	var info lockinfoBody
+	ok, err := decodeBody(req, &info)
+	if err != nil {
+		return statusError(http.StatusBadRequest)
+	}
+
+	var l *davLock
+	code := http.StatusOK
+	if !ok {
+		if l, err = s.locks.refresh(r.path, submittedTokens(req), timeout); err != nil {
+			return statusError(http.StatusPreconditionFailed)
+		}
+	} else {
+		infinite := true
+		switch req.Header.Get("Depth") {
+		case "", "infinity":
+		case "0":
+			infinite = false
+		default:
+			return statusError(http.StatusBadRequest)
+		}
+		kind, _, err := s.stat(r)
+		if err != nil {
+			return err
+		}
+		if kind == kindNone {
+			if err = s.checkParent(r); err != nil {
+				return err
+			}
+		}
+		owner := ""
+		if info.Owner != nil {
+			owner = strings.TrimSpace(info.Owner.Inner)
+		}
+		if l, err = s.locks.create(r.path, infinite, info.Shared == nil, owner, timeout); err != nil {
+			return statusError(http.StatusLocked)
+		}
+		if kind == kindNone {
+			err = s.putContent(r.object(r.objectPath()), 0, s.policy, bytes.NewReader(nil))
+			if err != nil {
+				s.locks.unlock(r.path, l.token)
+				return err
+			}
+			code = http.StatusCreated
+		}
+		rep.Header().Set("Lock-Token", "<"+l.token+">")
+	}
+
+	body := xml.Header + "<D:prop xmlns:D=\"DAV:\"><D:lockdiscovery>" +
+		activeLocks([]davLock{*l}, escapePath) + "</D:lockdiscovery></D:prop>"
+	rep.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	rep.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	rep.WriteHeader(code)
+	io.WriteString(rep, body)
+	return nil
+}
+
+func (s *davServer) unlock(rep http.ResponseWriter, req *http.Request, r *resource) error {
+	token := strings.Trim(strings.TrimSpace(req.Header.Get("Lock-Token")), "<>")
+	if len(token) <= 0 {
+		return statusError(http.StatusBadRequest)
+	}
+	if err := s.locks.unlock(r.path, token); err != nil {
+		return statusError(http.StatusConflict)
+	}
+	replyCode(rep, http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,256 @@
+/*
+OpenIO SDS Go client SDK
+Copyright (C) 2017-2018 OpenIO
+
+This library is free software; you can redistribute it and/or
+modify it under the terms of the GNU Lesser General Public
+License as published by the Free Software Foundation; either
+version 3.0 of the License, or (at your option) any later version.
+
+This library is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Lesser General Public License for more details.
+
+You should have received a copy of the GNU Lesser General Public
+License along with this library.
+*/
+
+package main
+
+import (
+	"encoding/xml"
+	"github.com/jfsmig/oio-go/oiotest"
+	oio "github.com/jfsmig/oio-go/sdk"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type testServer struct {
+	t   *testing.T
+	ns  *oiotest.Namespace
+	srv *httptest.Server
+}
+
+func startServer(t *testing.T) *testServer {
+	ns := oiotest.StartNamespace("NS", 3)
+	ns.Container.SetChunkSize(8)
+	// The container is created by the server on the first write
+	ns.Config.Set("NS", oio.KeyAutocreate, "false")
+	r, err := parseRoot("docs=oio://NS/ACCT/USER//shared")
+	if err != nil {
+		t.Fatal("Root not parsed: ", err)
+	}
+	s, err := makeDavServer([]*davRoot{r}, ns.Config)
+	if err != nil {
+		t.Fatal("Server not built: ", err)
+	}
+	return &testServer{t: t, ns: ns, srv: httptest.NewServer(s)}
+}
+
+func (ts *testServer) close() {
+	ts.srv.Close()
+	ts.ns.Close()
+}
+
+func (ts *testServer) do(method, path, body string, hdr map[string]string) (*http.Response, string) {
+	req, _ := http.NewRequest(method, ts.srv.URL+path, strings.NewReader(body))
+	for k, v := range hdr {
+		req.Header.Set(k, v)
+	}
+	rep, err := http.DefaultClient.Do(req)
+	if err != nil {
+		ts.t.Fatal(method, path, err)
+	}
+	defer rep.Body.Close()
+	out, _ := ioutil.ReadAll(rep.Body)
+	return rep, string(out)
+}
+
+func (ts *testServer) expect(method, path, body string, hdr map[string]string, code int) string {
+	rep, out := ts.do(method, path, body, hdr)
+	if rep.StatusCode != code {
+		ts.t.Fatal(method, " ", path, ": ", rep.StatusCode, " ", out)
+	}
+	return out
+}
+
+type testMultistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Length     string    `xml:"prop>getcontentlength"`
+			Collection *struct{} `xml:"prop>resourcetype>collection"`
+			Status     string    `xml:"status"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// Lists the members of the collection, the sub-collections with a trailing
+// slash and the files with their size
+func (ts *testServer) list(path string) string {
+	var ms testMultistatus
+	out := ts.expect("PROPFIND", path, "", map[string]string{"Depth": "1"}, http.StatusMultiStatus)
+	if err := xml.Unmarshal([]byte(out), &ms); err != nil {
+		ts.t.Fatal("Bad multistatus: ", err)
+	}
+	items := make([]string, 0)
+	for _, r := range ms.Responses[1:] {
+		item := strings.TrimPrefix(r.Href, path)
+		if r.Propstat[0].Collection == nil {
+			item += "=" + r.Propstat[0].Length
+		}
+		items = append(items, item)
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+func TestDav_Files(t *testing.T) {
+	ts := startServer(t)
+	defer ts.close()
+
+	ts.expect("PROPFIND", "/docs/", "", map[string]string{"Depth": "0"}, http.StatusMultiStatus)
+	ts.expect("PROPFIND", "/docs/", "", nil, http.StatusForbidden)
+	ts.expect("GET", "/other/", "", nil, http.StatusNotFound)
+
+	ts.expect("MKCOL", "/docs/a", "", nil, http.StatusCreated)
+	ts.expect("MKCOL", "/docs/a", "", nil, http.StatusMethodNotAllowed)
+	ts.expect("MKCOL", "/docs/x/y", "", nil, http.StatusConflict)
+	ts.expect("PUT", "/docs/x/y", "data", nil, http.StatusConflict)
+	ts.expect("PUT", "/docs/a/file.txt", "0123456789abcdefghij", nil, http.StatusCreated)
+	ts.expect("PUT", "/docs/a/file.txt", "0123456789", nil, http.StatusNoContent)
+	ts.expect("MKCOL", "/docs/a/b", "", nil, http.StatusCreated)
+	ts.expect("PUT", "/docs/top", "x", nil, http.StatusCreated)
+
+	if l := ts.list("/docs/"); l != "a/,top=1" {
+		t.Fatal("Bad listing: ", l)
+	}
+	if l := ts.list("/docs/a/"); l != "b/,file.txt=10" {
+		t.Fatal("Bad listing: ", l)
+	}
+	if ok, _ := ts.ns.Container.HasContent(&oio.FlatName{N: "NS", A: "ACCT", U: "USER", P: "shared/a/"}); !ok {
+		t.Fatal("Collection marker not found")
+	}
+
+	rep, out := ts.do("GET", "/docs/a/file.txt", "", map[string]string{"Range": "bytes=2-5"})
+	if rep.StatusCode != http.StatusPartialContent || out != "2345" ||
+		!strings.HasPrefix(rep.Header.Get("Content-Type"), "text/plain") {
+		t.Fatal("Ranged GET failed: ", rep.StatusCode, out, rep.Header)
+	}
+	ts.expect("GET", "/docs/a/", "", nil, http.StatusOK)
+
+	// COPY then MOVE of a collection, and DELETE
+	ts.expect("COPY", "/docs/a", "", map[string]string{"Destination": ts.srv.URL + "/docs/c"}, http.StatusCreated)
+	ts.expect("COPY", "/docs/a", "", map[string]string{"Destination": ts.srv.URL + "/docs/c", "Overwrite": "F"},
+		http.StatusPreconditionFailed)
+	ts.expect("COPY", "/docs/a", "", map[string]string{"Destination": ts.srv.URL + "/elsewhere"}, http.StatusBadGateway)
+	ts.expect("MOVE", "/docs/c", "", map[string]string{"Destination": ts.srv.URL + "/docs/top"}, http.StatusNoContent)
+	if l := ts.list("/docs/"); l != "a/,top/" {
+		t.Fatal("Bad listing: ", l)
+	}
+	if l := ts.list("/docs/top/"); l != "b/,file.txt=10" {
+		t.Fatal("Bad listing: ", l)
+	}
+	ts.expect("MOVE", "/docs/top/file.txt", "", map[string]string{"Destination": ts.srv.URL + "/docs/moved"},
+		http.StatusCreated)
+	if out := ts.expect("GET", "/docs/moved", "", nil, http.StatusOK); out != "0123456789" {
+		t.Fatal("Bad data: ", out)
+	}
+	ts.expect("DELETE", "/docs/a", "", nil, http.StatusNoContent)
+	ts.expect("DELETE", "/docs/a", "", nil, http.StatusNotFound)
+	if l := ts.list("/docs/"); l != "moved=10,top/" {
+		t.Fatal("Bad listing: ", l)
+	}
+}
+
+func TestDav_CopyOverwrite(t *testing.T) {
+	ts := startServer(t)
+	defer ts.close()
+	ts.expect("MKCOL", "/docs/s", "", nil, http.StatusCreated)
+	ts.expect("PUT", "/docs/s/keep", "k", nil, http.StatusCreated)
+	ts.expect("MKCOL", "/docs/d", "", nil, http.StatusCreated)
+	ts.expect("PUT", "/docs/d/old", "o", nil, http.StatusCreated)
+	ts.expect("PUT", "/docs/d/keep", "zz", nil, http.StatusCreated)
+	ts.expect("PUT", "/docs/f", "data", nil, http.StatusCreated)
+	copyTo := func(src, dst string, code int) {
+		ts.expect("COPY", src, "", map[string]string{"Destination": ts.srv.URL + dst}, code)
+	}
+
+	// A failed copy leaves the destination as it was
+	ts.ns.Container.Faults.FailOn("GenerateContentWithPolicy", 0, nil)
+	copyTo("/docs/f", "/docs/d", http.StatusInternalServerError)
+	ts.ns.Container.Faults.Reset()
+	if l := ts.list("/docs/d/"); l != "keep=2,old=1" {
+		t.Fatal("Destination lost: ", l)
+	}
+
+	// The members of the former destination absent from the source go away
+	copyTo("/docs/s", "/docs/d", http.StatusNoContent)
+	if l := ts.list("/docs/d/"); l != "keep=1" {
+		t.Fatal("Bad merge: ", l)
+	}
+	// A file replaces a collection, and the other way around
+	copyTo("/docs/f", "/docs/d", http.StatusNoContent)
+	copyTo("/docs/s", "/docs/f", http.StatusNoContent)
+	if l := ts.list("/docs/"); l != "d=4,f/,s/" {
+		t.Fatal("Bad replacement: ", l)
+	}
+}
+
+func TestDav_Props(t *testing.T) {
+	ts := startServer(t)
+	defer ts.close()
+	ts.expect("PUT", "/docs/f", "abc", nil, http.StatusCreated)
+
+	out := ts.expect("PROPFIND", "/docs/f", `<?xml version="1.0"?>
+<D:propfind xmlns:D="DAV:"><D:prop><D:getcontentlength/><x:color xmlns:x="urn:x"/></D:prop></D:propfind>`,
+		map[string]string{"Depth": "0"}, http.StatusMultiStatus)
+	var ms testMultistatus
+	if err := xml.Unmarshal([]byte(out), &ms); err != nil || len(ms.Responses) != 1 {
+		t.Fatal("Bad multistatus: ", err, out)
+	}
+	ps := ms.Responses[0].Propstat
+	if len(ps) != 2 || ps[0].Length != "3" || !strings.Contains(ps[1].Status, "404") {
+		t.Fatal("Bad properties: ", out)
+	}
+
+	out = ts.expect("PROPPATCH", "/docs/f", `<?xml version="1.0"?>
+<D:propertyupdate xmlns:D="DAV:"><D:set><D:prop><x:color xmlns:x="urn:x">blue</x:color></D:prop></D:set></D:propertyupdate>`,
+		nil, http.StatusMultiStatus)
+	if !strings.Contains(out, "403") {
+		t.Fatal("Dead property accepted: ", out)
+	}
+}
+
+func TestDav_Locks(t *testing.T) {
+	ts := startServer(t)
+	defer ts.close()
+	ts.expect("MKCOL", "/docs/d", "", nil, http.StatusCreated)
+
+	lockinfo := `<?xml version="1.0"?>
+<D:lockinfo xmlns:D="DAV:"><D:lockscope><D:exclusive/></D:lockscope><D:locktype><D:write/></D:locktype>
+<D:owner><D:href>jfs</D:href></D:owner></D:lockinfo>`
+	rep, out := ts.do("LOCK", "/docs/d/new", lockinfo, map[string]string{"Timeout": "Second-60"})
+	token := strings.Trim(rep.Header.Get("Lock-Token"), "<>")
+	if rep.StatusCode != http.StatusCreated || !strings.HasPrefix(token, lockTokenPrefix) ||
+		!strings.Contains(out, "Second-60") {
+		t.Fatal("LOCK failed: ", rep.StatusCode, out)
+	}
+	ts.expect("LOCK", "/docs/d", lockinfo, nil, http.StatusLocked)
+	ts.expect("PUT", "/docs/d/new", "data", nil, http.StatusLocked)
+	ts.expect("DELETE", "/docs/d", "", nil, http.StatusLocked)
+	ts.expect("PUT", "/docs/d/new", "data", map[string]string{"If": "(<" + token + ">)"}, http.StatusNoContent)
+	ts.expect("LOCK", "/docs/d/new", "", map[string]string{"If": "(<" + token + ">)"}, http.StatusOK)
+	if out = ts.expect("PROPFIND", "/docs/d/new", "", map[string]string{"Depth": "0"}, http.StatusMultiStatus); !strings.Contains(out, token) {
+		t.Fatal("Lock not discovered: ", out)
+	}
+
+	ts.expect("UNLOCK", "/docs/d/new", "", map[string]string{"Lock-Token": "<" + lockTokenPrefix + "x>"}, http.StatusConflict)
+	ts.expect("UNLOCK", "/docs/d/new", "", map[string]string{"Lock-Token": "<" + token + ">"}, http.StatusNoContent)
+	ts.expect("PUT", "/docs/d/new", "data", nil, http.StatusNoContent)
+}
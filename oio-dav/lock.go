@@ -0,0 +1,197 @@
+/*
+OpenIO SDS Go client SDK
+Copyright (C) 2017-2018 OpenIO
+
+This library is free software; you can redistribute it and/or
+modify it under the terms of the GNU Lesser General Public
+License as published by the Free Software Foundation; either
+version 3.0 of the License, or (at your option) any later version.
+
+This library is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Lesser General Public License for more details.
+
+You should have received a copy of the GNU Lesser General Public
+License along with this library.
+*/
+
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	lockTokenPrefix = "opaquelocktoken:"
+	maxLockTimeout  = time.Hour
+)
+
+var (
+	errLocked       = errors.New("Locked")
+	errNoSuchLock   = errors.New("No such lock")
+	errLockConflict = errors.New("Lock conflict")
+)
+
+// A write lock held by a client. The locks only live in the memory of the
+// server, the storage knows nothing about them.
+type davLock struct {
+	token     string
+	path      string
+	infinite  bool
+	exclusive bool
+	owner     string
+	timeout   time.Duration
+	expires   time.Time
+}
+
+// Tells if the lock applies to the resource at <path>
+func (l *davLock) covers(path string) bool {
+	return l.path == path || (l.infinite && isUnder(path, l.path))
+}
+
+// Tells if <path> is strictly under the collection at <parent>
+func isUnder(path, parent string) bool {
+	if parent == "/" {
+		return path != "/"
+	}
+	return strings.HasPrefix(path, parent+"/")
+}
+
+// The locks by token. The paths are the URL paths of the resources, without
+// trailing slash.
+type lockTable struct {
+	lock  sync.Mutex
+	locks map[string]*davLock
+	now   func() time.Time
+}
+
+func makeLockTable() *lockTable {
+	return &lockTable{locks: make(map[string]*davLock), now: time.Now}
+}
+
+func newLockToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return fmt.Sprintf("%s%x-%x-%x-%x-%x", lockTokenPrefix, b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// Forgets the expired locks. The lock must be held.
+func (t *lockTable) expire() {
+	now := t.now()
+	for token, l := range t.locks {
+		if now.After(l.expires) {
+			delete(t.locks, token)
+		}
+	}
+}
+
+// Creates a lock on the resource, unless it conflicts with a lock on the
+// resource, on one of its parents or, for a lock in depth, on one of its
+// children. Only the shared locks are compatible.
+func (t *lockTable) create(path string, infinite, exclusive bool, owner string, timeout time.Duration) (*davLock, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.expire()
+	for _, l := range t.locks {
+		if l.covers(path) || (infinite && isUnder(l.path, path)) {
+			if exclusive || l.exclusive {
+				return nil, errLockConflict
+			}
+		}
+	}
+	l := &davLock{
+		token:     newLockToken(),
+		path:      path,
+		infinite:  infinite,
+		exclusive: exclusive,
+		owner:     owner,
+		timeout:   timeout,
+		expires:   t.now().Add(timeout),
+	}
+	t.locks[l.token] = l
+	copied := *l
+	return &copied, nil
+}
+
+// Extends the life of one of the locks applying to <path>
+func (t *lockTable) refresh(path string, tokens []string, timeout time.Duration) (*davLock, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.expire()
+	for _, token := range tokens {
+		if l, ok := t.locks[token]; ok && l.covers(path) {
+			l.timeout = timeout
+			l.expires = t.now().Add(timeout)
+			copied := *l
+			return &copied, nil
+		}
+	}
+	return nil, errNoSuchLock
+}
+
+// Removes the lock, that must apply to <path>
+func (t *lockTable) unlock(path, token string) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.expire()
+	if l, ok := t.locks[token]; ok && l.covers(path) {
+		delete(t.locks, token)
+		return nil
+	}
+	return errNoSuchLock
+}
+
+// Fails with errLocked if a lock applies to the resource, or to one of its
+// children when <infinite>, and its token has not been submitted.
+func (t *lockTable) check(path string, infinite bool, tokens []string) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.expire()
+	for _, l := range t.locks {
+		if !l.covers(path) && !(infinite && isUnder(l.path, path)) {
+			continue
+		}
+		submitted := false
+		for _, token := range tokens {
+			if token == l.token {
+				submitted = true
+				break
+			}
+		}
+		if !submitted {
+			return errLocked
+		}
+	}
+	return nil
+}
+
+// Returns copies of the locks applying to the resource
+func (t *lockTable) discover(path string) []davLock {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.expire()
+	out := make([]davLock, 0)
+	for _, l := range t.locks {
+		if l.covers(path) {
+			out = append(out, *l)
+		}
+	}
+	return out
+}
+
+// Drops the locks on the resource and under it, after its deletion
+func (t *lockTable) drop(path string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for token, l := range t.locks {
+		if l.path == path || isUnder(l.path, path) {
+			delete(t.locks, token)
+		}
+	}
+}
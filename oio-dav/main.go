@@ -0,0 +1,83 @@
+/*
+OpenIO SDS Go client SDK
+Copyright (C) 2017-2018 OpenIO
+
+This library is free software; you can redistribute it and/or
+modify it under the terms of the GNU Lesser General Public
+License as published by the Free Software Foundation; either
+version 3.0 of the License, or (at your option) any later version.
+
+This library is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Lesser General Public License for more details.
+
+You should have received a copy of the GNU Lesser General Public
+License along with this library.
+*/
+
+package main
+
+/*
+Serves the contents of containers with WebDAV, so that they can be mounted
+as network drives. Each root maps a path of the server to a container, or to
+a prefix of the paths in a container.
+*/
+
+import (
+	"flag"
+	oio "github.com/jfsmig/oio-go/sdk"
+	"log"
+	"net"
+	"net/http"
+)
+
+func usage(why string) {
+	log.Println("oio-dav [-policy POLICY] [-tmpdir DIR] IP:PORT MOUNT=oio://NS/ACCOUNT/USER[/TYPE/PREFIX]...")
+	log.Fatal(why)
+}
+
+func main() {
+	policy := flag.String("policy", "", "Storage policy of the new objects")
+	tmpdir := flag.String("tmpdir", "", "Directory of the uploads in progress")
+	verbose := flag.Bool("v", false, "Log each request")
+	flag.Parse()
+	if flag.NArg() < 2 {
+		usage("Missing positional arguments")
+	}
+
+	ipPort := flag.Arg(0)
+	if _, err := net.ResolveTCPAddr("tcp", ipPort); err != nil {
+		usage("Invalid URL format")
+	}
+	roots := make([]*davRoot, 0)
+	mounts := make(map[string]bool)
+	for _, arg := range flag.Args()[1:] {
+		r, err := parseRoot(arg)
+		if err != nil {
+			usage("Invalid root [" + arg + "]: " + err.Error())
+		}
+		if mounts[r.mount] {
+			usage("Duplicated mount point [" + r.mount + "]")
+		}
+		mounts[r.mount] = true
+		roots = append(roots, r)
+	}
+
+	s, err := makeDavServer(roots, oio.MakeDefaultConfig())
+	if err != nil {
+		log.Fatal("SDK error: ", err)
+	}
+	s.policy, s.tmpdir = *policy, *tmpdir
+
+	var handler http.Handler = s
+	if *verbose {
+		handler = http.HandlerFunc(func(rep http.ResponseWriter, req *http.Request) {
+			log.Println(req.Method, req.URL.String())
+			s.ServeHTTP(rep, req)
+		})
+	}
+	if err := http.ListenAndServe(ipPort, handler); err != nil {
+		log.Fatal("HTTP error : ", err)
+	}
+}
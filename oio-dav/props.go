@@ -0,0 +1,159 @@
+/*
+OpenIO SDS Go client SDK
+Copyright (C) 2017-2018 OpenIO
+
+This library is free software; you can redistribute it and/or
+modify it under the terms of the GNU Lesser General Public
+License as published by the Free Software Foundation; either
+version 3.0 of the License, or (at your option) any later version.
+
+This library is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Lesser General Public License for more details.
+
+You should have received a copy of the GNU Lesser General Public
+License along with this library.
+*/
+
+package main
+
+import (
+	"encoding/xml"
+	oio "github.com/jfsmig/oio-go/sdk"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+)
+
+func davName(local string) xml.Name { return xml.Name{Space: davNS, Local: local} }
+
+// Computes the live properties of the resource. The header is nil for the
+// collections.
+func (s *davServer) liveProps(r *resource, hdr *oio.ContentHeader) []propValue {
+	display := path.Base(r.path)
+	out := []propValue{{davName("displayname"), escapeXml(display)}}
+	if hdr == nil {
+		out = append(out, propValue{davName("resourcetype"), "<D:collection/>"})
+	} else {
+		mtime := time.Unix(int64(hdr.CTime), 0).UTC()
+		out = append(out,
+			propValue{davName("resourcetype"), ""},
+			propValue{davName("getcontentlength"), strconv.FormatUint(hdr.Size, 10)},
+			propValue{davName("getcontenttype"), escapeXml(contentType(hdr))},
+			propValue{davName("getetag"), escapeXml(etag(hdr))},
+			propValue{davName("getlastmodified"), mtime.Format(http.TimeFormat)},
+			propValue{davName("creationdate"), mtime.Format(time.RFC3339)})
+	}
+	return append(out,
+		propValue{davName("supportedlock"), supportedLocks},
+		propValue{davName("lockdiscovery"), activeLocks(s.locks.discover(r.path), escapePath)})
+}
+
+// Adds the response about the resource, as requested in the PROPFIND
+func (s *davServer) describe(ms *multistatus, body *propfindBody, r *resource, hdr *oio.ContentHeader) {
+	live := s.liveProps(r, hdr)
+	href := r.href(hdr == nil)
+	switch {
+	case body.PropName != nil:
+		for i := range live {
+			live[i].inner = ""
+		}
+		ms.response(href, live, nil, nil)
+	case body.AllProp != nil:
+		ms.response(href, live, nil, nil)
+	default:
+		found, missing := make([]propValue, 0), make([]xml.Name, 0)
+		for _, n := range body.Prop {
+			ok := false
+			for _, p := range live {
+				if p.name == n {
+					found, ok = append(found, p), true
+					break
+				}
+			}
+			if !ok {
+				missing = append(missing, n)
+			}
+		}
+		ms.response(href, found, missing, nil)
+	}
+}
+
+// PROPFIND on a resource and, with a depth of 1, on the members of a
+// collection. An infinite depth would walk the whole container, it is
+// refused.
+func (s *davServer) propfind(rep http.ResponseWriter, req *http.Request, r *resource) error {
+	depth := req.Header.Get("Depth")
+	if depth != "0" && depth != "1" {
+		return statusError(http.StatusForbidden)
+	}
+	var body propfindBody
+	if ok, err := decodeBody(req, &body); err != nil {
+		return statusError(http.StatusBadRequest)
+	} else if !ok {
+		body.AllProp = &struct{}{}
+	}
+
+	kind, hdr, err := s.stat(r)
+	if err != nil {
+		return err
+	}
+	ms := makeMultistatus()
+	switch kind {
+	case kindNone:
+		return statusError(http.StatusNotFound)
+	case kindFile:
+		s.describe(ms, &body, r, &hdr)
+	case kindDir:
+		s.describe(ms, &body, r, nil)
+		if depth == "1" {
+			dir := r.dirPath()
+			err = s.walk(r.container(), dir, "/", func(name string, hdr *oio.ContentHeader) error {
+				if name == dir {
+					return nil
+				}
+				child := name[len(dir):]
+				if hdr == nil {
+					child = child[:len(child)-1]
+				}
+				s.describe(ms, &body, r.child(child), hdr)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	ms.reply(rep)
+	return nil
+}
+
+// The dead properties are not managed, each of them is refused
+func (s *davServer) proppatch(rep http.ResponseWriter, req *http.Request, r *resource) error {
+	if err := s.checkLocks(req, r.path, false); err != nil {
+		return err
+	}
+	var body proppatchBody
+	if ok, err := decodeBody(req, &body); err != nil || !ok {
+		return statusError(http.StatusBadRequest)
+	}
+	kind, _, err := s.stat(r)
+	if err != nil {
+		return err
+	}
+	if kind == kindNone {
+		return statusError(http.StatusNotFound)
+	}
+	denied := make([]xml.Name, 0)
+	for _, group := range [][]propNames{body.Set, body.Remove} {
+		for _, names := range group {
+			denied = append(denied, names...)
+		}
+	}
+	ms := makeMultistatus()
+	ms.response(r.href(kind == kindDir), nil, nil, denied)
+	ms.reply(rep)
+	return nil
+}
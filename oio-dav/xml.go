@@ -0,0 +1,209 @@
+/*
+OpenIO SDS Go client SDK
+Copyright (C) 2017-2018 OpenIO
+
+This library is free software; you can redistribute it and/or
+modify it under the terms of the GNU Lesser General Public
+License as published by the Free Software Foundation; either
+version 3.0 of the License, or (at your option) any later version.
+
+This library is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Lesser General Public License for more details.
+
+You should have received a copy of the GNU Lesser General Public
+License along with this library.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// The XML bodies are written by hand, with the "D:" prefix for the DAV:
+// namespace, since some clients do not accept the namespaces declared on
+// each element by encoding/xml.
+
+const davNS = "DAV:"
+
+func escapeXml(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// The names of the properties listed in a PROPFIND or a PROPPATCH
+type propNames []xml.Name
+
+func (p *propNames) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			*p = append(*p, t.Name)
+			if err = d.Skip(); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+type propfindBody struct {
+	XMLName  xml.Name  `xml:"DAV: propfind"`
+	AllProp  *struct{} `xml:"DAV: allprop"`
+	PropName *struct{} `xml:"DAV: propname"`
+	Prop     propNames `xml:"DAV: prop"`
+}
+
+type proppatchBody struct {
+	XMLName xml.Name    `xml:"DAV: propertyupdate"`
+	Set     []propNames `xml:"DAV: set>prop"`
+	Remove  []propNames `xml:"DAV: remove>prop"`
+}
+
+type lockOwner struct {
+	Inner string `xml:",innerxml"`
+}
+
+type lockinfoBody struct {
+	XMLName   xml.Name   `xml:"DAV: lockinfo"`
+	Exclusive *struct{}  `xml:"DAV: lockscope>exclusive"`
+	Shared    *struct{}  `xml:"DAV: lockscope>shared"`
+	Write     *struct{}  `xml:"DAV: locktype>write"`
+	Owner     *lockOwner `xml:"DAV: owner"`
+}
+
+// Decodes the optional XML body of the request. Returns false when the body
+// is empty.
+func decodeBody(req *http.Request, v interface{}) (bool, error) {
+	var b bytes.Buffer
+	if _, err := io.Copy(&b, io.LimitReader(req.Body, 1024*1024)); err != nil {
+		return false, err
+	}
+	if len(bytes.TrimSpace(b.Bytes())) <= 0 {
+		return false, nil
+	}
+	return true, xml.Unmarshal(b.Bytes(), v)
+}
+
+// Formats an empty element, for the names of the properties
+func emptyElement(n xml.Name) string {
+	if n.Space == davNS {
+		return "<D:" + n.Local + "/>"
+	}
+	return fmt.Sprintf("<x:%s xmlns:x=\"%s\"/>", n.Local, escapeXml(n.Space))
+}
+
+// A property with its value, already formatted
+type propValue struct {
+	name  xml.Name
+	inner string
+}
+
+func (p propValue) String() string {
+	if len(p.inner) <= 0 {
+		return emptyElement(p.name)
+	}
+	return "<D:" + p.name.Local + ">" + p.inner + "</D:" + p.name.Local + ">"
+}
+
+func statusLine(code int) string {
+	return "HTTP/1.1 " + strconv.Itoa(code) + " " + http.StatusText(code)
+}
+
+// Builds the body of a 207 Multi-Status reply
+type multistatus struct {
+	b bytes.Buffer
+}
+
+func makeMultistatus() *multistatus {
+	m := &multistatus{}
+	m.b.WriteString(xml.Header)
+	m.b.WriteString("<D:multistatus xmlns:D=\"DAV:\">")
+	return m
+}
+
+func (m *multistatus) propstat(props []string, code int) {
+	if len(props) <= 0 {
+		return
+	}
+	m.b.WriteString("<D:propstat><D:prop>")
+	m.b.WriteString(strings.Join(props, ""))
+	m.b.WriteString("</D:prop><D:status>" + statusLine(code) + "</D:status></D:propstat>")
+}
+
+// Adds the properties found for the resource, and the names of those missing
+func (m *multistatus) response(href string, found []propValue, missing []xml.Name, denied []xml.Name) {
+	m.b.WriteString("<D:response><D:href>" + escapeXml(href) + "</D:href>")
+	tab := make([]string, 0, len(found))
+	for _, p := range found {
+		tab = append(tab, p.String())
+	}
+	m.propstat(tab, http.StatusOK)
+	for _, group := range []struct {
+		names []xml.Name
+		code  int
+	}{{missing, http.StatusNotFound}, {denied, http.StatusForbidden}} {
+		tab = tab[:0]
+		for _, n := range group.names {
+			tab = append(tab, emptyElement(n))
+		}
+		m.propstat(tab, group.code)
+	}
+	m.b.WriteString("</D:response>")
+}
+
+// Adds the status of an operation on the resource
+func (m *multistatus) status(href string, code int) {
+	m.b.WriteString("<D:response><D:href>" + escapeXml(href) + "</D:href>")
+	m.b.WriteString("<D:status>" + statusLine(code) + "</D:status></D:response>")
+}
+
+func (m *multistatus) reply(rep http.ResponseWriter) {
+	m.b.WriteString("</D:multistatus>")
+	rep.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	rep.Header().Set("Content-Length", strconv.Itoa(m.b.Len()))
+	rep.WriteHeader(http.StatusMultiStatus)
+	rep.Write(m.b.Bytes())
+}
+
+// Formats the lockdiscovery property
+func activeLocks(locks []davLock, href func(path string) string) string {
+	var b strings.Builder
+	for _, l := range locks {
+		scope, depth := "shared", "0"
+		if l.exclusive {
+			scope = "exclusive"
+		}
+		if l.infinite {
+			depth = "infinity"
+		}
+		b.WriteString("<D:activelock><D:locktype><D:write/></D:locktype>")
+		b.WriteString("<D:lockscope><D:" + scope + "/></D:lockscope>")
+		b.WriteString("<D:depth>" + depth + "</D:depth>")
+		if len(l.owner) > 0 {
+			b.WriteString("<D:owner>" + l.owner + "</D:owner>")
+		}
+		b.WriteString("<D:timeout>Second-" + strconv.Itoa(int(l.timeout.Seconds())) + "</D:timeout>")
+		b.WriteString("<D:locktoken><D:href>" + escapeXml(l.token) + "</D:href></D:locktoken>")
+		b.WriteString("<D:lockroot><D:href>" + escapeXml(href(l.path)) + "</D:href></D:lockroot>")
+		b.WriteString("</D:activelock>")
+	}
+	return b.String()
+}
+
+const supportedLocks = "<D:lockentry><D:lockscope><D:exclusive/></D:lockscope><D:locktype><D:write/></D:locktype></D:lockentry>" +
+	"<D:lockentry><D:lockscope><D:shared/></D:lockscope><D:locktype><D:write/></D:locktype></D:lockentry>"
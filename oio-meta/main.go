@@ -0,0 +1,111 @@
+/*
+OpenIO SDS Go client SDK
+Copyright (C) 2017-2018 OpenIO
+
+This library is free software; you can redistribute it and/or
+modify it under the terms of the GNU Lesser General Public
+License as published by the Free Software Foundation; either
+version 3.0 of the License, or (at your option) any later version.
+
+This library is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Lesser General Public License for more details.
+
+You should have received a copy of the GNU Lesser General Public
+License along with this library.
+*/
+
+package main
+
+/*
+Replaces the meta services of a namespace on a single node: it serves the
+proxy API used by the SDK, keeps the references, containers and contents in a
+local database file with the journal of its changes, and places the chunks on
+the configured rawx services according to their score.
+*/
+
+import (
+	"flag"
+	"github.com/jfsmig/oio-go/metadb"
+	oio "github.com/jfsmig/oio-go/sdk"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func usage(why string) {
+	log.Println("oio-meta -db FILE -rawx IP:PORT,... [-chunk-size N] [-compact-period D] NS IP:PORT")
+	log.Fatal(why)
+}
+
+func main() {
+	db := flag.String("db", "", "Database file of the references, containers and contents (mandatory)")
+	rawx := flag.String("rawx", "", "Comma-separated addresses of the rawx services (mandatory)")
+	chunkSize := flag.Uint64("chunk-size", 0, "Maximum size of the chunks")
+	period := flag.Duration("score-period", 5*time.Second, "Period of the probes of the rawx services")
+	versioning := flag.Bool("versioning", false, "Keep the old versions of the contents")
+	compact := flag.Duration("compact-period", 10*time.Minute, "Period of the folding of the journal into the database file")
+	flag.Parse()
+	if flag.NArg() != 2 {
+		usage("Missing positional arguments")
+	}
+
+	ns := flag.Arg(0)
+	if !oio.IsValidNamespace(ns) {
+		usage("Invalid namespace format")
+	}
+	ipPort := flag.Arg(1)
+	if _, err := net.ResolveTCPAddr("tcp", ipPort); err != nil {
+		usage("Invalid URL format")
+	}
+	if len(*db) <= 0 {
+		usage("No database file")
+	}
+	addrs := make([]string, 0)
+	for _, addr := range strings.Split(*rawx, ",") {
+		if addr = strings.TrimSpace(addr); len(addr) > 0 {
+			addrs = append(addrs, addr)
+		}
+	}
+	if len(addrs) <= 0 {
+		usage("No rawx service")
+	}
+
+	// The containers are all served by this process
+	d := metadb.MakeDirectory(ns)
+	d.SetAllocator(metadb.StaticServices(map[string][]string{"meta2": {ipPort}}))
+	c := metadb.MakeContainer(ns, d)
+	c.SetVersioning(*versioning)
+	if *chunkSize > 0 {
+		c.SetChunkSize(*chunkSize)
+	}
+	journal, err := metadb.OpenJournal(*db, d, c)
+	if err != nil {
+		log.Fatal("Database not loaded: ", err)
+	}
+	go func() {
+		for range time.Tick(*compact) {
+			if journal.Pending() <= 0 {
+				continue
+			}
+			if err := journal.Compact(); err != nil {
+				log.Println("Journal not compacted: ", err)
+			}
+		}
+	}()
+
+	s := makeScorer(addrs, time.Second)
+	s.refresh()
+	go s.run(*period, nil)
+	c.SetRawxSelector(s.selectRawx)
+
+	server := metadb.MakeServer(ns, d, c)
+	server.Rawx = s.services
+
+	if err := http.ListenAndServe(ipPort, server); err != nil {
+		log.Fatal("HTTP error : ", err)
+	}
+}
@@ -0,0 +1,196 @@
+/*
+OpenIO SDS Go client SDK
+Copyright (C) 2017-2018 OpenIO
+
+This library is free software; you can redistribute it and/or
+modify it under the terms of the GNU Lesser General Public
+License as published by the Free Software Foundation; either
+version 3.0 of the License, or (at your option) any later version.
+
+This library is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Lesser General Public License for more details.
+
+You should have received a copy of the GNU Lesser General Public
+License along with this library.
+*/
+
+package main
+
+import (
+	"github.com/jfsmig/oio-go/metadb"
+	oio "github.com/jfsmig/oio-go/sdk"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	scoreMax = 100
+
+	// The mean time of a request (in microseconds) and the latency of the
+	// /stat probe that halve the score.
+	halfScoreRequestTime = 100000
+	halfScoreLatency     = 100 * time.Millisecond
+)
+
+type rawxScore struct {
+	addr  string
+	score int
+
+	// The counters at the previous probe, to score on the last period only
+	prev map[string]uint64
+}
+
+// Scores the rawx services from the counters of their /stat, and picks the
+// chunks locations among them with a probability proportional to the score.
+type scorer struct {
+	client http.Client
+	lock   sync.Mutex
+	rawx   []*rawxScore
+	rand   *rand.Rand
+}
+
+func makeScorer(addrs []string, timeout time.Duration) *scorer {
+	dial := func(network, addr string) (net.Conn, error) {
+		return net.DialTimeout(network, addr, timeout)
+	}
+	s := &scorer{
+		client: http.Client{Transport: &http.Transport{Dial: dial}, Timeout: timeout},
+		rawx:   make([]*rawxScore, 0, len(addrs)),
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for _, addr := range addrs {
+		s.rawx = append(s.rawx, &rawxScore{addr: addr})
+	}
+	return s
+}
+
+// Computes the score of a rawx from the increase of its counters since the
+// previous probe, and the latency of the probe. The score of an answering
+// rawx is never 0, so that it can still be chosen when the others are down.
+func computeScore(cur, prev map[string]uint64, latency time.Duration) int {
+	delta := func(k string) uint64 {
+		if cur[k] < prev[k] {
+			// The service restarted
+			return cur[k]
+		}
+		return cur[k] - prev[k]
+	}
+
+	score := float64(scoreMax)
+	if hits := delta("counter.rep.hits"); hits > 0 {
+		errors := delta("counter.rep.hits.5xx")
+		if errors > hits {
+			errors = hits
+		}
+		score *= 1 - float64(errors)/float64(hits)
+		mean := float64(delta("counter.rep.time")) / float64(hits)
+		score *= halfScoreRequestTime / (halfScoreRequestTime + mean)
+	}
+	score *= float64(halfScoreLatency) / float64(halfScoreLatency+latency)
+	if score < 1 {
+		return 1
+	}
+	return int(score)
+}
+
+func (s *scorer) probe(r *rawxScore) {
+	pre := time.Now()
+	score := 0
+	var stats map[string]uint64
+	rep, err := s.client.Get("http://" + r.addr + "/stat")
+	if err == nil {
+		if rep.StatusCode == http.StatusOK {
+			stats, err = oio.ParseRawxStats(rep.Body)
+		}
+		rep.Body.Close()
+	}
+	latency := time.Since(pre)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if stats != nil && err == nil {
+		score = computeScore(stats, r.prev, latency)
+		r.prev = stats
+	}
+	r.score = score
+}
+
+// Probes all the rawx services at once
+func (s *scorer) refresh() {
+	var wg sync.WaitGroup
+	for _, r := range s.rawx {
+		wg.Add(1)
+		go func(r *rawxScore) {
+			defer wg.Done()
+			s.probe(r)
+		}(r)
+	}
+	wg.Wait()
+}
+
+// Probes the rawx services every <period>, until <stop> is closed
+func (s *scorer) run(period time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.refresh()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Picks <count> distinct rawx services among those with a positive score
+func (s *scorer) selectRawx(count int) ([]string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	candidates := make([]*rawxScore, 0, len(s.rawx))
+	total := 0
+	for _, r := range s.rawx {
+		if r.score > 0 {
+			candidates = append(candidates, r)
+			total += r.score
+		}
+	}
+	if len(candidates) < count {
+		return nil, metadb.ErrorNoService
+	}
+
+	out := make([]string, 0, count)
+	for len(out) < count {
+		pick := s.rand.Intn(total)
+		for i, r := range candidates {
+			if pick < r.score {
+				out = append(out, r.addr)
+				total -= r.score
+				candidates = append(candidates[:i], candidates[i+1:]...)
+				break
+			}
+			pick -= r.score
+		}
+	}
+	return out, nil
+}
+
+func (s *scorer) services() []oio.ServiceInfo {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	out := make([]oio.ServiceInfo, 0, len(s.rawx))
+	for _, r := range s.rawx {
+		out = append(out, oio.ServiceInfo{
+			Type:  "rawx",
+			Addr:  r.addr,
+			Score: r.score,
+			Tags:  map[string]interface{}{"tag.up": r.score > 0},
+		})
+	}
+	return out
+}
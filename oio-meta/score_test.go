@@ -0,0 +1,84 @@
+/*
+OpenIO SDS Go client SDK
+Copyright (C) 2017-2018 OpenIO
+
+This library is free software; you can redistribute it and/or
+modify it under the terms of the GNU Lesser General Public
+License as published by the Free Software Foundation; either
+version 3.0 of the License, or (at your option) any later version.
+
+This library is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Lesser General Public License for more details.
+
+You should have received a copy of the GNU Lesser General Public
+License along with this library.
+*/
+
+package main
+
+import (
+	"fmt"
+	"github.com/jfsmig/oio-go/metadb"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScore_compute(t *testing.T) {
+	idle := computeScore(map[string]uint64{}, nil, 0)
+	if idle != scoreMax {
+		t.Fatal("Idle service not perfect: ", idle)
+	}
+	prev := map[string]uint64{"counter.rep.hits": 10, "counter.rep.time": 1000}
+	cur := map[string]uint64{"counter.rep.hits": 20, "counter.rep.hits.5xx": 5,
+		"counter.rep.time": 1000 + 10*halfScoreRequestTime}
+	if s := computeScore(cur, prev, 0); s != scoreMax/4 {
+		t.Fatal("Bad score: ", s)
+	}
+	if s := computeScore(cur, cur, halfScoreLatency); s != scoreMax/2 {
+		t.Fatal("Latency not considered: ", s)
+	}
+	failing := map[string]uint64{"counter.rep.hits": 5, "counter.rep.hits.5xx": 5}
+	if s := computeScore(failing, nil, 0); s != 1 {
+		t.Fatal("Answering service excluded: ", s)
+	}
+}
+
+func TestScore_select(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(rep http.ResponseWriter, req *http.Request) {
+		fmt.Fprintln(rep, "counter.rep.hits 0")
+	}))
+	defer up.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	defer down.Close()
+
+	addrs := []string{
+		strings.TrimPrefix(up.URL, "http://"),
+		strings.TrimPrefix(down.URL, "http://"),
+		"127.0.0.1:1",
+	}
+	s := makeScorer(addrs, time.Second)
+	s.refresh()
+	srv := s.services()
+	if srv[0].Score <= 0 || srv[1].Score != 0 || srv[2].Score != 0 {
+		t.Fatal("Bad scores: ", srv)
+	}
+	if got, err := s.selectRawx(1); err != nil || got[0] != addrs[0] {
+		t.Fatal("Bad selection: ", got, err)
+	}
+	if _, err := s.selectRawx(2); err != metadb.ErrorNoService {
+		t.Fatal("Down service selected")
+	}
+
+	s.rawx[1].score = 50
+	for i := 0; i < 10; i++ {
+		got, err := s.selectRawx(2)
+		if err != nil || got[0] == got[1] {
+			t.Fatal("Bad selection: ", got, err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+/*
+OpenIO SDS Go client SDK
+Copyright (C) 2017-2018 OpenIO
+
+This library is free software; you can redistribute it and/or
+modify it under the terms of the GNU Lesser General Public
+License as published by the Free Software Foundation; either
+version 3.0 of the License, or (at your option) any later version.
+
+This library is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Lesser General Public License for more details.
+
+You should have received a copy of the GNU Lesser General Public
+License along with this library.
+*/
+
+package main
+
+/*
+Serves the proxy API used by the SDK, with the in-memory fakes of the
+oiotest package as the storage, and chunks placed on local rawx services.
+Enough to run the oio-roundtrip on a laptop.
+*/
+
+import (
+	"flag"
+	"github.com/jfsmig/oio-go/oiotest"
+	oio "github.com/jfsmig/oio-go/sdk"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+func usage(why string) {
+	log.Println("oio-proxy [-rawx IP:PORT,...] [-state FILE] [-chunk-size N] NS IP:PORT")
+	log.Fatal(why)
+}
+
+func main() {
+	rawx := flag.String("rawx", "127.0.0.1:6010", "Comma-separated addresses of the rawx services")
+	state := flag.String("state", "", "File where the users and contents are kept across restarts")
+	chunkSize := flag.Uint64("chunk-size", 0, "Maximum size of the chunks")
+	verbose := flag.Bool("v", false, "Log each request")
+	flag.Parse()
+	if flag.NArg() != 2 {
+		usage("Missing positional arguments")
+	}
+
+	ns := flag.Arg(0)
+	if !oio.IsValidNamespace(ns) {
+		usage("Invalid namespace format")
+	}
+	ipPort := flag.Arg(1)
+	if _, err := net.ResolveTCPAddr("tcp", ipPort); err != nil {
+		usage("Invalid URL format")
+	}
+
+	addrs := make([]string, 0)
+	for _, addr := range strings.Split(*rawx, ",") {
+		if addr = strings.TrimSpace(addr); len(addr) > 0 {
+			addrs = append(addrs, addr)
+		}
+	}
+	if len(addrs) <= 0 {
+		usage("No rawx service")
+	}
+
+	d := oiotest.MakeFakeDirectory(ns)
+	c := oiotest.MakeFakeContainer(ns, d)
+	c.SetRawx(addrs)
+	if *chunkSize > 0 {
+		c.SetChunkSize(*chunkSize)
+	}
+	proxy := oiotest.MakeProxy(ns, d, c)
+
+	if len(*state) > 0 {
+		if err := oiotest.LoadState(*state, d, c); err != nil {
+			log.Fatal("State not loaded: ", err)
+		}
+		var lock sync.Mutex
+		proxy.OnChange = func() {
+			lock.Lock()
+			defer lock.Unlock()
+			if err := oiotest.SaveState(*state, d, c); err != nil {
+				log.Println("State not saved: ", err)
+			}
+		}
+	}
+
+	var handler http.Handler = proxy
+	if *verbose {
+		handler = http.HandlerFunc(func(rep http.ResponseWriter, req *http.Request) {
+			log.Println(req.Method, req.URL.String())
+			proxy.ServeHTTP(rep, req)
+		})
+	}
+	if err := http.ListenAndServe(ipPort, handler); err != nil {
+		log.Fatal("HTTP error : ", err)
+	}
+}
@@ -22,13 +22,13 @@ alternative file names, etc.
 */
 
 import (
+	oio "github.com/jfsmig/oio-go/sdk"
 	"os"
 	"strings"
 )
 
 type chunkRepository struct {
-	sub      Repository
-	accepted [32]byte
+	sub Repository
 }
 
 func MakeChunkRepository(sub Repository) *chunkRepository {
@@ -110,7 +110,7 @@ func (self *chunkRepository) Put(name string) (FileWriter, error) {
 // matches
 func (self *chunkRepository) nameToPath(name string) ([]string, error) {
 	name = strings.ToUpper(name)
-	if !isValidString(name, 64) {
+	if !oio.IsHexString(name, 64) {
 		return nil, ErrInvalidChunkName
 	} else {
 		tab := make([]string, 1)
@@ -122,10 +122,11 @@ func (self *chunkRepository) nameToPath(name string) ([]string, error) {
 func (self *chunkRepository) List(marker, prefix string, max int) (ListSlice, error) {
 	out := ListSlice{make([]string, 0), false}
 
-	if len(marker) > 0 && !isValidString(marker, 0) {
+	marker, prefix = strings.ToUpper(marker), strings.ToUpper(prefix)
+	if len(marker) > 0 && !oio.IsHexString(marker, 0) {
 		return out, ErrListMarker
 	}
-	if len(prefix) > 0 && !isValidString(prefix, 0) {
+	if len(prefix) > 0 && !oio.IsHexString(prefix, 0) {
 		return out, ErrListPrefix
 	}
 	return self.sub.List(marker, prefix, max)
@@ -0,0 +1,214 @@
+// OpenIO SDS Go rawx
+// Copyright (C) 2015-2018 OpenIO SAS
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+/*
+Runs a whole namespace in the current process, for the developers and the CI:
+a stand-in proxy and several rawx services, each on a temporary directory and
+a free port of the loopback. The proxy is declared in the configuration file
+of the user for the time the cluster runs.
+*/
+
+import (
+	"flag"
+	"fmt"
+	"github.com/jfsmig/oio-go/metadb"
+	oio "github.com/jfsmig/oio-go/sdk"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+)
+
+type clusterRawx struct {
+	rawxService
+	basedir string
+	server  *http.Server
+}
+
+type cluster struct {
+	ns      string
+	root    string
+	rawx    []*clusterRawx
+	proxy   *http.Server
+	proxyIP string
+
+	// The configuration file patched, and the value of the proxy it held
+	// before.
+	conf     string
+	oldProxy string
+}
+
+func serveOnLoopback(handler http.Handler) (*http.Server, string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, "", err
+	}
+	srv := &http.Server{Handler: handler}
+	go srv.Serve(l)
+	return srv, l.Addr().String(), nil
+}
+
+// Starts <count> rawx services and a proxy for the namespace, with all the
+// data under <root>. Nothing is left running when an error is returned.
+func startCluster(ns, root string, count int, chunkSize uint64) (*cluster, error) {
+	c := &cluster{ns: ns, root: root, rawx: make([]*clusterRawx, 0)}
+	addrs := make([]string, 0)
+	for i := 0; i < count; i++ {
+		r, err := c.startRawx(i)
+		if err != nil {
+			c.stop()
+			return nil, err
+		}
+		c.rawx = append(c.rawx, r)
+		addrs = append(addrs, r.url)
+	}
+
+	// The metadata only live in memory, the proxy serves all the containers
+	d := metadb.MakeDirectory(ns)
+	mc := metadb.MakeContainer(ns, d)
+	mc.SetRawx(addrs)
+	if chunkSize > 0 {
+		mc.SetChunkSize(chunkSize)
+	}
+	var err error
+	c.proxy, c.proxyIP, err = serveOnLoopback(metadb.MakeServer(ns, d, mc))
+	if err != nil {
+		c.stop()
+		return nil, err
+	}
+	d.SetAllocator(metadb.StaticServices(map[string][]string{"meta2": {c.proxyIP}}))
+	return c, nil
+}
+
+func (c *cluster) startRawx(i int) (*clusterRawx, error) {
+	basedir := filepath.Join(c.root, fmt.Sprintf("rawx-%d", i))
+	if err := os.MkdirAll(basedir, 0755); err != nil {
+		return nil, err
+	}
+	r := &clusterRawx{basedir: basedir}
+	r.ns = c.ns
+	r.logger_access = log.New(os.Stderr, fmt.Sprintf("rawx-%d access ", i), log.LstdFlags)
+	r.logger_error = log.New(os.Stderr, fmt.Sprintf("rawx-%d error ", i), log.LstdFlags)
+
+	// The address must be known before the volume is locked, and the
+	// handler built before the listener serves.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	r.url = l.Addr().String()
+	chunkrepo := MakeChunkRepository(MakeFileRepository(basedir, nil))
+	if err = chunkrepo.Lock(c.ns, r.url); err != nil {
+		l.Close()
+		return nil, err
+	}
+	r.repo = chunkrepo
+	r.server = &http.Server{Handler: makeMux(&r.rawxService)}
+	go r.server.Serve(l)
+	return r, nil
+}
+
+// Declares the proxy of the cluster in the configuration file at <path>,
+// remembering the previous value to restore it at the teardown.
+func (c *cluster) declare(path string) error {
+	prev := oio.MakeStaticConfig()
+	if err := prev.LoadWithFile(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	c.oldProxy, _ = prev.GetString(c.ns, oio.KeyProxy)
+	c.conf = path
+	return oio.UpdateConfigFile(path, c.ns, map[string]string{oio.KeyProxy: c.proxyIP}, nil)
+}
+
+// Stops all the services, restores the configuration and removes the data
+func (c *cluster) stop() {
+	if c.proxy != nil {
+		c.proxy.Close()
+	}
+	for _, r := range c.rawx {
+		r.server.Close()
+	}
+	if len(c.conf) > 0 {
+		var err error
+		if len(c.oldProxy) > 0 {
+			err = oio.UpdateConfigFile(c.conf, c.ns, map[string]string{oio.KeyProxy: c.oldProxy}, nil)
+		} else {
+			err = oio.UpdateConfigFile(c.conf, c.ns, nil, []string{oio.KeyProxy})
+		}
+		if err != nil {
+			log.Println("Configuration not restored: ", err)
+		}
+	}
+	if err := os.RemoveAll(c.root); err != nil {
+		log.Println("Data not removed: ", err)
+	}
+}
+
+func mainCluster(args []string) {
+	fs := flag.NewFlagSet("cluster", flag.ExitOnError)
+	count := fs.Int("n", 3, "Number of rawx services")
+	conf := fs.String("conf", "", "Configuration file to declare the namespace in (default ~/.oio/sds.conf)")
+	chunkSize := fs.Uint64("chunk-size", 0, "Maximum size of the chunks")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage("Missing namespace")
+	}
+	ns := fs.Arg(0)
+	if !checkNamespace(ns) {
+		usage("Invalid namespace Format")
+	}
+	if *count <= 0 {
+		usage("Invalid number of rawx")
+	}
+	if len(*conf) <= 0 {
+		var err error
+		if *conf, err = oio.LocalConfigFile(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	root, err := ioutil.TempDir("", "oio-cluster-"+ns+"-")
+	if err != nil {
+		log.Fatal("Temporary directory error: ", err)
+	}
+	c, err := startCluster(ns, root, *count, *chunkSize)
+	if err != nil {
+		os.RemoveAll(root)
+		log.Fatal("Cluster not started: ", err)
+	}
+	if err = c.declare(*conf); err != nil {
+		c.stop()
+		log.Fatal("Configuration not updated: ", err)
+	}
+
+	log.Printf("Namespace %s declared in %s", ns, *conf)
+	log.Printf("proxy %s", c.proxyIP)
+	for _, r := range c.rawx {
+		log.Printf("rawx %s %s", r.url, r.basedir)
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	<-sig
+	log.Println("Stopping the cluster")
+	c.stop()
+}
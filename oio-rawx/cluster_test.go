@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	oio "github.com/jfsmig/oio-go/sdk"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCluster_replication(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("/tmp", "rawx-test-")
+	if err != nil {
+		t.Fatal("TempDir failure: ", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	c, err := startCluster("NS", filepath.Join(tmpdir, "data"), 3, 0)
+	if err != nil {
+		t.Fatal("Cluster failure: ", err)
+	}
+	conf := filepath.Join(tmpdir, "sds.conf")
+	if err = c.declare(conf); err != nil {
+		c.stop()
+		t.Fatal("Declare failure: ", err)
+	}
+
+	cfg := oio.MakeStaticConfig()
+	if err = cfg.LoadWithFile(conf); err != nil {
+		t.Fatal("Configuration not written: ", err)
+	}
+	cfg.Set("NS", oio.KeyAutocreate, "true")
+	storage, _ := oio.MakeDefaultObjectStorageClient("NS", cfg)
+	n := oio.FlatName{N: "NS", A: "ACCT", U: "JFS", P: "obj"}
+	data := []byte("0123456789")
+	err = storage.PutContentWithPolicy(&n, uint64(len(data)), "THREECOPIES", true, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal("PutContent failure: ", err)
+	}
+	for _, r := range c.rawx {
+		count := 0
+		filepath.Walk(r.basedir, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() && oio.IsHexString(info.Name(), oio.ChunkIdLength) {
+				count++
+			}
+			return nil
+		})
+		if count != 1 {
+			t.Error("Chunk not replicated on ", r.url)
+		}
+	}
+	if in, err := storage.GetContent(&n); err != nil {
+		t.Error("GetContent failure: ", err)
+	} else {
+		got, _ := ioutil.ReadAll(in)
+		in.Close()
+		if !bytes.Equal(got, data) {
+			t.Error("Data mismatch: ", string(got))
+		}
+	}
+
+	c.stop()
+	if _, err = os.Stat(c.root); !os.IsNotExist(err) {
+		t.Error("Data not removed")
+	}
+	cfg = oio.MakeStaticConfig()
+	cfg.LoadWithFile(conf)
+	if _, err = cfg.GetString("NS", oio.KeyProxy); err == nil {
+		t.Error("Configuration not restored")
+	}
+}
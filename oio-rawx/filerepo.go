@@ -18,7 +18,6 @@ package main
 
 import (
 	"bytes"
-	"container/list"
 	"errors"
 	"io/ioutil"
 	"log"
@@ -29,11 +28,12 @@ import (
 )
 
 const (
-	hashWidth    = 3
-	hashDepth    = 1
-	putOpenFlags = os.O_WRONLY | os.O_CREATE | os.O_EXCL
-	putOpenMode  = 0644
-	putMkdirMode = 0755
+	defaultListMax = 1000
+	hashWidth      = 3
+	hashDepth      = 1
+	putOpenFlags   = os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	putOpenMode    = 0644
+	putMkdirMode   = 0755
 )
 
 type NoopNotifier struct{}
@@ -196,7 +196,7 @@ func (r *FileRepository) nameToPathTokens(name string) ([]string, error) {
 	tokens := make([]string, 0, 5)
 	tokens = append(tokens, r.root)
 	for i := 0; i < r.HashDepth; i++ {
-		start := i * r.HashDepth
+		start := i * r.HashWidth
 		tokens = append(tokens, name[start:start+r.HashWidth])
 	}
 
@@ -327,46 +327,56 @@ func (r *RealFileReader) GetAttr(n string) ([]byte, error) {
 	}
 }
 
+// Lists the chunks whose name is greater than <marker> and starts with
+// <prefix>, in the lexicographic order. The hashed directories that cannot
+// hold such names are skipped.
 func (self *FileRepository) List(marker, prefix string, max int) (ListSlice, error) {
 	out := ListSlice{make([]string, 0, 0), false}
-
-	// If both a prefix and a marker are set, if the marker is already
-	// greater than the prefix, no need to continue
-	if len(prefix) > 0 && len(marker) > 0 {
-		if marker > prefix {
-			out.Truncated = true
-			return out, nil
-		}
-	}
-
-	// Compute a path that is long enough to compute a full hashed directory,
-	// that is lexicographically greater than the marker
-	min_length := self.HashWidth * self.HashDepth
-	start := string(marker)
-	if prefix > start {
-		start = string(prefix)
-	}
-	if len(start) < min_length {
-		start = start + strings.Repeat(" ", min_length)
+	if max <= 0 {
+		max = defaultListMax
 	}
 
-	tokens, err := self.nameToPathTokens(start)
-	if err != nil {
-		return out, err
+	// Tells if some names starting with <head> may match
+	candidate := func(head string) bool {
+		if len(marker) >= len(head) && head < marker[:len(head)] {
+			return false
+		}
+		if len(prefix) >= len(head) {
+			return strings.HasPrefix(prefix, head)
+		}
+		return strings.HasPrefix(head, prefix)
 	}
 
-	// Iterate
-
-	stack := list.New()
-	if l0, err := ioutil.ReadDir(self.root); err == nil {
-		for _, item := range l0 {
-			if item.Name() < tokens[0] {
-				continue
+	var walk func(dir, head string, depth int) error
+	walk = func(dir, head string, depth int) error {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, item := range entries {
+			name := item.Name()
+			if depth < self.HashDepth {
+				if !item.IsDir() || len(name) != self.HashWidth || !candidate(head+name) {
+					continue
+				}
+				if err = walk(filepath.Join(dir, name), head+name, depth+1); err != nil {
+					return err
+				}
+			} else if !item.IsDir() && !strings.HasSuffix(name, ".pending") &&
+				name > marker && strings.HasPrefix(name, prefix) {
+				if len(out.Items) >= max {
+					out.Truncated = true
+				} else {
+					out.Items = append(out.Items, name)
+				}
+			}
+			if out.Truncated {
+				return nil
 			}
-			stack.PushFront(item)
 		}
+		return nil
 	}
 
-	// Deduce the starting directory for the fi
-	return out, ErrNotImplemented
+	err := walk(self.root, "", 0)
+	return out, err
 }
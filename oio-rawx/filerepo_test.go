@@ -3,6 +3,7 @@ package main
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -22,3 +23,45 @@ func TestList_empty(t *testing.T) {
 		}
 	}
 }
+
+func TestList_hashed(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("/tmp", "rawx-test-")
+	if err != nil {
+		t.Fatal("TempDir failure: ", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	filerepo := MakeFileRepository(tmpdir, nil)
+	filerepo.HashDepth = 2
+
+	// Each level of the hash takes the next characters of the name
+	path, _ := filerepo.nameToPath("0123456789")
+	if path != filepath.Join(tmpdir, "012", "345", "0123456789") {
+		t.Fatal("Bad hashed path: ", path)
+	}
+
+	names := []string{"000111A", "000111B", "000222A", "111000A", "111000B"}
+	for _, name := range names {
+		path, _ = filerepo.nameToPath(name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		ioutil.WriteFile(path, []byte{}, 0644)
+	}
+	ioutil.WriteFile(filepath.Join(tmpdir, "000", "111", "000111C.pending"), []byte{}, 0644)
+
+	check := func(marker, prefix string, max int, truncated bool, expected ...string) {
+		rc, err := filerepo.List(marker, prefix, max)
+		if err != nil || rc.Truncated != truncated || len(rc.Items) != len(expected) {
+			t.Fatal("List(", marker, prefix, max, ") failure: ", rc, err)
+		}
+		for i, name := range expected {
+			if rc.Items[i] != name {
+				t.Fatal("List(", marker, prefix, max, ") unexpected: ", rc.Items)
+			}
+		}
+	}
+	check("", "", 2, true, "000111A", "000111B")
+	check("000111B", "", 2, true, "000222A", "111000A")
+	check("111000A", "", 2, false, "111000B")
+	check("", "000", 0, false, "000111A", "000111B", "000222A")
+	check("000111A", "0001", 0, false, "000111B")
+	check("", "2", 0, false)
+}
@@ -82,7 +82,9 @@ func putData(out io.Writer, ul *upload) error {
 			chunkHash.Write(buf[:n])
 			remaining = remaining - int64(n)
 		}
-		if err != nil {
+		if err == io.EOF && remaining > 0 {
+			return io.ErrUnexpectedEOF
+		} else if err != nil && err != io.EOF {
 			return err
 		}
 	}
@@ -155,7 +157,7 @@ func uploadChunk(rr *rawxRequest, chunkid string) {
 	}
 
 	// Finish with the XATTR management
-	if err != nil {
+	if err == nil {
 		err = putFinish(rr, out, ul.h)
 	}
 
@@ -170,21 +172,28 @@ func uploadChunk(rr *rawxRequest, chunkid string) {
 	}
 }
 
-func checkChunk(rr *rawxRequest, chunkid string) {
-	in, err := rr.rawx.repo.Get(chunkid)
-	if in != nil {
-		defer in.Close()
+// Returns the attributes of the chunk as headers, with the names they are
+// uploaded with.
+func setAttrHeaders(rr *rawxRequest, in FileReader) {
+	for _, pair := range AttrMap {
+		if v, err := in.GetAttr(AttrPrefix + pair.attr); err == nil {
+			rr.rep.Header().Set(HeaderPrefix+pair.header, string(v))
+		}
 	}
+}
 
-	length := in.Size()
-	rr.rep.Header().Set("Content-Length", fmt.Sprintf("%v", length))
-	rr.rep.Header().Set("Accept-Ranges", "bytes")
-
+func checkChunk(rr *rawxRequest, chunkid string) {
+	in, err := rr.rawx.repo.Get(chunkid)
 	if err != nil {
 		rr.replyError(err)
-	} else {
-		rr.replyCode(http.StatusNoContent)
+		return
 	}
+	defer in.Close()
+
+	setAttrHeaders(rr, in)
+	rr.rep.Header().Set("Content-Length", fmt.Sprintf("%v", in.Size()))
+	rr.rep.Header().Set("Accept-Ranges", "bytes")
+	rr.replyCode(http.StatusOK)
 }
 
 func downloadChunk(rr *rawxRequest, chunkid string) {
@@ -203,7 +212,7 @@ func downloadChunk(rr *rawxRequest, chunkid string) {
 		var nb int
 		var last int64
 		nb, err := fmt.Fscanf(strings.NewReader(hdr_range), "bytes=%d-%d", &offset, &last)
-		if err != nil || nb != 2 || last <= offset {
+		if err != nil || nb != 2 || last < offset {
 			rr.replyError(ErrInvalidRange)
 			return
 		}
@@ -219,12 +228,11 @@ func downloadChunk(rr *rawxRequest, chunkid string) {
 	var in io.ReadCloser
 	v, err = inChunk.GetAttr(AttrPrefix + AttrNameCompression)
 	if err != nil {
+		err = nil
 		if has_range() && offset > 0 {
 			err = inChunk.Seek(offset)
-		} else {
-			in = ioutil.NopCloser(inChunk)
-			err = nil
 		}
+		in = ioutil.NopCloser(inChunk)
 	} else if bytes.Equal(v, AttrValueZLib) {
 		//in, err = zlib.NewReader(in)
 		// TODO(jfs): manage the Range offset
@@ -247,12 +255,7 @@ func downloadChunk(rr *rawxRequest, chunkid string) {
 		in = &limitedReader{sub: in, remaining: size}
 	}
 
-	for _, pair := range AttrMap {
-		v, err := inChunk.GetAttr(AttrPrefix + pair.attr)
-		if err != nil {
-			rr.rep.Header().Set(pair.header, string(v))
-		}
-	}
+	setAttrHeaders(rr, inChunk)
 
 	if has_range() {
 		rr.rep.Header().Set("Content-Length", fmt.Sprintf("%v", size))
@@ -262,7 +265,11 @@ func downloadChunk(rr *rawxRequest, chunkid string) {
 	}
 
 	// Now transmit the clear data to the client
-	rr.replyCode(http.StatusOK)
+	if has_range() {
+		rr.replyCode(http.StatusPartialContent)
+	} else {
+		rr.replyCode(http.StatusOK)
+	}
 	buf := make([]byte, bufSize)
 	for {
 		n, err := in.Read(buf)
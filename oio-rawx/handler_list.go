@@ -0,0 +1,77 @@
+// OpenIO SDS Go rawx
+// Copyright (C) 2015-2018 OpenIO SAS
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type listHandler struct {
+	rawx *rawxService
+}
+
+// Replies the ids of the chunks, one per line, and tells in a header if the
+// listing stopped before the end. The next page starts after the last id.
+func listChunks(rr *rawxRequest) {
+	q := rr.req.URL.Query()
+	max := 0
+	if v := q.Get("max"); len(v) > 0 {
+		var err error
+		if max, err = strconv.Atoi(v); err != nil || max < 0 {
+			rr.replyCode(http.StatusBadRequest)
+			return
+		}
+	}
+	marker := strings.ToUpper(q.Get("marker"))
+	prefix := strings.ToUpper(q.Get("prefix"))
+
+	l, err := rr.rawx.repo.List(marker, prefix, max)
+	if err != nil {
+		switch err {
+		case ErrListMarker, ErrListPrefix:
+			setError(rr.rep, err)
+			rr.replyCode(http.StatusBadRequest)
+		default:
+			rr.replyError(err)
+		}
+		return
+	}
+
+	body := strings.Join(l.Items, "\n")
+	if len(body) > 0 {
+		body = body + "\n"
+	}
+	rr.rep.Header().Set(HeaderPrefix+"List-Truncated", strconv.FormatBool(l.Truncated))
+	rr.rep.Header().Set("Content-Type", "text/plain")
+	rr.replyCode(http.StatusOK)
+	rr.bytes_out = uint64(len(body))
+	rr.rep.Write([]byte(body))
+}
+
+func (self *listHandler) ServeHTTP(rep http.ResponseWriter, req *http.Request) {
+	self.rawx.serveHTTP(rep, req, func(rr *rawxRequest) {
+		rr.stats_time = TimeList
+		rr.stats_hits = HitsList
+		if req.Method == "GET" {
+			listChunks(rr)
+		} else {
+			rr.replyCode(http.StatusMethodNotAllowed)
+		}
+	})
+}
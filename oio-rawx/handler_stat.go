@@ -83,8 +83,6 @@ type StatSet struct {
 	values [LastStat]uint64
 }
 
-var counters, timers StatSet
-
 func (ss *StatSet) Increment(which int) {
 	ss.lock.Lock()
 	defer ss.lock.Unlock()
@@ -125,8 +123,8 @@ func doCheckStats(rr *rawxRequest) {
 }
 
 func doGetStats(rr *rawxRequest) {
-	allCounters := counters.Get()
-	allTimers := timers.Get()
+	allCounters := rr.rawx.counters.Get()
+	allTimers := rr.rawx.timers.Get()
 
 	rr.replyCode(http.StatusOK)
 	for i, n := range statNames {
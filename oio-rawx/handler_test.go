@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	oio "github.com/jfsmig/oio-go/sdk"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func startTestRawx(t *testing.T, tmpdir string) (*httptest.Server, string) {
+	srv := httptest.NewUnstartedServer(nil)
+	addr := srv.Listener.Addr().String()
+	repo := MakeChunkRepository(MakeFileRepository(tmpdir, nil))
+	rawx := &rawxService{
+		ns:            "NS",
+		url:           addr,
+		repo:          repo,
+		logger_access: log.New(ioutil.Discard, "", 0),
+		logger_error:  log.New(ioutil.Discard, "", 0),
+	}
+	srv.Config.Handler = makeMux(rawx)
+	srv.Start()
+	return srv, addr
+}
+
+func TestHandler_chunks(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("/tmp", "rawx-test-")
+	if err != nil {
+		t.Fatal("TempDir failure: ", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	srv, addr := startTestRawx(t, tmpdir)
+	defer srv.Close()
+	cli, _ := oio.MakeRawxClient("NS", oio.MakeStaticConfig())
+
+	data := []byte("0123456789")
+	ids := make([]string, 0)
+	for i := 0; i < 3; i++ {
+		id := oio.GenerateChunkId()
+		url := oio.ChunkUrl{Host: addr, Id: id}.String()
+		meta := oio.ChunkMeta{Position: "0", Size: uint64(len(data)), Policy: "SINGLE",
+			MimeType: "octet/stream", ChunkMethod: "plain/nb_copy=1"}
+		if _, err = cli.PutChunk(url, meta, bytes.NewReader(data)); err != nil {
+			t.Fatal("PutChunk failure: ", err)
+		}
+		ids = append(ids, string(id))
+	}
+	url := oio.ChunkUrl{Host: addr, Id: oio.ChunkId(ids[0])}.String()
+
+	meta, err := cli.HeadChunk(url)
+	if err != nil || meta.Id != oio.ChunkId(ids[0]) || meta.Size != 10 || meta.Policy != "SINGLE" || len(meta.Hash) != 32 {
+		t.Fatal("HeadChunk failure: ", meta, err)
+	}
+	in, meta, err := cli.GetChunk(url, 2, 3)
+	if err != nil || meta.Position != "0" {
+		t.Fatal("GetChunk failure: ", err)
+	}
+	got, _ := ioutil.ReadAll(in)
+	in.Close()
+	if string(got) != "234" {
+		t.Fatal("Bad range: ", string(got))
+	}
+	if in, _, err = cli.GetChunk(url, 7, 0); err == nil {
+		got, _ = ioutil.ReadAll(in)
+		in.Close()
+	}
+	if string(got) != "789" {
+		t.Fatal("Bad tail: ", string(got), err)
+	}
+
+	l, err := cli.ListChunks(addr, "", "", 2)
+	if err != nil || len(l.Ids) != 2 || !l.Truncated {
+		t.Fatal("ListChunks failure: ", l, err)
+	}
+	l, err = cli.ListChunks(addr, string(l.Ids[1]), "", 2)
+	if err != nil || len(l.Ids) != 1 || l.Truncated {
+		t.Fatal("ListChunks failure: ", l, err)
+	}
+	l, _ = cli.ListChunks(addr, "", strings.ToLower(ids[2][:8]), 0)
+	if len(l.Ids) != 1 || string(l.Ids[0]) != ids[2] {
+		t.Fatal("Prefix ignored: ", l)
+	}
+
+	if err = cli.DeleteChunk(url); err != nil {
+		t.Fatal("DeleteChunk failure: ", err)
+	}
+	if _, err = cli.HeadChunk(url); err != oio.ErrorNotFound {
+		t.Fatal("Deleted chunk found: ", err)
+	}
+	if stats, err := cli.Stat(addr); err != nil || stats["counter.rep.hits.del"] != 1 {
+		t.Fatal("Stat failure: ", stats, err)
+	}
+
+	// The counters are those of the rawx asked, not of the process
+	srv2, addr2 := startTestRawx(t, tmpdir)
+	defer srv2.Close()
+	if stats, err := cli.Stat(addr2); err != nil || stats["counter.rep.hits.del"] != 0 {
+		t.Fatal("Counters shared between services: ", stats, err)
+	}
+}
+
+// Checks the server side of the protocol, without the SDK client
+func TestHandler_protocol(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("/tmp", "rawx-test-")
+	if err != nil {
+		t.Fatal("TempDir failure: ", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	srv, addr := startTestRawx(t, tmpdir)
+	defer srv.Close()
+
+	do := func(method, url, rng string, hdr map[string]string, body string) (*http.Response, string) {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		if rng != "" {
+			req.Header.Set("Range", rng)
+		}
+		for k, v := range hdr {
+			req.Header.Set(HeaderPrefix+k, v)
+		}
+		rep, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(method, " failure: ", err)
+		}
+		defer rep.Body.Close()
+		got, _ := ioutil.ReadAll(rep.Body)
+		return rep, string(got)
+	}
+
+	id := string(oio.GenerateChunkId())
+	url := "http://" + addr + "/" + id
+	hdr := map[string]string{
+		"Chunk-Meta-Content-Storage-Policy": "SINGLE",
+		"Chunk-Meta-Content-Mime-Type":      "octet/stream",
+		"Chunk-Meta-Content-Chunk-Method":   "plain/nb_copy=1",
+		"Chunk-Meta-Chunk-Id":               id,
+		"Chunk-Meta-Chunk-Size":             "10",
+		"Chunk-Meta-Chunk-Pos":              "0",
+		"Chunk-Meta-Chunk-Hash":             "00000000000000000000000000000000",
+	}
+
+	// The hash sent is checked once the data received
+	if rep, _ := do("PUT", url, "", hdr, "0123456789"); rep.StatusCode != http.StatusBadRequest {
+		t.Fatal("Bad hash accepted: ", rep.Status)
+	}
+	delete(hdr, "Chunk-Meta-Chunk-Hash")
+	if rep, _ := do("PUT", url, "", hdr, "0123456789"); rep.StatusCode != http.StatusOK {
+		t.Fatal("PUT failure: ", rep.Status)
+	}
+
+	// HEAD replies the attributes of the chunk, the computed hash included
+	rep, _ := do("HEAD", url, "", nil, "")
+	if rep.StatusCode != http.StatusOK || rep.ContentLength != 10 {
+		t.Fatal("HEAD failure: ", rep.Status, rep.ContentLength)
+	}
+	if rep.Header.Get(HeaderPrefix+"Chunk-Meta-Chunk-Id") != id ||
+		rep.Header.Get(HeaderPrefix+"Chunk-Meta-Chunk-Hash") != "781E5E245D69B566979B86E28D23F2C7" {
+		t.Fatal("HEAD attributes: ", rep.Header)
+	}
+
+	// A Range is served with a partial content, down to a single byte
+	rep, got := do("GET", url, "bytes=3-3", nil, "")
+	if rep.StatusCode != http.StatusPartialContent || got != "3" {
+		t.Fatal("Single byte range: ", rep.Status, got)
+	}
+	rep, got = do("GET", url, "", nil, "")
+	if rep.StatusCode != http.StatusOK || got != "0123456789" ||
+		rep.Header.Get(HeaderPrefix+"Chunk-Meta-Chunk-Pos") != "0" {
+		t.Fatal("GET failure: ", rep.Status, got)
+	}
+	if rep, _ = do("GET", url, "bytes=5-4", nil, ""); rep.StatusCode != http.StatusBadRequest {
+		t.Fatal("Inverted range accepted: ", rep.Status)
+	}
+
+	// The listing pages the ids, one per line
+	do("PUT", "http://"+addr+"/"+string(oio.GenerateChunkId()), "", hdr, "0123456789")
+	rep, got = do("GET", "http://"+addr+"/list?max=1", "", nil, "")
+	if rep.StatusCode != http.StatusOK || len(strings.Fields(got)) != 1 ||
+		rep.Header.Get(HeaderPrefix+"List-Truncated") != "true" {
+		t.Fatal("List failure: ", rep.Status, got, rep.Header)
+	}
+	if rep, _ = do("GET", "http://"+addr+"/list?max=x", "", nil, ""); rep.StatusCode != http.StatusBadRequest {
+		t.Fatal("Bad max accepted: ", rep.Status)
+	}
+}
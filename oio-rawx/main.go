@@ -23,16 +23,18 @@ http handler.
 
 import (
 	"flag"
+	oio "github.com/jfsmig/oio-go/sdk"
 	"log"
 	"log/syslog"
 	"net"
 	"net/http"
+	"os"
 	"path/filepath"
-	"regexp"
 )
 
 func usage(why string) {
 	log.Println("rawx NS IP:PORT BASEDIR")
+	log.Println("rawx cluster [-n COUNT] [-conf PATH] NS")
 	log.Fatal(why)
 }
 
@@ -48,11 +50,28 @@ func checkUrl(url string) bool {
 }
 
 func checkNamespace(ns string) bool {
-	ok, _ := regexp.MatchString("[0-9a-zA-Z]+(\\.[0-9a-zA-Z]+)*", ns)
-	return ok
+	return oio.IsValidNamespace(ns)
+}
+
+func makeMux(rawx *rawxService) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/chunk", &chunkHandler{rawx})
+	mux.Handle("/info", &statHandler{rawx})
+	mux.Handle("/stat", &statHandler{rawx})
+	mux.Handle("/list", &listHandler{rawx})
+
+	// Some usages of the RAWX API don't use any prefix when calling
+	// operations on chunks.
+	mux.Handle("/", &chunkHandler{rawx})
+	return mux
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "cluster" {
+		mainCluster(os.Args[2:])
+		return
+	}
+
 	flag.Parse()
 	if flag.NArg() != 3 {
 		usage("Missing positional arguments")
@@ -81,8 +100,15 @@ func main() {
 		usage("Basedir cannot be locked with xattr : " + err.Error())
 	}
 
-	logger_access, _ := syslog.NewLogger(syslog.LOG_INFO|syslog.LOG_LOCAL0, 0)
-	logger_error, _ := syslog.NewLogger(syslog.LOG_INFO|syslog.LOG_LOCAL1, 0)
+	// Without syslog, e.g. on a developer's laptop, log on stderr
+	logger_access, err := syslog.NewLogger(syslog.LOG_INFO|syslog.LOG_LOCAL0, 0)
+	if err != nil {
+		logger_access = log.New(os.Stderr, "access ", log.LstdFlags)
+	}
+	logger_error, err := syslog.NewLogger(syslog.LOG_INFO|syslog.LOG_LOCAL1, 0)
+	if err != nil {
+		logger_error = log.New(os.Stderr, "error ", log.LstdFlags)
+	}
 	rawx := rawxService{
 		ns:            ns,
 		id:            rawxid,
@@ -93,15 +119,7 @@ func main() {
 		logger_error:  logger_error,
 	}
 
-	http.Handle("/chunk", &chunkHandler{&rawx})
-	http.Handle("/info", &statHandler{&rawx})
-	http.Handle("/stat", &statHandler{&rawx})
-
-	// Some usages of the RAWX API don't use any prefix when calling
-	// operations on chunks.
-	http.Handle("/", &chunkHandler{&rawx})
-
-	if err := http.ListenAndServe(rawx.url, nil); err != nil {
+	if err := http.ListenAndServe(rawx.url, makeMux(&rawx)); err != nil {
 		log.Fatal("HTTP error : ", err)
 	}
 }
@@ -40,6 +40,9 @@ type rawxService struct {
 	compress      bool
 	logger_access *log.Logger
 	logger_error  *log.Logger
+	// Each service counts its own requests, several may run in a process
+	counters StatSet
+	timers   StatSet
 }
 
 type rawxRequest struct {
@@ -124,10 +127,10 @@ func (self *rawxService) serveHTTP(rep http.ResponseWriter, req *http.Request, a
 	spent := uint64(time.Since(pre).Nanoseconds() / 1000)
 
 	// Increment counters and log the request
-	counters.Increment(rawxreq.stats_hits)
-	counters.Add(rawxreq.stats_time, spent)
-	counters.Increment(HitsTotal)
-	counters.Add(TimeTotal, spent)
+	self.counters.Increment(rawxreq.stats_hits)
+	self.counters.Add(rawxreq.stats_time, spent)
+	self.counters.Increment(HitsTotal)
+	self.counters.Add(TimeTotal, spent)
 
 	trace := fmt.Sprintf(
 		"%d - INF %s %s %s %d %d %d %s %s",
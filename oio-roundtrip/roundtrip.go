@@ -29,7 +29,7 @@ import (
 
 func main() {
 
-	var ns, acct, user, subtype, path string
+	var ns, acct, user, subtype, path, rawurl string
 	var ok bool
 	var err error
 	var dir oio.Directory
@@ -41,8 +41,17 @@ func main() {
 	flag.StringVar(&user, "user", os.Getenv("OIO_USER"), "User (optional)")
 	flag.StringVar(&path, "path", os.Getenv("OIO_PATH"), "Path (optional)")
 	flag.StringVar(&subtype, "type", os.Getenv("OIO_TYPE"), "Service subtype (optional)")
+	flag.StringVar(&rawurl, "url", os.Getenv("OIO_URL"), "oio:// URL, replaces the other name flags (optional)")
 	flag.Parse()
 
+	if rawurl != "" {
+		parsed, err := oio.ParseName(rawurl)
+		if err != nil {
+			log.Fatal("Invalid URL: ", err)
+		}
+		ns, acct, user, subtype, path = parsed.N, parsed.A, parsed.U, parsed.S, parsed.P
+	}
+
 	if ns == "" {
 		log.Fatal("Namespace is not set")
 	}
@@ -50,7 +59,7 @@ func main() {
 		log.Fatal("Account is not set")
 	}
 
-	name := oio.FlatName{N: ns, A: acct, U: user, P: path}
+	name := oio.FlatName{N: ns, A: acct, U: user, S: subtype, P: path}
 
 	cfg := oio.MakeStaticConfig()
 	if err := cfg.LoadWithLocal(); err != nil {
@@ -0,0 +1,70 @@
+/*
+OpenIO SDS Go client SDK
+Copyright (C) 2017-2018 OpenIO
+
+This library is free software; you can redistribute it and/or
+modify it under the terms of the GNU Lesser General Public
+License as published by the Free Software Foundation; either
+version 3.0 of the License, or (at your option) any later version.
+
+This library is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Lesser General Public License for more details.
+
+You should have received a copy of the GNU Lesser General Public
+License along with this library.
+*/
+
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+)
+
+// An error as reported to the S3 clients
+type s3Error struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *s3Error) Error() string { return e.code + ": " + e.message }
+
+func makeS3Error(status int, code, message string) *s3Error {
+	return &s3Error{status: status, code: code, message: message}
+}
+
+var (
+	errAccessDenied           = makeS3Error(http.StatusForbidden, "AccessDenied", "Access Denied")
+	errAuthorizationMalformed = makeS3Error(http.StatusBadRequest, "AuthorizationHeaderMalformed", "The authorization is malformed")
+	errBadDigest              = makeS3Error(http.StatusBadRequest, "BadDigest", "The Content-MD5 you specified did not match what we received")
+	errBucketAlreadyOwned     = makeS3Error(http.StatusConflict, "BucketAlreadyOwnedByYou", "The bucket already exists and is owned by you")
+	errBucketNotEmpty         = makeS3Error(http.StatusConflict, "BucketNotEmpty", "The bucket you tried to delete is not empty")
+	errContentSha256Mismatch  = makeS3Error(http.StatusBadRequest, "XAmzContentSHA256Mismatch", "The provided x-amz-content-sha256 header does not match what was computed")
+	errExpiredPresigned       = makeS3Error(http.StatusForbidden, "AccessDenied", "Request has expired")
+	errIncompleteBody         = makeS3Error(http.StatusBadRequest, "IncompleteBody", "You did not provide the number of bytes specified by the Content-Length HTTP header")
+	errInternal               = makeS3Error(http.StatusInternalServerError, "InternalError", "We encountered an internal error. Please try again.")
+	errInvalidAccessKeyId     = makeS3Error(http.StatusForbidden, "InvalidAccessKeyId", "The access key Id you provided does not exist in our records")
+	errInvalidArgument        = makeS3Error(http.StatusBadRequest, "InvalidArgument", "Invalid argument")
+	errInvalidBucketName      = makeS3Error(http.StatusBadRequest, "InvalidBucketName", "The specified bucket is not valid")
+	errInvalidDigest          = makeS3Error(http.StatusBadRequest, "InvalidDigest", "The Content-MD5 you specified is not valid")
+	errMethodNotAllowed       = makeS3Error(http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource")
+	errMissingContentLength   = makeS3Error(http.StatusLengthRequired, "MissingContentLength", "You must provide the Content-Length HTTP header")
+	errMissingContentSha256   = makeS3Error(http.StatusBadRequest, "InvalidRequest", "Missing required header for this request: x-amz-content-sha256")
+	errNoSuchBucket           = makeS3Error(http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+	errNoSuchKey              = makeS3Error(http.StatusNotFound, "NoSuchKey", "The specified key does not exist")
+	errNotImplemented         = makeS3Error(http.StatusNotImplemented, "NotImplemented", "A header or a query parameter you provided implies a functionality that is not implemented")
+	errRequestTimeTooSkewed   = makeS3Error(http.StatusForbidden, "RequestTimeTooSkewed", "The difference between the request time and the server's time is too large")
+	errSignatureDoesNotMatch  = makeS3Error(http.StatusForbidden, "SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided")
+	errUnsupportedSignature   = makeS3Error(http.StatusBadRequest, "InvalidRequest", "Only the AWS4-HMAC-SHA256 signatures are supported")
+)
+
+type errorReply struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string   `xml:"Code"`
+	Message   string   `xml:"Message"`
+	Resource  string   `xml:"Resource"`
+	RequestId string   `xml:"RequestId"`
+}
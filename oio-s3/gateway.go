@@ -0,0 +1,561 @@
+/*
+OpenIO SDS Go client SDK
+Copyright (C) 2017-2018 OpenIO
+
+This library is free software; you can redistribute it and/or
+modify it under the terms of the GNU Lesser General Public
+License as published by the Free Software Foundation; either
+version 3.0 of the License, or (at your option) any later version.
+
+This library is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Lesser General Public License for more details.
+
+You should have received a copy of the GNU Lesser General Public
+License along with this library.
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	oio "github.com/jfsmig/oio-go/sdk"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	s3Namespace     = "http://s3.amazonaws.com/doc/2006-03-01/"
+	userMetaPrefix  = "X-Amz-Meta-"
+	defaultMaxKeys  = 1000
+	lastModifiedFmt = "2006-01-02T15:04:05.000Z"
+)
+
+// The sub-resources of the S3 API that are not managed. A request on any of
+// them is refused instead of being mistaken for a plain request.
+var unsupportedSubresources = []string{
+	"accelerate", "acl", "analytics", "attributes", "cors", "delete",
+	"encryption", "inventory", "legal-hold", "lifecycle", "location",
+	"logging", "metrics", "notification", "object-lock", "partNumber",
+	"policy", "replication", "requestPayment", "restore", "retention",
+	"select", "tagging", "torrent", "uploadId", "uploads", "versionId",
+	"versioning", "versions", "website",
+}
+
+// Serves the S3 API with path-style addressing: each bucket is a container
+// of the account, each key the path of a content.
+type gateway struct {
+	ns      string
+	account string
+	policy  string
+	region  string
+	creds   credentials
+	tmpdir  string
+
+	directory oio.Directory
+	container oio.Container
+	storage   oio.ObjectStorage
+	rawx      *oio.RawxClient
+
+	now func() time.Time
+}
+
+type s3Request struct {
+	rep    http.ResponseWriter
+	req    *http.Request
+	id     string
+	bucket string
+	key    string
+	sig    *sigV4
+}
+
+func newRequestId() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return strings.ToUpper(hex.EncodeToString(b))
+}
+
+func (r *s3Request) replyError(err error) {
+	e, ok := err.(*s3Error)
+	if !ok {
+		log.Println("Request", r.id, "failed:", err)
+		e = errInternal
+	}
+	r.rep.Header().Set("Content-Type", "application/xml")
+	r.rep.WriteHeader(e.status)
+	if r.req.Method == "HEAD" {
+		return
+	}
+	r.rep.Write([]byte(xml.Header))
+	xml.NewEncoder(r.rep).Encode(&errorReply{
+		Code:      e.code,
+		Message:   e.message,
+		Resource:  r.req.URL.Path,
+		RequestId: r.id,
+	})
+}
+
+func (r *s3Request) replyXml(v interface{}) {
+	var body bytes.Buffer
+	body.WriteString(xml.Header)
+	if err := xml.NewEncoder(&body).Encode(v); err != nil {
+		r.replyError(err)
+		return
+	}
+	r.rep.Header().Set("Content-Type", "application/xml")
+	r.rep.Header().Set("Content-Length", strconv.Itoa(body.Len()))
+	r.rep.WriteHeader(http.StatusOK)
+	r.rep.Write(body.Bytes())
+}
+
+func (r *s3Request) replyCode(code int) {
+	r.rep.Header().Set("Content-Length", "0")
+	r.rep.WriteHeader(code)
+}
+
+// Tells if the name is accepted by S3 for a new bucket
+func isValidBucketName(name string) bool {
+	if len(name) < 3 || len(name) > 63 {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !('a' <= c && c <= 'z') && !('0' <= c && c <= '9') && c != '-' && c != '.' {
+			return false
+		}
+	}
+	first, last := name[0], name[len(name)-1]
+	return first != '-' && first != '.' && last != '-' && last != '.'
+}
+
+func (g *gateway) containerName(bucket string) *oio.FlatName {
+	return &oio.FlatName{N: g.ns, A: g.account, U: bucket}
+}
+
+func (g *gateway) objectName(bucket, key string) *oio.FlatName {
+	return &oio.FlatName{N: g.ns, A: g.account, U: bucket, P: key}
+}
+
+// Converts an error of the SDK about a content. A missing content may be
+// due to a missing bucket.
+func (g *gateway) keyError(r *s3Request, err error) error {
+	if err != oio.ErrorNotFound {
+		return err
+	}
+	if ok, err := g.container.HasContainer(g.containerName(r.bucket)); err != nil {
+		return err
+	} else if !ok {
+		return errNoSuchBucket
+	}
+	return errNoSuchKey
+}
+
+func (g *gateway) ServeHTTP(rep http.ResponseWriter, req *http.Request) {
+	r := &s3Request{rep: rep, req: req, id: newRequestId()}
+	rep.Header().Set("X-Amz-Request-Id", r.id)
+	rep.Header().Set("Server", "oio-s3")
+
+	sig, err := parseSigV4(req)
+	if err == nil && sig == nil {
+		err = errAccessDenied
+	}
+	if err == nil {
+		err = sig.verify(req, g.creds, g.region, g.now())
+	}
+	if err != nil {
+		r.replyError(err)
+		return
+	}
+	r.sig = sig
+
+	q := req.URL.Query()
+	for _, k := range unsupportedSubresources {
+		if _, ok := q[k]; ok {
+			r.replyError(errNotImplemented)
+			return
+		}
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, "/")
+	if idx := strings.IndexByte(path, '/'); idx >= 0 {
+		r.bucket, r.key = path[:idx], path[idx+1:]
+	} else {
+		r.bucket = path
+	}
+
+	switch {
+	case len(r.bucket) <= 0:
+		// Listing the buckets requires an account service
+		r.replyError(errNotImplemented)
+	case len(r.key) <= 0:
+		g.serveBucket(r)
+	default:
+		g.serveObject(r)
+	}
+}
+
+func (g *gateway) serveBucket(r *s3Request) {
+	var err error
+	switch r.req.Method {
+	case "PUT":
+		err = g.createBucket(r)
+	case "DELETE":
+		err = g.deleteBucket(r)
+	case "HEAD":
+		var ok bool
+		if ok, err = g.container.HasContainer(g.containerName(r.bucket)); err == nil {
+			if ok {
+				r.replyCode(http.StatusOK)
+			} else {
+				err = errNoSuchBucket
+			}
+		}
+	case "GET":
+		err = g.listObjects(r)
+	default:
+		err = errMethodNotAllowed
+	}
+	if err != nil {
+		r.replyError(err)
+	}
+}
+
+func (g *gateway) serveObject(r *s3Request) {
+	var err error
+	switch r.req.Method {
+	case "PUT":
+		err = g.putObject(r)
+	case "GET", "HEAD":
+		err = g.getObject(r)
+	case "DELETE":
+		err = g.storage.DeleteContent(g.objectName(r.bucket, r.key))
+		if err == oio.ErrorNotFound {
+			// Deleting a missing key succeeds, but not in a missing bucket
+			if err = g.keyError(r, err); err == errNoSuchKey {
+				err = nil
+			}
+		}
+		if err == nil {
+			r.replyCode(http.StatusNoContent)
+		}
+	default:
+		err = errMethodNotAllowed
+	}
+	if err != nil {
+		r.replyError(err)
+	}
+}
+
+func (g *gateway) createBucket(r *s3Request) error {
+	if !isValidBucketName(r.bucket) {
+		return errInvalidBucketName
+	}
+	n := g.containerName(r.bucket)
+	if ok, err := g.container.HasContainer(n); err != nil {
+		return err
+	} else if ok {
+		return errBucketAlreadyOwned
+	}
+	if _, err := g.directory.CreateUser(n); err != nil {
+		return err
+	}
+	if _, err := g.container.CreateContainer(n, false); err != nil {
+		return err
+	}
+	r.rep.Header().Set("Location", "/"+r.bucket)
+	r.replyCode(http.StatusOK)
+	return nil
+}
+
+func (g *gateway) deleteBucket(r *s3Request) error {
+	n := g.containerName(r.bucket)
+	l, err := g.container.ListContentsWithParams(n, oio.ListParams{Max: 1, Versions: true})
+	if err == oio.ErrorNotFound {
+		return errNoSuchBucket
+	} else if err != nil {
+		return err
+	}
+	if len(l.Objects) > 0 {
+		return errBucketNotEmpty
+	}
+	if _, err = g.container.DeleteContainer(n); err == oio.ErrorNotFound {
+		return errNoSuchBucket
+	} else if err != nil {
+		return err
+	}
+	r.replyCode(http.StatusNoContent)
+	return nil
+}
+
+type listedObject struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag,omitempty"`
+	Size         uint64 `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type commonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type listBucketResult struct {
+	XMLName               xml.Name       `xml:"ListBucketResult"`
+	Xmlns                 string         `xml:"xmlns,attr"`
+	Name                  string         `xml:"Name"`
+	Prefix                string         `xml:"Prefix"`
+	Delimiter             string         `xml:"Delimiter,omitempty"`
+	MaxKeys               int            `xml:"MaxKeys"`
+	KeyCount              int            `xml:"KeyCount"`
+	IsTruncated           bool           `xml:"IsTruncated"`
+	EncodingType          string         `xml:"EncodingType,omitempty"`
+	ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	StartAfter            string         `xml:"StartAfter,omitempty"`
+	Contents              []listedObject `xml:"Contents"`
+	CommonPrefixes        []commonPrefix `xml:"CommonPrefixes"`
+}
+
+// Formats the MD5 of a content as an S3 ETag
+func etag(hash string) string {
+	if len(hash) <= 0 {
+		return ""
+	}
+	return "\"" + strings.ToLower(hash) + "\""
+}
+
+// ListObjectsV2. The continuation token is the marker of the container
+// listing, so that it resumes after a common prefix as well as after a key.
+func (g *gateway) listObjects(r *s3Request) error {
+	q := r.req.URL.Query()
+	if q.Get("list-type") != "2" {
+		return errNotImplemented
+	}
+	out := listBucketResult{
+		Xmlns:             s3Namespace,
+		Name:              r.bucket,
+		Prefix:            q.Get("prefix"),
+		Delimiter:         q.Get("delimiter"),
+		MaxKeys:           defaultMaxKeys,
+		ContinuationToken: q.Get("continuation-token"),
+		StartAfter:        q.Get("start-after"),
+		Contents:          make([]listedObject, 0),
+		CommonPrefixes:    make([]commonPrefix, 0),
+	}
+	if v := q.Get("max-keys"); len(v) > 0 {
+		max, err := strconv.Atoi(v)
+		if err != nil || max < 0 {
+			return errInvalidArgument
+		}
+		if max < defaultMaxKeys {
+			out.MaxKeys = max
+		}
+	}
+	encode := func(s string) string { return s }
+	if enc := q.Get("encoding-type"); enc == "url" {
+		out.EncodingType = enc
+		encode = url.QueryEscape
+	} else if len(enc) > 0 {
+		return errInvalidArgument
+	}
+
+	params := oio.ListParams{
+		Prefix:    out.Prefix,
+		Delimiter: out.Delimiter,
+		Marker:    out.StartAfter,
+		Max:       out.MaxKeys,
+	}
+	if len(out.ContinuationToken) > 0 {
+		marker, err := base64.StdEncoding.DecodeString(out.ContinuationToken)
+		if err != nil {
+			return errInvalidArgument
+		}
+		params.Marker = string(marker)
+	}
+
+	if out.MaxKeys > 0 {
+		l, err := g.container.ListContentsWithParams(g.containerName(r.bucket), params)
+		if err == oio.ErrorNotFound {
+			return errNoSuchBucket
+		} else if err != nil {
+			return err
+		}
+		last := ""
+		for _, hdr := range l.Objects {
+			if hdr.Deleted {
+				continue
+			}
+			out.Contents = append(out.Contents, listedObject{
+				Key:          encode(hdr.Name),
+				LastModified: time.Unix(int64(hdr.CTime), 0).UTC().Format(lastModifiedFmt),
+				ETag:         etag(hdr.Hash),
+				Size:         hdr.Size,
+				StorageClass: "STANDARD",
+			})
+			if hdr.Name > last {
+				last = hdr.Name
+			}
+		}
+		for _, p := range l.Prefixes {
+			out.CommonPrefixes = append(out.CommonPrefixes, commonPrefix{Prefix: encode(p)})
+			if p > last {
+				last = p
+			}
+		}
+		if l.Truncated {
+			marker := l.NextMarker
+			if len(marker) <= 0 {
+				marker = last
+			}
+			out.IsTruncated = true
+			out.NextContinuationToken = base64.StdEncoding.EncodeToString([]byte(marker))
+		}
+	} else if ok, err := g.container.HasContainer(g.containerName(r.bucket)); err != nil {
+		return err
+	} else if !ok {
+		return errNoSuchBucket
+	}
+
+	out.KeyCount = len(out.Contents) + len(out.CommonPrefixes)
+	out.Prefix, out.Delimiter, out.StartAfter = encode(out.Prefix), encode(out.Delimiter), encode(out.StartAfter)
+	r.replyXml(&out)
+	return nil
+}
+
+// Saves the body in a temporary file, since the SDK needs to know the size
+// and may read it several times, while checking its MD5 and its SHA256.
+func (g *gateway) spool(r *s3Request) (*os.File, []byte, error) {
+	f, err := ioutil.TempFile(g.tmpdir, "oio-s3-")
+	if err != nil {
+		return nil, nil, err
+	}
+	os.Remove(f.Name())
+
+	h5, h256 := md5.New(), sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h5, h256), r.req.Body)
+	if err == nil && n != r.req.ContentLength {
+		err = errIncompleteBody
+	}
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	sum := h5.Sum(nil)
+	if v := r.req.Header.Get("Content-MD5"); len(v) > 0 {
+		expected, err := base64.StdEncoding.DecodeString(v)
+		if err != nil || len(expected) != md5.Size {
+			err = errInvalidDigest
+		} else if !bytes.Equal(expected, sum) {
+			err = errBadDigest
+		}
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+	}
+	if r.sig.payloadHash != unsignedPayload && r.sig.payloadHash != hex.EncodeToString(h256.Sum(nil)) {
+		f.Close()
+		return nil, nil, errContentSha256Mismatch
+	}
+	return f, sum, nil
+}
+
+func (g *gateway) putObject(r *s3Request) error {
+	if len(r.req.Header.Get("X-Amz-Copy-Source")) > 0 {
+		return errNotImplemented
+	}
+	if r.req.ContentLength < 0 {
+		return errMissingContentLength
+	}
+
+	// The user metadata, named as S3 reports them
+	props := make(map[string]string)
+	for k, v := range r.req.Header {
+		if strings.HasPrefix(k, userMetaPrefix) && len(k) > len(userMetaPrefix) {
+			props[strings.ToLower(k[len(userMetaPrefix):])] = strings.Join(v, ",")
+		}
+	}
+
+	f, sum, err := g.spool(r)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	n := g.objectName(r.bucket, r.key)
+	// The metadata are saved with the object, that never exists without them
+	err = g.storage.PutContentWithProperties(n, uint64(r.req.ContentLength), g.policy, props, false, f)
+	if err == oio.ErrorNotFound {
+		return errNoSuchBucket
+	} else if err != nil {
+		return err
+	}
+	r.rep.Header().Set("ETag", etag(hex.EncodeToString(sum)))
+	r.replyCode(http.StatusOK)
+	return nil
+}
+
+// Stands for the data of the contents in the replies to HEAD
+type noData struct{}
+
+func (noData) ReadAt(p []byte, off int64) (int, error) { return 0, io.ErrUnexpectedEOF }
+
+// GetObject and HeadObject. The ranges and the conditional requests are
+// managed by the HTTP server of the standard library.
+func (g *gateway) getObject(r *s3Request) error {
+	n := g.objectName(r.bucket, r.key)
+	var hdr oio.ContentHeader
+	var content io.ReadSeeker
+	var err error
+	if r.req.Method == "HEAD" {
+		if hdr, err = g.container.StatContent(n); err == nil {
+			content = io.NewSectionReader(noData{}, 0, int64(hdr.Size))
+		}
+	} else {
+		var cr *oio.ContentReader
+		if cr, err = oio.OpenContent(g.container, g.rawx, n); err == nil {
+			defer cr.Close()
+			hdr, content = cr.Header(), cr
+		}
+	}
+	if err != nil {
+		return g.keyError(r, err)
+	}
+	props, err := g.container.GetContentProperties(n)
+	if err != nil {
+		return g.keyError(r, err)
+	}
+
+	h := r.rep.Header()
+	for k, v := range props {
+		h.Set(userMetaPrefix+k, v)
+	}
+	if v := etag(hdr.Hash); len(v) > 0 {
+		h.Set("ETag", v)
+	}
+	mimeType := hdr.MimeType
+	if len(mimeType) <= 0 {
+		mimeType = "application/octet-stream"
+	}
+	h.Set("Content-Type", mimeType)
+	http.ServeContent(r.rep, r.req, "", time.Unix(int64(hdr.CTime), 0), content)
+	return nil
+}
@@ -0,0 +1,277 @@
+/*
+OpenIO SDS Go client SDK
+Copyright (C) 2017-2018 OpenIO
+
+This library is free software; you can redistribute it and/or
+modify it under the terms of the GNU Lesser General Public
+License as published by the Free Software Foundation; either
+version 3.0 of the License, or (at your option) any later version.
+
+This library is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Lesser General Public License for more details.
+
+You should have received a copy of the GNU Lesser General Public
+License along with this library.
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"github.com/jfsmig/oio-go/oiotest"
+	oio "github.com/jfsmig/oio-go/sdk"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testGateway struct {
+	t   *testing.T
+	ns  *oiotest.Namespace
+	srv *httptest.Server
+}
+
+func startGateway(t *testing.T) *testGateway {
+	ns := oiotest.StartNamespace("NS", 3)
+	ns.Container.SetChunkSize(8)
+	g := &gateway{
+		ns:      "NS",
+		account: "s3",
+		region:  "us-east-1",
+		creds:   credentials{"AK": "SECRET"},
+		now:     time.Now,
+	}
+	g.directory, _ = oio.MakeDirectoryClient("NS", ns.Config)
+	g.container, _ = oio.MakeContainerClient("NS", ns.Config)
+	g.storage, _ = oio.MakeDefaultObjectStorageClient("NS", ns.Config)
+	g.rawx, _ = oio.MakeRawxClient("NS", ns.Config)
+	return &testGateway{t: t, ns: ns, srv: httptest.NewServer(g)}
+}
+
+func (tg *testGateway) close() {
+	tg.srv.Close()
+	tg.ns.Close()
+}
+
+// Signs the request like the AWS SDKs do, with a signed payload
+func signRequest(req *http.Request, body []byte, secret string) {
+	now := time.Now().UTC()
+	sum := sha256.Sum256(body)
+	s := &sigV4{
+		accessKey:     "AK",
+		scopeDate:     now.Format("20060102"),
+		region:        "us-east-1",
+		service:       "s3",
+		signedHeaders: []string{"host", "x-amz-content-sha256", "x-amz-date"},
+		date:          now,
+		payloadHash:   hex.EncodeToString(sum[:]),
+	}
+	req.Header.Set("X-Amz-Content-Sha256", s.payloadHash)
+	req.Header.Set("X-Amz-Date", now.Format(amzDateFormat))
+	req.Header.Set("Authorization", sigV4Algorithm+" Credential=AK/"+s.scope()+
+		", SignedHeaders="+strings.Join(s.signedHeaders, ";")+
+		", Signature="+s.compute(req, secret))
+}
+
+func (tg *testGateway) do(method, path string, body []byte, hdr map[string]string) (*http.Response, []byte) {
+	req, _ := http.NewRequest(method, tg.srv.URL+path, bytes.NewReader(body))
+	for k, v := range hdr {
+		req.Header.Set(k, v)
+	}
+	signRequest(req, body, "SECRET")
+	rep, err := http.DefaultClient.Do(req)
+	if err != nil {
+		tg.t.Fatal(method, path, err)
+	}
+	defer rep.Body.Close()
+	out, _ := ioutil.ReadAll(rep.Body)
+	return rep, out
+}
+
+func (tg *testGateway) expect(method, path string, body []byte, code int) []byte {
+	rep, out := tg.do(method, path, body, nil)
+	if rep.StatusCode != code {
+		tg.t.Fatal(method, " ", path, ": ", rep.StatusCode, " ", string(out))
+	}
+	return out
+}
+
+func errorCode(body []byte) string {
+	var e errorReply
+	xml.Unmarshal(body, &e)
+	return e.Code
+}
+
+func TestGateway_Buckets(t *testing.T) {
+	tg := startGateway(t)
+	defer tg.close()
+
+	rep, err := http.Get(tg.srv.URL + "/bucket")
+	if err != nil || rep.StatusCode != http.StatusForbidden {
+		t.Fatal("Anonymous request accepted: ", rep, err)
+	}
+	req, _ := http.NewRequest("GET", tg.srv.URL+"/bucket", nil)
+	signRequest(req, nil, "WRONG")
+	if rep, err = http.DefaultClient.Do(req); err != nil || rep.StatusCode != http.StatusForbidden {
+		t.Fatal("Bad signature accepted: ", rep, err)
+	}
+
+	tg.expect("PUT", "/Bad_Name", nil, http.StatusBadRequest)
+	tg.expect("PUT", "/bucket", nil, http.StatusOK)
+	if out := tg.expect("PUT", "/bucket", nil, http.StatusConflict); errorCode(out) != "BucketAlreadyOwnedByYou" {
+		t.Fatal("Bad error: ", string(out))
+	}
+	tg.expect("HEAD", "/bucket", nil, http.StatusOK)
+	tg.expect("PUT", "/bucket/key", []byte("data"), http.StatusOK)
+	if out := tg.expect("DELETE", "/bucket", nil, http.StatusConflict); errorCode(out) != "BucketNotEmpty" {
+		t.Fatal("Bad error: ", string(out))
+	}
+	tg.expect("DELETE", "/bucket/key", nil, http.StatusNoContent)
+	tg.expect("DELETE", "/bucket/key", nil, http.StatusNoContent)
+	tg.expect("DELETE", "/bucket", nil, http.StatusNoContent)
+	tg.expect("HEAD", "/bucket", nil, http.StatusNotFound)
+	if out := tg.expect("GET", "/bucket/key", nil, http.StatusNotFound); errorCode(out) != "NoSuchBucket" {
+		t.Fatal("Bad error: ", string(out))
+	}
+	tg.expect("GET", "/bucket?acl", nil, http.StatusNotImplemented)
+}
+
+func TestGateway_Objects(t *testing.T) {
+	tg := startGateway(t)
+	defer tg.close()
+	tg.expect("PUT", "/bucket", nil, http.StatusOK)
+
+	data := []byte("0123456789abcdefghij")
+	sum := md5.Sum(data)
+	rep, out := tg.do("PUT", "/bucket/dir/obj", data, map[string]string{
+		"Content-MD5":       base64.StdEncoding.EncodeToString(sum[:]),
+		"X-Amz-Meta-Color":  "blue",
+		"X-Amz-Meta-Author": "jfs",
+	})
+	etag := "\"" + hex.EncodeToString(sum[:]) + "\""
+	if rep.StatusCode != http.StatusOK || rep.Header.Get("ETag") != etag {
+		t.Fatal("PutObject failed: ", rep.StatusCode, string(out))
+	}
+
+	rep, out = tg.do("GET", "/bucket/dir/obj", nil, nil)
+	if rep.StatusCode != http.StatusOK || !bytes.Equal(out, data) {
+		t.Fatal("GetObject failed: ", rep.StatusCode, string(out))
+	}
+	rep, out = tg.do("GET", "/bucket/dir/obj", nil, map[string]string{"Range": "bytes=6-11"})
+	if rep.StatusCode != http.StatusPartialContent || string(out) != "6789ab" {
+		t.Fatal("Ranged GetObject failed: ", rep.StatusCode, string(out))
+	}
+	rep, _ = tg.do("HEAD", "/bucket/dir/obj", nil, nil)
+	if rep.StatusCode != http.StatusOK || rep.ContentLength != int64(len(data)) ||
+		rep.Header.Get("ETag") != etag || rep.Header.Get("X-Amz-Meta-Color") != "blue" {
+		t.Fatal("HeadObject failed: ", rep.StatusCode, rep.Header)
+	}
+	rep, _ = tg.do("GET", "/bucket/dir/obj", nil, map[string]string{"If-None-Match": etag})
+	if rep.StatusCode != http.StatusNotModified {
+		t.Fatal("Conditional GetObject failed: ", rep.StatusCode)
+	}
+
+	if out = tg.expect("GET", "/bucket/missing", nil, http.StatusNotFound); errorCode(out) != "NoSuchKey" {
+		t.Fatal("Bad error: ", string(out))
+	}
+	rep, out = tg.do("PUT", "/bucket/obj", data, map[string]string{"Content-MD5": "AAAAAAAAAAAAAAAAAAAAAA=="})
+	if rep.StatusCode != http.StatusBadRequest || errorCode(out) != "BadDigest" {
+		t.Fatal("Bad digest accepted: ", rep.StatusCode, string(out))
+	}
+	if out = tg.expect("PUT", "/nobucket/obj", data, http.StatusNotFound); errorCode(out) != "NoSuchBucket" {
+		t.Fatal("Bad error: ", string(out))
+	}
+
+	// The metadata are saved with the object, not after it
+	tg.ns.Container.Faults.FailOn("SetContentProperties", 0, nil)
+	put := func(color string) int {
+		rep, _ := tg.do("PUT", "/bucket/meta", []byte(color), map[string]string{"X-Amz-Meta-Color": color})
+		return rep.StatusCode
+	}
+	if code := put("red"); code != http.StatusOK {
+		t.Fatal("PutObject failed: ", code)
+	}
+	if code := put("green"); code != http.StatusOK {
+		t.Fatal("PutObject failed: ", code)
+	}
+	head := func() string {
+		rep, _ := tg.do("HEAD", "/bucket/meta", nil, nil)
+		return rep.Header.Get("X-Amz-Meta-Color")
+	}
+	if color := head(); color != "green" {
+		t.Fatal("Metadata not saved with the object: ", color)
+	}
+
+	// A failed PUT leaves the previous object as it was
+	tg.ns.Container.Faults.FailOn("PutContent", 0, nil)
+	if code := put("blue"); code != http.StatusInternalServerError {
+		t.Fatal("Failed creation ignored: ", code)
+	}
+	tg.ns.Container.Faults.Reset()
+	if out = tg.expect("GET", "/bucket/meta", nil, http.StatusOK); string(out) != "green" || head() != "green" {
+		t.Fatal("Previous object damaged: ", string(out), head())
+	}
+}
+
+func TestGateway_List(t *testing.T) {
+	tg := startGateway(t)
+	defer tg.close()
+	tg.expect("PUT", "/bucket", nil, http.StatusOK)
+	for _, k := range []string{"a", "b/1", "b/2", "c d", "e/f/g"} {
+		tg.expect("PUT", "/bucket/"+k, []byte(k), http.StatusOK)
+	}
+
+	list := func(query string) listBucketResult {
+		var l listBucketResult
+		if err := xml.Unmarshal(tg.expect("GET", "/bucket?list-type=2&"+query, nil, http.StatusOK), &l); err != nil {
+			t.Fatal("Bad listing: ", err)
+		}
+		return l
+	}
+	names := func(l listBucketResult) string {
+		out := make([]string, 0)
+		for _, c := range l.Contents {
+			out = append(out, c.Key)
+		}
+		for _, p := range l.CommonPrefixes {
+			out = append(out, p.Prefix+"*")
+		}
+		return strings.Join(out, ",")
+	}
+
+	if l := list(""); names(l) != "a,b/1,b/2,c d,e/f/g" || l.KeyCount != 5 || l.IsTruncated {
+		t.Fatal("Bad listing: ", names(l))
+	}
+	if l := list("prefix=b/"); names(l) != "b/1,b/2" || l.Contents[0].Size != 3 {
+		t.Fatal("Bad listing: ", names(l))
+	}
+
+	// Paginated, the common prefixes count in the pages
+	got := make([]string, 0)
+	query := "delimiter=/&max-keys=2"
+	for {
+		l := list(query)
+		got = append(got, names(l))
+		if !l.IsTruncated {
+			break
+		}
+		query = "delimiter=/&max-keys=2&continuation-token=" + strings.Replace(l.NextContinuationToken, "=", "%3D", -1)
+	}
+	if strings.Join(got, "|") != "a,b/*|c d,e/*" {
+		t.Fatal("Bad pages: ", got)
+	}
+
+	if l := list("encoding-type=url&start-after=b/2"); names(l) != "c+d,e%2Ff%2Fg" {
+		t.Fatal("Bad encoded listing: ", names(l))
+	}
+}
@@ -0,0 +1,96 @@
+/*
+OpenIO SDS Go client SDK
+Copyright (C) 2017-2018 OpenIO
+
+This library is free software; you can redistribute it and/or
+modify it under the terms of the GNU Lesser General Public
+License as published by the Free Software Foundation; either
+version 3.0 of the License, or (at your option) any later version.
+
+This library is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Lesser General Public License for more details.
+
+You should have received a copy of the GNU Lesser General Public
+License along with this library.
+*/
+
+package main
+
+/*
+Serves a subset of the S3 API on the containers of an account: the buckets
+are containers and the keys are the paths of the contents. The requests must
+be signed with AWS SigV4, with a key pair of the credentials file.
+*/
+
+import (
+	"flag"
+	oio "github.com/jfsmig/oio-go/sdk"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+func usage(why string) {
+	log.Println("oio-s3 -credentials FILE [-account ACCT] [-policy POLICY] [-region REGION] NS IP:PORT")
+	log.Fatal(why)
+}
+
+func main() {
+	creds := flag.String("credentials", "", "Credentials file, in the format of the AWS CLI (mandatory)")
+	account := flag.String("account", "s3", "Account of the containers")
+	policy := flag.String("policy", "", "Storage policy of the new objects")
+	region := flag.String("region", "us-east-1", "Region expected in the signatures, empty for any")
+	tmpdir := flag.String("tmpdir", "", "Directory of the uploads in progress")
+	verbose := flag.Bool("v", false, "Log each request")
+	flag.Parse()
+	if flag.NArg() != 2 {
+		usage("Missing positional arguments")
+	}
+
+	ns := flag.Arg(0)
+	if !oio.IsValidNamespace(ns) {
+		usage("Invalid namespace format")
+	}
+	ipPort := flag.Arg(1)
+	if _, err := net.ResolveTCPAddr("tcp", ipPort); err != nil {
+		usage("Invalid URL format")
+	}
+	if len(*creds) <= 0 {
+		usage("No credentials file")
+	}
+	keys, err := loadCredentials(*creds)
+	if err != nil {
+		log.Fatal("Credentials not loaded: ", err)
+	}
+
+	cfg := oio.MakeDefaultConfig()
+	g := &gateway{
+		ns:      ns,
+		account: *account,
+		policy:  *policy,
+		region:  *region,
+		creds:   keys,
+		tmpdir:  *tmpdir,
+		now:     time.Now,
+	}
+	g.directory, _ = oio.MakeDirectoryClient(ns, cfg)
+	g.container, _ = oio.MakeContainerClient(ns, cfg)
+	g.rawx, _ = oio.MakeRawxClient(ns, cfg)
+	if g.storage, err = oio.MakeDefaultObjectStorageClient(ns, cfg); err != nil {
+		log.Fatal("SDK error: ", err)
+	}
+
+	var handler http.Handler = g
+	if *verbose {
+		handler = http.HandlerFunc(func(rep http.ResponseWriter, req *http.Request) {
+			log.Println(req.Method, req.URL.String())
+			g.ServeHTTP(rep, req)
+		})
+	}
+	if err := http.ListenAndServe(ipPort, handler); err != nil {
+		log.Fatal("HTTP error : ", err)
+	}
+}
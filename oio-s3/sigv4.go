@@ -0,0 +1,276 @@
+/*
+OpenIO SDS Go client SDK
+Copyright (C) 2017-2018 OpenIO
+
+This library is free software; you can redistribute it and/or
+modify it under the terms of the GNU Lesser General Public
+License as published by the Free Software Foundation; either
+version 3.0 of the License, or (at your option) any later version.
+
+This library is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Lesser General Public License for more details.
+
+You should have received a copy of the GNU Lesser General Public
+License along with this library.
+*/
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/go-ini/ini"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm  = "AWS4-HMAC-SHA256"
+	sigV4Terminator = "aws4_request"
+	amzDateFormat   = "20060102T150405Z"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	maxClockSkew    = 15 * time.Minute
+	maxPresignedTTL = 7 * 24 * time.Hour
+)
+
+// The secret keys, by access key
+type credentials map[string]string
+
+// Loads the credentials file, in the format of the AWS CLI: one section per
+// key pair, with the aws_access_key_id and aws_secret_access_key entries.
+func loadCredentials(path string) (credentials, error) {
+	f, err := ini.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	out := make(credentials)
+	for _, section := range f.Sections() {
+		id := section.Key("aws_access_key_id").String()
+		secret := section.Key("aws_secret_access_key").String()
+		if len(id) > 0 && len(secret) > 0 {
+			out[id] = secret
+		}
+	}
+	if len(out) <= 0 {
+		return nil, fmt.Errorf("No key pair in [%s]", path)
+	}
+	return out, nil
+}
+
+// The elements of a request signature, from the Authorization header or
+// from the query string of a presigned URL.
+type sigV4 struct {
+	accessKey     string
+	scopeDate     string
+	region        string
+	service       string
+	signedHeaders []string
+	signature     string
+	date          time.Time
+	payloadHash   string
+
+	// Set for the presigned URLs
+	presigned bool
+	expires   time.Duration
+}
+
+func (s *sigV4) scope() string {
+	return strings.Join([]string{s.scopeDate, s.region, s.service, sigV4Terminator}, "/")
+}
+
+func (s *sigV4) parseCredential(v string) error {
+	tokens := strings.Split(v, "/")
+	if len(tokens) != 5 || tokens[4] != sigV4Terminator {
+		return errAuthorizationMalformed
+	}
+	s.accessKey, s.scopeDate, s.region, s.service = tokens[0], tokens[1], tokens[2], tokens[3]
+	return nil
+}
+
+// Extracts the signature from the request. Returns nil when the request is
+// anonymous.
+func parseSigV4(req *http.Request) (*sigV4, error) {
+	var err error
+	s := &sigV4{}
+	q := req.URL.Query()
+
+	if auth := req.Header.Get("Authorization"); len(auth) > 0 {
+		if !strings.HasPrefix(auth, sigV4Algorithm+" ") {
+			return nil, errUnsupportedSignature
+		}
+		fields := make(map[string]string)
+		for _, f := range strings.Split(auth[len(sigV4Algorithm)+1:], ",") {
+			if kv := strings.SplitN(strings.TrimSpace(f), "=", 2); len(kv) == 2 {
+				fields[kv[0]] = kv[1]
+			}
+		}
+		if err = s.parseCredential(fields["Credential"]); err != nil {
+			return nil, err
+		}
+		s.signature = fields["Signature"]
+		s.signedHeaders = strings.Split(fields["SignedHeaders"], ";")
+		s.payloadHash = req.Header.Get("X-Amz-Content-Sha256")
+		if len(s.payloadHash) <= 0 {
+			return nil, errMissingContentSha256
+		}
+		if v := req.Header.Get("X-Amz-Date"); len(v) > 0 {
+			s.date, err = time.Parse(amzDateFormat, v)
+		} else {
+			s.date, err = http.ParseTime(req.Header.Get("Date"))
+		}
+		if err != nil {
+			return nil, errAuthorizationMalformed
+		}
+	} else if alg := q.Get("X-Amz-Algorithm"); len(alg) > 0 {
+		if alg != sigV4Algorithm {
+			return nil, errUnsupportedSignature
+		}
+		s.presigned = true
+		if err = s.parseCredential(q.Get("X-Amz-Credential")); err != nil {
+			return nil, err
+		}
+		s.signature = q.Get("X-Amz-Signature")
+		s.signedHeaders = strings.Split(q.Get("X-Amz-SignedHeaders"), ";")
+		s.payloadHash = unsignedPayload
+		if s.date, err = time.Parse(amzDateFormat, q.Get("X-Amz-Date")); err != nil {
+			return nil, errAuthorizationMalformed
+		}
+		secs, err := strconv.Atoi(q.Get("X-Amz-Expires"))
+		if err != nil || secs <= 0 || time.Duration(secs)*time.Second > maxPresignedTTL {
+			return nil, errAuthorizationMalformed
+		}
+		s.expires = time.Duration(secs) * time.Second
+	} else {
+		return nil, nil
+	}
+
+	if len(s.signature) <= 0 || s.service != "s3" || s.date.Format("20060102") != s.scopeDate {
+		return nil, errAuthorizationMalformed
+	}
+	if strings.HasPrefix(s.payloadHash, "STREAMING-") {
+		return nil, errNotImplemented
+	}
+	return s, nil
+}
+
+// Encodes all the bytes but the unreserved characters, like the AWS
+// services. The slashes are kept for the paths.
+func awsEscape(s string, path bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~', path && c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func canonicalQuery(q url.Values, presigned bool) string {
+	pairs := make([]string, 0, len(q))
+	for k, values := range q {
+		if presigned && k == "X-Amz-Signature" {
+			continue
+		}
+		for _, v := range values {
+			pairs = append(pairs, awsEscape(k, false)+"="+awsEscape(v, false))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+func canonicalHeaderValue(req *http.Request, name string) string {
+	switch name {
+	case "host":
+		return req.Host
+	case "content-length":
+		if v := req.Header.Get("Content-Length"); len(v) > 0 {
+			return v
+		}
+		return strconv.FormatInt(req.ContentLength, 10)
+	}
+	values := req.Header[http.CanonicalHeaderKey(name)]
+	trimmed := make([]string, 0, len(values))
+	for _, v := range values {
+		trimmed = append(trimmed, strings.Join(strings.Fields(v), " "))
+	}
+	return strings.Join(trimmed, ",")
+}
+
+// Builds the canonical form of the request, as signed by the client
+func (s *sigV4) canonicalRequest(req *http.Request) string {
+	path := req.URL.Path
+	if len(path) <= 0 {
+		path = "/"
+	}
+	var headers strings.Builder
+	for _, name := range s.signedHeaders {
+		headers.WriteString(name + ":" + canonicalHeaderValue(req, name) + "\n")
+	}
+	return strings.Join([]string{
+		req.Method,
+		awsEscape(path, true),
+		canonicalQuery(req.URL.Query(), s.presigned),
+		headers.String(),
+		strings.Join(s.signedHeaders, ";"),
+		s.payloadHash,
+	}, "\n")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// Computes the signature of the request with the given secret key
+func (s *sigV4) compute(req *http.Request, secret string) string {
+	hashed := sha256.Sum256([]byte(s.canonicalRequest(req)))
+	toSign := strings.Join([]string{
+		sigV4Algorithm,
+		s.date.UTC().Format(amzDateFormat),
+		s.scope(),
+		hex.EncodeToString(hashed[:]),
+	}, "\n")
+	key := hmacSHA256([]byte("AWS4"+secret), s.scopeDate)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, s.service)
+	key = hmacSHA256(key, sigV4Terminator)
+	return hex.EncodeToString(hmacSHA256(key, toSign))
+}
+
+// Checks the signature of the request against the known credentials, the
+// region and the current time.
+func (s *sigV4) verify(req *http.Request, creds credentials, region string, now time.Time) error {
+	secret, ok := creds[s.accessKey]
+	if !ok {
+		return errInvalidAccessKeyId
+	}
+	if len(region) > 0 && s.region != region {
+		return errAuthorizationMalformed
+	}
+	if s.presigned {
+		if now.Before(s.date.Add(-maxClockSkew)) || now.After(s.date.Add(s.expires)) {
+			return errExpiredPresigned
+		}
+	} else if d := now.Sub(s.date); d > maxClockSkew || d < -maxClockSkew {
+		return errRequestTimeTooSkewed
+	}
+	if !hmac.Equal([]byte(s.compute(req, secret)), []byte(s.signature)) {
+		return errSignatureDoesNotMatch
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+/*
+OpenIO SDS Go client SDK
+Copyright (C) 2017-2018 OpenIO
+
+This library is free software; you can redistribute it and/or
+modify it under the terms of the GNU Lesser General Public
+License as published by the Free Software Foundation; either
+version 3.0 of the License, or (at your option) any later version.
+
+This library is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Lesser General Public License for more details.
+
+You should have received a copy of the GNU Lesser General Public
+License along with this library.
+*/
+
+package main
+
+import (
+	oio "github.com/jfsmig/oio-go/sdk"
+	"io"
+	"os"
+	"strconv"
+)
+
+var chunkActions = []action{
+	{"head", "URL", "Print the attributes of the chunk", doChunkHead},
+	{"get", "URL [FILE] [-offset N] [-size N]", "Download the chunk into the file, or to the standard output", doChunkGet},
+}
+
+// The attributes of a chunk, as printed
+type chunkInfo struct {
+	Url         string `json:"url"`
+	Id          string `json:"id"`
+	Position    string `json:"pos"`
+	Size        uint64 `json:"size"`
+	Hash        string `json:"hash"`
+	Alias       string `json:"alias,omitempty"`
+	Policy      string `json:"policy,omitempty"`
+	MimeType    string `json:"mime_type,omitempty"`
+	ChunkMethod string `json:"chunk_method,omitempty"`
+}
+
+// The chunk URL is enough, the namespace only brings its timeouts
+func (o *options) rawx() (*oio.RawxClient, error) {
+	return oio.MakeRawxClient(o.ns, o.cli.cfg)
+}
+
+func doChunkHead(o *options, args []string) error {
+	args, err := o.parseN(args, 1, 1)
+	if err != nil {
+		return err
+	}
+	rawx, err := o.rawx()
+	if err != nil {
+		return err
+	}
+	meta, err := rawx.HeadChunk(args[0])
+	if err != nil {
+		return err
+	}
+	info := chunkInfo{
+		Url:         args[0],
+		Id:          string(meta.Id),
+		Position:    meta.Position,
+		Size:        meta.Size,
+		Hash:        meta.Hash,
+		Alias:       meta.Alias,
+		Policy:      meta.Policy,
+		MimeType:    meta.MimeType,
+		ChunkMethod: meta.ChunkMethod,
+	}
+	return o.print(info, []string{"FIELD", "VALUE"}, [][]string{
+		{"url", info.Url},
+		{"id", info.Id},
+		{"pos", info.Position},
+		{"size", strconv.FormatUint(info.Size, 10)},
+		{"hash", info.Hash},
+		{"alias", info.Alias},
+		{"policy", info.Policy},
+		{"mime_type", info.MimeType},
+		{"chunk_method", info.ChunkMethod},
+	})
+}
+
+func doChunkGet(o *options, args []string) error {
+	offset := o.fs.Uint64("offset", 0, "Offset of the first byte")
+	size := o.fs.Uint64("size", 0, "Count of bytes, 0 up to the end")
+	args, err := o.parseN(args, 1, 2)
+	if err != nil {
+		return err
+	}
+	rawx, err := o.rawx()
+	if err != nil {
+		return err
+	}
+	in, _, err := rawx.GetChunk(args[0], *offset, *size)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if len(args) < 2 || args[1] == "-" {
+		_, err = io.Copy(o.cli.out, in)
+		return err
+	}
+	out, err := os.Create(args[1])
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(args[1])
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,266 @@
+/*
+OpenIO SDS Go client SDK
+Copyright (C) 2017-2018 OpenIO
+
+This library is free software; you can redistribute it and/or
+modify it under the terms of the GNU Lesser General Public
+License as published by the Free Software Foundation; either
+version 3.0 of the License, or (at your option) any later version.
+
+This library is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Lesser General Public License for more details.
+
+You should have received a copy of the GNU Lesser General Public
+License along with this library.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/jfsmig/oio-go/oiotest"
+	oio "github.com/jfsmig/oio-go/sdk"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type testCli struct {
+	t   *testing.T
+	ns  *oiotest.Namespace
+	out bytes.Buffer
+	cli *cli
+}
+
+func startCli(t *testing.T) *testCli {
+	tc := &testCli{t: t, ns: oiotest.StartNamespace("NS", 3)}
+	tc.ns.Container.SetChunkSize(8)
+	env := map[string]string{"OIO_NS": "NS", "OIO_ACCOUNT": "ACCT", "OIO_USER": "USER"}
+	tc.cli = &cli{
+		cfg:    tc.ns.Config,
+		out:    &tc.out,
+		getenv: func(k string) string { return env[k] },
+	}
+	return tc
+}
+
+// Runs the command line and returns its output
+func (tc *testCli) run(args ...string) string {
+	tc.out.Reset()
+	if err := tc.cli.run(args); err != nil {
+		tc.t.Fatal(strings.Join(args, " "), ": ", err)
+	}
+	return tc.out.String()
+}
+
+func (tc *testCli) json(v interface{}, args ...string) {
+	out := tc.run(append(args, "-format", "json")...)
+	if err := json.Unmarshal([]byte(out), v); err != nil {
+		tc.t.Fatal(strings.Join(args, " "), ": ", err, " ", out)
+	}
+}
+
+func TestCli_Usage(t *testing.T) {
+	tc := startCli(t)
+	defer tc.ns.Close()
+	for _, args := range [][]string{
+		{},
+		{"object"},
+		{"nope", "list"},
+		{"object", "nope"},
+		{"object", "get"},
+		{"object", "get", "a", "b", "c"},
+		{"object", "stat", "-nope", "a"},
+		{"object", "stat", "a", "-format", "xml"},
+		{"container", "props", "C", "-delete"},
+		{"container", "props", "C", "novalue"},
+	} {
+		err := tc.cli.run(args)
+		if _, ok := err.(usageError); !ok {
+			t.Fatal(args, ": expected a usage error, got ", err)
+		}
+	}
+	if err := tc.cli.run([]string{"container", "show", "oio://NS/ACCT/USER//path"}); err == nil {
+		t.Fatal("Object URL accepted as a container")
+	}
+	if err := tc.cli.run([]string{"object", "stat", "missing"}); err != oio.ErrorNotFound {
+		t.Fatal("Expected not found, got ", err)
+	}
+}
+
+func TestCli_Objects(t *testing.T) {
+	tc := startCli(t)
+	defer tc.ns.Close()
+
+	dir, err := ioutil.TempDir("", "oio-cli-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	data := "0123456789abcdefghijklmnopqrstuvwxyz"
+	local := filepath.Join(dir, "file.txt")
+	if err = ioutil.WriteFile(local, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var outcomes []outcome
+	tc.json(&outcomes, "container", "create", "USER")
+	if len(outcomes) != 1 || !outcomes[0].Done || outcomes[0].Name != "oio://NS/ACCT/USER" {
+		t.Fatal("Unexpected creation: ", outcomes)
+	}
+	tc.run("object", "put", local)
+	tc.run("object", "put", local, "dir/a")
+	tc.run("object", "put", local, "dir/sub/b")
+
+	var l listing
+	tc.json(&l, "object", "ls")
+	if len(l.Objects) != 1 || l.Objects[0].Name != "file.txt" || len(l.Prefixes) != 1 || l.Prefixes[0] != "dir/" {
+		t.Fatal("Unexpected listing: ", l)
+	}
+	tc.json(&l, "object", "ls", "-r", "dir/")
+	if len(l.Objects) != 2 || len(l.Prefixes) != 0 {
+		t.Fatal("Unexpected recursive listing: ", l)
+	}
+	tc.json(&l, "container", "list", "USER", "-max", "2")
+	if len(l.Objects) != 2 || !l.Truncated {
+		t.Fatal("Unexpected truncated listing: ", l)
+	}
+	if out := tc.run("container", "list", "USER", "-max", "2"); !strings.Contains(out, "Truncated") {
+		t.Fatal("Truncation not printed: ", out)
+	}
+
+	// The URL overrides the flags
+	var info objectInfo
+	tc.json(&info, "object", "stat", "-user", "OTHER", "oio://NS/ACCT/USER//dir%2Fa")
+	if info.Header.Size != uint64(len(data)) || info.Header.Name != "dir/a" {
+		t.Fatal("Unexpected stat: ", info)
+	}
+	if out := tc.run("object", "get", "dir/a"); out != data {
+		t.Fatal("Unexpected data: ", out)
+	}
+	copied := filepath.Join(dir, "copy")
+	tc.run("object", "get", "dir/sub/b", copied)
+	if b, err := ioutil.ReadFile(copied); err != nil || string(b) != data {
+		t.Fatal("Unexpected download: ", err, string(b))
+	}
+
+	var props map[string]string
+	tc.json(&props, "object", "props", "dir/a", "k0=v0", "k1=v1")
+	props = nil
+	tc.json(&props, "object", "props", "dir/a", "-delete", "k0")
+	if len(props) != 1 || props["k1"] != "v1" {
+		t.Fatal("Unexpected object properties: ", props)
+	}
+	if out := tc.run("object", "stat", "dir/a"); !strings.Contains(out, "prop.k1") {
+		t.Fatal("Properties not printed: ", out)
+	}
+	props = nil
+	tc.json(&props, "container", "props", "USER", "a=1")
+	if len(props) != 1 || props["a"] != "1" {
+		t.Fatal("Unexpected container properties: ", props)
+	}
+
+	// The chunks are reached through the URL given by the container
+	content, err := tc.ns.Container.GetContent(&oio.FlatName{N: "NS", A: "ACCT", U: "USER", P: "dir/a"})
+	if err != nil || len(content.Chunks) <= 0 {
+		t.Fatal("Chunks not found: ", err)
+	}
+	chunk := content.Chunks[0]
+	var ci chunkInfo
+	tc.json(&ci, "chunk", "head", chunk.Url)
+	if ci.Size != chunk.Size || ci.Position != chunk.Position {
+		t.Fatal("Unexpected chunk: ", ci, chunk)
+	}
+	if out := tc.run("chunk", "get", chunk.Url, "-offset", "2", "-size", "3"); out != data[2:5] {
+		t.Fatal("Unexpected range of the chunk: ", out)
+	}
+
+	tc.json(&outcomes, "object", "delete", "file.txt", "dir/a", "dir/sub/b")
+	if len(outcomes) != 3 {
+		t.Fatal("Unexpected deletion: ", outcomes)
+	}
+	tc.json(&outcomes, "container", "delete", "USER")
+	if len(outcomes) != 1 || !outcomes[0].Done {
+		t.Fatal("Unexpected container deletion: ", outcomes)
+	}
+}
+
+func TestCli_References(t *testing.T) {
+	tc := startCli(t)
+	defer tc.ns.Close()
+
+	tc.run("reference", "create", "REF")
+	var srv []oio.Service
+	tc.json(&srv, "reference", "force", "REF", "echo", "127.0.0.1:1,127.0.0.1:2", "-seq", "3")
+	if len(srv) != 2 || srv[0].Seq != 3 {
+		t.Fatal("Unexpected services: ", srv)
+	}
+	tc.run("reference", "props", "REF", "x=y")
+	var dump oio.RefDump
+	tc.json(&dump, "reference", "show", "REF")
+	if len(dump.Services) != 2 || len(dump.Properties) != 1 {
+		t.Fatal("Unexpected dump: ", dump)
+	}
+	if out := tc.run("reference", "show", "REF"); !strings.Contains(out, "127.0.0.1:2") {
+		t.Fatal("Services not printed: ", out)
+	}
+	var outcomes []outcome
+	tc.json(&outcomes, "reference", "unlink", "REF", "echo")
+	dump = oio.RefDump{}
+	tc.json(&dump, "reference", "show", "REF")
+	if len(dump.Services) != 0 {
+		t.Fatal("Services still linked: ", dump)
+	}
+}
+
+func TestCli_Sync(t *testing.T) {
+	tc := startCli(t)
+	defer tc.ns.Close()
+
+	dir, err := ioutil.TempDir("", "oio-cli-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "src")
+	os.MkdirAll(filepath.Join(src, "sub"), 0755)
+	ioutil.WriteFile(filepath.Join(src, "a.txt"), []byte("aaaa"), 0644)
+	ioutil.WriteFile(filepath.Join(src, "sub", "b.txt"), []byte("bbbb"), 0644)
+	ioutil.WriteFile(filepath.Join(src, "c.tmp"), []byte("cccc"), 0644)
+
+	var changes []syncChange
+	tc.json(&changes, "sync", "up", src, "builds", "-exclude", "*.tmp", "-dry-run")
+	if len(changes) != 2 {
+		t.Fatal("Unexpected dry run: ", changes)
+	}
+	var l listing
+	if err = tc.cli.run([]string{"object", "ls", "-r"}); err != oio.ErrorNotFound {
+		t.Fatal("Dry run created the container: ", err)
+	}
+	tc.json(&changes, "sync", "up", src, "builds", "-exclude", "*.tmp")
+	if len(changes) != 2 || changes[0].Path != "a.txt" || changes[1].Path != "sub/b.txt" {
+		t.Fatal("Unexpected upload: ", changes)
+	}
+	tc.json(&l, "object", "ls", "-r")
+	if len(l.Objects) != 2 || l.Objects[1].Name != "builds/sub/b.txt" {
+		t.Fatal("Unexpected listing: ", l)
+	}
+
+	dst := filepath.Join(dir, "dst")
+	changes = nil
+	tc.json(&changes, "sync", "down", "oio://NS/ACCT/USER//builds", dst, "-parallel", "1")
+	if len(changes) != 2 {
+		t.Fatal("Unexpected download: ", changes)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dst, "sub", "b.txt")); err != nil || string(b) != "bbbb" {
+		t.Fatal("Unexpected data: ", err, string(b))
+	}
+	if out := tc.run("sync", "down", "builds", dst); strings.Count(out, "\n") != 1 {
+		t.Fatal("Unexpected changes: ", out)
+	}
+}
@@ -0,0 +1,256 @@
+/*
+OpenIO SDS Go client SDK
+Copyright (C) 2017-2018 OpenIO
+
+This library is free software; you can redistribute it and/or
+modify it under the terms of the GNU Lesser General Public
+License as published by the Free Software Foundation; either
+version 3.0 of the License, or (at your option) any later version.
+
+This library is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Lesser General Public License for more details.
+
+You should have received a copy of the GNU Lesser General Public
+License along with this library.
+*/
+
+package main
+
+import (
+	oio "github.com/jfsmig/oio-go/sdk"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var containerActions = []action{
+	{"create", "NAME...", "Create the containers, and their references", doContainerCreate},
+	{"delete", "NAME...", "Delete the empty containers", doContainerDelete},
+	{"list", "NAME [-prefix P] [-marker M] [-delimiter D] [-max N] [-versions]", "List the contents of the container", doContainerList},
+	{"show", "NAME", "Print the services and the properties of the container", doContainerShow},
+	{"props", "NAME [KEY=VALUE...] [-delete KEY...]", "Print, set or delete the properties of the container", doContainerProps},
+}
+
+func doContainerCreate(o *options, args []string) error {
+	args, err := o.parseN(args, 1, -1)
+	if err != nil {
+		return err
+	}
+	dir, err := o.directory()
+	if err != nil {
+		return err
+	}
+	c, err := o.containers()
+	if err != nil {
+		return err
+	}
+	out := make([]outcome, 0, len(args))
+	for _, arg := range args {
+		n, err := o.container(arg)
+		if err != nil {
+			return err
+		}
+		if _, err = dir.CreateUser(n); err != nil {
+			return err
+		}
+		created, err := c.CreateContainer(n, false)
+		if err != nil {
+			return err
+		}
+		out = append(out, outcome{n.String(), created})
+	}
+	return o.printOutcomes("created", out)
+}
+
+func doContainerDelete(o *options, args []string) error {
+	args, err := o.parseN(args, 1, -1)
+	if err != nil {
+		return err
+	}
+	c, err := o.containers()
+	if err != nil {
+		return err
+	}
+	out := make([]outcome, 0, len(args))
+	for _, arg := range args {
+		n, err := o.container(arg)
+		if err != nil {
+			return err
+		}
+		deleted, err := c.DeleteContainer(n)
+		if err != nil {
+			return err
+		}
+		out = append(out, outcome{n.String(), deleted})
+	}
+	return o.printOutcomes("deleted", out)
+}
+
+// A page of a listing, as printed
+type listing struct {
+	Objects    []oio.ContentHeader `json:"objects"`
+	Prefixes   []string            `json:"prefixes"`
+	Truncated  bool                `json:"truncated"`
+	NextMarker string              `json:"next_marker,omitempty"`
+}
+
+// Lists the container page by page, up to <max> items or to the end when
+// <max> is zero.
+func listContents(c oio.Container, n oio.ContainerName, p oio.ListParams, max int) (listing, error) {
+	out := listing{Objects: make([]oio.ContentHeader, 0), Prefixes: make([]string, 0)}
+	for {
+		p.Max = 0
+		if max > 0 {
+			p.Max = max - len(out.Objects) - len(out.Prefixes)
+		}
+		l, err := c.ListContentsWithParams(n, p)
+		if err != nil {
+			return out, err
+		}
+		last := ""
+		for _, hdr := range l.Objects {
+			out.Objects = append(out.Objects, hdr)
+			if hdr.Name > last {
+				last = hdr.Name
+			}
+		}
+		for _, pfx := range l.Prefixes {
+			out.Prefixes = append(out.Prefixes, pfx)
+			if pfx > last {
+				last = pfx
+			}
+		}
+		if p.Marker = l.NextMarker; len(p.Marker) <= 0 {
+			p.Marker = last
+		}
+		if !l.Truncated {
+			return out, nil
+		}
+		if max > 0 && len(out.Objects)+len(out.Prefixes) >= max {
+			out.Truncated, out.NextMarker = true, p.Marker
+			return out, nil
+		}
+	}
+}
+
+func (o *options) printListing(l listing) error {
+	rows := make([][]string, 0, len(l.Objects)+len(l.Prefixes))
+	for _, pfx := range l.Prefixes {
+		rows = append(rows, []string{pfx, "", "", "", ""})
+	}
+	for _, hdr := range l.Objects {
+		name := hdr.Name
+		if hdr.Deleted {
+			name += " (deleted)"
+		}
+		rows = append(rows, []string{
+			name,
+			strconv.FormatUint(hdr.Size, 10),
+			strconv.FormatUint(hdr.Version, 10),
+			strings.ToLower(hdr.Hash),
+			time.Unix(int64(hdr.CTime), 0).UTC().Format(time.RFC3339),
+		})
+	}
+	if err := o.print(l, []string{"NAME", "SIZE", "VERSION", "HASH", "CTIME"}, rows); err != nil {
+		return err
+	}
+	if l.Truncated && o.format != "json" {
+		_, err := o.cli.out.Write([]byte("Truncated, next marker: " + l.NextMarker + "\n"))
+		return err
+	}
+	return nil
+}
+
+func doContainerList(o *options, args []string) error {
+	var p oio.ListParams
+	o.fs.StringVar(&p.Prefix, "prefix", "", "List the paths starting with the prefix")
+	o.fs.StringVar(&p.Marker, "marker", "", "List the paths after the marker")
+	o.fs.StringVar(&p.Delimiter, "delimiter", "", "Group the paths sharing a prefix up to the delimiter")
+	o.fs.BoolVar(&p.Versions, "versions", false, "Also list the old versions and the deleted contents")
+	max := o.fs.Int("max", 0, "Maximum count of items, 0 for all")
+	args, err := o.parseN(args, 1, 1)
+	if err != nil {
+		return err
+	}
+	n, err := o.container(args[0])
+	if err != nil {
+		return err
+	}
+	c, err := o.containers()
+	if err != nil {
+		return err
+	}
+	l, err := listContents(c, n, p, *max)
+	if err != nil {
+		return err
+	}
+	return o.printListing(l)
+}
+
+type containerInfo struct {
+	Name       string            `json:"name"`
+	Services   []oio.Service     `json:"services"`
+	Properties map[string]string `json:"properties"`
+}
+
+func doContainerShow(o *options, args []string) error {
+	args, err := o.parseN(args, 1, 1)
+	if err != nil {
+		return err
+	}
+	n, err := o.container(args[0])
+	if err != nil {
+		return err
+	}
+	dir, err := o.directory()
+	if err != nil {
+		return err
+	}
+	c, err := o.containers()
+	if err != nil {
+		return err
+	}
+	if ok, err := c.HasContainer(n); err != nil {
+		return err
+	} else if !ok {
+		return oio.ErrorNotFound
+	}
+	info := containerInfo{Name: n.String()}
+	if info.Properties, err = c.GetContainerProperties(n); err != nil {
+		return err
+	}
+	dump, err := dir.DumpUser(n)
+	if err != nil {
+		return err
+	}
+	info.Services = dump.Services
+	dump.Directory = nil
+	dump.Properties = make([]oio.Property, 0, len(info.Properties))
+	for k, v := range info.Properties {
+		dump.Properties = append(dump.Properties, oio.Property{Key: k, Value: v})
+	}
+	return o.printDump(info, dump)
+}
+
+func doContainerProps(o *options, args []string) error {
+	del := o.fs.Bool("delete", false, "Delete the given keys")
+	args, err := o.parseN(args, 1, -1)
+	if err != nil {
+		return err
+	}
+	n, err := o.container(args[0])
+	if err != nil {
+		return err
+	}
+	c, err := o.containers()
+	if err != nil {
+		return err
+	}
+	return o.props(args[1:], *del, propertyStore{
+		get: func() (map[string]string, error) { return c.GetContainerProperties(n) },
+		set: func(props map[string]string) (bool, error) { return c.SetContainerProperties(n, props) },
+		del: func(keys []string) (bool, error) { return c.DeleteContainerProperties(n, keys) },
+	})
+}
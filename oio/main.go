@@ -0,0 +1,123 @@
+/*
+OpenIO SDS Go client SDK
+Copyright (C) 2017-2018 OpenIO
+
+This library is free software; you can redistribute it and/or
+modify it under the terms of the GNU Lesser General Public
+License as published by the Free Software Foundation; either
+version 3.0 of the License, or (at your option) any later version.
+
+This library is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Lesser General Public License for more details.
+
+You should have received a copy of the GNU Lesser General Public
+License along with this library.
+*/
+
+package main
+
+/*
+Command-line client of the storage: the references in the directory, the
+containers, the objects and the chunks, each in its group of commands, and
+the synchronization of local directories with the containers.
+*/
+
+import (
+	"fmt"
+	oio "github.com/jfsmig/oio-go/sdk"
+	"io"
+	"log"
+	"os"
+)
+
+type action struct {
+	name string
+	args string
+	help string
+	run  func(o *options, args []string) error
+}
+
+type group struct {
+	name    string
+	help    string
+	actions []action
+}
+
+var groups []group
+
+func init() {
+	groups = []group{
+		{"reference", "References in the directory", referenceActions},
+		{"container", "Containers", containerActions},
+		{"object", "Objects", objectActions},
+		{"chunk", "Chunks on the rawx services", chunkActions},
+		{"sync", "Local directories mirrored in containers", syncActions},
+	}
+}
+
+// The state shared by the commands of a run, replaced by the tests
+type cli struct {
+	cfg    oio.Config
+	out    io.Writer
+	getenv func(string) string
+}
+
+// Returned when the command line is not understood
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+func (c *cli) usage(out io.Writer) {
+	fmt.Fprintln(out, "Usage: oio GROUP ACTION [FLAGS] [ARGS...]")
+	fmt.Fprintln(out, "The names are oio:// URLs, or completed with -ns, -account, -user and -type,")
+	fmt.Fprintln(out, "whose defaults come from OIO_NS, OIO_ACCOUNT, OIO_USER and OIO_TYPE.")
+	fmt.Fprintln(out, "An object URL is oio://NS/ACCOUNT/USER/TYPE/PATH, with an empty TYPE if none")
+	fmt.Fprintln(out, "and the '/' of the PATH escaped as %2F.")
+	for _, g := range groups {
+		fmt.Fprintf(out, "\n%s: %s\n", g.name, g.help)
+		for _, a := range g.actions {
+			fmt.Fprintf(out, "  %s %s %s\n", g.name, a.name, a.args)
+			fmt.Fprintf(out, "\t%s\n", a.help)
+		}
+	}
+}
+
+// Runs the command line, without the name of the program
+func (c *cli) run(args []string) error {
+	if len(args) < 2 {
+		return usageError("Expected GROUP ACTION")
+	}
+	for _, g := range groups {
+		if g.name != args[0] {
+			continue
+		}
+		for _, a := range g.actions {
+			if a.name == args[1] {
+				o := c.options(g.name+" "+a.name, a.args)
+				return a.run(o, args[2:])
+			}
+		}
+		return usageError("Unknown action [" + args[1] + "] in " + g.name)
+	}
+	return usageError("Unknown group [" + args[0] + "]")
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("oio: ")
+	c := &cli{cfg: oio.MakeDefaultConfig(), out: os.Stdout, getenv: os.Getenv}
+	if len(os.Args) == 2 && (os.Args[1] == "-h" || os.Args[1] == "-help" || os.Args[1] == "help") {
+		c.usage(os.Stdout)
+		return
+	}
+	if err := c.run(os.Args[1:]); err != nil {
+		if _, ok := err.(usageError); ok {
+			log.Println(err)
+			c.usage(os.Stderr)
+			os.Exit(2)
+		}
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,281 @@
+/*
+OpenIO SDS Go client SDK
+Copyright (C) 2017-2018 OpenIO
+
+This library is free software; you can redistribute it and/or
+modify it under the terms of the GNU Lesser General Public
+License as published by the Free Software Foundation; either
+version 3.0 of the License, or (at your option) any later version.
+
+This library is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Lesser General Public License for more details.
+
+You should have received a copy of the GNU Lesser General Public
+License along with this library.
+*/
+
+package main
+
+import (
+	oio "github.com/jfsmig/oio-go/sdk"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var objectActions = []action{
+	{"put", "FILE [OBJECT] [-policy P] [-autocreate=false]", "Upload the file (- for the standard input), named after the file by default", doObjectPut},
+	{"get", "OBJECT [FILE]", "Download the object into the file, or to the standard output", doObjectGet},
+	{"stat", "OBJECT", "Print the attributes and the properties of the object", doObjectStat},
+	{"delete", "OBJECT...", "Delete the objects", doObjectDelete},
+	{"ls", "[PREFIX] [-r]", "List the objects under the prefix, like a directory", doObjectLs},
+	{"props", "OBJECT [KEY=VALUE...] [-delete KEY...]", "Print, set or delete the properties of the object", doObjectProps},
+}
+
+// Opens the file to upload. The standard input is saved in a temporary
+// file, since the SDK needs to know the size and may read it several times.
+func openUpload(path string) (*os.File, int64, error) {
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, 0, err
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, 0, err
+		}
+		return f, fi.Size(), nil
+	}
+	f, err := ioutil.TempFile("", "oio-")
+	if err != nil {
+		return nil, 0, err
+	}
+	os.Remove(f.Name())
+	size, err := io.Copy(f, os.Stdin)
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, size, nil
+}
+
+func doObjectPut(o *options, args []string) error {
+	policy := o.fs.String("policy", "", "Storage policy of the object")
+	autocreate := o.fs.Bool("autocreate", true, "Create the container when it is missing")
+	args, err := o.parseN(args, 1, 2)
+	if err != nil {
+		return err
+	}
+	path := filepath.Base(args[0])
+	if len(args) > 1 {
+		path = args[1]
+	} else if args[0] == "-" {
+		return usageError(o.fs.Name() + ": an object name is expected with the standard input")
+	}
+	n, err := o.object(path, false)
+	if err != nil {
+		return err
+	}
+	s, err := o.storage()
+	if err != nil {
+		return err
+	}
+
+	f, size, err := openUpload(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// The upload fails before reading the file when the container is missing
+	err = s.PutContentWithPolicy(n, uint64(size), *policy, *autocreate, f)
+	if err == oio.ErrorNotFound && *autocreate {
+		err = o.createContainer(n)
+		if err == nil {
+			err = s.PutContentWithPolicy(n, uint64(size), *policy, true, f)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return o.printOutcomes("created", []outcome{{n.String(), true}})
+}
+
+// Creates the container of the object, and its reference
+func (o *options) createContainer(n oio.ContainerName) error {
+	dir, err := o.directory()
+	if err != nil {
+		return err
+	}
+	c, err := o.containers()
+	if err != nil {
+		return err
+	}
+	if _, err = dir.CreateUser(n); err == nil {
+		_, err = c.CreateContainer(n, false)
+	}
+	return err
+}
+
+func doObjectGet(o *options, args []string) error {
+	args, err := o.parseN(args, 1, 2)
+	if err != nil {
+		return err
+	}
+	n, err := o.object(args[0], false)
+	if err != nil {
+		return err
+	}
+	s, err := o.storage()
+	if err != nil {
+		return err
+	}
+	in, err := s.GetContent(n)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if len(args) < 2 || args[1] == "-" {
+		_, err = io.Copy(o.cli.out, in)
+		return err
+	}
+	out, err := os.Create(args[1])
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(args[1])
+		return err
+	}
+	return out.Close()
+}
+
+type objectInfo struct {
+	Name       string            `json:"name"`
+	Header     oio.ContentHeader `json:"header"`
+	Properties map[string]string `json:"properties"`
+}
+
+func doObjectStat(o *options, args []string) error {
+	args, err := o.parseN(args, 1, 1)
+	if err != nil {
+		return err
+	}
+	n, err := o.object(args[0], false)
+	if err != nil {
+		return err
+	}
+	c, err := o.containers()
+	if err != nil {
+		return err
+	}
+	info := objectInfo{Name: n.String()}
+	if info.Header, err = c.StatContent(n); err != nil {
+		return err
+	}
+	if info.Properties, err = c.GetContentProperties(n); err != nil {
+		return err
+	}
+
+	hdr := info.Header
+	rows := [][]string{
+		{"name", hdr.Name},
+		{"id", hdr.Id},
+		{"version", strconv.FormatUint(hdr.Version, 10)},
+		{"size", strconv.FormatUint(hdr.Size, 10)},
+		{"hash", strings.ToLower(hdr.Hash)},
+		{"ctime", time.Unix(int64(hdr.CTime), 0).UTC().Format(time.RFC3339)},
+		{"policy", hdr.Policy},
+		{"chunk_method", hdr.ChunkMethod},
+		{"mime_type", hdr.MimeType},
+	}
+	for _, k := range sortedKeys(info.Properties) {
+		rows = append(rows, []string{"prop." + k, info.Properties[k]})
+	}
+	return o.print(info, []string{"FIELD", "VALUE"}, rows)
+}
+
+func doObjectDelete(o *options, args []string) error {
+	args, err := o.parseN(args, 1, -1)
+	if err != nil {
+		return err
+	}
+	s, err := o.storage()
+	if err != nil {
+		return err
+	}
+	out := make([]outcome, 0, len(args))
+	for _, arg := range args {
+		n, err := o.object(arg, false)
+		if err != nil {
+			return err
+		}
+		if err = s.DeleteContent(n); err != nil {
+			return err
+		}
+		out = append(out, outcome{n.String(), true})
+	}
+	return o.printOutcomes("deleted", out)
+}
+
+func doObjectLs(o *options, args []string) error {
+	recursive := o.fs.Bool("r", false, "List the whole tree under the prefix")
+	args, err := o.parseN(args, 0, 1)
+	if err != nil {
+		return err
+	}
+	prefix := ""
+	if len(args) > 0 {
+		prefix = args[0]
+	}
+	n, err := o.object(prefix, true)
+	if err != nil {
+		return err
+	}
+	c, err := o.containers()
+	if err != nil {
+		return err
+	}
+	p := oio.ListParams{Prefix: n.P}
+	if !*recursive {
+		p.Delimiter = "/"
+	}
+	n.P = ""
+	l, err := listContents(c, n, p, 0)
+	if err != nil {
+		return err
+	}
+	return o.printListing(l)
+}
+
+func doObjectProps(o *options, args []string) error {
+	del := o.fs.Bool("delete", false, "Delete the given keys")
+	args, err := o.parseN(args, 1, -1)
+	if err != nil {
+		return err
+	}
+	n, err := o.object(args[0], false)
+	if err != nil {
+		return err
+	}
+	c, err := o.containers()
+	if err != nil {
+		return err
+	}
+	return o.props(args[1:], *del, propertyStore{
+		get: func() (map[string]string, error) { return c.GetContentProperties(n) },
+		set: func(props map[string]string) (bool, error) { return c.SetContentProperties(n, props) },
+		del: func(keys []string) (bool, error) { return c.DeleteContentProperties(n, keys) },
+	})
+}
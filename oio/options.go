@@ -0,0 +1,229 @@
+/*
+OpenIO SDS Go client SDK
+Copyright (C) 2017-2018 OpenIO
+
+This library is free software; you can redistribute it and/or
+modify it under the terms of the GNU Lesser General Public
+License as published by the Free Software Foundation; either
+version 3.0 of the License, or (at your option) any later version.
+
+This library is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Lesser General Public License for more details.
+
+You should have received a copy of the GNU Lesser General Public
+License along with this library.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	oio "github.com/jfsmig/oio-go/sdk"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+const namePrefix = "oio://"
+
+// The flags common to all the actions, and the flag set where each action
+// declares its own flags.
+type options struct {
+	cli      *cli
+	fs       *flag.FlagSet
+	synopsis string
+	ns       string
+	account  string
+	user     string
+	typ      string
+	format   string
+}
+
+func (c *cli) options(name, args string) *options {
+	o := &options{cli: c, fs: flag.NewFlagSet(name, flag.ContinueOnError), synopsis: args}
+	o.fs.SetOutput(ioutil.Discard)
+	o.fs.StringVar(&o.ns, "ns", c.getenv("OIO_NS"), "Namespace")
+	o.fs.StringVar(&o.account, "account", c.getenv("OIO_ACCOUNT"), "Account")
+	o.fs.StringVar(&o.user, "user", c.getenv("OIO_USER"), "User, i.e. the name of the reference or the container")
+	o.fs.StringVar(&o.typ, "type", c.getenv("OIO_TYPE"), "Service subtype of the container")
+	o.fs.StringVar(&o.format, "format", "table", "Output format: table or json")
+	return o
+}
+
+// Parses the flags wherever they are among the positional arguments, and
+// returns the positional arguments.
+func (o *options) parse(args []string) ([]string, error) {
+	positional := make([]string, 0)
+	for {
+		if err := o.fs.Parse(args); err != nil {
+			return nil, usageError(o.fs.Name() + ": " + err.Error())
+		}
+		args = o.fs.Args()
+		if len(args) <= 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	if o.format != "table" && o.format != "json" {
+		return nil, usageError(o.fs.Name() + ": unknown format " + o.format)
+	}
+	return positional, nil
+}
+
+// Parses the flags and checks the count of positional arguments, -1 for no
+// maximum.
+func (o *options) parseN(args []string, min, max int) ([]string, error) {
+	args, err := o.parse(args)
+	if err == nil && (len(args) < min || (max >= 0 && len(args) > max)) {
+		err = usageError(o.fs.Name() + ": expected " + o.synopsis)
+	}
+	return args, err
+}
+
+// Resolves the name of a reference or a container: an oio:// URL, else the
+// name of the user, with the other components from the flags.
+func (o *options) container(arg string) (*oio.FlatName, error) {
+	var n *oio.FlatName
+	if strings.HasPrefix(arg, namePrefix) {
+		var err error
+		if n, err = oio.ParseName(arg); err != nil {
+			return nil, err
+		}
+		if len(n.P) > 0 || n.V != 0 || len(n.I) > 0 {
+			return nil, oio.NameError{Component: "url", Value: arg, Problem: "expected a container"}
+		}
+	} else {
+		n = &oio.FlatName{N: o.ns, A: o.account, U: arg, S: o.typ}
+	}
+	return n, oio.ValidateContainerName(n)
+}
+
+// Resolves the name of an object: an oio:// URL, else its path in the
+// container designated by the flags. With <prefix>, the path may be empty.
+func (o *options) object(arg string, prefix bool) (*oio.FlatName, error) {
+	var n *oio.FlatName
+	if strings.HasPrefix(arg, namePrefix) {
+		var err error
+		if n, err = oio.ParseName(arg); err != nil {
+			return nil, err
+		}
+	} else {
+		n = &oio.FlatName{N: o.ns, A: o.account, U: o.user, S: o.typ, P: arg}
+	}
+	if prefix && len(n.P) <= 0 {
+		return n, oio.ValidateContainerName(n)
+	}
+	return n, oio.ValidateObjectName(n)
+}
+
+func (o *options) directory() (oio.Directory, error) {
+	return oio.MakeMultiDirectoryClient(o.cli.cfg)
+}
+
+func (o *options) containers() (oio.Container, error) {
+	return oio.MakeMultiContainerClient(o.cli.cfg)
+}
+
+func (o *options) storage() (oio.ObjectStorage, error) {
+	return oio.MakeMultiObjectStorageClient(o.cli.cfg)
+}
+
+// Prints <v> as JSON, or the rows as a table under the header
+func (o *options) print(v interface{}, header []string, rows [][]string) error {
+	if o.format == "json" {
+		encoded, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(o.cli.out, string(encoded))
+		return err
+	}
+	w := tabwriter.NewWriter(o.cli.out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+// The outcome of an action changing a single item
+type outcome struct {
+	Name string `json:"name"`
+	Done bool   `json:"done"`
+}
+
+func (o *options) printOutcomes(what string, out []outcome) error {
+	table := make([][]string, 0, len(out))
+	for _, oc := range out {
+		table = append(table, []string{oc.Name, fmt.Sprint(oc.Done)})
+	}
+	return o.print(out, []string{"NAME", strings.ToUpper(what)}, table)
+}
+
+func sortedKeys(props map[string]string) []string {
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (o *options) printProperties(props map[string]string) error {
+	rows := make([][]string, 0, len(props))
+	for _, k := range sortedKeys(props) {
+		rows = append(rows, []string{k, props[k]})
+	}
+	return o.print(props, []string{"KEY", "VALUE"}, rows)
+}
+
+// Parses the KEY=VALUE arguments
+func parseProperties(args []string) (map[string]string, error) {
+	out := make(map[string]string)
+	for _, a := range args {
+		idx := strings.IndexByte(a, '=')
+		if idx <= 0 {
+			return nil, usageError("Expected KEY=VALUE, got [" + a + "]")
+		}
+		out[a[:idx]] = a[idx+1:]
+	}
+	return out, nil
+}
+
+// The common behavior of the "props" actions: print the properties, or set
+// those given as KEY=VALUE, or delete the keys given with -delete.
+type propertyStore struct {
+	get func() (map[string]string, error)
+	set func(props map[string]string) (bool, error)
+	del func(keys []string) (bool, error)
+}
+
+func (o *options) props(args []string, del bool, store propertyStore) error {
+	if del {
+		if len(args) <= 0 {
+			return usageError(o.fs.Name() + ": expected the keys to delete")
+		}
+		if _, err := store.del(args); err != nil {
+			return err
+		}
+	} else if len(args) > 0 {
+		props, err := parseProperties(args)
+		if err != nil {
+			return err
+		}
+		if _, err = store.set(props); err != nil {
+			return err
+		}
+	}
+	props, err := store.get()
+	if err != nil {
+		return err
+	}
+	return o.printProperties(props)
+}
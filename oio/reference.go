@@ -0,0 +1,155 @@
+/*
+OpenIO SDS Go client SDK
+Copyright (C) 2017-2018 OpenIO
+
+This library is free software; you can redistribute it and/or
+modify it under the terms of the GNU Lesser General Public
+License as published by the Free Software Foundation; either
+version 3.0 of the License, or (at your option) any later version.
+
+This library is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Lesser General Public License for more details.
+
+You should have received a copy of the GNU Lesser General Public
+License along with this library.
+*/
+
+package main
+
+import (
+	oio "github.com/jfsmig/oio-go/sdk"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var referenceActions = []action{
+	{"create", "NAME", "Create the reference", doReferenceCreate},
+	{"show", "NAME", "Print the services linked to the reference and its properties", doReferenceShow},
+	{"link", "NAME TYPE", "Link the reference to a service of the type, polled by the directory", doReferenceLink},
+	{"unlink", "NAME TYPE", "Unlink the reference from the services of the type", doReferenceUnlink},
+	{"force", "NAME TYPE HOST... [-seq N] [-args ARGS]", "Link the reference to the given services", doReferenceForce},
+	{"props", "NAME [KEY=VALUE...] [-delete KEY...]", "Print, set or delete the properties of the reference", doReferenceProps},
+}
+
+// Parses the flags and resolves the name in the first positional argument
+func (o *options) reference(args []string, min, max int) (oio.Directory, *oio.FlatName, []string, error) {
+	args, err := o.parseN(args, min, max)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	n, err := o.container(args[0])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	dir, err := o.directory()
+	return dir, n, args[1:], err
+}
+
+func (o *options) printServices(srv []oio.Service) error {
+	rows := make([][]string, 0, len(srv))
+	for _, s := range srv {
+		rows = append(rows, []string{s.Type, strconv.FormatUint(s.Seq, 10), s.Url, s.Args})
+	}
+	return o.print(srv, []string{"TYPE", "SEQ", "HOST", "ARGS"}, rows)
+}
+
+// Prints the services and the properties, in a single table
+func (o *options) printDump(v interface{}, dump oio.RefDump) error {
+	rows := make([][]string, 0)
+	for _, s := range dump.Directory {
+		rows = append(rows, []string{"dir", s.Type, strconv.FormatUint(s.Seq, 10), s.Url})
+	}
+	for _, s := range dump.Services {
+		rows = append(rows, []string{"srv", s.Type, strconv.FormatUint(s.Seq, 10), s.Url})
+	}
+	props := make([]oio.Property, len(dump.Properties))
+	copy(props, dump.Properties)
+	sort.Slice(props, func(i, j int) bool { return props[i].Key < props[j].Key })
+	for _, p := range props {
+		rows = append(rows, []string{"prop", p.Key, "", p.Value})
+	}
+	return o.print(v, []string{"KIND", "NAME", "SEQ", "VALUE"}, rows)
+}
+
+func doReferenceCreate(o *options, args []string) error {
+	dir, n, _, err := o.reference(args, 1, 1)
+	if err != nil {
+		return err
+	}
+	created, err := dir.CreateUser(n)
+	if err != nil {
+		return err
+	}
+	return o.printOutcomes("created", []outcome{{n.String(), created}})
+}
+
+func doReferenceShow(o *options, args []string) error {
+	dir, n, _, err := o.reference(args, 1, 1)
+	if err != nil {
+		return err
+	}
+	dump, err := dir.DumpUser(n)
+	if err != nil {
+		return err
+	}
+	return o.printDump(dump, dump)
+}
+
+func doReferenceLink(o *options, args []string) error {
+	dir, n, args, err := o.reference(args, 2, 2)
+	if err != nil {
+		return err
+	}
+	srv, err := dir.LinkServices(n, args[0])
+	if err != nil {
+		return err
+	}
+	return o.printServices(srv)
+}
+
+func doReferenceUnlink(o *options, args []string) error {
+	dir, n, args, err := o.reference(args, 2, 2)
+	if err != nil {
+		return err
+	}
+	done, err := dir.UnlinkServices(n, args[0])
+	if err != nil {
+		return err
+	}
+	return o.printOutcomes("unlinked", []outcome{{n.String(), done}})
+}
+
+func doReferenceForce(o *options, args []string) error {
+	seq := o.fs.Uint64("seq", 1, "Sequence number of the services")
+	srvArgs := o.fs.String("args", "", "Arguments of the services")
+	dir, n, args, err := o.reference(args, 3, -1)
+	if err != nil {
+		return err
+	}
+	srv := make([]oio.Service, 0, len(args)-1)
+	for _, host := range args[1:] {
+		for _, h := range strings.Split(host, ",") {
+			srv = append(srv, oio.Service{Seq: *seq, Type: args[0], Url: h, Args: *srvArgs})
+		}
+	}
+	if srv, err = dir.ForceServices(n, srv); err != nil {
+		return err
+	}
+	return o.printServices(srv)
+}
+
+func doReferenceProps(o *options, args []string) error {
+	del := o.fs.Bool("delete", false, "Delete the given keys")
+	dir, n, args, err := o.reference(args, 1, -1)
+	if err != nil {
+		return err
+	}
+	return o.props(args, *del, propertyStore{
+		get: func() (map[string]string, error) { return dir.GetAllProperties(n) },
+		set: func(props map[string]string) (bool, error) { return dir.SetProperties(n, props) },
+		del: func(keys []string) (bool, error) { return dir.DeleteProperties(n, keys) },
+	})
+}
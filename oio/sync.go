@@ -0,0 +1,149 @@
+/*
+OpenIO SDS Go client SDK
+Copyright (C) 2017-2018 OpenIO
+
+This library is free software; you can redistribute it and/or
+modify it under the terms of the GNU Lesser General Public
+License as published by the Free Software Foundation; either
+version 3.0 of the License, or (at your option) any later version.
+
+This library is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Lesser General Public License for more details.
+
+You should have received a copy of the GNU Lesser General Public
+License along with this library.
+*/
+
+package main
+
+import (
+	"errors"
+	oio "github.com/jfsmig/oio-go/sdk"
+	"strconv"
+	"strings"
+)
+
+const syncFlags = "[-delete] [-dry-run] [-parallel N] [-include GLOB...] [-exclude GLOB...] [-manifest FILE]"
+
+var syncActions = []action{
+	{"up", "DIR [PREFIX] [-policy P] " + syncFlags, "Upload the new and changed files of the directory under the prefix", doSyncUp},
+	{"down", "[PREFIX] DIR " + syncFlags, "Download the new and changed objects under the prefix into the directory", doSyncDown},
+}
+
+var errSyncFailed = errors.New("Some changes failed")
+
+// A flag that may be repeated
+type globList []string
+
+func (g *globList) String() string { return strings.Join(*g, ",") }
+
+func (g *globList) Set(v string) error {
+	*g = append(*g, v)
+	return nil
+}
+
+// A change of the synchronization, as printed
+type syncChange struct {
+	Path   string `json:"path"`
+	Action string `json:"action"`
+	Reason string `json:"reason"`
+	Size   uint64 `json:"size"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (o *options) syncOptions() *oio.SyncOptions {
+	opts := &oio.SyncOptions{}
+	o.fs.BoolVar(&opts.Delete, "delete", false, "Delete the items of the destination missing in the source")
+	o.fs.BoolVar(&opts.DryRun, "dry-run", false, "Only print the changes")
+	o.fs.IntVar(&opts.Concurrency, "parallel", 8, "How many transfers run at once")
+	o.fs.Var((*globList)(&opts.Include), "include", "Only consider the paths matching the glob")
+	o.fs.Var((*globList)(&opts.Exclude), "exclude", "Ignore the paths matching the glob")
+	o.fs.StringVar(&opts.Manifest, "manifest", "", "File caching the hashes, to resume an interrupted run")
+	return opts
+}
+
+// Runs the synchronization between <dir> and the prefix designated by <arg>
+func (o *options) sync(arg, dir string, opts *oio.SyncOptions, up bool) error {
+	n, err := o.object(arg, true)
+	if err != nil {
+		return err
+	}
+	c, err := o.containers()
+	if err != nil {
+		return err
+	}
+	s, err := o.storage()
+	if err != nil {
+		return err
+	}
+	if up && !opts.DryRun {
+		if ok, err := c.HasContainer(n); err != nil {
+			return err
+		} else if !ok {
+			if err = o.createContainer(n); err != nil {
+				return err
+			}
+		}
+	}
+
+	prefix := n.P
+	n.P = ""
+	syncer := oio.MakeSyncer(s, c, n, prefix, dir, *opts)
+	var ops []oio.SyncOp
+	if up {
+		ops, err = syncer.Upload()
+	} else {
+		ops, err = syncer.Download()
+	}
+	if err != nil {
+		return err
+	}
+
+	failed := false
+	changes := make([]syncChange, 0, len(ops))
+	rows := make([][]string, 0, len(ops))
+	for _, op := range ops {
+		ch := syncChange{Path: op.Path, Action: string(op.Action), Reason: op.Reason, Size: op.Size}
+		if op.Err != nil {
+			ch.Error, failed = op.Err.Error(), true
+		}
+		changes = append(changes, ch)
+		rows = append(rows, []string{ch.Path, ch.Action, ch.Reason, strconv.FormatUint(ch.Size, 10), ch.Error})
+	}
+	if err = o.print(changes, []string{"PATH", "ACTION", "REASON", "SIZE", "ERROR"}, rows); err != nil {
+		return err
+	}
+	if failed {
+		return errSyncFailed
+	}
+	return nil
+}
+
+func doSyncUp(o *options, args []string) error {
+	opts := o.syncOptions()
+	o.fs.StringVar(&opts.Policy, "policy", "", "Storage policy of the uploaded objects")
+	args, err := o.parseN(args, 1, 2)
+	if err != nil {
+		return err
+	}
+	prefix := ""
+	if len(args) > 1 {
+		prefix = args[1]
+	}
+	return o.sync(prefix, args[0], opts, true)
+}
+
+func doSyncDown(o *options, args []string) error {
+	opts := o.syncOptions()
+	args, err := o.parseN(args, 1, 2)
+	if err != nil {
+		return err
+	}
+	prefix := ""
+	if len(args) > 1 {
+		prefix, args = args[0], args[1:]
+	}
+	return o.sync(prefix, args[0], opts, false)
+}
@@ -0,0 +1,253 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2017-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oiotest
+
+import (
+	"fmt"
+	"github.com/jfsmig/oio-go/metadb"
+	oio "github.com/jfsmig/oio-go/sdk"
+	"sync"
+)
+
+// The policy applied when none is requested
+const DefaultPolicy = metadb.DefaultPolicy
+
+// Chooses the addresses of the rawx services for the <count> chunks of a
+// metachunk, or fails with ErrorNoService.
+type RawxSelector = metadb.RawxSelector
+
+// FakeContainer is an in-memory oio.Container for a single namespace, the
+// metadb.Container with injected faults. The contents are versioned like in
+// the real containers: each upload gets a new version, and when the
+// versioning is enabled the deletion of the latest version only adds a
+// deletion marker.
+type FakeContainer struct {
+	// The faults injected in all the methods
+	Faults *Faults
+
+	store *metadb.Container
+
+	lock     sync.Mutex
+	hasRawx  bool
+	selector RawxSelector
+}
+
+// Builds an empty container service for the namespace. When <d> is not nil,
+// the containers can only be created for the users known by <d>, unless the
+// autocreation is requested.
+func MakeFakeContainer(ns string, d *FakeDirectory) *FakeContainer {
+	var dir *metadb.Directory
+	if d != nil {
+		dir = d.store
+	}
+	c := &FakeContainer{Faults: makeFaults(), store: metadb.MakeContainer(ns, dir)}
+	c.store.SetRawxSelector(madeUpRawx)
+	return c
+}
+
+// Places the chunks of a metachunk on made-up services
+func madeUpRawx(count int) ([]string, error) {
+	out := make([]string, count)
+	for i := range out {
+		out[i] = fmt.Sprintf("127.0.0.1:%d", 6010+i)
+	}
+	return out, nil
+}
+
+// Declares the storage policy with the chunk method of its contents, e.g.
+// "plain/nb_copy=2".
+func (c *FakeContainer) SetPolicy(name, chunkMethod string) {
+	c.store.SetPolicy(name, chunkMethod)
+}
+
+// Sets the size of the metachunks of the next contents
+func (c *FakeContainer) SetChunkSize(size uint64) {
+	c.store.SetChunkSize(size)
+}
+
+// Sets the addresses of the rawx services the chunks are placed on. By
+// default, the chunks are placed on made-up services.
+func (c *FakeContainer) SetRawx(addrs []string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.store.SetRawx(addrs)
+	c.hasRawx = len(addrs) > 0
+	c.place()
+}
+
+// Makes the chunks placed by <sel> instead of on the services set with
+// SetRawx(). A nil selector restores the default placement.
+func (c *FakeContainer) SetRawxSelector(sel RawxSelector) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.selector = sel
+	c.place()
+}
+
+// The lock must be held
+func (c *FakeContainer) place() {
+	switch {
+	case c.selector != nil:
+		c.store.SetRawxSelector(c.selector)
+	case c.hasRawx:
+		c.store.SetRawxSelector(nil)
+	default:
+		c.store.SetRawxSelector(madeUpRawx)
+	}
+}
+
+// Keeps the old versions of the contents and the deletion markers, instead
+// of only the latest version.
+func (c *FakeContainer) SetVersioning(enabled bool) {
+	c.store.SetVersioning(enabled)
+}
+
+// Returns the rawx services set with SetRawx()
+func (c *FakeContainer) Rawx() []string {
+	return c.store.Rawx()
+}
+
+// Describes the namespace as the conscience does
+func (c *FakeContainer) NamespaceInfo() oio.NamespaceInfo {
+	return c.store.NamespaceInfo()
+}
+
+func (c *FakeContainer) CreateContainer(n oio.ContainerName, auto bool) (bool, error) {
+	if err := c.Faults.enter("CreateContainer"); err != nil {
+		return false, err
+	}
+	return c.store.CreateContainer(n, auto)
+}
+
+func (c *FakeContainer) DeleteContainer(n oio.ContainerName) (bool, error) {
+	if err := c.Faults.enter("DeleteContainer"); err != nil {
+		return false, err
+	}
+	return c.store.DeleteContainer(n)
+}
+
+func (c *FakeContainer) HasContainer(n oio.ContainerName) (bool, error) {
+	if err := c.Faults.enter("HasContainer"); err != nil {
+		return false, err
+	}
+	return c.store.HasContainer(n)
+}
+
+func (c *FakeContainer) GetContainerProperties(n oio.ContainerName) (map[string]string, error) {
+	if err := c.Faults.enter("GetContainerProperties"); err != nil {
+		return nil, err
+	}
+	return c.store.GetContainerProperties(n)
+}
+
+func (c *FakeContainer) SetContainerProperties(n oio.ContainerName, props map[string]string) (bool, error) {
+	if err := c.Faults.enter("SetContainerProperties"); err != nil {
+		return false, err
+	}
+	return c.store.SetContainerProperties(n, props)
+}
+
+func (c *FakeContainer) DeleteContainerProperties(n oio.ContainerName, keys []string) (bool, error) {
+	if err := c.Faults.enter("DeleteContainerProperties"); err != nil {
+		return false, err
+	}
+	return c.store.DeleteContainerProperties(n, keys)
+}
+
+func (c *FakeContainer) ListContents(n oio.ContainerName) (oio.ContainerListing, error) {
+	if err := c.Faults.enter("ListContents"); err != nil {
+		return oio.ContainerListing{}, err
+	}
+	return c.store.ListContents(n)
+}
+
+func (c *FakeContainer) ListContentsWithParams(n oio.ContainerName, p oio.ListParams) (oio.ContainerListing, error) {
+	if err := c.Faults.enter("ListContentsWithParams"); err != nil {
+		return oio.ContainerListing{}, err
+	}
+	return c.store.ListContentsWithParams(n, p)
+}
+
+func (c *FakeContainer) GetContent(n oio.ObjectName) (oio.Content, error) {
+	if err := c.Faults.enter("GetContent"); err != nil {
+		return oio.Content{}, err
+	}
+	return c.store.GetContent(n)
+}
+
+func (c *FakeContainer) StatContent(n oio.ObjectName) (oio.ContentHeader, error) {
+	if err := c.Faults.enter("StatContent"); err != nil {
+		return oio.ContentHeader{}, err
+	}
+	return c.store.StatContent(n)
+}
+
+func (c *FakeContainer) HasContent(n oio.ObjectName) (bool, error) {
+	if err := c.Faults.enter("HasContent"); err != nil {
+		return false, err
+	}
+	return c.store.HasContent(n)
+}
+
+func (c *FakeContainer) GenerateContent(n oio.ObjectName, size uint64, auto bool) (oio.Content, error) {
+	if err := c.Faults.enter("GenerateContent"); err != nil {
+		return oio.Content{}, err
+	}
+	return c.store.GenerateContent(n, size, auto)
+}
+
+func (c *FakeContainer) GenerateContentWithPolicy(n oio.ObjectName, size uint64, policy string, auto bool) (oio.Content, error) {
+	if err := c.Faults.enter("GenerateContentWithPolicy"); err != nil {
+		return oio.Content{}, err
+	}
+	return c.store.GenerateContentWithPolicy(n, size, policy, auto)
+}
+
+func (c *FakeContainer) PutContent(n oio.ContainerName, content oio.Content, auto bool) error {
+	if err := c.Faults.enter("PutContent"); err != nil {
+		return err
+	}
+	return c.store.PutContent(n, content, auto)
+}
+
+func (c *FakeContainer) DeleteContent(n oio.ObjectName) (bool, error) {
+	if err := c.Faults.enter("DeleteContent"); err != nil {
+		return false, err
+	}
+	return c.store.DeleteContent(n)
+}
+
+func (c *FakeContainer) GetContentProperties(n oio.ObjectName) (map[string]string, error) {
+	if err := c.Faults.enter("GetContentProperties"); err != nil {
+		return nil, err
+	}
+	return c.store.GetContentProperties(n)
+}
+
+func (c *FakeContainer) SetContentProperties(n oio.ObjectName, props map[string]string) (bool, error) {
+	if err := c.Faults.enter("SetContentProperties"); err != nil {
+		return false, err
+	}
+	return c.store.SetContentProperties(n, props)
+}
+
+func (c *FakeContainer) DeleteContentProperties(n oio.ObjectName, keys []string) (bool, error) {
+	if err := c.Faults.enter("DeleteContentProperties"); err != nil {
+		return false, err
+	}
+	return c.store.DeleteContentProperties(n, keys)
+}
@@ -0,0 +1,138 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2017-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oiotest
+
+import (
+	"fmt"
+	"github.com/jfsmig/oio-go/metadb"
+	oio "github.com/jfsmig/oio-go/sdk"
+	"sync"
+)
+
+// FakeDirectory is an in-memory oio.Directory for a single namespace, the
+// metadb.Directory with injected faults. The services linked to the users
+// are made up, they are not polled from any conscience.
+type FakeDirectory struct {
+	// The faults injected in all the methods
+	Faults *Faults
+
+	store *metadb.Directory
+}
+
+// Builds an empty directory for the namespace. The calls for any other
+// namespace fail with oio.ErrorNsNotManaged.
+func MakeFakeDirectory(ns string) *FakeDirectory {
+	d := &FakeDirectory{Faults: makeFaults(), store: metadb.MakeDirectory(ns)}
+	d.store.SetAllocator(madeUpServices())
+	return d
+}
+
+// Returns an allocator giving a new address to each service
+func madeUpServices() metadb.ServiceAllocator {
+	var lock sync.Mutex
+	last := 0
+	return func(srvtype string) (string, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		last++
+		return fmt.Sprintf("127.0.0.1:%d", 6000+last), nil
+	}
+}
+
+func (d *FakeDirectory) HasUser(n oio.UserName) (bool, error) {
+	if err := d.Faults.enter("HasUser"); err != nil {
+		return false, err
+	}
+	return d.store.HasUser(n)
+}
+
+func (d *FakeDirectory) CreateUser(n oio.UserName) (bool, error) {
+	if err := d.Faults.enter("CreateUser"); err != nil {
+		return false, err
+	}
+	return d.store.CreateUser(n)
+}
+
+func (d *FakeDirectory) DeleteUser(n oio.UserName) (bool, error) {
+	if err := d.Faults.enter("DeleteUser"); err != nil {
+		return false, err
+	}
+	return d.store.DeleteUser(n)
+}
+
+func (d *FakeDirectory) DumpUser(n oio.UserName) (oio.RefDump, error) {
+	if err := d.Faults.enter("DumpUser"); err != nil {
+		return oio.RefDump{}, err
+	}
+	return d.store.DumpUser(n)
+}
+
+func (d *FakeDirectory) LinkServices(n oio.UserName, srvtype string) ([]oio.Service, error) {
+	if err := d.Faults.enter("LinkServices"); err != nil {
+		return nil, err
+	}
+	return d.store.LinkServices(n, srvtype)
+}
+
+func (d *FakeDirectory) RenewServices(n oio.UserName, srvtype string) ([]oio.Service, error) {
+	if err := d.Faults.enter("RenewServices"); err != nil {
+		return nil, err
+	}
+	return d.store.RenewServices(n, srvtype)
+}
+
+func (d *FakeDirectory) ForceServices(n oio.UserName, srv []oio.Service) ([]oio.Service, error) {
+	if err := d.Faults.enter("ForceServices"); err != nil {
+		return nil, err
+	}
+	return d.store.ForceServices(n, srv)
+}
+
+func (d *FakeDirectory) ListServices(n oio.UserName, srvtype string) ([]oio.Service, error) {
+	if err := d.Faults.enter("ListServices"); err != nil {
+		return nil, err
+	}
+	return d.store.ListServices(n, srvtype)
+}
+
+func (d *FakeDirectory) UnlinkServices(n oio.UserName, srvtype string) (bool, error) {
+	if err := d.Faults.enter("UnlinkServices"); err != nil {
+		return false, err
+	}
+	return d.store.UnlinkServices(n, srvtype)
+}
+
+func (d *FakeDirectory) GetAllProperties(n oio.UserName) (map[string]string, error) {
+	if err := d.Faults.enter("GetAllProperties"); err != nil {
+		return nil, err
+	}
+	return d.store.GetAllProperties(n)
+}
+
+func (d *FakeDirectory) SetProperties(n oio.UserName, props map[string]string) (bool, error) {
+	if err := d.Faults.enter("SetProperties"); err != nil {
+		return false, err
+	}
+	return d.store.SetProperties(n, props)
+}
+
+func (d *FakeDirectory) DeleteProperties(n oio.UserName, keys []string) (bool, error) {
+	if err := d.Faults.enter("DeleteProperties"); err != nil {
+		return false, err
+	}
+	return d.store.DeleteProperties(n, keys)
+}
@@ -0,0 +1,121 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2017-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+// Package oiotest provides in-memory implementations of the Directory,
+// Container and ObjectStorage interfaces of the SDK, for the unit tests of
+// the applications built on them. The fakes are safe for concurrent use and
+// accept injected faults.
+package oiotest
+
+import (
+	"errors"
+	"github.com/jfsmig/oio-go/metadb"
+	"sync"
+	"time"
+)
+
+// The error returned by a fault registered without an explicit error
+var ErrorInjected = errors.New("Injected fault")
+
+// Returned when the operation conflicts with the current state, e.g. the
+// deletion of a container that still holds contents.
+var ErrorConflict = metadb.ErrorConflict
+
+// Returned when too few services are available to place the chunks
+var ErrorNoService = metadb.ErrorNoService
+
+// Matches all the methods in Faults.FailOn()
+const AnyMethod = ""
+
+type faultRule struct {
+	method string
+	nth    int
+	err    error
+}
+
+// Faults drives the errors and the latency injected in the calls to a fake.
+// The methods are designated by their name in the interface of the SDK, e.g.
+// "CreateUser".
+type Faults struct {
+	lock    sync.Mutex
+	calls   map[string]int
+	rules   []faultRule
+	latency time.Duration
+}
+
+func makeFaults() *Faults {
+	return &Faults{calls: make(map[string]int), rules: make([]faultRule, 0)}
+}
+
+// Makes the <nth> call (counting from 1, since the last Reset()) of the
+// method fail with <err>. With <nth> at 0, all the calls fail. With
+// AnyMethod, the calls to all the methods are counted together.
+func (f *Faults) FailOn(method string, nth int, err error) {
+	if err == nil {
+		err = ErrorInjected
+	}
+	f.lock.Lock()
+	f.rules = append(f.rules, faultRule{method: method, nth: nth, err: err})
+	f.lock.Unlock()
+}
+
+// Delays each call by <d>
+func (f *Faults) SetLatency(d time.Duration) {
+	f.lock.Lock()
+	f.latency = d
+	f.lock.Unlock()
+}
+
+// Returns how many times the method has been called, AnyMethod for all the
+// methods.
+func (f *Faults) Calls(method string) int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.calls[method]
+}
+
+// Forgets the counters, the rules and the latency
+func (f *Faults) Reset() {
+	f.lock.Lock()
+	f.calls = make(map[string]int)
+	f.rules = make([]faultRule, 0)
+	f.latency = 0
+	f.lock.Unlock()
+}
+
+// Called at the beginning of each method of the fakes
+func (f *Faults) enter(method string) error {
+	f.lock.Lock()
+	f.calls[method]++
+	f.calls[AnyMethod]++
+	latency := f.latency
+	var err error
+	for _, r := range f.rules {
+		if r.method != AnyMethod && r.method != method {
+			continue
+		}
+		if r.nth == 0 || r.nth == f.calls[r.method] {
+			err = r.err
+			break
+		}
+	}
+	f.lock.Unlock()
+
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+	return err
+}
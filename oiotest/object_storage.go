@@ -0,0 +1,267 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2017-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oiotest
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	oio "github.com/jfsmig/oio-go/sdk"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Returned by GetContent() when all the chunks of a metachunk are lost
+var ErrorChunkLost = errors.New("All the chunks of a metachunk are lost")
+
+// Returned for the chunk methods the fake doesn't store, e.g. EC
+var ErrorNotImplemented = errors.New("Chunk method not implemented")
+
+// FakeObjectStorage is an in-memory oio.ObjectStorage whose contents are
+// described in a FakeContainer, and whose chunks are kept in memory. The
+// bulk operations are those of the SDK, running on the FakeContainer.
+type FakeObjectStorage struct {
+	// The faults injected in all the methods
+	Faults *Faults
+
+	container *FakeContainer
+	bulk      oio.ObjectStorage
+
+	lock  sync.Mutex
+	blobs map[string][]byte
+}
+
+// Builds an object storage on the given fakes. <d> is optional.
+func MakeFakeObjectStorage(d *FakeDirectory, c *FakeContainer) *FakeObjectStorage {
+	var dir oio.Directory
+	if d != nil {
+		dir = d
+	}
+	bulk, _ := oio.MakeObjectStorageClient(dir, c)
+	return &FakeObjectStorage{
+		Faults:    makeFaults(),
+		container: c,
+		bulk:      bulk,
+		blobs:     make(map[string][]byte),
+	}
+}
+
+func md5Hex(b []byte) string {
+	sum := md5.Sum(b)
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// Returns the chunks grouped by metachunk, in the order of the positions
+func groupChunks(chunks []oio.Chunk) ([][]oio.Chunk, error) {
+	out := make([][]oio.Chunk, 0)
+	for _, chunk := range chunks {
+		pos, err := strconv.Atoi(chunk.Position)
+		if err != nil || pos < 0 {
+			return nil, oio.LayoutError{Position: chunk.Position, Problem: "invalid position"}
+		}
+		for len(out) <= pos {
+			out = append(out, make([]oio.Chunk, 0))
+		}
+		out[pos] = append(out[pos], chunk)
+	}
+	return out, nil
+}
+
+func (o *FakeObjectStorage) PutContent(n oio.ObjectName, size uint64, auto bool, in io.ReadSeeker) error {
+	if err := o.Faults.enter("PutContent"); err != nil {
+		return err
+	}
+	return o.put(n, size, "", nil, auto, in)
+}
+
+func (o *FakeObjectStorage) PutContentWithPolicy(n oio.ObjectName, size uint64, policy string, auto bool, in io.ReadSeeker) error {
+	if err := o.Faults.enter("PutContentWithPolicy"); err != nil {
+		return err
+	}
+	return o.put(n, size, policy, nil, auto, in)
+}
+
+func (o *FakeObjectStorage) PutContentWithProperties(n oio.ObjectName, size uint64, policy string, props map[string]string, auto bool, in io.ReadSeeker) error {
+	if err := o.Faults.enter("PutContentWithProperties"); err != nil {
+		return err
+	}
+	return o.put(n, size, policy, props, auto, in)
+}
+
+func (o *FakeObjectStorage) put(n oio.ObjectName, size uint64, policy string, props map[string]string, auto bool, in io.ReadSeeker) error {
+	data := make([]byte, size)
+	if _, err := io.ReadFull(in, data); err != nil {
+		return err
+	}
+
+	content, err := o.container.GenerateContentWithPolicy(n, size, policy, auto)
+	if err != nil {
+		return err
+	}
+	cm, err := oio.ParseChunkMethod(content.Header.ChunkMethod)
+	if err != nil {
+		return err
+	}
+	if !cm.IsPlain() {
+		return ErrorNotImplemented
+	}
+
+	// Cut the data into metachunks, each stored in all its chunks
+	blobs := make(map[string][]byte)
+	for i := range content.Chunks {
+		chunk := &content.Chunks[i]
+		pos, _ := strconv.ParseUint(chunk.Position, 10, 64)
+		offset := pos * chunk.Size
+		end := offset + chunk.Size
+		if end > size {
+			end = size
+		}
+		part := append(make([]byte, 0, end-offset), data[offset:end]...)
+		chunk.Size = uint64(len(part))
+		chunk.Hash = md5Hex(part)
+		blobs[chunk.Url] = part
+	}
+	content.Header.Hash = md5Hex(data)
+	for k, v := range props {
+		content.Properties = append(content.Properties, oio.Property{Key: k, Value: v})
+	}
+
+	o.lock.Lock()
+	for url, part := range blobs {
+		o.blobs[url] = part
+	}
+	o.lock.Unlock()
+
+	return o.container.PutContent(n, content, auto)
+}
+
+// Reads each metachunk from its first chunk still present, and fails with
+// ErrorChunkLost if none is left.
+func (o *FakeObjectStorage) GetContent(n oio.ObjectName) (io.ReadCloser, error) {
+	if err := o.Faults.enter("GetContent"); err != nil {
+		return nil, err
+	}
+	content, err := o.container.GetContent(n)
+	if err != nil {
+		return nil, err
+	}
+	cm, err := oio.ParseChunkMethod(content.Header.ChunkMethod)
+	if err != nil {
+		return nil, err
+	}
+	if !cm.IsPlain() {
+		return nil, ErrorNotImplemented
+	}
+	mcSet, err := groupChunks(content.Chunks)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	for _, mc := range mcSet {
+		found := false
+		for _, chunk := range mc {
+			if part, ok := o.blobs[chunk.Url]; ok {
+				out.Write(part)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, ErrorChunkLost
+		}
+	}
+	return ioutil.NopCloser(&out), nil
+}
+
+// Drops the chunk at the given URL, as if its rawx lost it
+func (o *FakeObjectStorage) LoseChunk(url string) {
+	o.lock.Lock()
+	delete(o.blobs, url)
+	o.lock.Unlock()
+}
+
+// Drops <count> chunks of each metachunk of the content, and returns their
+// URL. With <count> at least the number of copies, the content is lost.
+func (o *FakeObjectStorage) LoseChunks(n oio.ObjectName, count int) ([]string, error) {
+	content, err := o.container.GetContent(n)
+	if err != nil {
+		return nil, err
+	}
+	mcSet, err := groupChunks(content.Chunks)
+	if err != nil {
+		return nil, err
+	}
+	lost := make([]string, 0)
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	for _, mc := range mcSet {
+		for i := 0; i < count && i < len(mc); i++ {
+			delete(o.blobs, mc[i].Url)
+			lost = append(lost, mc[i].Url)
+		}
+	}
+	return lost, nil
+}
+
+func (o *FakeObjectStorage) StatContent(n oio.ObjectName) (oio.ContentHeader, error) {
+	if err := o.Faults.enter("StatContent"); err != nil {
+		return oio.ContentHeader{}, err
+	}
+	return o.bulk.StatContent(n)
+}
+
+func (o *FakeObjectStorage) HasContent(n oio.ObjectName) (bool, error) {
+	if err := o.Faults.enter("HasContent"); err != nil {
+		return false, err
+	}
+	return o.bulk.HasContent(n)
+}
+
+func (o *FakeObjectStorage) DeleteContent(n oio.ObjectName) error {
+	if err := o.Faults.enter("DeleteContent"); err != nil {
+		return err
+	}
+	return o.bulk.DeleteContent(n)
+}
+
+func (o *FakeObjectStorage) DeleteContents(n oio.ContainerName, paths []string, max int) ([]oio.DeleteResult, error) {
+	if err := o.Faults.enter("DeleteContents"); err != nil {
+		return nil, err
+	}
+	return o.bulk.DeleteContents(n, paths, max)
+}
+
+func (o *FakeObjectStorage) DeleteContentsWithPrefix(n oio.ContainerName, prefix string, max int) ([]oio.DeleteResult, error) {
+	if err := o.Faults.enter("DeleteContentsWithPrefix"); err != nil {
+		return nil, err
+	}
+	return o.bulk.DeleteContentsWithPrefix(n, prefix, max)
+}
+
+func (o *FakeObjectStorage) PurgeContainer(n oio.ContainerName, keep int, destroy bool, max int) ([]oio.DeleteResult, error) {
+	if err := o.Faults.enter("PurgeContainer"); err != nil {
+		return nil, err
+	}
+	return o.bulk.PurgeContainer(n, keep, destroy, max)
+}
@@ -0,0 +1,237 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2017-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oiotest
+
+import (
+	"bytes"
+	oio "github.com/jfsmig/oio-go/sdk"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	_ oio.Directory     = (*FakeDirectory)(nil)
+	_ oio.Container     = (*FakeContainer)(nil)
+	_ oio.ObjectStorage = (*FakeObjectStorage)(nil)
+)
+
+func TestFake_Directory(t *testing.T) {
+	d := MakeFakeDirectory("NS")
+	n := oio.FlatName{N: "NS", A: "ACCT", U: "JFS"}
+
+	if ok, err := d.CreateUser(&n); !ok || err != nil {
+		t.Fatal("CreateUser failed: ", err)
+	}
+	if ok, err := d.CreateUser(&n); ok || err != nil {
+		t.Fatal("CreateUser didn't find the user: ", err)
+	}
+	srv, err := d.LinkServices(&n, "meta2")
+	if err != nil || len(srv) != 1 {
+		t.Fatal("LinkServices failed: ", err)
+	}
+	if again, _ := d.LinkServices(&n, "meta2"); again[0] != srv[0] {
+		t.Fatal("LinkServices changed the service")
+	}
+	if renewed, _ := d.RenewServices(&n, "meta2"); renewed[0].Seq != 2 {
+		t.Fatal("RenewServices kept the sequence")
+	}
+	if _, err = d.DeleteUser(&n); err != ErrorConflict {
+		t.Fatal("DeleteUser ignored the services")
+	}
+	d.UnlinkServices(&n, "meta2")
+	if ok, err := d.DeleteUser(&n); !ok || err != nil {
+		t.Fatal("DeleteUser failed: ", err)
+	}
+	if _, err = d.DumpUser(&n); err != oio.ErrorNotFound {
+		t.Fatal("DumpUser found a deleted user")
+	}
+	other := oio.FlatName{N: "NS2", A: "ACCT", U: "JFS"}
+	if _, err = d.HasUser(&other); err != oio.ErrorNsNotManaged {
+		t.Fatal("Namespace not checked")
+	}
+}
+
+func TestFake_ContainerVersions(t *testing.T) {
+	c := MakeFakeContainer("NS", MakeFakeDirectory("NS"))
+	c.SetVersioning(true)
+	n := oio.FlatName{N: "NS", A: "ACCT", U: "JFS", P: "obj"}
+
+	if _, err := c.CreateContainer(&n, false); err != oio.ErrorNotFound {
+		t.Fatal("Container created without user")
+	}
+	versions := make([]uint64, 0)
+	for i := 0; i < 3; i++ {
+		content, err := c.GenerateContent(&n, 10, true)
+		if err != nil {
+			t.Fatal("GenerateContent failed: ", err)
+		}
+		if err = c.PutContent(&n, content, false); err != nil {
+			t.Fatal("PutContent failed: ", err)
+		}
+		versions = append(versions, content.Header.Version)
+	}
+	if versions[0] >= versions[1] || versions[1] >= versions[2] {
+		t.Fatal("Versions not increasing: ", versions)
+	}
+	if h, _ := c.StatContent(&n); h.Version != versions[2] {
+		t.Fatal("Not the latest version")
+	}
+
+	if ok, err := c.DeleteContent(&n); !ok || err != nil {
+		t.Fatal("DeleteContent failed: ", err)
+	}
+	if ok, _ := c.HasContent(&n); ok {
+		t.Fatal("Deleted content found")
+	}
+	l, _ := c.ListContentsWithParams(&n, oio.ListParams{Versions: true})
+	if len(l.Objects) != 4 || !l.Objects[0].Deleted {
+		t.Fatal("Bad versions listing: ", l.Objects)
+	}
+	old := n
+	old.V = versions[0]
+	if ok, _ := c.HasContent(&old); !ok {
+		t.Fatal("Old version not found")
+	}
+	if _, err := c.DeleteContainer(&n); err != ErrorConflict {
+		t.Fatal("Non-empty container deleted")
+	}
+}
+
+func TestFake_ContainerListing(t *testing.T) {
+	c := MakeFakeContainer("NS", nil)
+	n := oio.FlatName{N: "NS", A: "ACCT", U: "JFS"}
+	for _, p := range []string{"a", "d/1", "d/2", "e", "f"} {
+		n.P = p
+		content, _ := c.GenerateContent(&n, 0, true)
+		c.PutContent(&n, content, false)
+	}
+
+	params := oio.ListParams{Delimiter: "/", Max: 2}
+	names := make([]string, 0)
+	for {
+		l, err := c.ListContentsWithParams(&n, params)
+		if err != nil {
+			t.Fatal("List failed: ", err)
+		}
+		names = append(names, l.Prefixes...)
+		for _, h := range l.Objects {
+			names = append(names, h.Name)
+		}
+		if !l.Truncated {
+			break
+		}
+		params.Marker = l.NextMarker
+	}
+	sort.Strings(names)
+	if len(names) != 4 || names[0] != "a" || names[1] != "d/" || names[3] != "f" {
+		t.Fatal("Bad listing: ", names)
+	}
+}
+
+func TestFake_ObjectStorage(t *testing.T) {
+	c := MakeFakeContainer("NS", nil)
+	c.SetChunkSize(4)
+	o := MakeFakeObjectStorage(nil, c)
+	n := oio.FlatName{N: "NS", A: "ACCT", U: "JFS", P: "obj"}
+	data := []byte("0123456789")
+
+	if err := o.PutContentWithPolicy(&n, 10, "THREECOPIES", true, bytes.NewReader(data)); err != nil {
+		t.Fatal("PutContent failed: ", err)
+	}
+	content, _ := c.GetContent(&n)
+	if len(content.Chunks) != 9 || content.Header.Size != 10 {
+		t.Fatal("Bad chunks: ", content.Chunks)
+	}
+
+	if _, err := o.LoseChunks(&n, 2); err != nil {
+		t.Fatal("LoseChunks failed: ", err)
+	}
+	r, err := o.GetContent(&n)
+	if err != nil {
+		t.Fatal("GetContent failed: ", err)
+	}
+	if got, _ := ioutil.ReadAll(r); !bytes.Equal(got, data) {
+		t.Fatal("Data mismatch: ", string(got))
+	}
+	o.LoseChunks(&n, 3)
+	if _, err = o.GetContent(&n); err != ErrorChunkLost {
+		t.Fatal("Lost content read")
+	}
+
+	results, err := o.PurgeContainer(&n, 0, true, 0)
+	if err != nil || len(results) != 1 || results[0].Err != nil {
+		t.Fatal("Purge failed: ", results, err)
+	}
+	if ok, _ := c.HasContainer(&n); ok {
+		t.Fatal("Container not destroyed")
+	}
+}
+
+func TestFake_Faults(t *testing.T) {
+	d := MakeFakeDirectory("NS")
+	n := oio.FlatName{N: "NS", A: "ACCT", U: "JFS"}
+
+	d.Faults.FailOn("HasUser", 2, nil)
+	for i := 1; i <= 3; i++ {
+		_, err := d.HasUser(&n)
+		if (i == 2) != (err == ErrorInjected) {
+			t.Fatal("Unexpected result at call ", i, ": ", err)
+		}
+	}
+	if d.Faults.Calls("HasUser") != 3 || d.Faults.Calls(AnyMethod) != 3 {
+		t.Fatal("Bad call counters")
+	}
+
+	d.Faults.Reset()
+	d.Faults.SetLatency(10 * time.Millisecond)
+	pre := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.CreateUser(&n)
+		}()
+	}
+	wg.Wait()
+	if time.Since(pre) < 10*time.Millisecond {
+		t.Fatal("No latency injected")
+	}
+	if ok, _ := d.HasUser(&n); !ok {
+		t.Fatal("User not created")
+	}
+}
+
+func TestFake_RawxSelector(t *testing.T) {
+	c := MakeFakeContainer("NS", nil)
+	n := oio.FlatName{N: "NS", A: "ACCT", U: "JFS", P: "obj"}
+	c.SetRawxSelector(func(count int) ([]string, error) {
+		return []string{"10.0.0.1:6000", "10.0.0.2:6000", "10.0.0.3:6000"}[:count], nil
+	})
+	content, err := c.GenerateContentWithPolicy(&n, 10, "THREECOPIES", true)
+	if err != nil || !strings.Contains(content.Chunks[2].Url, "10.0.0.3:6000") {
+		t.Fatal("Selector not used: ", content.Chunks, err)
+	}
+	c.SetRawxSelector(func(count int) ([]string, error) { return nil, nil })
+	if _, err = c.GenerateContent(&n, 10, true); err != ErrorNoService {
+		t.Fatal("Missing services not detected: ", err)
+	}
+}
@@ -0,0 +1,36 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2017-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oiotest
+
+import (
+	"github.com/jfsmig/oio-go/metadb"
+)
+
+// Proxy serves the subset of the HTTP API of the oio-proxy used by the SDK,
+// as the metadb.Server, with a FakeDirectory and a FakeContainer as the
+// storage. The chunks are placed on the rawx services set with
+// FakeContainer.SetRawx().
+type Proxy struct {
+	*metadb.Server
+	directory *FakeDirectory
+	container *FakeContainer
+}
+
+// Builds a proxy serving the namespace with the given fakes
+func MakeProxy(ns string, d *FakeDirectory, c *FakeContainer) *Proxy {
+	return &Proxy{Server: metadb.MakeServer(ns, d, c), directory: d, container: c}
+}
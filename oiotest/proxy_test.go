@@ -0,0 +1,163 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2017-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oiotest
+
+import (
+	oio "github.com/jfsmig/oio-go/sdk"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func startProxy(t *testing.T) (*Proxy, oio.Config, func()) {
+	d := MakeFakeDirectory("NS")
+	c := MakeFakeContainer("NS", d)
+	c.SetRawx([]string{"127.0.0.1:6010", "127.0.0.1:6011", "127.0.0.1:6012"})
+	p := MakeProxy("NS", d, c)
+	srv := httptest.NewServer(p)
+	cfg := oio.MakeStaticConfig()
+	cfg.Set("NS", oio.KeyProxy, strings.TrimPrefix(srv.URL, "http://"))
+	cfg.Set("NS", oio.KeyAutocreate, "true")
+	return p, cfg, srv.Close
+}
+
+func TestProxy_Directory(t *testing.T) {
+	_, cfg, stop := startProxy(t)
+	defer stop()
+	d, _ := oio.MakeDirectoryClient("NS", cfg)
+	n := oio.FlatName{N: "NS", A: "ACCT", U: "JFS"}
+
+	if ok, _ := d.HasUser(&n); ok {
+		t.Fatal("Unexpected user")
+	}
+	if _, err := d.CreateUser(&n); err != nil {
+		t.Fatal("CreateUser failed: ", err)
+	}
+	srv, err := d.LinkServices(&n, "meta2")
+	if err != nil || len(srv) != 1 {
+		t.Fatal("LinkServices failed: ", err)
+	}
+	if _, err = d.SetProperties(&n, map[string]string{"k": "v"}); err != nil {
+		t.Fatal("SetProperties failed: ", err)
+	}
+	dump, err := d.DumpUser(&n)
+	if err != nil || len(dump.Services) != 1 || len(dump.Properties) != 1 {
+		t.Fatal("DumpUser failed: ", dump, err)
+	}
+	if _, err = d.DeleteUser(&n); err == nil {
+		t.Fatal("DeleteUser ignored the services")
+	}
+}
+
+func TestProxy_Content(t *testing.T) {
+	_, cfg, stop := startProxy(t)
+	defer stop()
+	c, _ := oio.MakeContainerClient("NS", cfg)
+	n := oio.FlatName{N: "NS", A: "ACCT", U: "JFS", P: "obj"}
+
+	content, err := c.GenerateContentWithPolicy(&n, 10, "THREECOPIES", true)
+	if err != nil {
+		t.Fatal("GenerateContent failed: ", err)
+	}
+	if len(content.Chunks) != 3 || !strings.HasPrefix(content.Chunks[0].Url, "http://127.0.0.1:601") {
+		t.Fatal("Bad chunks: ", content.Chunks)
+	}
+	if err = c.PutContent(&n, content, true); err != nil {
+		t.Fatal("PutContent failed: ", err)
+	}
+
+	hdr, err := c.StatContent(&n)
+	if err != nil {
+		t.Fatal("StatContent failed: ", err)
+	}
+	if hdr.Id != content.Header.Id || hdr.Policy != "THREECOPIES" || hdr.Size != 10 {
+		t.Fatal("Bad header: ", hdr)
+	}
+	got, err := c.GetContent(&n)
+	if err != nil || len(got.Chunks) != 3 {
+		t.Fatal("GetContent failed: ", got, err)
+	}
+
+	if _, err = c.SetContentProperties(&n, map[string]string{"a": "1", "b": "2"}); err != nil {
+		t.Fatal("SetContentProperties failed: ", err)
+	}
+	if _, err = c.DeleteContentProperties(&n, []string{"a"}); err != nil {
+		t.Fatal("DeleteContentProperties failed: ", err)
+	}
+	props, err := c.GetContentProperties(&n)
+	if err != nil || len(props) != 1 || props["b"] != "2" {
+		t.Fatal("Bad properties: ", props, err)
+	}
+
+	l, err := c.ListContentsWithParams(&n, oio.ListParams{Max: 1})
+	if err != nil || len(l.Objects) != 1 || l.Truncated {
+		t.Fatal("List failed: ", l, err)
+	}
+	if _, err = c.DeleteContent(&n); err != nil {
+		t.Fatal("DeleteContent failed: ", err)
+	}
+	if _, err = c.StatContent(&n); err != oio.ErrorNotFound {
+		t.Fatal("Deleted content found: ", err)
+	}
+}
+
+func TestProxy_State(t *testing.T) {
+	p, cfg, stop := startProxy(t)
+	defer stop()
+	dir, err := ioutil.TempDir("", "oiotest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+	p.OnChange = func() {
+		if err := SaveState(path, p.directory, p.container); err != nil {
+			t.Error("SaveState failed: ", err)
+		}
+	}
+
+	c, _ := oio.MakeContainerClient("NS", cfg)
+	n := oio.FlatName{N: "NS", A: "ACCT", U: "JFS", P: "obj"}
+	content, _ := c.GenerateContent(&n, 0, true)
+	if err = c.PutContent(&n, content, true); err != nil {
+		t.Fatal("PutContent failed: ", err)
+	}
+	if _, err = c.SetContainerProperties(&n, map[string]string{"a": "1", "b": "2"}); err != nil {
+		t.Fatal("SetContainerProperties failed: ", err)
+	}
+	if _, err = c.DeleteContainerProperties(&n, []string{"a"}); err != nil {
+		t.Fatal("DeleteContainerProperties failed: ", err)
+	}
+
+	d2 := MakeFakeDirectory("NS")
+	c2 := MakeFakeContainer("NS", d2)
+	if err = LoadState(path, d2, c2); err != nil {
+		t.Fatal("LoadState failed: ", err)
+	}
+	if ok, _ := c2.HasContent(&n); !ok {
+		t.Fatal("Content not restored")
+	}
+	if ok, _ := d2.HasUser(&n); !ok {
+		t.Fatal("User not restored")
+	}
+	if props, err := c2.GetContainerProperties(&n); err != nil || len(props) != 1 || props["b"] != "2" {
+		t.Fatal("Bad container properties: ", props, err)
+	}
+}
@@ -0,0 +1,265 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2017-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oiotest
+
+import (
+	"fmt"
+	oio "github.com/jfsmig/oio-go/sdk"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The name of the counters of the requests, by method
+var rawxStatNames = map[string]string{"PUT": "put", "GET": "get", "HEAD": "head", "DELETE": "del"}
+
+type fakeChunk struct {
+	data  []byte
+	attrs http.Header
+}
+
+// FakeRawx is an in-memory rawx service, serving the chunks, their listing
+// and its counters with the same API as the oio-rawx.
+type FakeRawx struct {
+	// The faults injected in the requests, by HTTP method
+	Faults *Faults
+
+	lock   sync.Mutex
+	chunks map[oio.ChunkId]*fakeChunk
+	hits   map[string]uint64
+}
+
+// Builds an empty rawx
+func MakeFakeRawx() *FakeRawx {
+	return &FakeRawx{
+		Faults: makeFaults(),
+		chunks: make(map[oio.ChunkId]*fakeChunk),
+		hits:   make(map[string]uint64),
+	}
+}
+
+// Returns the number of chunks held
+func (r *FakeRawx) Count() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return len(r.chunks)
+}
+
+func (r *FakeRawx) ServeHTTP(rep http.ResponseWriter, req *http.Request) {
+	if err := r.Faults.enter(req.Method); err != nil {
+		rep.Header().Set("X-Error", err.Error())
+		rep.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	r.lock.Lock()
+	if name, ok := rawxStatNames[req.Method]; ok {
+		r.hits[name]++
+	} else {
+		r.hits["other"]++
+	}
+	r.lock.Unlock()
+
+	switch req.URL.Path {
+	case "/stat":
+		r.serveStat(rep)
+		return
+	case "/list":
+		r.serveList(rep, req)
+		return
+	}
+
+	id, err := oio.ParseChunkId(path.Base(req.URL.Path))
+	if err != nil {
+		rep.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	switch req.Method {
+	case "PUT":
+		r.put(rep, req, id)
+	case "GET", "HEAD":
+		r.get(rep, req, id)
+	case "DELETE":
+		r.lock.Lock()
+		_, ok := r.chunks[id]
+		delete(r.chunks, id)
+		r.lock.Unlock()
+		if ok {
+			rep.WriteHeader(http.StatusNoContent)
+		} else {
+			rep.WriteHeader(http.StatusNotFound)
+		}
+	default:
+		rep.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (r *FakeRawx) put(rep http.ResponseWriter, req *http.Request, id oio.ChunkId) {
+	data, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		rep.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c := &fakeChunk{data: data, attrs: http.Header{}}
+	for k, v := range req.Header {
+		if strings.HasPrefix(k, "X-Oio-") {
+			c.attrs[k] = v
+		}
+	}
+	h := md5Hex(data)
+	if v := c.attrs.Get(oio.RawxHeaderChunkHash); len(v) > 0 && strings.ToUpper(v) != h {
+		rep.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.attrs.Set(oio.RawxHeaderChunkHash, h)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, ok := r.chunks[id]; ok {
+		rep.WriteHeader(http.StatusForbidden)
+		return
+	}
+	r.chunks[id] = c
+	rep.Header().Set("chunkhash", h)
+	rep.WriteHeader(http.StatusOK)
+}
+
+func (r *FakeRawx) get(rep http.ResponseWriter, req *http.Request, id oio.ChunkId) {
+	r.lock.Lock()
+	c, ok := r.chunks[id]
+	r.lock.Unlock()
+	if !ok {
+		rep.WriteHeader(http.StatusNotFound)
+		return
+	}
+	for k, v := range c.attrs {
+		rep.Header()[k] = v
+	}
+
+	data, code := c.data, http.StatusOK
+	if hdr := req.Header.Get("Range"); len(hdr) > 0 {
+		var first, last int
+		n, err := fmt.Sscanf(hdr, "bytes=%d-%d", &first, &last)
+		if err != nil || n != 2 || first > last || first >= len(data) {
+			rep.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		if last >= len(data) {
+			last = len(data) - 1
+		}
+		data, code = data[first:last+1], http.StatusPartialContent
+	}
+	rep.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	rep.WriteHeader(code)
+	if req.Method == "GET" {
+		rep.Write(data)
+	}
+}
+
+func (r *FakeRawx) serveList(rep http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	marker := strings.ToUpper(q.Get("marker"))
+	prefix := strings.ToUpper(q.Get("prefix"))
+	max, _ := strconv.Atoi(q.Get("max"))
+	if max <= 0 {
+		max = 1000
+	}
+
+	r.lock.Lock()
+	ids := make([]string, 0)
+	for id := range r.chunks {
+		if s := string(id); s > marker && strings.HasPrefix(s, prefix) {
+			ids = append(ids, s)
+		}
+	}
+	r.lock.Unlock()
+	sort.Strings(ids)
+	truncated := len(ids) > max
+	if truncated {
+		ids = ids[:max]
+	}
+	rep.Header().Set("X-Oio-List-Truncated", strconv.FormatBool(truncated))
+	rep.WriteHeader(http.StatusOK)
+	for _, id := range ids {
+		fmt.Fprintln(rep, id)
+	}
+}
+
+func (r *FakeRawx) serveStat(rep http.ResponseWriter) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	total := uint64(0)
+	rep.WriteHeader(http.StatusOK)
+	for name, count := range r.hits {
+		fmt.Fprintf(rep, "counter.rep.hits.%s %d\n", name, count)
+		total += count
+	}
+	fmt.Fprintf(rep, "counter.rep.hits %d\n", total)
+}
+
+// Namespace is a whole namespace served over HTTP on the loopback: a Proxy
+// on the fakes and several FakeRawx, with the configuration of the SDK to
+// reach them.
+type Namespace struct {
+	Name      string
+	Config    *oio.StaticConfig
+	Directory *FakeDirectory
+	Container *FakeContainer
+	Proxy     *Proxy
+	Rawx      []*FakeRawx
+
+	servers []*httptest.Server
+}
+
+// Starts a namespace with <count> rawx services. The autocreation is
+// enabled in the configuration.
+func StartNamespace(ns string, count int) *Namespace {
+	n := &Namespace{
+		Name:      ns,
+		Config:    oio.MakeStaticConfig(),
+		Directory: MakeFakeDirectory(ns),
+		Rawx:      make([]*FakeRawx, 0, count),
+		servers:   make([]*httptest.Server, 0, count+1),
+	}
+	n.Container = MakeFakeContainer(ns, n.Directory)
+	addrs := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		r := MakeFakeRawx()
+		srv := httptest.NewServer(r)
+		n.Rawx = append(n.Rawx, r)
+		n.servers = append(n.servers, srv)
+		addrs = append(addrs, strings.TrimPrefix(srv.URL, "http://"))
+	}
+	n.Container.SetRawx(addrs)
+	n.Proxy = MakeProxy(ns, n.Directory, n.Container)
+	srv := httptest.NewServer(n.Proxy)
+	n.servers = append(n.servers, srv)
+	n.Config.Set(ns, oio.KeyProxy, strings.TrimPrefix(srv.URL, "http://"))
+	n.Config.Set(ns, oio.KeyAutocreate, "true")
+	return n
+}
+
+// Stops all the services
+func (n *Namespace) Close() {
+	for _, srv := range n.servers {
+		srv.Close()
+	}
+}
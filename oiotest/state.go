@@ -0,0 +1,48 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2017-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oiotest
+
+import (
+	"github.com/jfsmig/oio-go/metadb"
+)
+
+func stores(d *FakeDirectory, c *FakeContainer) (*metadb.Directory, *metadb.Container) {
+	var ds *metadb.Directory
+	var cs *metadb.Container
+	if d != nil {
+		ds = d.store
+	}
+	if c != nil {
+		cs = c.store
+	}
+	return ds, cs
+}
+
+// Saves the users of <d> and the containers of <c> in the file at <path>,
+// replaced atomically. Both fakes are optional.
+func SaveState(path string, d *FakeDirectory, c *FakeContainer) error {
+	ds, cs := stores(d, c)
+	return metadb.SaveSnapshot(path, ds, cs)
+}
+
+// Replaces the users of <d> and the containers of <c> with those saved in
+// the file at <path>. A missing file leaves the fakes empty.
+func LoadState(path string, d *FakeDirectory, c *FakeContainer) error {
+	ds, cs := stores(d, c)
+	_, err := metadb.LoadSnapshot(path, ds, cs)
+	return err
+}
@@ -0,0 +1,226 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2015-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oio
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+var errInvalidAccountListing = errors.New("Invalid listing from the account service")
+
+type accountClient struct {
+	ns     string
+	config Config
+}
+
+// The reply of the account service to a listing request. Each item of the
+// listing is an array [name, objects, bytes, is_prefix, mtime].
+type accountListingReply struct {
+	Listing    [][]interface{} `json:"listing"`
+	Truncated  bool            `json:"truncated"`
+	NextMarker string          `json:"next_marker"`
+}
+
+func (cli *accountClient) getAccountUrl(n AccountName, action string) string {
+	return fmt.Sprintf("http://%s/v3.0/%s/account/%s?id=%s",
+		getProxyAccountUrl(cli.ns, cli.config), cli.ns, action,
+		url.QueryEscape(n.Account()))
+}
+
+func (cli *accountClient) simpleRequest(req *http.Request) (bool, error) {
+	p := makeHttpClient(cli.ns, cli.config)
+	rep, err := p.Do(req)
+	if rep != nil {
+		defer rep.Body.Close()
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if rep.StatusCode/100 == 2 {
+		return true, nil
+	} else if rep.StatusCode == 404 {
+		return false, ErrorNotFound
+	} else {
+		return false, readProxyError(rep.StatusCode, rep)
+	}
+}
+
+func (cli *accountClient) CreateAccount(n AccountName) (bool, error) {
+	if n.NS() != cli.ns {
+		return false, ErrorNsNotManaged
+	}
+	req, _ := http.NewRequest("POST", cli.getAccountUrl(n, "create"), nil)
+
+	p := makeHttpClient(cli.ns, cli.config)
+	rep, err := p.Do(req)
+	if rep != nil {
+		defer rep.Body.Close()
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// The account service replies 202 when the account already exists
+	if rep.StatusCode == 202 {
+		return false, nil
+	} else if rep.StatusCode/100 == 2 {
+		return true, nil
+	} else {
+		return false, readProxyError(rep.StatusCode, rep)
+	}
+}
+
+func (cli *accountClient) DeleteAccount(n AccountName) (bool, error) {
+	if n.NS() != cli.ns {
+		return false, ErrorNsNotManaged
+	}
+	req, _ := http.NewRequest("POST", cli.getAccountUrl(n, "delete"), nil)
+	return cli.simpleRequest(req)
+}
+
+func (cli *accountClient) ShowAccount(n AccountName) (AccountInfo, error) {
+	out := AccountInfo{Metadata: make(map[string]string)}
+	if n.NS() != cli.ns {
+		return out, ErrorNsNotManaged
+	}
+	req, _ := http.NewRequest("GET", cli.getAccountUrl(n, "show"), nil)
+
+	p := makeHttpClient(cli.ns, cli.config)
+	rep, err := p.Do(req)
+	if rep != nil {
+		defer rep.Body.Close()
+	}
+	if err != nil {
+		return out, err
+	}
+
+	if rep.StatusCode/100 == 2 {
+		decoder := json.NewDecoder(rep.Body)
+		err = decoder.Decode(&out)
+		return out, err
+	} else if rep.StatusCode == 404 {
+		return out, ErrorNotFound
+	} else {
+		return out, readProxyError(rep.StatusCode, rep)
+	}
+}
+
+func (cli *accountClient) ListContainers(n AccountName, params ListParams) (AccountListing, error) {
+	out := AccountListing{Containers: make([]AccountContainer, 0)}
+	if n.NS() != cli.ns {
+		return out, ErrorNsNotManaged
+	}
+
+	u := cli.getAccountUrl(n, "containers")
+	if len(params.Prefix) > 0 {
+		u = u + "&prefix=" + url.QueryEscape(params.Prefix)
+	}
+	if len(params.Marker) > 0 {
+		u = u + "&marker=" + url.QueryEscape(params.Marker)
+	}
+	if len(params.Delimiter) > 0 {
+		u = u + "&delimiter=" + url.QueryEscape(params.Delimiter)
+	}
+	if params.Max > 0 {
+		u = u + "&limit=" + strconv.Itoa(params.Max)
+	}
+	req, _ := http.NewRequest("GET", u, nil)
+
+	p := makeHttpClient(cli.ns, cli.config)
+	rep, err := p.Do(req)
+	if rep != nil {
+		defer rep.Body.Close()
+	}
+	if err != nil {
+		return out, err
+	}
+
+	if rep.StatusCode == 404 {
+		return out, ErrorNotFound
+	} else if rep.StatusCode/100 != 2 {
+		return out, readProxyError(rep.StatusCode, rep)
+	}
+
+	var reply accountListingReply
+	decoder := json.NewDecoder(rep.Body)
+	if err = decoder.Decode(&reply); err != nil {
+		return out, err
+	}
+	for _, item := range reply.Listing {
+		c, err := unpackAccountContainer(item)
+		if err != nil {
+			return out, err
+		}
+		out.Containers = append(out.Containers, c)
+	}
+	out.Truncated = reply.Truncated
+	out.NextMarker = reply.NextMarker
+	if out.Truncated && len(out.NextMarker) <= 0 && len(out.Containers) > 0 {
+		out.NextMarker = out.Containers[len(out.Containers)-1].Name
+	}
+	return out, nil
+}
+
+func (cli *accountClient) UpdateAccount(n AccountName, set map[string]string, del []string) (bool, error) {
+	if n.NS() != cli.ns {
+		return false, ErrorNsNotManaged
+	}
+	if set == nil {
+		set = make(map[string]string)
+	}
+	if del == nil {
+		del = make([]string, 0)
+	}
+	body, _ := json.Marshal(map[string]interface{}{"metadata": set, "to_delete": del})
+	req, _ := http.NewRequest("POST", cli.getAccountUrl(n, "update"),
+		bytes.NewBuffer(body))
+	return cli.simpleRequest(req)
+}
+
+func unpackAccountContainer(item []interface{}) (AccountContainer, error) {
+	var c AccountContainer
+	var ok bool
+	if len(item) < 4 {
+		return c, errInvalidAccountListing
+	}
+	if c.Name, ok = item[0].(string); !ok {
+		return c, errInvalidAccountListing
+	}
+	if v, ok := item[1].(float64); ok {
+		c.Objects = uint64(v)
+	}
+	if v, ok := item[2].(float64); ok {
+		c.Bytes = uint64(v)
+	}
+	switch v := item[3].(type) {
+	case bool:
+		c.Prefix = v
+	case float64:
+		c.Prefix = v != 0
+	}
+	if len(item) > 4 {
+		c.MTime, _ = item[4].(float64)
+	}
+	return c, nil
+}
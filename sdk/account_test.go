@@ -0,0 +1,44 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2015-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oio
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAccount_UnpackListing(t *testing.T) {
+	var reply accountListingReply
+	encoded := `{"listing":[["c0",3,4000,0,1500000000.5],["c1/",0,0,1,0]],"truncated":true}`
+	if err := json.Unmarshal([]byte(encoded), &reply); err != nil {
+		t.Fatal("Decoding failed: ", err)
+	}
+
+	c, err := unpackAccountContainer(reply.Listing[0])
+	if err != nil {
+		t.Fatal("Unpack failed: ", err)
+	}
+	if c.Name != "c0" || c.Objects != 3 || c.Bytes != 4000 || c.Prefix {
+		t.Fatal("Unexpected container: ", c)
+	}
+	if c, _ = unpackAccountContainer(reply.Listing[1]); !c.Prefix {
+		t.Fatal("Prefix not recognized: ", c)
+	}
+	if _, err = unpackAccountContainer([]interface{}{1.0, 2.0}); err == nil {
+		t.Fatal("Invalid item accepted")
+	}
+}
@@ -81,18 +81,24 @@ var ErrorNsNotManaged = errors.New("Namespace Not Managed")
 // The configuration value has not been provided.
 var ErrorConfiguration = errors.New("Invalid configuration")
 
+// The storage policy requested is not declared in the namespace
+var ErrorInvalidPolicy = errors.New("Invalid storage policy")
+
 var zeroByte = make([]byte, 1, 1)
 
 // A prefix to all the headers related to chunk attributes
 const RAWX_HEADER_PREFIX = "X-oio-chunk-meta-"
 
 const (
+	KeyProxyAccount    = "proxy-account"
 	KeyProxyConscience = "proxy-conscience"
 	KeyProxyContainer  = "proxy-container"
 	KeyProxyDirectory  = "proxy-dir"
 	KeyProxy           = "proxy"
 	KeyAutocreate      = "autocreate"
 	KeyForce           = "force"
+	KeyNsInfoTTL       = "nsinfo-ttl"
+	KeyConnectTimeout  = "connect-timeout"
 )
 
 // AccountName describes a set of getters for all the fields that uniquely
@@ -212,6 +218,134 @@ type Content struct {
 type ContainerListing struct {
 	Objects    []ContentHeader
 	Properties []Property
+
+	// The common prefixes met when a delimiter was specified
+	Prefixes []string
+
+	// Tells if the listing stopped before the end of the container, then
+	// NextMarker tells where to restart.
+	Truncated  bool   `json:"-"`
+	NextMarker string `json:"-"`
+}
+
+// ListParams gathers the optional filters of a container listing. All the
+// fields left to their zero value are not sent.
+type ListParams struct {
+	Prefix    string
+	Marker    string
+	Delimiter string
+	Max       int
+
+	// Also list the old versions and the deleted contents
+	Versions bool
+}
+
+// DeleteResult tells the outcome of the deletion of a single content, during
+// a bulk operation.
+type DeleteResult struct {
+	Path    string
+	Version uint64
+	Err     error
+}
+
+// AccountInfo gathers the statistics and the properties of an account, as
+// reported by the account service.
+type AccountInfo struct {
+	Id         string            `json:"id"`
+	Containers uint64            `json:"containers"`
+	Objects    uint64            `json:"objects"`
+	Bytes      uint64            `json:"bytes"`
+	CTime      float64           `json:"ctime"`
+	Metadata   map[string]string `json:"metadata"`
+}
+
+// A container as seen by the account service. When a delimiter has been
+// used, Prefix tells the item is a common prefix instead of a container.
+type AccountContainer struct {
+	Name    string
+	Objects uint64
+	Bytes   uint64
+	Prefix  bool
+	MTime   float64
+}
+
+// AccountListing is the output of ListContainers(), i.e. a slice of the
+// containers of an account.
+type AccountListing struct {
+	Containers []AccountContainer
+	Truncated  bool
+	NextMarker string
+}
+
+// Client to the account services of the Software Defined Storage.
+type Account interface {
+
+	// Create the account. If the output is (false,nil) then the account
+	// already existed.
+	CreateAccount(n AccountName) (bool, error)
+
+	// Delete the account. This fails if the account still has containers.
+	DeleteAccount(n AccountName) (bool, error)
+
+	// Get the statistics and the properties of the account.
+	ShowAccount(n AccountName) (AccountInfo, error)
+
+	// Get a slice of the containers of the account. Only the Prefix, Marker,
+	// Delimiter and Max fields of the parameters are considered.
+	ListContainers(n AccountName, p ListParams) (AccountListing, error)
+
+	// Set the properties in <set> and remove the properties in <del>, at
+	// once.
+	UpdateAccount(n AccountName, set map[string]string, del []string) (bool, error)
+}
+
+// A service as known by the conscience, with its current score and the tags
+// it registered.
+type ServiceInfo struct {
+	Type  string                 `json:"type,omitempty"`
+	Addr  string                 `json:"addr"`
+	Score int                    `json:"score"`
+	Tags  map[string]interface{} `json:"tags,omitempty"`
+}
+
+// NamespaceInfo gathers the namespace-wide configuration held by the
+// conscience. The policies, securities and pools are left in their raw
+// textual form.
+type NamespaceInfo struct {
+	Name            string            `json:"ns"`
+	ChunkSize       int64             `json:"chunksize"`
+	Options         map[string]string `json:"options"`
+	StoragePolicies map[string]string `json:"storage_policy"`
+	DataSecurities  map[string]string `json:"data_security"`
+	ServicePools    map[string]string `json:"service_pools"`
+}
+
+// Client to the conscience of the Software Defined Storage, i.e. the
+// service in charge of the load-balancing.
+type Conscience interface {
+
+	// Get all the services of the given type, with their score and tags
+	ListServices(ns, srvtype string) ([]ServiceInfo, error)
+
+	// Get the types of the services known in the namespace
+	ListTypes(ns string) ([]string, error)
+
+	// Declare the service, or refresh its registration. The score is
+	// ignored, it is computed by the conscience.
+	RegisterService(ns string, srv ServiceInfo) error
+
+	// Remove the service from the conscience
+	DeregisterService(ns string, srv ServiceInfo) error
+
+	// Force the score of the service to the value in <srv>, until
+	// UnlockScore() is called.
+	LockScore(ns string, srv ServiceInfo) error
+
+	// Let the conscience compute the score of the service again
+	UnlockScore(ns string, srv ServiceInfo) error
+
+	// Get the namespace-wide configuration
+	GetNamespaceInfo(ns string) (NamespaceInfo, error)
 }
 
 // Client to the directory services of the Software Defined Storage.
@@ -270,7 +404,9 @@ type Directory interface {
 type Container interface {
 
 	// Create the given container. Returns false if nothing was created (i.e.
-	// (false,nil) means the container already exists)
+	// (false,nil) means the container already exists). With <auto>, and the
+	// autocreation enabled in the configuration, the missing user is created
+	// too.
 	CreateContainer(n ContainerName, auto bool) (bool, error)
 
 	// Removes the container from the storage. Returns (true,nil) if the deletion
@@ -282,20 +418,68 @@ type Container interface {
 	// means we weren't able to check.
 	HasContainer(n ContainerName) (bool, error)
 
+	// Get all the properties associated with the container. The map is
+	// empty, never nil, when there is none. ErrorNotFound is returned for a
+	// missing container.
+	GetContainerProperties(n ContainerName) (map[string]string, error)
+
+	// Bind an additional set of key/value pairs to the container. If some
+	// keys were already bound, they are replaced. Returns (true,nil) once the
+	// properties are saved, ErrorNotFound for a missing container.
+	SetContainerProperties(n ContainerName, props map[string]string) (bool, error)
+
+	// Remove some key/value bindings of the container. The keys not bound
+	// are ignored. Returns (true,nil) once the properties are saved,
+	// ErrorNotFound for a missing container.
+	DeleteContainerProperties(n ContainerName, keys []string) (bool, error)
+
 	// Get a list of all the contents of the container.
 	ListContents(n ContainerName) (ContainerListing, error)
 
+	// Get a slice of the contents of the container, filtered with the
+	// given parameters.
+	ListContentsWithParams(n ContainerName, p ListParams) (ContainerListing, error)
+
 	// Get a description of the content whos ename is given
 	GetContent(n ObjectName) (Content, error)
 
-	// Get places to upload a content with the given name and size
+	// Get the header of the content, without the locations of its chunks
+	StatContent(n ObjectName) (ContentHeader, error)
+
+	// Check the content exists. (false,nil) means it doesn't. (false,!nil)
+	// means we weren't able to check.
+	HasContent(n ObjectName) (bool, error)
+
+	// Get places to upload a content with the given name and size. With
+	// <auto>, and the autocreation enabled in the configuration, the missing
+	// container is created, otherwise ErrorNotFound is returned.
 	GenerateContent(n ObjectName, size uint64, auto bool) (Content, error)
 
-	// Save the places used by the content with the given name and size
+	// Acts as GenerateContent() but the places will match the given storage
+	// policy (leave empty for the default policy of the namespace).
+	GenerateContentWithPolicy(n ObjectName, size uint64, policy string, auto bool) (Content, error)
+
+	// Save the places used by the content with the given name and size. The
+	// container is autocreated as by GenerateContent().
 	PutContent(container ContainerName, content Content, auto bool) error
 
 	// Remove the given content from the storage
 	DeleteContent(n ObjectName) (bool, error)
+
+	// Get all the properties associated with the content, the latest version
+	// unless <n> carries one. The map is empty, never nil, when there is
+	// none. ErrorNotFound is returned for a missing content.
+	GetContentProperties(n ObjectName) (map[string]string, error)
+
+	// Bind an additional set of key/value pairs to the content. If some keys
+	// were already bound, they are replaced. Returns (true,nil) once the
+	// properties are saved, ErrorNotFound for a missing content.
+	SetContentProperties(n ObjectName, props map[string]string) (bool, error)
+
+	// Remove some key/value bindings of the content. The keys not bound are
+	// ignored. Returns (true,nil) once the properties are saved,
+	// ErrorNotFound for a missing content.
+	DeleteContentProperties(n ObjectName, keys []string) (bool, error)
 }
 
 type ObjectStorage interface {
@@ -303,18 +487,52 @@ type ObjectStorage interface {
 	// Uploads <size> bytes from <in> as an object named <n>.
 	PutContent(n ObjectName, size uint64, auto bool, in io.ReadSeeker) error
 
+	// Acts as PutContent() with an explicit storage policy. ErrorInvalidPolicy
+	// is returned if the policy is not declared in the namespace.
+	PutContentWithPolicy(n ObjectName, size uint64, policy string, auto bool, in io.ReadSeeker) error
+
+	// Acts as PutContentWithPolicy() and binds the properties to the content
+	// at its creation, so that it never exists without them.
+	PutContentWithProperties(n ObjectName, size uint64, policy string, props map[string]string, auto bool, in io.ReadSeeker) error
+
 	// Get a stream to read the content.
 	// TODO: make the output a "ReadSeekCloser" to let the appication efficiently
 	// read a slice of it.
 	GetContent(n ObjectName) (io.ReadCloser, error)
 
+	// Get the size, the hash, the policy etc. of the given content.
+	StatContent(n ObjectName) (ContentHeader, error)
+
+	// Check the content exists. (false,nil) means it doesn't.
+	HasContent(n ObjectName) (bool, error)
+
 	// Remove the given content from the storage
 	DeleteContent(n ObjectName) error
+
+	// Remove several contents from the given container, with at most <max>
+	// deletions running at once. There is one result per path, in the same
+	// order.
+	DeleteContents(n ContainerName, paths []string, max int) ([]DeleteResult, error)
+
+	// Remove all the contents whose path starts with <prefix>, with at most
+	// <max> deletions running at once.
+	DeleteContentsWithPrefix(n ContainerName, prefix string, max int) ([]DeleteResult, error)
+
+	// Remove all the contents of the container, except the <keep> latest
+	// versions of each content, with at most <max> deletions running at
+	// once. The deletion markers are not counted among the kept versions.
+	// If <destroy> is set and the container ended empty (i.e. <keep> is 0),
+	// the container itself is removed.
+	PurgeContainer(n ContainerName, keep int, destroy bool, max int) ([]DeleteResult, error)
 }
 
 func makeHttpClient(ns string, cfg Config) *http.Client {
+	timeout, err := GetDuration(cfg, ns, KeyConnectTimeout)
+	if err != nil || timeout <= 0 {
+		timeout = 1000 * time.Millisecond
+	}
 	dial := func(network, addr string) (net.Conn, error) {
-		return net.DialTimeout(network, addr, 1000*time.Millisecond)
+		return net.DialTimeout(network, addr, timeout)
 	}
 	transport := http.Transport{Dial: dial}
 	return &http.Client{Transport: &transport}
@@ -334,11 +552,13 @@ func ComputeUserId(name UserName) []byte {
 }
 
 // Creates an implementation of an ObjectStorage, relying on the default
-// implementation of a Directory and a Container clients.
+// implementation of a Directory, a Container and a Conscience clients.
 func MakeDefaultObjectStorageClient(ns string, cfg Config) (ObjectStorage, error) {
 	d, _ := MakeDirectoryClient(ns, cfg)
 	c, _ := MakeContainerClient(ns, cfg)
-	return MakeObjectStorageClient(d, c)
+	cs, _ := MakeConscienceClient(ns, cfg)
+	return MakeObjectStorageClientWithCache(d, c,
+		MakeNamespaceInfoCache(cs, getNsInfoTTL(ns, cfg)))
 }
 
 // Creates the default implementation for an ObjectStorage, relying on the given
@@ -348,6 +568,27 @@ func MakeObjectStorageClient(d Directory, c Container) (ObjectStorage, error) {
 	return out, nil
 }
 
+// Acts as MakeObjectStorageClient(), with a cache of namespace info used to
+// size the uploads and validate the storage policies.
+func MakeObjectStorageClientWithCache(d Directory, c Container, cache *NamespaceInfoCache) (ObjectStorage, error) {
+	out := &objectStorageClient{directory: d, container: c, nsinfo: cache}
+	return out, nil
+}
+
+// Creates an instance of the default implementation of the Account client.
+// The output will only serve the given namespace.
+func MakeAccountClient(ns string, cfg Config) (Account, error) {
+	out := &accountClient{ns: ns, config: cfg}
+	return out, nil
+}
+
+// Creates an instance of the default implementation of the Conscience
+// client. The output will only serve the given namespace.
+func MakeConscienceClient(ns string, cfg Config) (Conscience, error) {
+	out := &conscienceClient{ns: ns, config: cfg}
+	return out, nil
+}
+
 // Creates an instance of the default implementation of the Directory client
 // implementation. The subsequent calls will only accept to serve the namespace
 // now given, all other namespaces will result in the error ErrorNsNotManaged
@@ -0,0 +1,159 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2015-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oio
+
+import (
+	"sort"
+	"sync"
+)
+
+// How many deletions run at once when the caller doesn't tell
+const defaultBulkConcurrency = 8
+
+// Deletes all the given contents with at most <max> deletions in flight.
+// The results are in the same order than the targets.
+func bulkDelete(c Container, targets []FlatName, max int) []DeleteResult {
+	out := make([]DeleteResult, len(targets))
+	if max <= 0 {
+		max = defaultBulkConcurrency
+	}
+
+	var wg sync.WaitGroup
+	jobs := make(chan int, len(targets))
+	for i := range targets {
+		jobs <- i
+	}
+	close(jobs)
+
+	if max > len(targets) {
+		max = len(targets)
+	}
+	for w := 0; w < max; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				n := &targets[i]
+				_, err := c.DeleteContent(n)
+				out[i] = DeleteResult{Path: n.P, Version: n.V, Err: err}
+			}
+		}()
+	}
+	wg.Wait()
+	return out
+}
+
+// Lists the whole container (or the part of it matching the prefix),
+// following the markers until the listing is not truncated anymore.
+func listAll(c Container, n ContainerName, p ListParams) ([]ContentHeader, error) {
+	out := make([]ContentHeader, 0)
+	for {
+		l, err := c.ListContentsWithParams(n, p)
+		if err != nil {
+			return out, err
+		}
+		out = append(out, l.Objects...)
+		if !l.Truncated || len(l.Objects) <= 0 {
+			return out, nil
+		}
+		if len(l.NextMarker) > 0 {
+			p.Marker = l.NextMarker
+		} else {
+			p.Marker = l.Objects[len(l.Objects)-1].Name
+		}
+	}
+}
+
+func contentName(n ContainerName, path string, version uint64) FlatName {
+	return FlatName{
+		N: n.NS(), A: n.Account(), U: n.User(), S: n.Type(),
+		P: path, V: version,
+	}
+}
+
+func (cli *objectStorageClient) DeleteContents(n ContainerName, paths []string, max int) ([]DeleteResult, error) {
+	targets := make([]FlatName, len(paths))
+	for i, p := range paths {
+		targets[i] = contentName(n, p, 0)
+	}
+	return bulkDelete(cli.container, targets, max), nil
+}
+
+func (cli *objectStorageClient) DeleteContentsWithPrefix(n ContainerName, prefix string, max int) ([]DeleteResult, error) {
+	headers, err := listAll(cli.container, n, ListParams{Prefix: prefix})
+	if err != nil {
+		return make([]DeleteResult, 0), err
+	}
+	targets := make([]FlatName, 0, len(headers))
+	for _, h := range headers {
+		targets = append(targets, contentName(n, h.Name, 0))
+	}
+	return bulkDelete(cli.container, targets, max), nil
+}
+
+func (cli *objectStorageClient) PurgeContainer(n ContainerName, keep int, destroy bool, max int) ([]DeleteResult, error) {
+	headers, err := listAll(cli.container, n, ListParams{Versions: true})
+	if err != nil {
+		return make([]DeleteResult, 0), err
+	}
+
+	// Group the versions by content, the most recent first
+	byPath := make(map[string][]ContentHeader)
+	paths := make([]string, 0)
+	for _, h := range headers {
+		if _, ok := byPath[h.Name]; !ok {
+			paths = append(paths, h.Name)
+		}
+		byPath[h.Name] = append(byPath[h.Name], h)
+	}
+
+	// The deletion markers don't count as kept versions. Those more recent
+	// than the last kept version stay, so that a deleted content remains
+	// deleted.
+	targets := make([]FlatName, 0, len(headers))
+	for _, p := range paths {
+		versions := byPath[p]
+		sort.Slice(versions, func(i, j int) bool { return versions[i].Version > versions[j].Version })
+		kept := 0
+		for _, h := range versions {
+			if kept >= keep {
+				targets = append(targets, contentName(n, p, h.Version))
+			} else if !h.Deleted {
+				kept++
+			}
+		}
+	}
+
+	out := bulkDelete(cli.container, targets, max)
+
+	// A content already gone is not a failure of the purge
+	failed := false
+	for i := range out {
+		if out[i].Err == ErrorNotFound {
+			out[i].Err = nil
+		} else if out[i].Err != nil {
+			failed = true
+		}
+	}
+
+	if destroy && keep <= 0 && !failed {
+		if _, err = cli.container.DeleteContainer(n); err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,103 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2015-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oio
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// Only implements the methods used by the bulk operations
+type listingContainer struct {
+	Container
+	lock      sync.Mutex
+	headers   []ContentHeader
+	deleted   map[string]bool
+	destroyed bool
+}
+
+func (c *listingContainer) ListContentsWithParams(n ContainerName, p ListParams) (ContainerListing, error) {
+	return ContainerListing{Objects: c.headers}, nil
+}
+
+func (c *listingContainer) DeleteContent(n ObjectName) (bool, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.deleted[n.Path()+"/"+strconv.FormatUint(n.Version(), 10)] = true
+	return true, nil
+}
+
+func (c *listingContainer) DeleteContainer(n ContainerName) (bool, error) {
+	c.destroyed = true
+	return true, nil
+}
+
+func TestBulk_PurgeKeepsLatest(t *testing.T) {
+	c := &listingContainer{deleted: make(map[string]bool)}
+	c.headers = []ContentHeader{
+		{Name: "a", Version: 1}, {Name: "a", Version: 3}, {Name: "a", Version: 2},
+		{Name: "b", Version: 1},
+	}
+	cli := &objectStorageClient{container: c}
+	n := FlatName{N: "NS", A: "ACCT", U: "JFS"}
+
+	results, err := cli.PurgeContainer(&n, 1, true, 2)
+	if err != nil {
+		t.Fatal("Purge failed: ", err)
+	}
+	if len(results) != 2 {
+		t.Fatal("Unexpected number of deletions: ", len(results))
+	}
+	if !c.deleted["a/1"] || !c.deleted["a/2"] || c.deleted["a/3"] || c.deleted["b/1"] {
+		t.Fatal("Wrong versions deleted: ", c.deleted)
+	}
+	if c.destroyed {
+		t.Fatal("Container destroyed while not empty")
+	}
+}
+
+func TestBulk_PurgeSkipsMarkers(t *testing.T) {
+	c := &listingContainer{deleted: make(map[string]bool)}
+	c.headers = []ContentHeader{
+		{Name: "a", Version: 4, Deleted: true}, {Name: "a", Version: 3},
+		{Name: "a", Version: 2, Deleted: true}, {Name: "a", Version: 1},
+	}
+	cli := &objectStorageClient{container: c}
+	n := FlatName{N: "NS", A: "ACCT", U: "JFS"}
+
+	if _, err := cli.PurgeContainer(&n, 1, false, 0); err != nil {
+		t.Fatal("Purge failed: ", err)
+	}
+	if c.deleted["a/4"] || c.deleted["a/3"] || !c.deleted["a/2"] || !c.deleted["a/1"] {
+		t.Fatal("Wrong versions deleted: ", c.deleted)
+	}
+}
+
+func TestBulk_DeleteOrder(t *testing.T) {
+	c := &listingContainer{deleted: make(map[string]bool)}
+	cli := &objectStorageClient{container: c}
+	n := FlatName{N: "NS", A: "ACCT", U: "JFS"}
+
+	paths := []string{"x", "y", "z", "t"}
+	results, _ := cli.DeleteContents(&n, paths, 2)
+	for i, r := range results {
+		if r.Path != paths[i] || r.Err != nil {
+			t.Fatal("Unexpected result: ", r)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2017-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oio
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The kinds of chunk methods
+const (
+	// The chunks are full copies of the metachunk
+	ChunkMethodPlain = "plain"
+
+	// The chunks are the k data and m parity fragments of the metachunk
+	ChunkMethodEc = "ec"
+
+	// The content is stored in a Backblaze bucket
+	ChunkMethodBackblaze = "backblaze"
+)
+
+var ErrorInvalidChunkMethod = errors.New("Invalid chunk method")
+
+// ChunkMethod tells how the metachunks of a content are spread on chunks,
+// e.g. "plain/nb_copy=3" or "ec/algo=liberasurecode_rs_vand,k=6,m=3".
+type ChunkMethod struct {
+	Type   string
+	Params map[string]string
+}
+
+// Parses the chunk method of a content. The empty string stands for a single
+// plain copy.
+func ParseChunkMethod(s string) (ChunkMethod, error) {
+	cm := ChunkMethod{Type: ChunkMethodPlain, Params: make(map[string]string)}
+	if len(s) <= 0 {
+		return cm, nil
+	}
+
+	tokens := strings.SplitN(s, "/", 2)
+	cm.Type = tokens[0]
+	if len(tokens) > 1 && len(tokens[1]) > 0 {
+		for _, kv := range strings.Split(tokens[1], ",") {
+			idx := strings.Index(kv, "=")
+			if idx <= 0 {
+				return cm, ErrorInvalidChunkMethod
+			}
+			cm.Params[kv[:idx]] = kv[idx+1:]
+		}
+	}
+
+	switch cm.Type {
+	case ChunkMethodPlain:
+		if _, ok := cm.Params["nb_copy"]; ok && cm.Copies() <= 0 {
+			return cm, ErrorInvalidChunkMethod
+		}
+	case ChunkMethodEc:
+		if cm.K() <= 0 || cm.M() < 0 {
+			return cm, ErrorInvalidChunkMethod
+		}
+	case ChunkMethodBackblaze:
+	default:
+		return cm, ErrorInvalidChunkMethod
+	}
+	return cm, nil
+}
+
+// Returns the canonical form of the chunk method, with sorted parameters
+func (cm ChunkMethod) String() string {
+	keys := make([]string, 0, len(cm.Params))
+	for k := range cm.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + cm.Params[k]
+	}
+	return cm.Type + "/" + strings.Join(pairs, ",")
+}
+
+func (cm ChunkMethod) intParam(key string, def int) int {
+	v, ok := cm.Params[key]
+	if !ok {
+		return def
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return -1
+	}
+	return i
+}
+
+// Tells if the metachunks are erasure coded
+func (cm ChunkMethod) IsEc() bool { return cm.Type == ChunkMethodEc }
+
+// Tells if the metachunks are replicated
+func (cm ChunkMethod) IsPlain() bool { return cm.Type == ChunkMethodPlain }
+
+// Returns the number of copies of each metachunk for a plain method
+func (cm ChunkMethod) Copies() int { return cm.intParam("nb_copy", 1) }
+
+// Returns the number of data fragments for an EC method
+func (cm ChunkMethod) K() int { return cm.intParam("k", -1) }
+
+// Returns the number of parity fragments for an EC method
+func (cm ChunkMethod) M() int { return cm.intParam("m", -1) }
+
+// Returns the EC algorithm, e.g. "liberasurecode_rs_vand"
+func (cm ChunkMethod) Algo() string { return cm.Params["algo"] }
+
+// Returns the number of chunks expected for each metachunk
+func (cm ChunkMethod) Width() int {
+	switch cm.Type {
+	case ChunkMethodPlain:
+		return cm.Copies()
+	case ChunkMethodEc:
+		return cm.K() + cm.M()
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,59 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2017-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oio
+
+import (
+	"testing"
+)
+
+func TestChunkMethod_Parse(t *testing.T) {
+	cm, err := ParseChunkMethod("ec/k=6,m=3,algo=liberasurecode_rs_vand")
+	if err != nil {
+		t.Fatal("Parse error: ", err)
+	}
+	if !cm.IsEc() || cm.K() != 6 || cm.M() != 3 || cm.Width() != 9 ||
+		cm.Algo() != "liberasurecode_rs_vand" {
+		t.Fatal("Bad EC method: ", cm)
+	}
+	if cm.String() != "ec/algo=liberasurecode_rs_vand,k=6,m=3" {
+		t.Fatal("Bad format: ", cm.String())
+	}
+
+	cm, err = ParseChunkMethod("plain/nb_copy=3")
+	if err != nil || !cm.IsPlain() || cm.Width() != 3 {
+		t.Fatal("Bad plain method: ", cm, err)
+	}
+	cm, err = ParseChunkMethod("")
+	if err != nil || !cm.IsPlain() || cm.Copies() != 1 {
+		t.Fatal("Bad default method: ", cm, err)
+	}
+	cm, err = ParseChunkMethod("backblaze/account_id=x,bucket_name=y")
+	if err != nil || cm.Type != ChunkMethodBackblaze || cm.Params["bucket_name"] != "y" {
+		t.Fatal("Bad backblaze method: ", cm, err)
+	}
+}
+
+func TestChunkMethod_Invalid(t *testing.T) {
+	for _, s := range []string{
+		"raid/k=1", "ec/m=3", "ec/k=x,m=1", "ec/k=2,m=-1",
+		"plain/nb_copy=0", "plain/nb_copy", "plain/=3",
+	} {
+		if _, err := ParseChunkMethod(s); err != ErrorInvalidChunkMethod {
+			t.Fatal("Unexpected success for ", s)
+		}
+	}
+}
@@ -25,17 +25,22 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 // Dummy Config implementation where everything is stored in a single map.
 // The map keys are encoded as "ns.key". This means the dot '.' is forbidden
 // in the namespace names to work properly.
+// It is safe for concurrent use.
 type StaticConfig struct {
+	lock  sync.RWMutex
 	pairs map[string]string
 }
 
 // Returns the list of namespaces known in the (loaded) configuration
 func (cfg *StaticConfig) Namespaces() []string {
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
 	tmp := make(map[string]bool)
 	for k, _ := range cfg.pairs {
 		if idx := strings.Index(k, "/"); idx > 0 {
@@ -52,6 +57,8 @@ func (cfg *StaticConfig) Namespaces() []string {
 // Returns all the keys known for the given namespace in the (loaded)
 // configuration
 func (cfg *StaticConfig) Keys(ns string) []string {
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
 	out := make([]string, 0)
 	for k, _ := range cfg.pairs {
 		if idx := strings.Index(k, "/"); idx > 0 {
@@ -66,14 +73,26 @@ func (cfg *StaticConfig) Keys(ns string) []string {
 // Sets a configuration key for the given namespace
 func (cfg *StaticConfig) Set(ns, key, value string) {
 	k := strings.Join([]string{ns, key}, "/")
+	cfg.lock.Lock()
 	cfg.pairs[k] = value
+	cfg.lock.Unlock()
+}
+
+// Removes a configuration key of the given namespace
+func (cfg *StaticConfig) Unset(ns, key string) {
+	k := strings.Join([]string{ns, key}, "/")
+	cfg.lock.Lock()
+	delete(cfg.pairs, k)
+	cfg.lock.Unlock()
 }
 
 // Get the raw value of a configuration key, if set. Otherwise an error is
 //returned.
 func (cfg *StaticConfig) GetString(ns, key string) (string, error) {
 	k := strings.Join([]string{ns, key}, "/")
+	cfg.lock.RLock()
 	v, ok := cfg.pairs[k]
+	cfg.lock.RUnlock()
 	if !ok {
 		return "", ErrorNotFound
 	}
@@ -104,16 +123,41 @@ func MakeStaticConfig() *StaticConfig {
 }
 
 func (cfg *StaticConfig) LoadWithContent(content []byte) error {
-	kvf, err := ini.Load(content)
+	pairs, err := parseContent(content)
 	if err != nil {
 		return err
 	}
+	cfg.lock.Lock()
+	for k, v := range pairs {
+		cfg.pairs[k] = v
+	}
+	cfg.lock.Unlock()
+	return nil
+}
+
+// Returns a copy of all the pairs, with keys encoded as "ns/key"
+func (cfg *StaticConfig) snapshot() map[string]string {
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	out := make(map[string]string, len(cfg.pairs))
+	for k, v := range cfg.pairs {
+		out[k] = v
+	}
+	return out
+}
+
+func parseContent(content []byte) (map[string]string, error) {
+	kvf, err := ini.Load(content)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string)
 	for _, section := range kvf.Sections() {
 		for _, key := range section.Keys() {
-			cfg.pairs[section.Name()+"/"+key.Name()] = key.Value()
+			out[section.Name()+"/"+key.Name()] = key.Value()
 		}
 	}
-	return nil
+	return out, nil
 }
 
 // Loads the given StaticConfig with the content of the given file
@@ -128,15 +172,15 @@ func (cfg *StaticConfig) LoadWithFile(path string) error {
 // Loads the given StaticConfig with the system-wide configuration
 func (cfg *StaticConfig) LoadWithSystem() error {
 	var err error
-	err = cfg.LoadWithFile("/etc/oio/sds.conf")
+	err = cfg.LoadWithFile(systemConfigFile)
 	if err != nil {
 		return err
 	}
 
-	files, _ := filepath.Glob("/etc/oio/sds.conf.d/*.conf")
+	files, _ := filepath.Glob(systemConfigGlob)
 	for _, file := range files {
 		if err = cfg.LoadWithFile(file); err != nil {
-			log.Println("StaticConfig: Failed to load [%s]", file)
+			log.Printf("StaticConfig: Failed to load [%s]", file)
 		}
 	}
 
@@ -146,11 +190,36 @@ func (cfg *StaticConfig) LoadWithSystem() error {
 // Loads the given StaticConfig with the configuration in the homedir of
 // the user.
 func (cfg *StaticConfig) LoadWithLocal() error {
+	if path, err := localConfigFile(); err != nil {
+		return err
+	} else {
+		return cfg.LoadWithFile(path)
+	}
+}
+
+const (
+	systemConfigFile = "/etc/oio/sds.conf"
+	systemConfigGlob = "/etc/oio/sds.conf.d/*.conf"
+)
+
+func localConfigFile() (string, error) {
 	if home, ok := os.LookupEnv("HOME"); !ok {
-		return errors.New("No HOME in ENV")
+		return "", errors.New("No HOME in ENV")
 	} else {
-		return cfg.LoadWithFile(home + "/.oio/sds.conf")
+		return home + "/.oio/sds.conf", nil
+	}
+}
+
+// Returns the files loaded by LoadWithSystem() then LoadWithLocal(), in the
+// same order.
+func defaultConfigFiles() []string {
+	out := []string{systemConfigFile}
+	files, _ := filepath.Glob(systemConfigGlob)
+	out = append(out, files...)
+	if path, err := localConfigFile(); err == nil {
+		out = append(out, path)
 	}
+	return out
 }
 
 func getProxyUrl(ns string, cfg Config) string {
@@ -161,6 +230,14 @@ func getProxyUrl(ns string, cfg Config) string {
 	return u
 }
 
+func getProxyAccountUrl(ns string, cfg Config) string {
+	u, err := cfg.GetString(ns, KeyProxyAccount)
+	if err != nil {
+		return getProxyUrl(ns, cfg)
+	}
+	return u
+}
+
 func getProxyConscienceUrl(ns string, cfg Config) string {
 	u, err := cfg.GetString(ns, KeyProxyConscience)
 	if err != nil {
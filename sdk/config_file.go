@@ -0,0 +1,110 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2017-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oio
+
+import (
+	"github.com/go-ini/ini"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const (
+	configFileMode = 0644
+	configDirMode  = 0755
+)
+
+// Returns the path of the configuration file of the current user
+func LocalConfigFile() (string, error) {
+	return localConfigFile()
+}
+
+// Sets the keys in <set> and removes the keys in <unset>, in the section of
+// the namespace of the given INI file. The file is created if missing, and
+// the section removed if it ends empty. The file is replaced atomically, so
+// that a concurrent reader never sees a partial content.
+func UpdateConfigFile(path, ns string, set map[string]string, unset []string) error {
+	var f *ini.File
+	var err error
+
+	if _, err = os.Stat(path); err == nil {
+		f, err = ini.Load(path)
+	} else if os.IsNotExist(err) {
+		f, err = ini.Empty(), nil
+	}
+	if err != nil {
+		return err
+	}
+
+	section := f.Section(ns)
+	for k, v := range set {
+		section.Key(k).SetValue(v)
+	}
+	for _, k := range unset {
+		section.DeleteKey(k)
+	}
+	if len(section.Keys()) <= 0 {
+		f.DeleteSection(ns)
+	}
+
+	return writeConfigFile(path, f)
+}
+
+func writeConfigFile(path string, f *ini.File) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, configDirMode); err != nil {
+		return err
+	}
+
+	mode := os.FileMode(configFileMode)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	abort := func(err error) error {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if _, err = f.WriteTo(tmp); err != nil {
+		return abort(err)
+	}
+	if err = tmp.Chmod(mode); err != nil {
+		return abort(err)
+	}
+	if err = tmp.Sync(); err != nil {
+		return abort(err)
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
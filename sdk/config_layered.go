@@ -0,0 +1,354 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2017-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oio
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Names of the layers built by MakeDefaultConfig(), from the weakest to the
+// strongest.
+const (
+	LayerDefaults  = "defaults"
+	LayerSystem    = "system"
+	LayerLocal     = "local"
+	LayerEnv       = "env"
+	LayerOverrides = "overrides"
+)
+
+// The prefix of the environment variables considered by EnvConfig
+const envPrefix = "OIO_"
+
+// A Config whose content can be enumerated
+type ConfigSet interface {
+	Config
+
+	// Returns the list of namespaces known in the configuration
+	Namespaces() []string
+
+	// Returns all the keys known for the given namespace
+	Keys(ns string) []string
+}
+
+// A configuration value with the name of the layer that provided it
+type ConfigValue struct {
+	Key    string
+	Value  string
+	Origin string
+}
+
+type configLayer struct {
+	name string
+	cfg  ConfigSet
+}
+
+// LayeredConfig is a stack of configuration sets. A key is looked up from the
+// last layer pushed to the first, so that the last layers take precedence.
+type LayeredConfig struct {
+	layers    []configLayer
+	overrides *StaticConfig
+}
+
+// EnvConfig exposes the environment variables named OIO_<NS>_<KEY> as a
+// configuration set. The namespace and the key are upper-cased, their '.'
+// and '-' replaced by '_'. E.g. "proxy-dir" in "OPENIO" is read from
+// OIO_OPENIO_PROXY_DIR.
+type EnvConfig struct{}
+
+// Builds an empty stack of configurations. Only the programmatic overrides
+// are present.
+func MakeLayeredConfig() *LayeredConfig {
+	lc := new(LayeredConfig)
+	lc.layers = make([]configLayer, 0)
+	lc.overrides = MakeStaticConfig()
+	return lc
+}
+
+// Builds the stack of configurations used by default, i.e. from the weakest
+// to the strongest: the SDK defaults, the system-wide files, the user's file,
+// the environment then the programmatic overrides. The missing files are
+// ignored.
+func MakeDefaultConfig() *LayeredConfig {
+	lc := MakeLayeredConfig()
+	lc.Push(LayerDefaults, makeDefaultsConfig())
+
+	system := MakeStaticConfig()
+	system.LoadWithSystem()
+	lc.Push(LayerSystem, system)
+
+	local := MakeStaticConfig()
+	local.LoadWithLocal()
+	lc.Push(LayerLocal, local)
+
+	lc.Push(LayerEnv, &EnvConfig{})
+	return lc
+}
+
+// Adds a configuration set on the top of the stack, i.e. its values will
+// supersede the values of the layers already present (except the
+// programmatic overrides that always come last).
+func (lc *LayeredConfig) Push(name string, cfg ConfigSet) {
+	lc.layers = append(lc.layers, configLayer{name: name, cfg: cfg})
+}
+
+// Sets a programmatic override, stronger than all the layers
+func (lc *LayeredConfig) Set(ns, key, value string) {
+	lc.overrides.Set(ns, key, value)
+}
+
+func (lc *LayeredConfig) all() []configLayer {
+	out := make([]configLayer, 0, len(lc.layers)+1)
+	out = append(out, lc.layers...)
+	return append(out, configLayer{name: LayerOverrides, cfg: lc.overrides})
+}
+
+func (lc *LayeredConfig) lookup(ns, key string) (string, string, error) {
+	layers := lc.all()
+	for i := len(layers) - 1; i >= 0; i-- {
+		if v, err := layers[i].cfg.GetString(ns, key); err == nil {
+			return v, layers[i].name, nil
+		}
+	}
+	return "", "", ErrorNotFound
+}
+
+func (lc *LayeredConfig) GetString(ns, key string) (string, error) {
+	v, _, err := lc.lookup(ns, key)
+	return v, err
+}
+
+func (lc *LayeredConfig) GetBool(ns, key string) (bool, error) {
+	s, err := lc.GetString(ns, key)
+	if err != nil {
+		return false, err
+	}
+	return strconv.ParseBool(s)
+}
+
+func (lc *LayeredConfig) GetInt(ns, key string) (int64, error) {
+	s, err := lc.GetString(ns, key)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// Returns the name of the layer providing the effective value of the key
+func (lc *LayeredConfig) Origin(ns, key string) (string, error) {
+	_, origin, err := lc.lookup(ns, key)
+	return origin, err
+}
+
+// Returns the namespaces known in any of the layers. The environment only
+// tells the encoded names of the namespaces, those matching a namespace of
+// another layer are reported under its name.
+func (lc *LayeredConfig) Namespaces() []string {
+	tmp := make(map[string]bool)
+	encoded := make(map[string]bool)
+	for _, l := range lc.all() {
+		if _, ok := l.cfg.(*EnvConfig); ok {
+			for _, ns := range l.cfg.Namespaces() {
+				encoded[ns] = true
+			}
+			continue
+		}
+		for _, ns := range l.cfg.Namespaces() {
+			tmp[ns] = true
+		}
+	}
+	for ns := range tmp {
+		delete(encoded, envToken(ns))
+	}
+	for ns := range encoded {
+		tmp[ns] = true
+	}
+	out := make([]string, 0, len(tmp))
+	for ns := range tmp {
+		out = append(out, ns)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Returns the keys known for the namespace in any of the layers
+func (lc *LayeredConfig) Keys(ns string) []string {
+	tmp := make(map[string]bool)
+	for _, l := range lc.all() {
+		for _, k := range l.cfg.Keys(ns) {
+			tmp[k] = true
+		}
+	}
+	out := make([]string, 0, len(tmp))
+	for k := range tmp {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Returns the effective value of all the keys of the namespace, with the
+// layer each one comes from, sorted by key.
+func (lc *LayeredConfig) Describe(ns string) []ConfigValue {
+	out := make([]ConfigValue, 0)
+	for _, k := range lc.Keys(ns) {
+		if v, origin, err := lc.lookup(ns, k); err == nil {
+			out = append(out, ConfigValue{Key: k, Value: v, Origin: origin})
+		}
+	}
+	return out
+}
+
+// The values the SDK assumes when nothing is configured. They apply to any
+// namespace.
+type defaultsConfig struct {
+	pairs map[string]string
+}
+
+func makeDefaultsConfig() *defaultsConfig {
+	cfg := &defaultsConfig{pairs: make(map[string]string)}
+	for _, spec := range configSchema {
+		if len(spec.Default) > 0 {
+			cfg.pairs[spec.Key] = spec.Default
+		}
+	}
+	return cfg
+}
+
+func (cfg *defaultsConfig) GetString(ns, key string) (string, error) {
+	if v, ok := cfg.pairs[key]; ok {
+		return v, nil
+	}
+	return "", ErrorNotFound
+}
+
+func (cfg *defaultsConfig) GetBool(ns, key string) (bool, error) {
+	s, err := cfg.GetString(ns, key)
+	if err != nil {
+		return false, err
+	}
+	return strconv.ParseBool(s)
+}
+
+func (cfg *defaultsConfig) GetInt(ns, key string) (int64, error) {
+	s, err := cfg.GetString(ns, key)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+func (cfg *defaultsConfig) Namespaces() []string { return make([]string, 0) }
+
+func (cfg *defaultsConfig) Keys(ns string) []string {
+	out := make([]string, 0, len(cfg.pairs))
+	for k := range cfg.pairs {
+		out = append(out, k)
+	}
+	return out
+}
+
+func envToken(s string) string {
+	s = strings.Replace(s, ".", "_", -1)
+	s = strings.Replace(s, "-", "_", -1)
+	return strings.ToUpper(s)
+}
+
+func envName(ns, key string) string {
+	return envPrefix + envToken(ns) + "_" + envToken(key)
+}
+
+// Returns the name of the environment variable EnvConfig reads for the given
+// namespace and key.
+func EnvVarName(ns, key string) string {
+	return envName(ns, key)
+}
+
+// Splits the name of an environment variable into a namespace and a key.
+// Only the keys known by the SDK are recognized, the longest first.
+func envSplit(name string) (string, string, bool) {
+	if !strings.HasPrefix(name, envPrefix) {
+		return "", "", false
+	}
+	name = name[len(envPrefix):]
+	best := ""
+	for _, spec := range configSchema {
+		k := spec.Key
+		suffix := "_" + envToken(k)
+		if strings.HasSuffix(name, suffix) && len(name) > len(suffix) && len(k) > len(best) {
+			best = k
+		}
+	}
+	if len(best) <= 0 {
+		return "", "", false
+	}
+	return name[:len(name)-len(best)-1], best, true
+}
+
+func (cfg *EnvConfig) GetString(ns, key string) (string, error) {
+	if v, ok := os.LookupEnv(envName(ns, key)); ok {
+		return v, nil
+	}
+	return "", ErrorNotFound
+}
+
+func (cfg *EnvConfig) GetBool(ns, key string) (bool, error) {
+	s, err := cfg.GetString(ns, key)
+	if err != nil {
+		return false, err
+	}
+	return strconv.ParseBool(s)
+}
+
+func (cfg *EnvConfig) GetInt(ns, key string) (int64, error) {
+	s, err := cfg.GetString(ns, key)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// Returns the namespaces, as spelled in the environment (i.e. upper-cased),
+// having at least one known key set.
+func (cfg *EnvConfig) Namespaces() []string {
+	tmp := make(map[string]bool)
+	for _, pair := range os.Environ() {
+		name := strings.SplitN(pair, "=", 2)[0]
+		if ns, _, ok := envSplit(name); ok {
+			tmp[ns] = true
+		}
+	}
+	out := make([]string, 0, len(tmp))
+	for ns := range tmp {
+		out = append(out, ns)
+	}
+	return out
+}
+
+// Returns the known keys set in the environment for the given namespace.
+func (cfg *EnvConfig) Keys(ns string) []string {
+	out := make([]string, 0)
+	for _, pair := range os.Environ() {
+		name := strings.SplitN(pair, "=", 2)[0]
+		if ns0, k, ok := envSplit(name); ok && ns0 == envToken(ns) {
+			out = append(out, k)
+		}
+	}
+	return out
+}
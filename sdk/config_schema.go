@@ -0,0 +1,305 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2017-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oio
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errInvalidSize = errors.New("Invalid size")
+
+// The kinds of values a configuration key can hold
+type KeyKind int
+
+const (
+	KindString KeyKind = iota
+	KindBool
+	KindInt
+	KindDuration
+	KindSize
+	KindList
+	KindUrl
+)
+
+// KeySpec describes a configuration key known by the SDK
+type KeySpec struct {
+	Key       string
+	Kind      KeyKind
+	Default   string
+	Mandatory bool
+	Doc       string
+}
+
+// The reasons a configuration is rejected by the validation
+const (
+	ProblemUnknown = "unknown key"
+	ProblemInvalid = "invalid value"
+	ProblemMissing = "missing mandatory key"
+)
+
+// ConfigError reports a problem met while validating a configuration
+type ConfigError struct {
+	NS      string
+	Key     string
+	Problem string
+	Cause   error
+}
+
+func (e ConfigError) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("%s/%s: %s (%s)", e.NS, e.Key, e.Problem, e.Cause.Error())
+	}
+	return fmt.Sprintf("%s/%s: %s", e.NS, e.Key, e.Problem)
+}
+
+// All the keys known by the SDK, including the keys of the sds.conf files
+// that are used by the other OpenIO services.
+var configSchema = []KeySpec{
+	{KeyProxy, KindUrl, "", false,
+		"Address (IP:PORT) of the oio-proxy, used when no specific proxy is set. Mandatory unless both proxy-dir and proxy-container are set"},
+	{KeyProxyAccount, KindUrl, "", false,
+		"Address of the proxy to the account services"},
+	{KeyProxyConscience, KindUrl, "", false,
+		"Address of the proxy to the conscience"},
+	{KeyProxyContainer, KindUrl, "", false,
+		"Address of the proxy to the container services"},
+	{KeyProxyDirectory, KindUrl, "", false,
+		"Address of the proxy to the directory services"},
+	{KeyAutocreate, KindBool, "false", false,
+		"Create the users and the containers on the fly"},
+	{KeyForce, KindBool, "false", false,
+		"Ask the services to force the operations"},
+	{KeyNsInfoTTL, KindDuration, "60s", false,
+		"How long the namespace info are cached (a plain integer counts seconds)"},
+	{KeyConnectTimeout, KindDuration, "1s", false,
+		"Timeout for the establishment of the connections to the services"},
+	{"conscience", KindList, "", false,
+		"Addresses of the conscience services (not used by the SDK)"},
+	{"zookeeper", KindList, "", false,
+		"Addresses of the zookeeper nodes (not used by the SDK)"},
+	{"event-agent", KindString, "", false,
+		"Endpoint of the event agent (not used by the SDK)"},
+	{"ecd", KindUrl, "", false,
+		"Address of the erasure coding daemon (not used by the SDK)"},
+	{"meta1_digits", KindInt, "", false,
+		"Number of digits of the meta1 prefixes (not used by the SDK)"},
+	{"udp_allowed", KindBool, "", false,
+		"Allow UDP between the services (not used by the SDK)"},
+	{"log_outgoing", KindBool, "", false,
+		"Log the outgoing requests (not used by the SDK)"},
+}
+
+// Returns the description of all the keys known by the SDK, sorted by key.
+func KnownKeys() []KeySpec {
+	out := make([]KeySpec, len(configSchema))
+	copy(out, configSchema)
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+// Returns the description of the given key, if known by the SDK.
+func LookupKey(key string) (KeySpec, bool) {
+	for _, spec := range configSchema {
+		if spec.Key == key {
+			return spec, true
+		}
+	}
+	return KeySpec{Key: key}, false
+}
+
+// Get the value of the key, or its default value from the schema if the key
+// is not set.
+func getStringOrDefault(cfg Config, ns, key string) (string, error) {
+	v, err := cfg.GetString(ns, key)
+	if err == ErrorNotFound {
+		if spec, ok := LookupKey(key); ok && len(spec.Default) > 0 {
+			return spec.Default, nil
+		}
+	}
+	return v, err
+}
+
+// Get a duration. Either a plain integer counting seconds, or a value
+// accepted by time.ParseDuration() (e.g. "1m30s").
+func GetDuration(cfg Config, ns, key string) (time.Duration, error) {
+	s, err := getStringOrDefault(cfg, ns, key)
+	if err != nil {
+		return 0, err
+	}
+	return parseDuration(s)
+}
+
+// Get a size in bytes, e.g. "4096", "100M" or "1G". The units are powers of
+// 1024.
+func GetSize(cfg Config, ns, key string) (uint64, error) {
+	s, err := getStringOrDefault(cfg, ns, key)
+	if err != nil {
+		return 0, err
+	}
+	return parseSize(s)
+}
+
+// Get a list of values separated by commas. The items are trimmed and the
+// empty items dropped.
+func GetList(cfg Config, ns, key string) ([]string, error) {
+	s, err := getStringOrDefault(cfg, ns, key)
+	if err != nil {
+		return make([]string, 0), err
+	}
+	return parseList(s), nil
+}
+
+// Get an URL. A plain "HOST:PORT" is accepted and considered as an HTTP
+// endpoint.
+func GetUrl(cfg Config, ns, key string) (*url.URL, error) {
+	s, err := getStringOrDefault(cfg, ns, key)
+	if err != nil {
+		return nil, err
+	}
+	return parseUrl(s)
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Duration(v) * time.Second, nil
+	}
+	return time.ParseDuration(s)
+}
+
+func parseSize(s string) (uint64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+	if len(s) <= 0 {
+		return 0, errInvalidSize
+	}
+	var mult uint64 = 1
+	switch s[len(s)-1] {
+	case 'K':
+		mult = 1 << 10
+	case 'M':
+		mult = 1 << 20
+	case 'G':
+		mult = 1 << 30
+	case 'T':
+		mult = 1 << 40
+	}
+	if mult > 1 {
+		s = s[:len(s)-1]
+	}
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || v > math.MaxUint64/mult {
+		return 0, errInvalidSize
+	}
+	return v * mult, nil
+}
+
+func parseList(s string) []string {
+	out := make([]string, 0)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func parseUrl(s string) (*url.URL, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "://") {
+		if _, _, err := net.SplitHostPort(s); err != nil {
+			return nil, err
+		}
+		s = "http://" + s
+	}
+	u, err := url.Parse(s)
+	if err == nil && len(u.Host) <= 0 {
+		err = errors.New("No host in URL")
+	}
+	return u, err
+}
+
+func checkValue(kind KeyKind, s string) error {
+	var err error
+	switch kind {
+	case KindBool:
+		_, err = strconv.ParseBool(s)
+	case KindInt:
+		_, err = strconv.ParseInt(s, 10, 64)
+	case KindDuration:
+		_, err = parseDuration(s)
+	case KindSize:
+		_, err = parseSize(s)
+	case KindUrl:
+		for _, item := range parseList(s) {
+			if _, err = parseUrl(item); err != nil {
+				break
+			}
+		}
+	}
+	return err
+}
+
+// Checks the configuration of the given namespace against the keys known by
+// the SDK. It reports the unknown keys, the values that cannot be parsed and
+// the mandatory keys that are missing.
+func ValidateConfig(cfg ConfigSet, ns string) []ConfigError {
+	out := make([]ConfigError, 0)
+	present := make(map[string]bool)
+	keys := cfg.Keys(ns)
+	sort.Strings(keys)
+	for _, k := range keys {
+		present[k] = true
+		spec, ok := LookupKey(k)
+		if !ok {
+			out = append(out, ConfigError{NS: ns, Key: k, Problem: ProblemUnknown})
+			continue
+		}
+		v, _ := cfg.GetString(ns, k)
+		if err := checkValue(spec.Kind, v); err != nil {
+			out = append(out, ConfigError{NS: ns, Key: k, Problem: ProblemInvalid, Cause: err})
+		}
+	}
+	for _, spec := range KnownKeys() {
+		if spec.Mandatory && !present[spec.Key] {
+			out = append(out, ConfigError{NS: ns, Key: spec.Key, Problem: ProblemMissing})
+		}
+	}
+	// The main proxy is only needed by the services without a specific one
+	if !present[KeyProxy] && !(present[KeyProxyDirectory] && present[KeyProxyContainer]) {
+		out = append(out, ConfigError{NS: ns, Key: KeyProxy, Problem: ProblemMissing})
+	}
+	return out
+}
+
+// Checks the configuration of the namespace, see ValidateConfig()
+func (cfg *StaticConfig) Validate(ns string) []ConfigError {
+	return ValidateConfig(cfg, ns)
+}
+
+// Checks the effective configuration of the namespace, see ValidateConfig()
+func (lc *LayeredConfig) Validate(ns string) []ConfigError {
+	return ValidateConfig(lc, ns)
+}
@@ -17,7 +17,13 @@
 package oio
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestConfig_Init(t *testing.T) {
@@ -68,3 +74,273 @@ func TestConfig_LoadInValidContent(t *testing.T) {
 		t.Fatal("LoadWithContent succeeded: ", err)
 	}
 }
+
+func TestConfig_Layers(t *testing.T) {
+	low := MakeStaticConfig()
+	low.Set("NS", KeyProxy, "127.0.0.1:6000")
+	low.Set("NS", KeyProxyDirectory, "127.0.0.1:6001")
+	high := MakeStaticConfig()
+	high.Set("NS", KeyProxy, "127.0.0.1:6002")
+
+	lc := MakeLayeredConfig()
+	lc.Push(LayerDefaults, makeDefaultsConfig())
+	lc.Push(LayerSystem, low)
+	lc.Push(LayerLocal, high)
+
+	if v, _ := lc.GetString("NS", KeyProxy); v != "127.0.0.1:6002" {
+		t.Fatal("Wrong precedence: ", v)
+	}
+	if o, _ := lc.Origin("NS", KeyProxyDirectory); o != LayerSystem {
+		t.Fatal("Wrong origin: ", o)
+	}
+	if o, _ := lc.Origin("NS", KeyAutocreate); o != LayerDefaults {
+		t.Fatal("Wrong origin: ", o)
+	}
+
+	lc.Set("NS", KeyProxy, "127.0.0.1:6003")
+	if v, _ := lc.GetString("NS", KeyProxy); v != "127.0.0.1:6003" {
+		t.Fatal("Override ignored: ", v)
+	}
+	for _, cv := range lc.Describe("NS") {
+		if cv.Key == KeyProxy && cv.Origin != LayerOverrides {
+			t.Fatal("Wrong origin: ", cv)
+		}
+	}
+}
+
+func TestConfig_Env(t *testing.T) {
+	os.Setenv("OIO_MY_NS_PROXY_DIR", "127.0.0.1:6004")
+	defer os.Unsetenv("OIO_MY_NS_PROXY_DIR")
+
+	if ns, k, ok := envSplit("OIO_MY_NS_PROXY_DIR"); !ok || ns != "MY_NS" || k != KeyProxyDirectory {
+		t.Fatal("Wrong split: ", ns, k)
+	}
+	if _, _, ok := envSplit("OIO_NS"); ok {
+		t.Fatal("OIO_NS taken as a configuration key")
+	}
+
+	cfg := &EnvConfig{}
+	if v, err := cfg.GetString("my-ns", KeyProxyDirectory); err != nil || v != "127.0.0.1:6004" {
+		t.Fatal("Env not loaded: ", v, err)
+	}
+	if keys := cfg.Keys("my-ns"); len(keys) != 1 || keys[0] != KeyProxyDirectory {
+		t.Fatal("Unexpected keys: ", keys)
+	}
+
+	// The encoded names are reported under the names of the other layers
+	os.Setenv("OIO_OTHER_PROXY", "127.0.0.1:6000")
+	defer os.Unsetenv("OIO_OTHER_PROXY")
+	lc := MakeLayeredConfig()
+	static := MakeStaticConfig()
+	static.Set("my-ns", KeyProxy, "127.0.0.1:6000")
+	lc.Push(LayerSystem, static)
+	lc.Push(LayerEnv, cfg)
+	if l := lc.Namespaces(); len(l) != 2 || l[0] != "OTHER" || l[1] != "my-ns" {
+		t.Fatal("Unexpected namespaces: ", l)
+	}
+}
+
+func TestConfig_Typed(t *testing.T) {
+	cfg := MakeStaticConfig()
+	cfg.Set("NS", "ttl", "90")
+	cfg.Set("NS", "timeout", "1m30s")
+	cfg.Set("NS", "size", "100M")
+	cfg.Set("NS", "list", " a, b,,c ")
+	cfg.Set("NS", KeyProxy, "127.0.0.1:6000")
+
+	if d, err := GetDuration(cfg, "NS", "ttl"); err != nil || d != 90*time.Second {
+		t.Fatal("Wrong duration: ", d, err)
+	}
+	if d, err := GetDuration(cfg, "NS", "timeout"); err != nil || d != 90*time.Second {
+		t.Fatal("Wrong duration: ", d, err)
+	}
+	if d, err := GetDuration(cfg, "NS", KeyConnectTimeout); err != nil || d != time.Second {
+		t.Fatal("Default not applied: ", d, err)
+	}
+	if s, err := GetSize(cfg, "NS", "size"); err != nil || s != 100*1024*1024 {
+		t.Fatal("Wrong size: ", s, err)
+	}
+	if l, _ := GetList(cfg, "NS", "list"); len(l) != 3 || l[2] != "c" {
+		t.Fatal("Wrong list: ", l)
+	}
+	if u, err := GetUrl(cfg, "NS", KeyProxy); err != nil || u.Host != "127.0.0.1:6000" {
+		t.Fatal("Wrong URL: ", u, err)
+	}
+	if _, err := parseSize("12X"); err == nil {
+		t.Fatal("Invalid size accepted")
+	}
+	if _, err := parseSize("18014398509481984K"); err == nil {
+		t.Fatal("Overflowing size accepted")
+	}
+	if s, err := parseSize("16777215T"); err != nil || s != 16777215<<40 {
+		t.Fatal("Wrong size: ", s, err)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	cfg := MakeStaticConfig()
+	cfg.Set("NS", KeyAutocreate, "yes please")
+	cfg.Set("NS", "proxy-dri", "127.0.0.1:6000")
+
+	problems := make(map[string]string)
+	for _, e := range cfg.Validate("NS") {
+		problems[e.Key] = e.Problem
+	}
+	if problems[KeyAutocreate] != ProblemInvalid ||
+		problems["proxy-dri"] != ProblemUnknown ||
+		problems[KeyProxy] != ProblemMissing || len(problems) != 3 {
+		t.Fatal("Unexpected problems: ", problems)
+	}
+
+	// The specific proxies are enough
+	cfg = MakeStaticConfig()
+	cfg.Set("NS", KeyProxyDirectory, "127.0.0.1:6000")
+	cfg.Set("NS", KeyProxyContainer, "127.0.0.1:6000")
+	if problems := cfg.Validate("NS"); len(problems) != 0 {
+		t.Fatal("Unexpected problems: ", problems)
+	}
+	cfg = MakeStaticConfig()
+	cfg.Set("NS", KeyProxyDirectory, "127.0.0.1:6000")
+	errs := cfg.Validate("NS")
+	if len(errs) != 1 || errs[0].Key != KeyProxy {
+		t.Fatal("Unexpected problems: ", errs)
+	}
+	var err error = errs[0]
+	if !strings.Contains(err.Error(), ProblemMissing) {
+		t.Fatal("Unexpected error: ", err)
+	}
+}
+
+func TestConfig_Concurrent(t *testing.T) {
+	cfg := MakeStaticConfig()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				cfg.Set("NS", KeyProxy, "127.0.0.1:6000")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				cfg.GetString("NS", KeyProxy)
+				cfg.Keys("NS")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestConfig_Watch(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "oio-config-test-")
+	if err != nil {
+		t.Fatal("TempDir failure: ", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	path := filepath.Join(tmpdir, "sds.conf")
+	ioutil.WriteFile(path, []byte("[NS]\nproxy=127.0.0.1:6000\nzookeeper=127.0.0.1:2181\n"), 0644)
+
+	cfg := MakeStaticConfig()
+	cfg.Set("NS", KeyAutocreate, "true")
+	w := MakeConfigWatcherWithSources(cfg, time.Second, func() []string { return []string{path} })
+	if v, _ := cfg.GetString("NS", "zookeeper"); v != "127.0.0.1:2181" {
+		t.Fatal("Files not loaded at once: ", v)
+	}
+
+	changes := make(map[string][]string)
+	w.Subscribe(func(ns string, keys []string) { changes[ns] = keys })
+
+	ioutil.WriteFile(path, []byte("[NS]\nproxy=127.0.0.1:6001\n"), 0644)
+	if err = w.Reload(); err != nil {
+		t.Fatal("Reload failed: ", err)
+	}
+	if keys := changes["NS"]; len(keys) != 2 || keys[0] != KeyProxy || keys[1] != "zookeeper" {
+		t.Fatal("Unexpected changes: ", changes)
+	}
+	if v, _ := cfg.GetString("NS", KeyProxy); v != "127.0.0.1:6001" {
+		t.Fatal("Value not reloaded: ", v)
+	}
+	if _, err = cfg.GetString("NS", "zookeeper"); err != ErrorNotFound {
+		t.Fatal("Removed key still present")
+	}
+	if v, _ := cfg.GetString("NS", KeyAutocreate); v != "true" {
+		t.Fatal("Programmatic value lost")
+	}
+}
+
+func TestConfig_WatchPartialWrite(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "oio-config-test-")
+	if err != nil {
+		t.Fatal("TempDir failure: ", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	path := filepath.Join(tmpdir, "sds.conf")
+	ioutil.WriteFile(path, []byte("[NS]\nproxy=127.0.0.1:6000\n"), 0644)
+
+	cfg := MakeStaticConfig()
+	w := MakeConfigWatcherWithSources(cfg, time.Second, func() []string { return []string{path} })
+	changes := 0
+	w.Subscribe(func(ns string, keys []string) { changes++ })
+
+	// Truncated, then half written: the keys stay and the file is checked
+	// again.
+	for _, partial := range []string{"", "[NS\npro"} {
+		ioutil.WriteFile(path, []byte(partial), 0644)
+		if err = w.Reload(); err == nil {
+			t.Fatal("Partial file accepted: ", partial)
+		}
+		if v, _ := cfg.GetString("NS", KeyProxy); v != "127.0.0.1:6000" {
+			t.Fatal("Keys lost with a partial file: ", v)
+		}
+		if !w.changed() {
+			t.Fatal("Partial file not checked again")
+		}
+	}
+	if changes != 0 {
+		t.Fatal("Subscribers notified of a partial file: ", changes)
+	}
+
+	ioutil.WriteFile(path, []byte("[NS]\nproxy=127.0.0.1:6001\n"), 0644)
+	if err = w.Reload(); err != nil {
+		t.Fatal("Reload failed: ", err)
+	}
+	if v, _ := cfg.GetString("NS", KeyProxy); v != "127.0.0.1:6001" || changes != 1 {
+		t.Fatal("Value not reloaded: ", v, changes)
+	}
+
+	os.Remove(path)
+	w.Reload()
+	if _, err = cfg.GetString("NS", KeyProxy); err != ErrorNotFound {
+		t.Fatal("Keys of a removed file still present")
+	}
+}
+
+func TestConfig_UpdateFile(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "oio-config-test-")
+	if err != nil {
+		t.Fatal("TempDir failure: ", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	path := filepath.Join(tmpdir, "sub", "sds.conf")
+
+	set := map[string]string{KeyProxy: "127.0.0.1:6000", "zookeeper": "127.0.0.1:2181"}
+	if err = UpdateConfigFile(path, "NS", set, nil); err != nil {
+		t.Fatal("Update failed: ", err)
+	}
+	if err = UpdateConfigFile(path, "NS", nil, []string{"zookeeper"}); err != nil {
+		t.Fatal("Update failed: ", err)
+	}
+
+	cfg := MakeStaticConfig()
+	if err = cfg.LoadWithFile(path); err != nil {
+		t.Fatal("Load failed: ", err)
+	}
+	if v, _ := cfg.GetString("NS", KeyProxy); v != "127.0.0.1:6000" {
+		t.Fatal("Key not set: ", v)
+	}
+	if _, err = cfg.GetString("NS", "zookeeper"); err != ErrorNotFound {
+		t.Fatal("Key not removed")
+	}
+}
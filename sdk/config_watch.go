@@ -0,0 +1,256 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2017-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oio
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How often the files are checked when the caller doesn't tell
+const defaultWatchInterval = 5 * time.Second
+
+// Called with the keys of the namespace whose value changed, appeared or
+// disappeared during a reload.
+type ConfigSubscriber func(ns string, keys []string)
+
+type fileStamp struct {
+	size  int64
+	mtime time.Time
+}
+
+// ConfigWatcher periodically checks the system-wide and the local
+// configuration files, and reloads a StaticConfig when they change.
+//
+// Only the keys coming from the files are managed: a key set with
+// StaticConfig.Set() is kept until the same key changes in the files.
+type ConfigWatcher struct {
+	cfg      *StaticConfig
+	interval time.Duration
+
+	// Returns the files to be loaded, in the loading order
+	sources func() []string
+
+	lock        sync.Mutex
+	stamps      map[string]fileStamp
+	files       map[string]map[string]string
+	loaded      map[string]string
+	subscribers []ConfigSubscriber
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Builds a watcher on the system-wide and the local configuration files,
+// checked every <interval>. The files are loaded at once in <cfg>, then the
+// watcher must be started with Start().
+func MakeConfigWatcher(cfg *StaticConfig, interval time.Duration) *ConfigWatcher {
+	return MakeConfigWatcherWithSources(cfg, interval, defaultConfigFiles)
+}
+
+// Acts as MakeConfigWatcher() on the files returned by <sources>, in the
+// loading order.
+func MakeConfigWatcherWithSources(cfg *StaticConfig, interval time.Duration, sources func() []string) *ConfigWatcher {
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	w := &ConfigWatcher{
+		cfg:         cfg,
+		interval:    interval,
+		sources:     sources,
+		stamps:      make(map[string]fileStamp),
+		files:       make(map[string]map[string]string),
+		loaded:      make(map[string]string),
+		subscribers: make([]ConfigSubscriber, 0),
+	}
+	w.Reload()
+	return w
+}
+
+// Registers a function called after each reload, once per namespace with
+// changed keys.
+func (w *ConfigWatcher) Subscribe(fn ConfigSubscriber) {
+	w.lock.Lock()
+	w.subscribers = append(w.subscribers, fn)
+	w.lock.Unlock()
+}
+
+// Starts checking the files in a background goroutine
+func (w *ConfigWatcher) Start() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.stop != nil {
+		return
+	}
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	go w.run(w.stop, w.done)
+}
+
+// Stops the background checks, and waits for the goroutine to exit
+func (w *ConfigWatcher) Stop() {
+	w.lock.Lock()
+	stop, done := w.stop, w.done
+	w.stop, w.done = nil, nil
+	w.lock.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+func (w *ConfigWatcher) run(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if w.changed() {
+				w.Reload()
+			}
+		}
+	}
+}
+
+func (w *ConfigWatcher) stampAll() map[string]fileStamp {
+	out := make(map[string]fileStamp)
+	for _, path := range w.sources() {
+		if fi, err := os.Stat(path); err == nil {
+			out[path] = fileStamp{size: fi.Size(), mtime: fi.ModTime()}
+		}
+	}
+	return out
+}
+
+// Tells if a file appeared, disappeared or has been modified since the last
+// reload.
+func (w *ConfigWatcher) changed() bool {
+	stamps := w.stampAll()
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if len(stamps) != len(w.stamps) {
+		return true
+	}
+	for path, st := range stamps {
+		if old, ok := w.stamps[path]; !ok || old != st {
+			return true
+		}
+	}
+	return false
+}
+
+// Reads all the files again and applies the differences to the StaticConfig.
+// The subscribers are then notified of the changed keys.
+//
+// A file that cannot be read or parsed, or that turned empty, is probably
+// being written: its previous keys are kept and it will be read again at the
+// next check. Only a file that disappeared loses its keys.
+func (w *ConfigWatcher) Reload() error {
+	stamps := w.stampAll()
+	w.lock.Lock()
+	previous := w.files
+	oldStamps := w.stamps
+	w.lock.Unlock()
+
+	files := make(map[string]map[string]string)
+	fresh := make(map[string]string)
+	var lastErr error
+	for _, path := range w.sources() {
+		pairs, err := w.loadFile(path, previous[path])
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			log.Printf("ConfigWatcher: Failed to load [%s]: %s", path, err.Error())
+			lastErr = err
+			pairs = previous[path]
+			if st, ok := oldStamps[path]; ok {
+				stamps[path] = st
+			} else {
+				delete(stamps, path)
+			}
+		}
+		files[path] = pairs
+		for k, v := range pairs {
+			fresh[k] = v
+		}
+	}
+
+	w.lock.Lock()
+	changes := make(map[string][]string)
+	record := func(k string) {
+		if idx := strings.Index(k, "/"); idx > 0 {
+			changes[k[:idx]] = append(changes[k[:idx]], k[idx+1:])
+		}
+	}
+	w.cfg.lock.Lock()
+	for k, v := range fresh {
+		if old, ok := w.loaded[k]; !ok || old != v {
+			w.cfg.pairs[k] = v
+			record(k)
+		}
+	}
+	for k := range w.loaded {
+		if _, ok := fresh[k]; !ok {
+			delete(w.cfg.pairs, k)
+			record(k)
+		}
+	}
+	w.cfg.lock.Unlock()
+	w.loaded = fresh
+	w.files = files
+	w.stamps = stamps
+	subscribers := make([]ConfigSubscriber, len(w.subscribers))
+	copy(subscribers, w.subscribers)
+	w.lock.Unlock()
+
+	namespaces := make([]string, 0, len(changes))
+	for ns := range changes {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	for _, ns := range namespaces {
+		keys := changes[ns]
+		sort.Strings(keys)
+		for _, fn := range subscribers {
+			fn(ns, keys)
+		}
+	}
+	return lastErr
+}
+
+var errConfigEmptied = errors.New("Configuration file emptied")
+
+func (w *ConfigWatcher) loadFile(path string, previous map[string]string) (map[string]string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(content) <= 0 && len(previous) > 0 {
+		return nil, errConfigEmptied
+	}
+	return parseContent(content)
+}
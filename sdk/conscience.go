@@ -0,0 +1,152 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2015-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oio
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+type conscienceClient struct {
+	ns     string
+	config Config
+}
+
+// The description of a service expected by the conscience in the bodies of
+// the register, deregister, lock and unlock requests.
+type conscienceItem struct {
+	NS    string                 `json:"ns"`
+	Type  string                 `json:"type"`
+	Addr  string                 `json:"addr"`
+	Score int                    `json:"score"`
+	Tags  map[string]interface{} `json:"tags,omitempty"`
+}
+
+func (cli *conscienceClient) getUrl(action string) string {
+	return fmt.Sprintf("http://%s/v3.0/%s/conscience/%s",
+		getProxyConscienceUrl(cli.ns, cli.config), cli.ns, action)
+}
+
+// Sends the request and decodes the JSON reply into <out>, unless <out> is
+// nil.
+func (cli *conscienceClient) jsonRequest(req *http.Request, out interface{}) error {
+	p := makeHttpClient(cli.ns, cli.config)
+	rep, err := p.Do(req)
+	if rep != nil {
+		defer rep.Body.Close()
+	}
+	if err != nil {
+		return err
+	}
+
+	if rep.StatusCode/100 == 2 {
+		if out == nil {
+			return nil
+		}
+		decoder := json.NewDecoder(rep.Body)
+		return decoder.Decode(out)
+	} else if rep.StatusCode == 404 {
+		return ErrorNotFound
+	} else {
+		return readProxyError(rep.StatusCode, rep)
+	}
+}
+
+func (cli *conscienceClient) serviceRequest(action string, body interface{}) error {
+	encoded, _ := json.Marshal(body)
+	req, _ := http.NewRequest("POST", cli.getUrl(action), bytes.NewBuffer(encoded))
+	return cli.jsonRequest(req, nil)
+}
+
+func (cli *conscienceClient) makeItem(srv ServiceInfo) conscienceItem {
+	return conscienceItem{
+		NS: cli.ns, Type: srv.Type, Addr: srv.Addr,
+		Score: srv.Score, Tags: srv.Tags,
+	}
+}
+
+func (cli *conscienceClient) ListServices(ns, srvtype string) ([]ServiceInfo, error) {
+	out := make([]ServiceInfo, 0)
+	if ns != cli.ns {
+		return out, ErrorNsNotManaged
+	}
+	req, _ := http.NewRequest("GET",
+		cli.getUrl("list")+"?type="+url.QueryEscape(srvtype), nil)
+	err := cli.jsonRequest(req, &out)
+	for i := range out {
+		if len(out[i].Type) <= 0 {
+			out[i].Type = srvtype
+		}
+	}
+	return out, err
+}
+
+func (cli *conscienceClient) ListTypes(ns string) ([]string, error) {
+	out := make([]string, 0)
+	if ns != cli.ns {
+		return out, ErrorNsNotManaged
+	}
+	req, _ := http.NewRequest("GET", cli.getUrl("info")+"?what=types", nil)
+	err := cli.jsonRequest(req, &out)
+	return out, err
+}
+
+func (cli *conscienceClient) RegisterService(ns string, srv ServiceInfo) error {
+	if ns != cli.ns {
+		return ErrorNsNotManaged
+	}
+	return cli.serviceRequest("register", cli.makeItem(srv))
+}
+
+func (cli *conscienceClient) DeregisterService(ns string, srv ServiceInfo) error {
+	if ns != cli.ns {
+		return ErrorNsNotManaged
+	}
+	return cli.serviceRequest("deregister", []conscienceItem{cli.makeItem(srv)})
+}
+
+func (cli *conscienceClient) LockScore(ns string, srv ServiceInfo) error {
+	if ns != cli.ns {
+		return ErrorNsNotManaged
+	}
+	return cli.serviceRequest("lock", cli.makeItem(srv))
+}
+
+func (cli *conscienceClient) UnlockScore(ns string, srv ServiceInfo) error {
+	if ns != cli.ns {
+		return ErrorNsNotManaged
+	}
+	return cli.serviceRequest("unlock", cli.makeItem(srv))
+}
+
+func (cli *conscienceClient) GetNamespaceInfo(ns string) (NamespaceInfo, error) {
+	out := NamespaceInfo{
+		Options:         make(map[string]string),
+		StoragePolicies: make(map[string]string),
+		DataSecurities:  make(map[string]string),
+		ServicePools:    make(map[string]string),
+	}
+	if ns != cli.ns {
+		return out, ErrorNsNotManaged
+	}
+	req, _ := http.NewRequest("GET", cli.getUrl("info"), nil)
+	err := cli.jsonRequest(req, &out)
+	return out, err
+}
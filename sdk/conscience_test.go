@@ -0,0 +1,143 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2015-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oio
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Serves the conscience requests of the proxy, and records the bodies of
+// the service requests.
+func startTestConscience(t *testing.T, posted map[string][]byte) (*httptest.Server, Conscience) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rep http.ResponseWriter, req *http.Request) {
+		if !strings.HasPrefix(req.URL.Path, "/v3.0/NS/conscience/") {
+			rep.WriteHeader(http.StatusNotFound)
+			return
+		}
+		action := strings.TrimPrefix(req.URL.Path, "/v3.0/NS/conscience/")
+		switch {
+		case action == "list" && req.URL.Query().Get("type") == "rawx":
+			rep.Write([]byte(`[{"addr":"127.0.0.1:6010","score":42,"tags":{"tag.up":true}},` +
+				`{"type":"rawx","addr":"127.0.0.1:6011","score":0}]`))
+		case action == "list" && req.URL.Query().Get("type") == "broken":
+			rep.WriteHeader(http.StatusInternalServerError)
+			rep.Write([]byte(`{"status":500,"message":"boom"}`))
+		case action == "list":
+			rep.WriteHeader(http.StatusNotFound)
+		case action == "info" && req.URL.Query().Get("what") == "types":
+			rep.Write([]byte(`["meta2","rawx"]`))
+		case action == "info":
+			rep.Write([]byte(`{"ns":"NS","chunksize":1048576,` +
+				`"options":{"flat_bitlength":"17"},` +
+				`"storage_policy":{"SINGLE":"NONE:NONE"},` +
+				`"data_security":{},"service_pools":{}}`))
+		default:
+			var body json.RawMessage
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+				rep.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			posted[action] = body
+			rep.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	cfg := MakeStaticConfig()
+	cfg.Set("NS", KeyProxy, strings.TrimPrefix(srv.URL, "http://"))
+	cs, _ := MakeConscienceClient("NS", cfg)
+	return srv, cs
+}
+
+func TestConscience_List(t *testing.T) {
+	srv, cs := startTestConscience(t, nil)
+	defer srv.Close()
+
+	l, err := cs.ListServices("NS", "rawx")
+	if err != nil || len(l) != 2 {
+		t.Fatal("Listing failed: ", l, err)
+	}
+	if l[0].Type != "rawx" || l[0].Addr != "127.0.0.1:6010" || l[0].Score != 42 || l[0].Tags["tag.up"] != true {
+		t.Fatal("Unexpected service: ", l[0])
+	}
+	if _, err = cs.ListServices("NS", "nope"); err != ErrorNotFound {
+		t.Fatal("Expected not found, got ", err)
+	}
+	if _, err = cs.ListServices("NS", "broken"); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Fatal("Expected a proxy error, got ", err)
+	}
+	if _, err = cs.ListServices("OTHER", "rawx"); err != ErrorNsNotManaged {
+		t.Fatal("Expected a namespace error, got ", err)
+	}
+
+	types, err := cs.ListTypes("NS")
+	if err != nil || len(types) != 2 || types[1] != "rawx" {
+		t.Fatal("Unexpected types: ", types, err)
+	}
+}
+
+func TestConscience_Info(t *testing.T) {
+	srv, cs := startTestConscience(t, nil)
+	defer srv.Close()
+
+	ni, err := cs.GetNamespaceInfo("NS")
+	if err != nil {
+		t.Fatal("Info failed: ", err)
+	}
+	if ni.Name != "NS" || ni.ChunkSize != 1048576 || ni.Options["flat_bitlength"] != "17" ||
+		ni.StoragePolicies["SINGLE"] != "NONE:NONE" {
+		t.Fatal("Unexpected info: ", ni)
+	}
+	if _, err = cs.GetNamespaceInfo("OTHER"); err != ErrorNsNotManaged {
+		t.Fatal("Expected a namespace error, got ", err)
+	}
+
+	srv.Close()
+	if _, err = cs.GetNamespaceInfo("NS"); err == nil {
+		t.Fatal("Info fetched from a stopped proxy")
+	}
+}
+
+func TestConscience_Services(t *testing.T) {
+	posted := make(map[string][]byte)
+	srv, cs := startTestConscience(t, posted)
+	defer srv.Close()
+
+	s := ServiceInfo{Type: "rawx", Addr: "127.0.0.1:6010", Score: 12}
+	for _, f := range []func(string, ServiceInfo) error{
+		cs.RegisterService, cs.DeregisterService, cs.LockScore, cs.UnlockScore,
+	} {
+		if err := f("NS", s); err != nil {
+			t.Fatal("Service request failed: ", err)
+		}
+	}
+
+	var item conscienceItem
+	if err := json.Unmarshal(posted["register"], &item); err != nil ||
+		item.NS != "NS" || item.Type != "rawx" || item.Addr != s.Addr || item.Score != 12 {
+		t.Fatal("Unexpected registration: ", string(posted["register"]), err)
+	}
+	var items []conscienceItem
+	if err := json.Unmarshal(posted["deregister"], &items); err != nil || len(items) != 1 {
+		t.Fatal("Unexpected deregistration: ", string(posted["deregister"]), err)
+	}
+	if len(posted["lock"]) <= 0 || len(posted["unlock"]) <= 0 {
+		t.Fatal("Score requests not sent: ", posted)
+	}
+}
@@ -62,7 +62,7 @@ func (cli *containerClient) actionFlags(autocreate, force bool) string {
 }
 
 func (cli *containerClient) autocreateFlag(auto bool) string {
-	return cli.actionFlags(true, false)
+	return cli.actionFlags(auto, false)
 }
 
 func (cli *containerClient) getRefUrl(n UserName, action string) string {
@@ -73,10 +73,34 @@ func (cli *containerClient) getRefUrl(n UserName, action string) string {
 }
 
 func (cli *containerClient) getContentUrl(n ObjectName, action string) string {
-	return fmt.Sprintf("http://%s/v3.0/%s/content/%s?acct=%s&ref=%s&path=%s",
+	u := fmt.Sprintf("http://%s/v3.0/%s/content/%s?acct=%s&ref=%s&path=%s",
 		getProxyContainerUrl(cli.ns, cli.config),
 		cli.ns, action,
 		url.QueryEscape(n.Account()), url.QueryEscape(n.User()), url.QueryEscape(n.Path()))
+	if v := n.Version(); v > 0 {
+		u = u + "&version=" + strconv.FormatUint(v, 10)
+	}
+	return u
+}
+
+func (cli *containerClient) getListUrl(n ContainerName, p ListParams) string {
+	u := cli.getRefUrl(n, "list")
+	if len(p.Prefix) > 0 {
+		u = u + "&prefix=" + url.QueryEscape(p.Prefix)
+	}
+	if len(p.Marker) > 0 {
+		u = u + "&marker=" + url.QueryEscape(p.Marker)
+	}
+	if len(p.Delimiter) > 0 {
+		u = u + "&delimiter=" + url.QueryEscape(p.Delimiter)
+	}
+	if p.Max > 0 {
+		u = u + "&max=" + strconv.Itoa(p.Max)
+	}
+	if p.Versions {
+		u = u + "&all=1"
+	}
+	return u
 }
 
 func (cli *containerClient) CreateContainer(n ContainerName, auto bool) (bool, error) {
@@ -110,14 +134,19 @@ func (cli *containerClient) HasContainer(n ContainerName) (bool, error) {
 }
 
 func (cli *containerClient) ListContents(n ContainerName) (ContainerListing, error) {
+	return cli.ListContentsWithParams(n, ListParams{})
+}
+
+func (cli *containerClient) ListContentsWithParams(n ContainerName, params ListParams) (ContainerListing, error) {
 	if n.NS() != cli.ns {
 		var out ContainerListing
 		return out, ErrorNsNotManaged
 	}
-	req, _ := http.NewRequest("GET", cli.getRefUrl(n, "list"), nil)
+	req, _ := http.NewRequest("GET", cli.getListUrl(n, params), nil)
 	out := ContainerListing{
 		Objects:    make([]ContentHeader, 0),
 		Properties: make([]Property, 0),
+		Prefixes:   make([]string, 0),
 	}
 
 	p := makeHttpClient(cli.ns, cli.config)
@@ -132,6 +161,8 @@ func (cli *containerClient) ListContents(n ContainerName) (ContainerListing, err
 	if rep.StatusCode/100 == 2 {
 		decoder := json.NewDecoder(rep.Body)
 		err = decoder.Decode(&out)
+		out.Truncated, _ = strconv.ParseBool(rep.Header.Get("X-oio-list-truncated"))
+		out.NextMarker = rep.Header.Get("X-oio-list-marker")
 		return out, err
 	} else if rep.StatusCode == 404 {
 		return out, ErrorNotFound
@@ -162,7 +193,10 @@ func (cli *containerClient) GetContent(n ObjectName) (Content, error) {
 
 	if rep.StatusCode/100 == 2 {
 		decoder := json.NewDecoder(rep.Body)
-		err = decoder.Decode(&content.Chunks)
+		if err = decoder.Decode(&content.Chunks); err != nil {
+			return content, err
+		}
+		content.Header, err = readContentHeader(rep.Header)
 		return content, err
 	} else if rep.StatusCode == 404 {
 		return content, ErrorNotFound
@@ -171,7 +205,44 @@ func (cli *containerClient) GetContent(n ObjectName) (Content, error) {
 	}
 }
 
+func (cli *containerClient) StatContent(n ObjectName) (ContentHeader, error) {
+	var hdr ContentHeader
+	if n.NS() != cli.ns {
+		return hdr, ErrorNsNotManaged
+	}
+	req, _ := http.NewRequest("HEAD", cli.getContentUrl(n, "show"), nil)
+
+	p := makeHttpClient(cli.ns, cli.config)
+	rep, err := p.Do(req)
+	if rep != nil {
+		defer rep.Body.Close()
+	}
+	if err != nil {
+		return hdr, err
+	}
+
+	if rep.StatusCode/100 == 2 {
+		return readContentHeader(rep.Header)
+	} else if rep.StatusCode == 404 {
+		return hdr, ErrorNotFound
+	} else {
+		return hdr, readProxyError(rep.StatusCode, rep)
+	}
+}
+
+func (cli *containerClient) HasContent(n ObjectName) (bool, error) {
+	_, err := cli.StatContent(n)
+	if err == ErrorNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
 func (cli *containerClient) GenerateContent(n ObjectName, size uint64, auto bool) (Content, error) {
+	return cli.GenerateContentWithPolicy(n, size, "", auto)
+}
+
+func (cli *containerClient) GenerateContentWithPolicy(n ObjectName, size uint64, policy string, auto bool) (Content, error) {
 	var content Content
 
 	if n.NS() != cli.ns {
@@ -179,7 +250,7 @@ func (cli *containerClient) GenerateContent(n ObjectName, size uint64, auto bool
 	}
 
 	// Query the directory through the proxy
-	args := map[string]string{"policy": "", "size": strconv.FormatUint(size, 10)}
+	args := map[string]string{"policy": policy, "size": strconv.FormatUint(size, 10)}
 	encoded, _ := json.Marshal(args)
 	req, _ := http.NewRequest("POST", cli.getContentUrl(n, "prepare"),
 		bytes.NewBuffer(encoded))
@@ -209,10 +280,10 @@ func (cli *containerClient) GenerateContent(n ObjectName, size uint64, auto bool
 		return content, err
 	}
 
-	content.Header.Id = rep.Header.Get("X-oio-content-meta-id")
-	content.Header.Name = rep.Header.Get("X-oio-content-meta-name")
-	content.Header.Policy = rep.Header.Get("X-oio-content-meta-policy")
-	content.Header.Version, err = strconv.ParseUint(rep.Header.Get("X-oio-content-meta-version"), 10, 64)
+	content.Header, err = readContentHeader(rep.Header)
+	if err == nil && content.Header.Version == 0 {
+		err = errInvalidVersion
+	}
 	return content, err
 }
 
@@ -222,11 +293,29 @@ func (cli *containerClient) PutContent(container ContainerName, content Content,
 
 	fqc := fullyQualifiedContent{content: &content, container: container}
 
-	body, _ := json.Marshal(content.Chunks)
+	// The properties are sent with the chunks, so that the content never
+	// exists without them. The plain list of chunks is kept otherwise, for
+	// the proxies that only accept it.
+	var body []byte
+	if len(content.Properties) > 0 {
+		props := make(map[string]string)
+		for _, p := range content.Properties {
+			props[p.Key] = p.Value
+		}
+		body, _ = json.Marshal(map[string]interface{}{
+			"chunks":     content.Chunks,
+			"properties": props,
+		})
+	} else {
+		body, _ = json.Marshal(content.Chunks)
+	}
 	req, _ := http.NewRequest("POST", cli.getContentUrl(&fqc, "create"),
 		bytes.NewBuffer(body))
 	req.Header.Set("X-oio-action-mode", cli.autocreateFlag(auto))
 	req.Header.Set("X-oio-content-meta-length", strconv.FormatUint(content.Header.Size, 10))
+	if len(content.Header.Hash) > 0 {
+		req.Header.Set("X-oio-content-meta-hash", content.Header.Hash)
+	}
 
 	p := makeHttpClient(cli.ns, cli.config)
 	rep, err := p.Do(req)
@@ -269,3 +358,112 @@ func (cli *containerClient) DeleteContent(n ObjectName) (bool, error) {
 		return false, readProxyError(rep.StatusCode, rep)
 	}
 }
+
+// Reads the user properties in the reply of a get_properties action, that
+// also carries the system properties.
+func (cli *containerClient) getProperties(u string) (map[string]string, error) {
+	req, _ := http.NewRequest("POST", u, nil)
+	var body struct {
+		Properties map[string]string `json:"properties"`
+	}
+
+	p := makeHttpClient(cli.ns, cli.config)
+	rep, err := p.Do(req)
+	if rep != nil {
+		defer rep.Body.Close()
+	}
+	if err != nil {
+		return make(map[string]string), err
+	}
+
+	if rep.StatusCode/100 == 2 {
+		decoder := json.NewDecoder(rep.Body)
+		err = decoder.Decode(&body)
+		if body.Properties == nil {
+			body.Properties = make(map[string]string)
+		}
+		return body.Properties, err
+	} else if rep.StatusCode == 404 {
+		return make(map[string]string), ErrorNotFound
+	} else {
+		return make(map[string]string), readProxyError(rep.StatusCode, rep)
+	}
+}
+
+func (cli *containerClient) GetContainerProperties(n ContainerName) (map[string]string, error) {
+	if n.NS() != cli.ns {
+		return make(map[string]string), ErrorNsNotManaged
+	}
+	return cli.getProperties(cli.getRefUrl(n, "get_properties"))
+}
+
+func (cli *containerClient) SetContainerProperties(n ContainerName, props map[string]string) (bool, error) {
+	if n.NS() != cli.ns {
+		return false, ErrorNsNotManaged
+	}
+	body, _ := json.Marshal(map[string]interface{}{"properties": props})
+	req, _ := http.NewRequest("POST", cli.getRefUrl(n, "set_properties"),
+		bytes.NewBuffer(body))
+	return cli.simpleRequest(req)
+}
+
+func (cli *containerClient) DeleteContainerProperties(n ContainerName, keys []string) (bool, error) {
+	if n.NS() != cli.ns {
+		return false, ErrorNsNotManaged
+	}
+	body, _ := json.Marshal(keys)
+	req, _ := http.NewRequest("POST", cli.getRefUrl(n, "del_properties"),
+		bytes.NewBuffer(body))
+	return cli.simpleRequest(req)
+}
+
+func (cli *containerClient) GetContentProperties(n ObjectName) (map[string]string, error) {
+	if n.NS() != cli.ns {
+		return make(map[string]string), ErrorNsNotManaged
+	}
+	return cli.getProperties(cli.getContentUrl(n, "get_properties"))
+}
+
+func (cli *containerClient) SetContentProperties(n ObjectName, props map[string]string) (bool, error) {
+	if n.NS() != cli.ns {
+		return false, ErrorNsNotManaged
+	}
+	body, _ := json.Marshal(map[string]interface{}{"properties": props})
+	req, _ := http.NewRequest("POST", cli.getContentUrl(n, "set_properties"),
+		bytes.NewBuffer(body))
+	return cli.simpleRequest(req)
+}
+
+func (cli *containerClient) DeleteContentProperties(n ObjectName, keys []string) (bool, error) {
+	if n.NS() != cli.ns {
+		return false, ErrorNsNotManaged
+	}
+	body, _ := json.Marshal(keys)
+	req, _ := http.NewRequest("POST", cli.getContentUrl(n, "del_properties"),
+		bytes.NewBuffer(body))
+	return cli.simpleRequest(req)
+}
+
+// Extracts the description of a content from the headers of a reply of the
+// proxy. The numeric fields are left to zero when absent.
+func readContentHeader(h http.Header) (ContentHeader, error) {
+	var hdr ContentHeader
+	var err error
+	parseUint := func(k string, out *uint64) {
+		if v := h.Get(k); len(v) > 0 && err == nil {
+			*out, err = strconv.ParseUint(v, 10, 64)
+		}
+	}
+
+	hdr.Id = h.Get("X-oio-content-meta-id")
+	hdr.Name = h.Get("X-oio-content-meta-name")
+	hdr.Hash = h.Get("X-oio-content-meta-hash")
+	hdr.Policy = h.Get("X-oio-content-meta-policy")
+	hdr.ChunkMethod = h.Get("X-oio-content-meta-chunk-method")
+	hdr.MimeType = h.Get("X-oio-content-meta-mime-type")
+	hdr.Deleted, _ = strconv.ParseBool(h.Get("X-oio-content-meta-deleted"))
+	parseUint("X-oio-content-meta-version", &hdr.Version)
+	parseUint("X-oio-content-meta-length", &hdr.Size)
+	parseUint("X-oio-content-meta-ctime", &hdr.CTime)
+	return hdr, err
+}
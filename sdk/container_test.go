@@ -0,0 +1,83 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2015-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oio
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestContainer_ReadHeader(t *testing.T) {
+	h := make(http.Header)
+	h.Set("X-oio-content-meta-name", "plop")
+	h.Set("X-oio-content-meta-length", "4000")
+	h.Set("X-oio-content-meta-ctime", "1500000000")
+	h.Set("X-oio-content-meta-version", "1500000000123456")
+	h.Set("X-oio-content-meta-hash", "0123456789ABCDEF0123456789ABCDEF")
+	h.Set("X-oio-content-meta-policy", "THREECOPIES")
+	h.Set("X-oio-content-meta-mime-type", "text/plain")
+
+	hdr, err := readContentHeader(h)
+	if err != nil {
+		t.Fatal("Failed to read the header: ", err)
+	}
+	if hdr.Name != "plop" || hdr.Size != 4000 || hdr.CTime != 1500000000 ||
+		hdr.Version != 1500000000123456 || hdr.Policy != "THREECOPIES" ||
+		hdr.MimeType != "text/plain" || len(hdr.Hash) != 32 {
+		t.Fatal("Unexpected header: ", hdr)
+	}
+
+	h.Set("X-oio-content-meta-length", "-1")
+	if _, err = readContentHeader(h); err == nil {
+		t.Fatal("Invalid size accepted")
+	}
+}
+
+// The autocreation is only asked when both the caller and the configuration
+// allow it.
+func TestContainer_Autocreate(t *testing.T) {
+	var modes []string
+	srv := httptest.NewServer(http.HandlerFunc(func(rep http.ResponseWriter, req *http.Request) {
+		modes = append(modes, req.Header.Get("X-oio-action-mode"))
+		rep.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+	cfg := MakeStaticConfig()
+	cfg.Set("NS", KeyProxy, strings.TrimPrefix(srv.URL, "http://"))
+	cli, _ := MakeContainerClient("NS", cfg)
+	n := FlatName{N: "NS", A: "ACCT", U: "JFS"}
+	content := Content{Header: ContentHeader{Name: "obj"}}
+
+	call := func() {
+		modes = modes[:0]
+		cli.CreateContainer(&n, true)
+		cli.CreateContainer(&n, false)
+		cli.PutContent(&n, content, true)
+		cli.PutContent(&n, content, false)
+	}
+	call()
+	if strings.Join(modes, "|") != "|||" {
+		t.Fatal("Autocreation asked without the configuration: ", modes)
+	}
+	cfg.Set("NS", KeyAutocreate, "true")
+	call()
+	if strings.Join(modes, "|") != "autocreate||autocreate|" {
+		t.Fatal("Autocreation not driven by the caller: ", modes)
+	}
+}
@@ -17,7 +17,10 @@
 package oio
 
 import (
+	"errors"
 	"io"
+	"os"
+	"strings"
 )
 
 type chunksDownload struct {
@@ -27,10 +30,13 @@ type chunksDownload struct {
 	currentIn *metaChunkReader
 }
 
-func makeChunksDownload(chunks []Chunk) (*chunksDownload, error) {
+func makeChunksDownload(chunks []Chunk, cm ChunkMethod) (*chunksDownload, error) {
 	var err error
 	cd := new(chunksDownload)
 	cd.mc, err = organizeChunks(chunks)
+	if err == nil {
+		err = checkLayout(cd.mc, cm, false)
+	}
 	cd.closed = false
 	cd.nextIdx = 0
 	cd.currentIn = nil
@@ -87,3 +93,226 @@ func (dl *chunksDownload) Read(p []byte) (int, error) {
 
 	return 0, err
 }
+
+// ContentReader reads a content with ranged requests to the rawx services,
+// so that it can be seeked. The sequential reads share one request per
+// metachunk, opened again only after a Seek() or to fail over to another
+// chunk. Each range of a metachunk is read from the first of its chunks that
+// replies.
+type ContentReader struct {
+	rawx   *RawxClient
+	header ContentHeader
+	mc     []metaChunk
+	offset int64
+	closed bool
+
+	// The range being streamed: its metachunk, the chunk serving it and its
+	// position in the content.
+	in      io.ReadCloser
+	inMc    int
+	inChunk int
+	inPos   int64
+}
+
+// Locates the chunks of the content, for random reads. Only the replicated
+// contents can be read yet.
+func OpenContent(c Container, rawx *RawxClient, n ObjectName) (*ContentReader, error) {
+	content, err := c.GetContent(n)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only consider the HTTP locations, no other tier is managed yet
+	rawxChunks := make([]Chunk, 0, len(content.Chunks))
+	for _, chunk := range content.Chunks {
+		if strings.HasPrefix(chunk.Url, "http://") {
+			rawxChunks = append(rawxChunks, chunk)
+		}
+	}
+
+	cm, err := ParseChunkMethod(content.Header.ChunkMethod)
+	if err != nil {
+		return nil, err
+	}
+	if cm.IsEc() {
+		return nil, errECNotImplemented
+	} else if !cm.IsPlain() {
+		return nil, errorNotImplemented
+	}
+	mcSet, err := organizeChunks(rawxChunks)
+	if err == nil {
+		err = checkLayout(mcSet, cm, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The chunks report their nominal size, the last one is shorter
+	var offset uint64
+	for i := range mcSet {
+		mcSet[i].offset = offset
+		mcSet[i].meta_size = mcSet[i].data[0].Size
+		if offset+mcSet[i].meta_size > content.Header.Size {
+			mcSet[i].meta_size = content.Header.Size - offset
+		}
+		offset += mcSet[i].meta_size
+	}
+	return &ContentReader{rawx: rawx, header: content.Header, mc: mcSet}, nil
+}
+
+// Returns the header of the content read
+func (r *ContentReader) Header() ContentHeader { return r.header }
+
+func (r *ContentReader) Close() error {
+	if r.closed {
+		return os.ErrClosed
+	}
+	r.closed = true
+	r.closeStream()
+	return nil
+}
+
+func (r *ContentReader) closeStream() {
+	if r.in != nil {
+		r.in.Close()
+		r.in = nil
+	}
+}
+
+// Opens the range of the <i>th metachunk from the current position up to
+// its end, on the first of its chunks from <from> that replies. Returns
+// <err> if there is no chunk left to try.
+func (r *ContentReader) openStream(i, from int, err error) error {
+	r.closeStream()
+	mc := r.mc[i]
+	start := uint64(r.offset) - mc.offset
+	for k := from; k < len(mc.data); k++ {
+		var in io.ReadCloser
+		in, _, err = r.rawx.GetChunk(mc.data[k].Url, start, mc.meta_size-start)
+		if err == nil {
+			r.in, r.inMc, r.inChunk, r.inPos = in, i, k, r.offset
+			return nil
+		}
+	}
+	return err
+}
+
+func (r *ContentReader) Read(p []byte) (int, error) {
+	if r.closed {
+		return 0, os.ErrClosed
+	}
+	if r.offset >= int64(r.header.Size) {
+		r.closeStream()
+		return 0, io.EOF
+	}
+	if len(p) <= 0 {
+		return 0, nil
+	}
+
+	// Follow the stream, unless the position moved
+	if r.in == nil || r.inPos != r.offset {
+		i := 0
+		for i < len(r.mc)-1 && uint64(r.offset) >= r.mc[i].offset+r.mc[i].meta_size {
+			i++
+		}
+		if err := r.openStream(i, 0, io.ErrUnexpectedEOF); err != nil {
+			return 0, err
+		}
+	}
+
+	mc := r.mc[r.inMc]
+	end := int64(mc.offset + mc.meta_size)
+	if rest := end - r.offset; int64(len(p)) > rest {
+		p = p[:rest]
+	}
+	for {
+		n, err := r.in.Read(p)
+		r.offset += int64(n)
+		r.inPos = r.offset
+		if r.offset >= end {
+			// The next metachunk is streamed by the next Read()
+			r.closeStream()
+			return n, nil
+		}
+		if err == nil {
+			return n, nil
+		}
+		// The chunk failed or ended early, go on with the next one
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err = r.openStream(r.inMc, r.inChunk+1, err); err != nil || n > 0 {
+			return n, err
+		}
+	}
+}
+
+func (r *ContentReader) Seek(offset int64, whence int) (int64, error) {
+	if r.closed {
+		return 0, os.ErrClosed
+	}
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += int64(r.header.Size)
+	default:
+		return 0, errors.New("Invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("Negative position")
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+func (r *ContentReader) ReadAt(p []byte, off int64) (int, error) {
+	if r.closed {
+		return 0, os.ErrClosed
+	}
+	if off < 0 {
+		return 0, errors.New("Negative offset")
+	}
+	done := 0
+	for _, mc := range r.mc {
+		if done >= len(p) {
+			break
+		}
+		start := uint64(off) + uint64(done)
+		if mc.meta_size <= 0 || start >= mc.offset+mc.meta_size || start < mc.offset {
+			continue
+		}
+		want := mc.offset + mc.meta_size - start
+		if rest := uint64(len(p) - done); want > rest {
+			want = rest
+		}
+		n, err := r.readMetaChunk(mc, start-mc.offset, p[done:done+int(want)])
+		done += n
+		if err != nil {
+			return done, err
+		}
+	}
+	if done < len(p) {
+		return done, io.EOF
+	}
+	return done, nil
+}
+
+func (r *ContentReader) readMetaChunk(mc metaChunk, offset uint64, p []byte) (int, error) {
+	var err error
+	for _, chunk := range mc.data {
+		var in io.ReadCloser
+		in, _, err = r.rawx.GetChunk(chunk.Url, offset, uint64(len(p)))
+		if err != nil {
+			continue
+		}
+		var n int
+		n, err = io.ReadFull(in, p)
+		in.Close()
+		if err == nil {
+			return n, nil
+		}
+	}
+	return 0, err
+}
@@ -0,0 +1,254 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2017-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oio
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const fsListMax = 1000
+
+// ContainerFS exposes the contents of a container as a read-only fs.FS. The
+// '/' in the paths of the contents separate the directories, that exist as
+// long as a content is under them. The files are read with ranged requests
+// to the rawx services, so that they can be seeked.
+type ContainerFS struct {
+	container Container
+	rawx      *RawxClient
+	name      ContainerName
+}
+
+var (
+	_ fs.ReadDirFS = (*ContainerFS)(nil)
+	_ fs.StatFS    = (*ContainerFS)(nil)
+)
+
+// Builds a file system on the container <n>. The chunks are read with
+// <rawx>.
+func MakeContainerFS(c Container, rawx *RawxClient, n ContainerName) *ContainerFS {
+	return &ContainerFS{container: c, rawx: rawx, name: n}
+}
+
+func (cfs *ContainerFS) objectName(p string) *FlatName {
+	return &FlatName{
+		N: cfs.name.NS(),
+		A: cfs.name.Account(),
+		U: cfs.name.User(),
+		S: cfs.name.Type(),
+		P: p,
+	}
+}
+
+func fsError(op, name string, err error) error {
+	if err == ErrorNotFound {
+		err = fs.ErrNotExist
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// Returns the prefix of the contents under the directory
+func dirPrefix(name string) string {
+	if name == "." {
+		return ""
+	}
+	return name + "/"
+}
+
+type contentInfo struct {
+	name  string
+	size  int64
+	mtime time.Time
+	dir   bool
+}
+
+func (fi *contentInfo) Name() string       { return fi.name }
+func (fi *contentInfo) Size() int64        { return fi.size }
+func (fi *contentInfo) ModTime() time.Time { return fi.mtime }
+func (fi *contentInfo) IsDir() bool        { return fi.dir }
+func (fi *contentInfo) Sys() interface{}   { return nil }
+
+func (fi *contentInfo) Mode() fs.FileMode {
+	if fi.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+// Implements fs.DirEntry
+func (fi *contentInfo) Type() fs.FileMode          { return fi.Mode().Type() }
+func (fi *contentInfo) Info() (fs.FileInfo, error) { return fi, nil }
+
+func fileInfo(hdr ContentHeader) *contentInfo {
+	return &contentInfo{
+		name:  path.Base(hdr.Name),
+		size:  int64(hdr.Size),
+		mtime: time.Unix(int64(hdr.CTime), 0),
+	}
+}
+
+func dirInfo(name string) *contentInfo {
+	return &contentInfo{name: path.Base(name), dir: true}
+}
+
+// Tells if some content is under the directory
+func (cfs *ContainerFS) isDir(name string) (bool, error) {
+	if name == "." {
+		return true, nil
+	}
+	l, err := cfs.container.ListContentsWithParams(cfs.name,
+		ListParams{Prefix: name + "/", Delimiter: "/", Max: 1})
+	if err != nil {
+		return false, err
+	}
+	return len(l.Objects) > 0 || len(l.Prefixes) > 0, nil
+}
+
+// Returns the description of the content or the directory
+func (cfs *ContainerFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, fsError("stat", name, fs.ErrInvalid)
+	}
+	if name != "." {
+		hdr, err := cfs.container.StatContent(cfs.objectName(name))
+		if err == nil && !hdr.Deleted {
+			return fileInfo(hdr), nil
+		}
+		if err != nil && err != ErrorNotFound {
+			return nil, fsError("stat", name, err)
+		}
+	}
+	ok, err := cfs.isDir(name)
+	if err != nil {
+		return nil, fsError("stat", name, err)
+	}
+	if !ok {
+		return nil, fsError("stat", name, fs.ErrNotExist)
+	}
+	return dirInfo(name), nil
+}
+
+// Lists the directory, sorted by name. The contents whose path would not be
+// a valid name in the directory, e.g. with a "//", are skipped.
+func (cfs *ContainerFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, fsError("readdir", name, fs.ErrInvalid)
+	}
+	prefix := dirPrefix(name)
+	out := make([]fs.DirEntry, 0)
+	params := ListParams{Prefix: prefix, Delimiter: "/", Max: fsListMax}
+	for {
+		l, err := cfs.container.ListContentsWithParams(cfs.name, params)
+		if err != nil {
+			return nil, fsError("readdir", name, err)
+		}
+		for _, hdr := range l.Objects {
+			if !hdr.Deleted && fs.ValidPath(hdr.Name) && len(hdr.Name) > len(prefix) {
+				out = append(out, fileInfo(hdr))
+			}
+		}
+		for _, p := range l.Prefixes {
+			if p = strings.TrimSuffix(p, "/"); fs.ValidPath(p) && len(p) > len(prefix) {
+				out = append(out, dirInfo(p))
+			}
+		}
+		if !l.Truncated {
+			break
+		}
+		params.Marker = l.NextMarker
+	}
+	if len(out) <= 0 {
+		if ok, err := cfs.isDir(name); err != nil {
+			return nil, fsError("readdir", name, err)
+		} else if !ok {
+			return nil, fsError("readdir", name, fs.ErrNotExist)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name() < out[j].Name() })
+	return out, nil
+}
+
+// Opens the content or the directory. The content returned is a
+// ContentReader.
+func (cfs *ContainerFS) Open(name string) (fs.File, error) {
+	fi, err := cfs.Stat(name)
+	if err != nil {
+		err.(*fs.PathError).Op = "open"
+		return nil, err
+	}
+	if fi.IsDir() {
+		return &dirFile{cfs: cfs, name: name, info: fi}, nil
+	}
+
+	r, err := OpenContent(cfs.container, cfs.rawx, cfs.objectName(name))
+	if err != nil {
+		return nil, fsError("open", name, err)
+	}
+	return &contentFile{ContentReader: r, info: fileInfo(r.Header())}, nil
+}
+
+type contentFile struct {
+	*ContentReader
+	info *contentInfo
+}
+
+func (f *contentFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+type dirFile struct {
+	cfs     *ContainerFS
+	name    string
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	listed  bool
+}
+
+func (d *dirFile) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *dirFile) Close() error               { return nil }
+
+func (d *dirFile) Read(p []byte) (int, error) {
+	return 0, fsError("read", d.name, errors.New("is a directory"))
+}
+
+// Implements fs.ReadDirFile
+func (d *dirFile) ReadDir(count int) ([]fs.DirEntry, error) {
+	if !d.listed {
+		entries, err := d.cfs.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries, d.listed = entries, true
+	}
+	if count <= 0 {
+		out := d.entries
+		d.entries = nil
+		return out, nil
+	}
+	if len(d.entries) <= 0 {
+		return nil, io.EOF
+	}
+	if count > len(d.entries) {
+		count = len(d.entries)
+	}
+	out := d.entries[:count]
+	d.entries = d.entries[count:]
+	return out, nil
+}
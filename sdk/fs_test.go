@@ -0,0 +1,157 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2017-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oio_test
+
+import (
+	"bytes"
+	"errors"
+	"github.com/jfsmig/oio-go/oiotest"
+	oio "github.com/jfsmig/oio-go/sdk"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"testing/iotest"
+)
+
+func makeTestFS(t *testing.T) (*oiotest.Namespace, *oio.ContainerFS) {
+	ns := oiotest.StartNamespace("NS", 3)
+	ns.Container.SetChunkSize(4)
+	storage, _ := oio.MakeDefaultObjectStorageClient("NS", ns.Config)
+	files := map[string]string{
+		"index.html":     "<html>hello</html>",
+		"css/site.css":   "body {}",
+		"css/img/a.png":  "PNG",
+		"docs/empty.txt": "",
+	}
+	for p, data := range files {
+		n := oio.FlatName{N: "NS", A: "ACCT", U: "site", P: p}
+		err := storage.PutContentWithPolicy(&n, uint64(len(data)), "THREECOPIES", true, bytes.NewReader([]byte(data)))
+		if err != nil {
+			t.Fatal("PutContent failed: ", err)
+		}
+	}
+	c, _ := oio.MakeContainerClient("NS", ns.Config)
+	rawx, _ := oio.MakeRawxClient("NS", ns.Config)
+	return ns, oio.MakeContainerFS(c, rawx, &oio.FlatName{N: "NS", A: "ACCT", U: "site"})
+}
+
+func TestFS_Standard(t *testing.T) {
+	ns, cfs := makeTestFS(t)
+	defer ns.Close()
+	if err := fstest.TestFS(cfs, "index.html", "css/site.css", "css/img/a.png", "docs/empty.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cfs.Stat("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("Missing file found: ", err)
+	}
+}
+
+func TestFS_SeekAndFailover(t *testing.T) {
+	ns, cfs := makeTestFS(t)
+	defer ns.Close()
+
+	// The first replica of each metachunk is down
+	ns.Rawx[0].Faults.FailOn("GET", 0, nil)
+
+	f, err := cfs.Open("index.html")
+	if err != nil {
+		t.Fatal("Open failed: ", err)
+	}
+	defer f.Close()
+	rs := f.(io.ReadSeeker)
+	if _, err = rs.Seek(-7, io.SeekEnd); err != nil {
+		t.Fatal("Seek failed: ", err)
+	}
+	got, err := ioutil.ReadAll(rs)
+	if err != nil || string(got) != "</html>" {
+		t.Fatal("Bad tail: ", string(got), err)
+	}
+	buf := make([]byte, 5)
+	if n, err := f.(io.ReaderAt).ReadAt(buf, 6); n != 5 || err != nil || string(buf) != "hello" {
+		t.Fatal("Bad ReadAt: ", string(buf[:n]), err)
+	}
+}
+
+func TestFS_SequentialReads(t *testing.T) {
+	ns, cfs := makeTestFS(t)
+	defer ns.Close()
+	gets := func() int {
+		total := 0
+		for _, r := range ns.Rawx {
+			total += r.Faults.Calls("GET")
+			r.Faults.Reset()
+		}
+		return total
+	}
+	gets()
+
+	f, err := cfs.Open("index.html")
+	if err != nil {
+		t.Fatal("Open failed: ", err)
+	}
+	defer f.Close()
+
+	// Byte per byte, a single request per metachunk of 4 bytes
+	got, err := ioutil.ReadAll(iotest.OneByteReader(f))
+	if err != nil || string(got) != "<html>hello</html>" {
+		t.Fatal("Bad content: ", string(got), err)
+	}
+	if n := gets(); n != 5 {
+		t.Fatal("Unexpected GET requests: ", n)
+	}
+
+	// A Seek() opens a new range, the next metachunk another one
+	rs := f.(io.ReadSeeker)
+	rs.Seek(6, io.SeekStart)
+	buf := make([]byte, 1)
+	got = got[:0]
+	for i := 0; i < 5; i++ {
+		if _, err = rs.Read(buf); err != nil {
+			t.Fatal("Read failed: ", err)
+		}
+		got = append(got, buf[0])
+	}
+	if string(got) != "hello" {
+		t.Fatal("Bad content after Seek: ", string(got))
+	}
+	if n := gets(); n != 2 {
+		t.Fatal("Unexpected GET requests after Seek: ", n)
+	}
+}
+
+func TestFS_FileServer(t *testing.T) {
+	ns, cfs := makeTestFS(t)
+	defer ns.Close()
+	srv := httptest.NewServer(http.FileServer(http.FS(cfs)))
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/css/site.css", nil)
+	req.Header.Set("Range", "bytes=5-6")
+	rep, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := ioutil.ReadAll(rep.Body)
+	rep.Body.Close()
+	if rep.StatusCode != http.StatusPartialContent || string(got) != "{}" {
+		t.Fatal("Bad range: ", rep.StatusCode, string(got))
+	}
+}
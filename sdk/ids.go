@@ -0,0 +1,157 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2017-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oio
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+const (
+	// Length of the hexadecimal form of a container id
+	ContainerIdLength = 2 * sha256.Size
+
+	// Length of the hexadecimal form of a chunk id
+	ChunkIdLength = 64
+
+	// Length of the hexadecimal form of a content id
+	ContentIdLength = 32
+)
+
+var (
+	ErrorInvalidContainerId = errors.New("Invalid container id")
+	ErrorInvalidChunkId     = errors.New("Invalid chunk id")
+	ErrorInvalidChunkUrl    = errors.New("Invalid chunk URL")
+)
+
+var hexAccepted [32]byte
+
+func init() {
+	for _, c := range []byte("0123456789ABCDEFabcdef") {
+		hexAccepted[c/8] |= (1 << (c % 8))
+	}
+}
+
+// Tells if the string is made of hexadecimal characters only, in either case,
+// and has the expected length when <length> is positive.
+func IsHexString(s string, length int) bool {
+	if length > 0 && len(s) != length {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 0 == (hexAccepted[c/8] & (1 << (c % 8))) {
+			return false
+		}
+	}
+	return true
+}
+
+// The hexadecimal, upper-case, form of the unique id of a container
+type ContainerId string
+
+// Hashes the account, the user and, when set, the service subtype of the
+// container. Without subtype, the value matches ComputeUserId().
+func ComputeContainerId(n ContainerName) ContainerId {
+	h := sha256.New()
+	if acct := n.Account(); len(acct) > 0 {
+		h.Write([]byte(acct))
+		h.Write(zeroByte)
+	}
+	h.Write([]byte(n.User()))
+	if t := n.Type(); len(t) > 0 {
+		h.Write(zeroByte)
+		h.Write([]byte(t))
+	}
+	return ContainerId(strings.ToUpper(hex.EncodeToString(h.Sum(nil))))
+}
+
+// Checks the string is a container id, the case is ignored
+func ParseContainerId(s string) (ContainerId, error) {
+	s = strings.ToUpper(s)
+	if !IsHexString(s, ContainerIdLength) {
+		return "", ErrorInvalidContainerId
+	}
+	return ContainerId(s), nil
+}
+
+// The hexadecimal, upper-case, form of the unique id of a chunk
+type ChunkId string
+
+// Checks the string is a chunk id, the case is ignored
+func ParseChunkId(s string) (ChunkId, error) {
+	s = strings.ToUpper(s)
+	if !IsHexString(s, ChunkIdLength) {
+		return "", ErrorInvalidChunkId
+	}
+	return ChunkId(s), nil
+}
+
+// Returns a random chunk id
+func GenerateChunkId() ChunkId {
+	return ChunkId(randomHex(ChunkIdLength / 2))
+}
+
+// Returns a random content id, in the same form than the ids generated by
+// the proxy.
+func GenerateContentId() string {
+	return randomHex(ContentIdLength / 2)
+}
+
+func randomHex(size int) string {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		panic("No random source: " + err.Error())
+	}
+	return strings.ToUpper(hex.EncodeToString(b))
+}
+
+// The components of the URL of a chunk: http://HOST[/VOLUME]/CHUNKID
+type ChunkUrl struct {
+	Host   string
+	Volume string
+	Id     ChunkId
+}
+
+// Splits the URL of a chunk, as returned by the proxy
+func ParseChunkUrl(s string) (ChunkUrl, error) {
+	var out ChunkUrl
+	u, err := url.Parse(s)
+	if err != nil || len(u.Host) <= 0 {
+		return out, ErrorInvalidChunkUrl
+	}
+	p := strings.Trim(u.Path, "/")
+	if idx := strings.LastIndexByte(p, '/'); idx >= 0 {
+		out.Volume, p = p[:idx], p[idx+1:]
+	}
+	if out.Id, err = ParseChunkId(p); err != nil {
+		return out, err
+	}
+	out.Host = u.Host
+	return out, nil
+}
+
+func (cu ChunkUrl) String() string {
+	if len(cu.Volume) > 0 {
+		return "http://" + cu.Host + "/" + cu.Volume + "/" + string(cu.Id)
+	}
+	return "http://" + cu.Host + "/" + string(cu.Id)
+}
@@ -0,0 +1,90 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2017-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oio
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+const testChunkId = "0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF"
+
+func TestIds_Container(t *testing.T) {
+	n := FlatName{N: "NS", A: "ACCT", U: "JFS"}
+	cid := ComputeContainerId(&n)
+	if string(cid) != strings.ToUpper(hex.EncodeToString(ComputeUserId(&n))) {
+		t.Fatal("CID mismatch without type")
+	}
+	n.S = "0"
+	if ComputeContainerId(&n) == cid {
+		t.Fatal("The type is ignored")
+	}
+	if parsed, err := ParseContainerId(strings.ToLower(string(cid))); err != nil || parsed != cid {
+		t.Fatal("Parse error: ", err)
+	}
+	if _, err := ParseContainerId("XYZ"); err != ErrorInvalidContainerId {
+		t.Fatal("Unexpected success")
+	}
+}
+
+func TestIds_Generate(t *testing.T) {
+	id0, id1 := GenerateContentId(), GenerateContentId()
+	if !IsHexString(id0, ContentIdLength) || id0 == id1 {
+		t.Fatal("Bad content ids: ", id0, id1)
+	}
+	if _, err := ParseChunkId(string(GenerateChunkId())); err != nil {
+		t.Fatal("Bad chunk id: ", err)
+	}
+}
+
+func TestIds_HexCase(t *testing.T) {
+	for _, s := range []string{"0A1B", "0a1b", "0a1B"} {
+		if !IsHexString(s, 4) {
+			t.Fatal("Rejected ", s)
+		}
+	}
+	if IsHexString("0a1g", 0) || IsHexString("0a1b", 3) {
+		t.Fatal("Unexpected success")
+	}
+	n := FlatName{N: "NS", A: "ACCT", U: "JFS", P: "obj", I: strings.ToLower(GenerateContentId())}
+	if err := ValidateObjectName(&n); err != nil {
+		t.Fatal("Lower-case content id rejected: ", err)
+	}
+}
+
+func TestIds_ChunkUrl(t *testing.T) {
+	cu, err := ParseChunkUrl("http://127.0.0.1:6010/" + strings.ToLower(testChunkId))
+	if err != nil {
+		t.Fatal("Parse error: ", err)
+	}
+	if cu.Host != "127.0.0.1:6010" || cu.Volume != "" || cu.Id != testChunkId {
+		t.Fatal("Bad split: ", cu)
+	}
+	cu, err = ParseChunkUrl("http://127.0.0.1:6010/vol/0/" + testChunkId)
+	if err != nil {
+		t.Fatal("Parse error: ", err)
+	}
+	if cu.Volume != "vol/0" || cu.String() != "http://127.0.0.1:6010/vol/0/"+testChunkId {
+		t.Fatal("Bad volume: ", cu)
+	}
+	for _, u := range []string{"http:///" + testChunkId, "http://h:1/XYZ", "http://h:1/"} {
+		if _, err = ParseChunkUrl(u); err == nil {
+			t.Fatal("Unexpected success for ", u)
+		}
+	}
+}
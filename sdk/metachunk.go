@@ -18,6 +18,7 @@ package oio
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
@@ -40,15 +41,32 @@ type metaChunk struct {
 	meta_size uint64
 	data      []Chunk
 	parity    []Chunk
+
+	// Set when the positions of the chunks have a fragment index, i.e.
+	// the metachunk is erasure coded.
+	ec bool
 }
 
 type position struct {
 	idx    int
 	meta   int
 	intra  int
+	sub    bool
 	parity bool
 }
 
+// Returned when the set of chunks of a content cannot be rebuilt into a
+// contiguous sequence of metachunks.
+type LayoutError struct {
+	// The position of the faulty chunk or metachunk
+	Position string
+	Problem  string
+}
+
+func (e LayoutError) Error() string {
+	return fmt.Sprintf("Invalid chunk layout at position [%s]: %s", e.Position, e.Problem)
+}
+
 type positionSet struct {
 	tab []position
 }
@@ -74,13 +92,20 @@ func (s *positionSet) Swap(i, j int) {
 	s.tab[j] = tmp
 }
 
+// Groups the chunks by metachunk, sorted by position. The metachunks must be
+// contiguous from 0, all replicated with the same number of copies or all
+// erasure coded with the same number of fragments.
 func organizeChunks(chunks []Chunk) ([]metaChunk, error) {
 	pos := positionSet{tab: make([]position, len(chunks), len(chunks))}
 
 	// extract the position of all the chunks
 	for idx, chunk := range chunks {
-		pos.tab[idx] = (&chunk).getPositon()
-		pos.tab[idx].idx = idx
+		p, err := (&chunk).getPosition()
+		if err != nil {
+			return nil, err
+		}
+		p.idx = idx
+		pos.tab[idx] = p
 	}
 
 	// sort the chunks by position by ascending meta2, intra, then parity
@@ -88,41 +113,112 @@ func organizeChunks(chunks []Chunk) ([]metaChunk, error) {
 
 	// now organize the chunks into set serving the same meta chunk
 	out := make([]metaChunk, 0)
-	var current_meta metaChunk
-	var current_idx int = 0
-	append_and_renew := func() {
-		out = append(out, current_meta)
-		current_meta.data = make([]Chunk, 0)
-		current_meta.parity = make([]Chunk, 0)
-	}
-	for i := 0; i < len(pos.tab); i = i + 1 {
-		if pos.tab[i].meta != current_idx { // renew the metaChunk
-			append_and_renew()
-			current_idx = pos.tab[i].meta
+	for _, p := range pos.tab {
+		if p.meta > len(out) {
+			return nil, LayoutError{strconv.Itoa(len(out)), "missing metachunk"}
 		}
-		if pos.tab[i].parity {
-			current_meta.parity = append(current_meta.parity, chunks[pos.tab[i].idx])
+		if p.meta == len(out) {
+			out = append(out, metaChunk{
+				data:   make([]Chunk, 0),
+				parity: make([]Chunk, 0),
+				ec:     p.sub,
+			})
+		}
+		mc := &out[p.meta]
+		if mc.ec != p.sub {
+			return nil, LayoutError{chunks[p.idx].Position, "replicas mixed with fragments"}
+		}
+		if p.parity {
+			mc.parity = append(mc.parity, chunks[p.idx])
 		} else {
-			current_meta.data = append(current_meta.data, chunks[pos.tab[i].idx])
+			mc.data = append(mc.data, chunks[p.idx])
 		}
 	}
-	if len(current_meta.data) > 0 {
-		append_and_renew()
+
+	if err := checkMetaChunks(out); err != nil {
+		return nil, err
 	}
 	return out, nil
 }
 
-func (chunk *Chunk) getPositon() position {
+// Checks the fragments of each erasure coded metachunk are unique and
+// contiguous, and all the metachunks have the same width.
+func checkMetaChunks(mcSet []metaChunk) error {
+	width := -1
+	for i, mc := range mcSet {
+		if mc.ec {
+			for _, group := range [][]Chunk{mc.data, mc.parity} {
+				for expected, chunk := range group {
+					p, _ := chunk.getPosition()
+					if p.intra < expected {
+						return LayoutError{chunk.Position, "duplicate fragment"}
+					}
+					if p.intra > expected {
+						return LayoutError{chunk.Position, "missing fragment " + strconv.Itoa(expected)}
+					}
+				}
+			}
+		}
+		w := len(mc.data) + len(mc.parity)
+		if width < 0 {
+			width = w
+		} else if w != width {
+			return LayoutError{strconv.Itoa(i),
+				fmt.Sprintf("%d chunks instead of %d", w, width)}
+		}
+	}
+	return nil
+}
+
+// Checks the metachunks match the chunk method of the content: k+m
+// fragments for an EC method, replicas for a plain method. When <complete>
+// is set, the number of replicas must also match the number of copies.
+func checkLayout(mcSet []metaChunk, cm ChunkMethod, complete bool) error {
+	for i, mc := range mcSet {
+		w := len(mc.data) + len(mc.parity)
+		switch {
+		case cm.IsEc():
+			if !mc.ec {
+				return LayoutError{strconv.Itoa(i), "replicas instead of fragments"}
+			}
+			if w != cm.Width() {
+				return LayoutError{strconv.Itoa(i),
+					fmt.Sprintf("%d fragments instead of %d", w, cm.Width())}
+			}
+		case cm.IsPlain():
+			if mc.ec {
+				return LayoutError{strconv.Itoa(i), "fragments instead of replicas"}
+			}
+			if complete && w != cm.Width() {
+				return LayoutError{strconv.Itoa(i),
+					fmt.Sprintf("%d replicas instead of %d", w, cm.Width())}
+			}
+		}
+	}
+	return nil
+}
+
+// Parses positions such as "3" (a replica of the 4th metachunk), "3.1" (the
+// 2nd fragment of the 4th metachunk) or "3.1p" (the 2nd parity fragment).
+func (chunk *Chunk) getPosition() (position, error) {
 	var p position
+	var err error
 	tokens := strings.SplitN(chunk.Position, ".", 2)
-	p.meta, _ = strconv.Atoi(tokens[0])
+	if p.meta, err = strconv.Atoi(tokens[0]); err != nil || p.meta < 0 {
+		return p, LayoutError{chunk.Position, "invalid position"}
+	}
 	if len(tokens) > 1 {
-		p.intra, _ = strconv.Atoi(tokens[1])
-		if strings.HasSuffix(tokens[1], "p") {
+		p.sub = true
+		intra := tokens[1]
+		if strings.HasSuffix(intra, "p") {
 			p.parity = true
+			intra = intra[:len(intra)-1]
+		}
+		if p.intra, err = strconv.Atoi(intra); err != nil || p.intra < 0 {
+			return p, LayoutError{chunk.Position, "invalid position"}
 		}
 	}
-	return p
+	return p, nil
 }
 
 func maxSize(tab *[]Chunk) uint64 {
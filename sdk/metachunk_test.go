@@ -0,0 +1,189 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2017-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oio
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func makeChunks(positions ...string) []Chunk {
+	out := make([]Chunk, len(positions))
+	for i, p := range positions {
+		out[i] = Chunk{Url: "http://127.0.0.1:6010/" + testChunkId, Position: p}
+	}
+	return out
+}
+
+func TestMetaChunk_Replicated(t *testing.T) {
+	mcSet, err := organizeChunks(makeChunks("1", "0", "1", "0"))
+	if err != nil {
+		t.Fatal("Layout error: ", err)
+	}
+	if len(mcSet) != 2 || len(mcSet[0].data) != 2 || len(mcSet[1].data) != 2 {
+		t.Fatal("Bad layout: ", mcSet)
+	}
+	if mcSet[0].ec || mcSet[0].data[0].Position != "0" {
+		t.Fatal("Bad order: ", mcSet)
+	}
+	plain, _ := ParseChunkMethod("plain/nb_copy=2")
+	if err = checkLayout(mcSet, plain, true); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	plain, _ = ParseChunkMethod("plain/nb_copy=3")
+	if err = checkLayout(mcSet, plain, true); err == nil {
+		t.Fatal("Missing replica accepted")
+	}
+	if err = checkLayout(mcSet, plain, false); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	ec, _ := ParseChunkMethod("ec/k=1,m=1")
+	if err = checkLayout(mcSet, ec, false); err == nil {
+		t.Fatal("Replicas accepted as fragments")
+	}
+}
+
+func TestMetaChunk_Ec(t *testing.T) {
+	mcSet, err := organizeChunks(makeChunks("0.2", "0.0", "0.1", "1.1", "1.0", "1.2"))
+	if err != nil {
+		t.Fatal("Layout error: ", err)
+	}
+	if len(mcSet) != 2 || !mcSet[1].ec || mcSet[1].data[2].Position != "1.2" {
+		t.Fatal("Bad layout: ", mcSet)
+	}
+	ec, _ := ParseChunkMethod("ec/algo=liberasurecode_rs_vand,k=2,m=1")
+	if err = checkLayout(mcSet, ec, true); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	ec, _ = ParseChunkMethod("ec/algo=liberasurecode_rs_vand,k=6,m=3")
+	if err = checkLayout(mcSet, ec, false); err == nil {
+		t.Fatal("Unexpected success")
+	}
+}
+
+func TestMetaChunk_Invalid(t *testing.T) {
+	for _, positions := range [][]string{
+		{"x"},
+		{"0.x"},
+		{"-1"},
+		{"0", "2"},
+		{"1"},
+		{"0", "0", "1"},
+		{"0.0", "0.0", "0.1"},
+		{"0.0", "0.2"},
+		{"0", "0.1"},
+	} {
+		_, err := organizeChunks(makeChunks(positions...))
+		if err == nil {
+			t.Fatal("Unexpected success for ", positions)
+		}
+		if _, ok := err.(LayoutError); !ok {
+			t.Fatal("Unexpected error type: ", err)
+		}
+	}
+}
+
+// Proposes the chunks of a content without its chunk method, as the older
+// proxies do, and records the saved content.
+type layoutContainer struct {
+	Container
+	proposed Content
+	saved    []Content
+}
+
+func (c *layoutContainer) GenerateContentWithPolicy(n ObjectName, size uint64, policy string, auto bool) (Content, error) {
+	return c.proposed, nil
+}
+
+func (c *layoutContainer) PutContent(n ContainerName, content Content, auto bool) error {
+	c.saved = append(c.saved, content)
+	return nil
+}
+
+func TestMetaChunk_NoChunkMethod(t *testing.T) {
+	rawx := httptest.NewServer(http.HandlerFunc(func(rep http.ResponseWriter, req *http.Request) {
+		rep.WriteHeader(http.StatusCreated)
+	}))
+	defer rawx.Close()
+
+	c := &layoutContainer{}
+	c.proposed.Header = ContentHeader{Id: GenerateContentId(), Version: 1}
+	for _, p := range []string{"0", "0", "0", "1", "1", "1"} {
+		u := rawx.URL + "/" + string(GenerateChunkId())
+		c.proposed.Chunks = append(c.proposed.Chunks, Chunk{Url: u, Position: p, Size: 8})
+	}
+	cli := &objectStorageClient{container: c}
+	n := FlatName{N: "NS", A: "ACCT", U: "JFS", P: "x"}
+	data := []byte(strings.Repeat("x", 10))
+	if err := cli.PutContent(&n, uint64(len(data)), false, bytes.NewReader(data)); err != nil {
+		t.Fatal("Upload without chunk method failed: ", err)
+	}
+	if len(c.saved) != 1 {
+		t.Fatal("Content not saved")
+	}
+
+	// The replicas must still be the same for all the metachunks
+	c.proposed.Chunks = c.proposed.Chunks[:5]
+	if err := cli.PutContent(&n, uint64(len(data)), false, bytes.NewReader(data)); err == nil {
+		t.Fatal("Missing replica accepted")
+	}
+}
+
+func TestMetaChunk_UploadTargets(t *testing.T) {
+	var lock sync.Mutex
+	received := make(map[string][]string)
+	rawx := httptest.NewServer(http.HandlerFunc(func(rep http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		lock.Lock()
+		received[req.URL.Path] = append(received[req.URL.Path],
+			req.Header.Get(RAWX_HEADER_PREFIX+"chunk-pos")+" "+
+				req.Header.Get(RAWX_HEADER_PREFIX+"chunk-size")+" "+string(body))
+		lock.Unlock()
+		rep.WriteHeader(http.StatusCreated)
+	}))
+	defer rawx.Close()
+
+	c := &layoutContainer{}
+	c.proposed.Header = ContentHeader{Id: GenerateContentId(), Version: 1, ChunkMethod: "plain/nb_copy=2"}
+	for _, p := range []string{"0", "1", "0", "1"} {
+		u := rawx.URL + "/" + string(GenerateChunkId())
+		c.proposed.Chunks = append(c.proposed.Chunks, Chunk{Url: u, Position: p, Size: 8})
+	}
+	cli := &objectStorageClient{container: c}
+	n := FlatName{N: "NS", A: "ACCT", U: "JFS", P: "x"}
+	data := []byte("0123456789")
+	if err := cli.PutContent(&n, uint64(len(data)), false, bytes.NewReader(data)); err != nil {
+		t.Fatal("Upload failed: ", err)
+	}
+
+	// Each chunk only receives the data of its own metachunk
+	for _, chunk := range c.proposed.Chunks {
+		expected := "0 8 01234567"
+		if chunk.Position == "1" {
+			expected = "1 2 89"
+		}
+		got := received[chunk.Url[len(rawx.URL):]]
+		if len(got) != 1 || got[0] != expected {
+			t.Fatal("Unexpected upload of chunk ", chunk.Position, ": ", got)
+		}
+	}
+}
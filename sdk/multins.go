@@ -0,0 +1,442 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2015-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oio
+
+import (
+	"sync"
+	"time"
+)
+
+// The single-namespace clients serving one namespace
+type nsClients struct {
+	directory  Directory
+	container  Container
+	account    Account
+	conscience Conscience
+}
+
+// Lazily builds the clients of each namespace, the first time the namespace
+// is called. Only the namespaces with a proxy configured are accepted.
+type clientRegistry struct {
+	config  Config
+	lock    sync.Mutex
+	clients map[string]*nsClients
+}
+
+type multiDirectory struct{ reg *clientRegistry }
+
+type multiContainer struct{ reg *clientRegistry }
+
+type multiAccount struct{ reg *clientRegistry }
+
+type multiConscience struct{ reg *clientRegistry }
+
+func makeClientRegistry(cfg Config) *clientRegistry {
+	return &clientRegistry{config: cfg, clients: make(map[string]*nsClients)}
+}
+
+func (r *clientRegistry) isManaged(ns string) bool {
+	if len(ns) <= 0 {
+		return false
+	}
+	for _, k := range []string{KeyProxy, KeyProxyDirectory, KeyProxyContainer, KeyProxyAccount, KeyProxyConscience} {
+		if _, err := r.config.GetString(ns, k); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *clientRegistry) get(ns string) (*nsClients, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if c, ok := r.clients[ns]; ok {
+		return c, nil
+	}
+	if !r.isManaged(ns) {
+		return nil, ErrorNsNotManaged
+	}
+
+	c := new(nsClients)
+	c.directory, _ = MakeDirectoryClient(ns, r.config)
+	c.container, _ = MakeContainerClient(ns, r.config)
+	c.account, _ = MakeAccountClient(ns, r.config)
+	c.conscience, _ = MakeConscienceClient(ns, r.config)
+	r.clients[ns] = c
+	return c, nil
+}
+
+// Creates a Directory client serving all the namespaces configured in <cfg>.
+// Each call is routed to the namespace of the name given.
+func MakeMultiDirectoryClient(cfg Config) (Directory, error) {
+	return &multiDirectory{reg: makeClientRegistry(cfg)}, nil
+}
+
+// Creates a Container client serving all the namespaces configured in <cfg>.
+func MakeMultiContainerClient(cfg Config) (Container, error) {
+	return &multiContainer{reg: makeClientRegistry(cfg)}, nil
+}
+
+// Creates an Account client serving all the namespaces configured in <cfg>.
+func MakeMultiAccountClient(cfg Config) (Account, error) {
+	return &multiAccount{reg: makeClientRegistry(cfg)}, nil
+}
+
+// Creates a Conscience client serving all the namespaces configured in <cfg>.
+func MakeMultiConscienceClient(cfg Config) (Conscience, error) {
+	return &multiConscience{reg: makeClientRegistry(cfg)}, nil
+}
+
+// Creates an ObjectStorage client serving all the namespaces configured in
+// <cfg>. The underlying clients are built the first time a namespace is
+// called, and then shared by all the subsequent calls.
+func MakeMultiObjectStorageClient(cfg Config) (ObjectStorage, error) {
+	reg := makeClientRegistry(cfg)
+	cache := MakeNamespaceInfoCache(&multiConscience{reg: reg}, defaultNsInfoTTL)
+	cache.ttlOf = func(ns string) time.Duration { return getNsInfoTTL(ns, cfg) }
+	return MakeObjectStorageClientWithCache(
+		&multiDirectory{reg: reg}, &multiContainer{reg: reg}, cache)
+}
+
+func (m *multiDirectory) HasUser(n UserName) (bool, error) {
+	c, err := m.reg.get(n.NS())
+	if err != nil {
+		return false, err
+	}
+	return c.directory.HasUser(n)
+}
+
+func (m *multiDirectory) CreateUser(n UserName) (bool, error) {
+	c, err := m.reg.get(n.NS())
+	if err != nil {
+		return false, err
+	}
+	return c.directory.CreateUser(n)
+}
+
+func (m *multiDirectory) DeleteUser(n UserName) (bool, error) {
+	c, err := m.reg.get(n.NS())
+	if err != nil {
+		return false, err
+	}
+	return c.directory.DeleteUser(n)
+}
+
+func (m *multiDirectory) DumpUser(n UserName) (RefDump, error) {
+	c, err := m.reg.get(n.NS())
+	if err != nil {
+		return RefDump{make([]Service, 0), make([]Service, 0), make([]Property, 0)}, err
+	}
+	return c.directory.DumpUser(n)
+}
+
+func (m *multiDirectory) LinkServices(n UserName, srvtype string) ([]Service, error) {
+	c, err := m.reg.get(n.NS())
+	if err != nil {
+		return make([]Service, 0), err
+	}
+	return c.directory.LinkServices(n, srvtype)
+}
+
+func (m *multiDirectory) RenewServices(n UserName, srvtype string) ([]Service, error) {
+	c, err := m.reg.get(n.NS())
+	if err != nil {
+		return make([]Service, 0), err
+	}
+	return c.directory.RenewServices(n, srvtype)
+}
+
+func (m *multiDirectory) ForceServices(n UserName, srv []Service) ([]Service, error) {
+	c, err := m.reg.get(n.NS())
+	if err != nil {
+		return make([]Service, 0), err
+	}
+	return c.directory.ForceServices(n, srv)
+}
+
+func (m *multiDirectory) ListServices(n UserName, srvtype string) ([]Service, error) {
+	c, err := m.reg.get(n.NS())
+	if err != nil {
+		return make([]Service, 0), err
+	}
+	return c.directory.ListServices(n, srvtype)
+}
+
+func (m *multiDirectory) UnlinkServices(n UserName, srvtype string) (bool, error) {
+	c, err := m.reg.get(n.NS())
+	if err != nil {
+		return false, err
+	}
+	return c.directory.UnlinkServices(n, srvtype)
+}
+
+func (m *multiDirectory) GetAllProperties(n UserName) (map[string]string, error) {
+	c, err := m.reg.get(n.NS())
+	if err != nil {
+		return make(map[string]string), err
+	}
+	return c.directory.GetAllProperties(n)
+}
+
+func (m *multiDirectory) SetProperties(n UserName, props map[string]string) (bool, error) {
+	c, err := m.reg.get(n.NS())
+	if err != nil {
+		return false, err
+	}
+	return c.directory.SetProperties(n, props)
+}
+
+func (m *multiDirectory) DeleteProperties(n UserName, keys []string) (bool, error) {
+	c, err := m.reg.get(n.NS())
+	if err != nil {
+		return false, err
+	}
+	return c.directory.DeleteProperties(n, keys)
+}
+
+func (m *multiContainer) CreateContainer(n ContainerName, auto bool) (bool, error) {
+	c, err := m.reg.get(n.NS())
+	if err != nil {
+		return false, err
+	}
+	return c.container.CreateContainer(n, auto)
+}
+
+func (m *multiContainer) DeleteContainer(n ContainerName) (bool, error) {
+	c, err := m.reg.get(n.NS())
+	if err != nil {
+		return false, err
+	}
+	return c.container.DeleteContainer(n)
+}
+
+func (m *multiContainer) HasContainer(n ContainerName) (bool, error) {
+	c, err := m.reg.get(n.NS())
+	if err != nil {
+		return false, err
+	}
+	return c.container.HasContainer(n)
+}
+
+func (m *multiContainer) GetContainerProperties(n ContainerName) (map[string]string, error) {
+	c, err := m.reg.get(n.NS())
+	if err != nil {
+		return make(map[string]string), err
+	}
+	return c.container.GetContainerProperties(n)
+}
+
+func (m *multiContainer) SetContainerProperties(n ContainerName, props map[string]string) (bool, error) {
+	c, err := m.reg.get(n.NS())
+	if err != nil {
+		return false, err
+	}
+	return c.container.SetContainerProperties(n, props)
+}
+
+func (m *multiContainer) DeleteContainerProperties(n ContainerName, keys []string) (bool, error) {
+	c, err := m.reg.get(n.NS())
+	if err != nil {
+		return false, err
+	}
+	return c.container.DeleteContainerProperties(n, keys)
+}
+
+func (m *multiContainer) ListContents(n ContainerName) (ContainerListing, error) {
+	return m.ListContentsWithParams(n, ListParams{})
+}
+
+func (m *multiContainer) ListContentsWithParams(n ContainerName, p ListParams) (ContainerListing, error) {
+	c, err := m.reg.get(n.NS())
+	if err != nil {
+		return ContainerListing{}, err
+	}
+	return c.container.ListContentsWithParams(n, p)
+}
+
+func (m *multiContainer) GetContent(n ObjectName) (Content, error) {
+	c, err := m.reg.get(n.NS())
+	if err != nil {
+		return Content{}, err
+	}
+	return c.container.GetContent(n)
+}
+
+func (m *multiContainer) StatContent(n ObjectName) (ContentHeader, error) {
+	c, err := m.reg.get(n.NS())
+	if err != nil {
+		return ContentHeader{}, err
+	}
+	return c.container.StatContent(n)
+}
+
+func (m *multiContainer) HasContent(n ObjectName) (bool, error) {
+	c, err := m.reg.get(n.NS())
+	if err != nil {
+		return false, err
+	}
+	return c.container.HasContent(n)
+}
+
+func (m *multiContainer) GenerateContent(n ObjectName, size uint64, auto bool) (Content, error) {
+	return m.GenerateContentWithPolicy(n, size, "", auto)
+}
+
+func (m *multiContainer) GenerateContentWithPolicy(n ObjectName, size uint64, policy string, auto bool) (Content, error) {
+	c, err := m.reg.get(n.NS())
+	if err != nil {
+		return Content{}, err
+	}
+	return c.container.GenerateContentWithPolicy(n, size, policy, auto)
+}
+
+func (m *multiContainer) PutContent(container ContainerName, content Content, auto bool) error {
+	c, err := m.reg.get(container.NS())
+	if err != nil {
+		return err
+	}
+	return c.container.PutContent(container, content, auto)
+}
+
+func (m *multiContainer) DeleteContent(n ObjectName) (bool, error) {
+	c, err := m.reg.get(n.NS())
+	if err != nil {
+		return false, err
+	}
+	return c.container.DeleteContent(n)
+}
+
+func (m *multiContainer) GetContentProperties(n ObjectName) (map[string]string, error) {
+	c, err := m.reg.get(n.NS())
+	if err != nil {
+		return make(map[string]string), err
+	}
+	return c.container.GetContentProperties(n)
+}
+
+func (m *multiContainer) SetContentProperties(n ObjectName, props map[string]string) (bool, error) {
+	c, err := m.reg.get(n.NS())
+	if err != nil {
+		return false, err
+	}
+	return c.container.SetContentProperties(n, props)
+}
+
+func (m *multiContainer) DeleteContentProperties(n ObjectName, keys []string) (bool, error) {
+	c, err := m.reg.get(n.NS())
+	if err != nil {
+		return false, err
+	}
+	return c.container.DeleteContentProperties(n, keys)
+}
+
+func (m *multiAccount) CreateAccount(n AccountName) (bool, error) {
+	c, err := m.reg.get(n.NS())
+	if err != nil {
+		return false, err
+	}
+	return c.account.CreateAccount(n)
+}
+
+func (m *multiAccount) DeleteAccount(n AccountName) (bool, error) {
+	c, err := m.reg.get(n.NS())
+	if err != nil {
+		return false, err
+	}
+	return c.account.DeleteAccount(n)
+}
+
+func (m *multiAccount) ShowAccount(n AccountName) (AccountInfo, error) {
+	c, err := m.reg.get(n.NS())
+	if err != nil {
+		return AccountInfo{Metadata: make(map[string]string)}, err
+	}
+	return c.account.ShowAccount(n)
+}
+
+func (m *multiAccount) ListContainers(n AccountName, p ListParams) (AccountListing, error) {
+	c, err := m.reg.get(n.NS())
+	if err != nil {
+		return AccountListing{Containers: make([]AccountContainer, 0)}, err
+	}
+	return c.account.ListContainers(n, p)
+}
+
+func (m *multiAccount) UpdateAccount(n AccountName, set map[string]string, del []string) (bool, error) {
+	c, err := m.reg.get(n.NS())
+	if err != nil {
+		return false, err
+	}
+	return c.account.UpdateAccount(n, set, del)
+}
+
+func (m *multiConscience) ListServices(ns, srvtype string) ([]ServiceInfo, error) {
+	c, err := m.reg.get(ns)
+	if err != nil {
+		return make([]ServiceInfo, 0), err
+	}
+	return c.conscience.ListServices(ns, srvtype)
+}
+
+func (m *multiConscience) ListTypes(ns string) ([]string, error) {
+	c, err := m.reg.get(ns)
+	if err != nil {
+		return make([]string, 0), err
+	}
+	return c.conscience.ListTypes(ns)
+}
+
+func (m *multiConscience) RegisterService(ns string, srv ServiceInfo) error {
+	c, err := m.reg.get(ns)
+	if err != nil {
+		return err
+	}
+	return c.conscience.RegisterService(ns, srv)
+}
+
+func (m *multiConscience) DeregisterService(ns string, srv ServiceInfo) error {
+	c, err := m.reg.get(ns)
+	if err != nil {
+		return err
+	}
+	return c.conscience.DeregisterService(ns, srv)
+}
+
+func (m *multiConscience) LockScore(ns string, srv ServiceInfo) error {
+	c, err := m.reg.get(ns)
+	if err != nil {
+		return err
+	}
+	return c.conscience.LockScore(ns, srv)
+}
+
+func (m *multiConscience) UnlockScore(ns string, srv ServiceInfo) error {
+	c, err := m.reg.get(ns)
+	if err != nil {
+		return err
+	}
+	return c.conscience.UnlockScore(ns, srv)
+}
+
+func (m *multiConscience) GetNamespaceInfo(ns string) (NamespaceInfo, error) {
+	c, err := m.reg.get(ns)
+	if err != nil {
+		return NamespaceInfo{}, err
+	}
+	return c.conscience.GetNamespaceInfo(ns)
+}
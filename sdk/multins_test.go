@@ -0,0 +1,64 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2015-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMultiNs_Registry(t *testing.T) {
+	cfg := MakeStaticConfig()
+	cfg.Set("NS0", KeyProxy, "127.0.0.1:6000")
+	cfg.Set("NS1", KeyProxyContainer, "127.0.0.1:6001")
+	reg := makeClientRegistry(cfg)
+
+	c0, err := reg.get("NS0")
+	if err != nil {
+		t.Fatal("NS0 not managed: ", err)
+	}
+	if c, _ := reg.get("NS0"); c != c0 {
+		t.Fatal("Clients not shared")
+	}
+	if _, err = reg.get("NS1"); err != nil {
+		t.Fatal("NS1 not managed: ", err)
+	}
+	if _, err = reg.get("NS2"); err != ErrorNsNotManaged {
+		t.Fatal("NS2 managed")
+	}
+
+	dir, _ := MakeMultiDirectoryClient(cfg)
+	n := FlatName{N: "NS2", A: "ACCT", U: "JFS"}
+	if _, err = dir.HasUser(&n); err != ErrorNsNotManaged {
+		t.Fatal("NS2 managed")
+	}
+}
+
+func TestMultiNs_NsInfoTTL(t *testing.T) {
+	cfg := MakeStaticConfig()
+	cfg.Set("NS0", KeyProxy, "127.0.0.1:6000")
+	cfg.Set("NS0", KeyNsInfoTTL, "5s")
+	cfg.Set("NS1", KeyProxy, "127.0.0.1:6001")
+	s, _ := MakeMultiObjectStorageClient(cfg)
+	cache := s.(*objectStorageClient).nsinfo
+	if ttl := cache.ttlOf("NS0"); ttl != 5*time.Second {
+		t.Fatal("Unexpected TTL of NS0: ", ttl)
+	}
+	if ttl := cache.ttlOf("NS1"); ttl != defaultNsInfoTTL {
+		t.Fatal("Unexpected TTL of NS1: ", ttl)
+	}
+}
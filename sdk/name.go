@@ -74,3 +74,24 @@ func (self *fullyQualifiedContent) Path() string { return self.content.Header.Na
 func (self *fullyQualifiedContent) Id() string { return self.content.Header.Id }
 
 func (self *fullyQualifiedContent) Version() uint64 { return self.content.Header.Version }
+
+// Returns the URL of the object, see FormatName()
+func (n *FlatName) String() string { return FormatName(n) }
+
+// Encodes the name as its URL, so that it also appears as a string in JSON
+func (n FlatName) MarshalText() ([]byte, error) {
+	if err := ValidateContainerName(&n); err != nil {
+		return nil, err
+	}
+	return []byte(FormatName(&n)), nil
+}
+
+// Decodes a URL produced by MarshalText()
+func (n *FlatName) UnmarshalText(text []byte) error {
+	parsed, err := ParseName(string(text))
+	if err != nil {
+		return err
+	}
+	*n = *parsed
+	return nil
+}
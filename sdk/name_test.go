@@ -0,0 +1,91 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2017-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oio
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestName_Format(t *testing.T) {
+	cases := []struct {
+		name FlatName
+		url  string
+	}{
+		{FlatName{N: "NS", A: "ACCT", U: "JFS"}, "oio://NS/ACCT/JFS"},
+		{FlatName{N: "NS", A: "ACCT", U: "JFS", S: "0"}, "oio://NS/ACCT/JFS/0/"},
+		{FlatName{N: "NS", A: "ACCT", U: "JFS", P: "a/b c"}, "oio://NS/ACCT/JFS//a%2Fb%20c"},
+		{FlatName{N: "NS.1", A: "A?", U: "U", S: "m2", P: "p", V: 3, I: "0A"},
+			"oio://NS.1/A%3F/U/m2/p?id=0A&version=3"},
+	}
+	for _, c := range cases {
+		if u := FormatName(&c.name); u != c.url {
+			t.Fatalf("Bad URL: %s instead of %s", u, c.url)
+		}
+		n, err := ParseName(c.url)
+		if err != nil {
+			t.Fatal("Parse error: ", err)
+		}
+		if *n != c.name {
+			t.Fatalf("Bad name: %v instead of %v", *n, c.name)
+		}
+	}
+}
+
+func TestName_Invalid(t *testing.T) {
+	for _, u := range []string{
+		"http://NS/ACCT/JFS",
+		"oio://NS/ACCT",
+		"oio://NS/ACCT/JFS/T/P/X",
+		"oio://NS./ACCT/JFS",
+		"oio://N-S/ACCT/JFS",
+		"oio://NS//JFS",
+		"oio://NS/ACCT/",
+		"oio://NS/ACCT/J%0AFS",
+		"oio://NS/ACCT/JFS//P?version=x",
+		"oio://NS/ACCT/JFS//P?id=XYZ",
+		"oio://NS/ACCT/JFS//P?foo=bar",
+		"oio://NS/ACCT/JFS//P%ZZ",
+		"oio://NS/ACCT/JFS/P",
+		"oio://NS/ACCT/JFS//dir/file",
+	} {
+		if _, err := ParseName(u); err == nil {
+			t.Fatal("Unexpected success for ", u)
+		}
+	}
+}
+
+func TestName_Json(t *testing.T) {
+	in := FlatName{N: "NS", A: "ACCT", U: "JFS", P: "obj", V: 1}
+	encoded, err := json.Marshal(map[string]FlatName{"name": in})
+	if err != nil {
+		t.Fatal("Marshal error: ", err)
+	}
+	if string(encoded) != `{"name":"oio://NS/ACCT/JFS//obj?version=1"}` {
+		t.Fatal("Bad JSON: ", string(encoded))
+	}
+	out := make(map[string]FlatName)
+	if err = json.Unmarshal(encoded, &out); err != nil {
+		t.Fatal("Unmarshal error: ", err)
+	}
+	if out["name"] != in {
+		t.Fatal("Name mismatch: ", out["name"])
+	}
+	if err = json.Unmarshal([]byte(`{"name":"oio://NS"}`), &out); err == nil {
+		t.Fatal("Unexpected success")
+	}
+}
@@ -0,0 +1,225 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2015-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oio
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How long a namespace info is kept when the configuration doesn't tell
+const defaultNsInfoTTL = 60 * time.Second
+
+var errInvalidServicePool = errors.New("Invalid service pool")
+
+// A storage policy, as declared in the namespace: the pool the services are
+// polled from, and the data security applied to the contents.
+type StoragePolicy struct {
+	Name string
+
+	// Name of the service pool, empty for the default pool of rawx
+	Pool string
+
+	// Name of the data security, empty when the data is not protected
+	DataSecurity string
+
+	// The raw description of the data security, e.g. "plain/nb_copy=3"
+	Description string
+
+	// The parsed description, a single plain copy when no data security is
+	// set. The type is left unknown when the description is invalid.
+	Method ChunkMethod
+}
+
+// One set of services to be polled in a service pool: <Count> services of
+// any of the <Slots>.
+type ServicePoolTarget struct {
+	Count int
+	Slots []string
+}
+
+// A service pool tells which services are polled together to host the chunks
+// of a metachunk.
+type ServicePool struct {
+	Name    string
+	Targets []ServicePoolTarget
+	Options map[string]string
+}
+
+// Returns the parsed storage policy with the given name. ErrorNotFound is
+// returned if the policy is not declared in the namespace.
+func (ni *NamespaceInfo) GetStoragePolicy(name string) (StoragePolicy, error) {
+	raw, ok := ni.StoragePolicies[name]
+	if !ok {
+		return StoragePolicy{Name: name}, ErrorNotFound
+	}
+	return ni.parseStoragePolicy(name, raw), nil
+}
+
+// Returns all the storage policies of the namespace, parsed.
+func (ni *NamespaceInfo) GetStoragePolicies() map[string]StoragePolicy {
+	out := make(map[string]StoragePolicy)
+	for name, raw := range ni.StoragePolicies {
+		out[name] = ni.parseStoragePolicy(name, raw)
+	}
+	return out
+}
+
+// Returns the parsed service pool with the given name.
+func (ni *NamespaceInfo) GetServicePool(name string) (ServicePool, error) {
+	raw, ok := ni.ServicePools[name]
+	if !ok {
+		return ServicePool{Name: name}, ErrorNotFound
+	}
+	return parseServicePool(name, raw)
+}
+
+// A storage policy is encoded as "POOL:DATASECURITY", with "NONE" for the
+// unset fields.
+func (ni *NamespaceInfo) parseStoragePolicy(name, raw string) StoragePolicy {
+	sp := StoragePolicy{Name: name}
+	tokens := strings.Split(raw, ":")
+	if len(tokens) > 0 && tokens[0] != "NONE" {
+		sp.Pool = tokens[0]
+	}
+	if len(tokens) > 1 && tokens[1] != "NONE" {
+		sp.DataSecurity = tokens[1]
+		sp.Description = ni.DataSecurities[sp.DataSecurity]
+	}
+	if cm, err := ParseChunkMethod(sp.Description); err == nil {
+		sp.Method = cm
+	}
+	return sp
+}
+
+// A service pool is encoded as a list of targets separated by ';', where
+// each target is "COUNT,SLOT[,SLOT...]". The "KEY=VALUE" tokens are options.
+func parseServicePool(name, raw string) (ServicePool, error) {
+	sp := ServicePool{
+		Name:    name,
+		Targets: make([]ServicePoolTarget, 0),
+		Options: make(map[string]string),
+	}
+	for _, token := range strings.Split(raw, ";") {
+		if len(token) <= 0 {
+			continue
+		}
+		if idx := strings.Index(token, "="); idx > 0 {
+			sp.Options[token[:idx]] = token[idx+1:]
+			continue
+		}
+		fields := strings.Split(token, ",")
+		if len(fields) < 2 {
+			return sp, errInvalidServicePool
+		}
+		count, err := strconv.Atoi(fields[0])
+		if err != nil || count <= 0 {
+			return sp, errInvalidServicePool
+		}
+		sp.Targets = append(sp.Targets, ServicePoolTarget{Count: count, Slots: fields[1:]})
+	}
+	return sp, nil
+}
+
+type nsInfoEntry struct {
+	info     NamespaceInfo
+	deadline time.Time
+}
+
+// A fetch in progress, shared by the callers missing the same namespace
+type nsInfoCall struct {
+	done chan struct{}
+	info NamespaceInfo
+	err  error
+}
+
+// NamespaceInfoCache keeps the namespace info fetched from the conscience for
+// a while, so that they are not fetched at each upload.
+type NamespaceInfoCache struct {
+	conscience Conscience
+	ttl        time.Duration
+	// When set, tells the TTL of each namespace instead of <ttl>
+	ttlOf   func(ns string) time.Duration
+	lock    sync.Mutex
+	entries map[string]nsInfoEntry
+	calls   map[string]*nsInfoCall
+}
+
+// Builds a cache that fetches the namespace info with the given Conscience
+// client, and keeps them at most <ttl>.
+func MakeNamespaceInfoCache(cs Conscience, ttl time.Duration) *NamespaceInfoCache {
+	if ttl <= 0 {
+		ttl = defaultNsInfoTTL
+	}
+	return &NamespaceInfoCache{
+		conscience: cs,
+		ttl:        ttl,
+		entries:    make(map[string]nsInfoEntry),
+		calls:      make(map[string]*nsInfoCall),
+	}
+}
+
+// Returns the namespace info, from the cache if still valid. Otherwise the
+// conscience is asked, once for all the concurrent callers.
+func (c *NamespaceInfoCache) Get(ns string) (NamespaceInfo, error) {
+	c.lock.Lock()
+	if entry, ok := c.entries[ns]; ok && time.Now().Before(entry.deadline) {
+		c.lock.Unlock()
+		return entry.info, nil
+	}
+	if call, ok := c.calls[ns]; ok {
+		c.lock.Unlock()
+		<-call.done
+		return call.info, call.err
+	}
+	call := &nsInfoCall{done: make(chan struct{})}
+	c.calls[ns] = call
+	c.lock.Unlock()
+
+	call.info, call.err = c.conscience.GetNamespaceInfo(ns)
+
+	c.lock.Lock()
+	delete(c.calls, ns)
+	if call.err == nil {
+		ttl := c.ttl
+		if c.ttlOf != nil {
+			ttl = c.ttlOf(ns)
+		}
+		c.entries[ns] = nsInfoEntry{info: call.info, deadline: time.Now().Add(ttl)}
+	}
+	c.lock.Unlock()
+	close(call.done)
+	return call.info, call.err
+}
+
+// Forget the namespace info, the next Get() will ask the conscience.
+func (c *NamespaceInfoCache) Invalidate(ns string) {
+	c.lock.Lock()
+	delete(c.entries, ns)
+	c.lock.Unlock()
+}
+
+func getNsInfoTTL(ns string, cfg Config) time.Duration {
+	v, err := GetDuration(cfg, ns, KeyNsInfoTTL)
+	if err != nil || v <= 0 {
+		return defaultNsInfoTTL
+	}
+	return v
+}
@@ -0,0 +1,206 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2015-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oio
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Only implements the namespace info retrieval, and counts the calls
+type countingConscience struct {
+	Conscience
+	calls int
+}
+
+func (cs *countingConscience) GetNamespaceInfo(ns string) (NamespaceInfo, error) {
+	cs.calls++
+	return NamespaceInfo{Name: ns, ChunkSize: 1048576}, nil
+}
+
+func TestNsInfo_Policies(t *testing.T) {
+	ni := NamespaceInfo{
+		StoragePolicies: map[string]string{
+			"SINGLE":      "NONE:NONE",
+			"THREECOPIES": "rawx3:DUPONETHREE",
+		},
+		DataSecurities: map[string]string{
+			"DUPONETHREE": "plain/distance=1,nb_copy=3",
+		},
+		ServicePools: map[string]string{
+			"rawx3": "3,rawx-europe,rawx;min_dist=1",
+		},
+	}
+
+	sp, err := ni.GetStoragePolicy("THREECOPIES")
+	if err != nil {
+		t.Fatal("Policy not found: ", err)
+	}
+	if sp.Pool != "rawx3" || sp.DataSecurity != "DUPONETHREE" ||
+		sp.Description != "plain/distance=1,nb_copy=3" {
+		t.Fatal("Unexpected policy: ", sp)
+	}
+	if !sp.Method.IsPlain() || sp.Method.Copies() != 3 {
+		t.Fatal("Unexpected chunk method: ", sp.Method)
+	}
+	if sp, _ = ni.GetStoragePolicy("SINGLE"); sp.Pool != "" || sp.DataSecurity != "" ||
+		sp.Method.Copies() != 1 {
+		t.Fatal("Unexpected policy: ", sp)
+	}
+	if _, err = ni.GetStoragePolicy("PLOP"); err != ErrorNotFound {
+		t.Fatal("Unknown policy found")
+	}
+
+	pool, err := ni.GetServicePool("rawx3")
+	if err != nil {
+		t.Fatal("Pool not parsed: ", err)
+	}
+	if len(pool.Targets) != 1 || pool.Targets[0].Count != 3 ||
+		len(pool.Targets[0].Slots) != 2 || pool.Options["min_dist"] != "1" {
+		t.Fatal("Unexpected pool: ", pool)
+	}
+	if _, err = parseServicePool("x", "rawx"); err == nil {
+		t.Fatal("Invalid pool accepted")
+	}
+}
+
+func TestNsInfo_Cache(t *testing.T) {
+	cs := &countingConscience{}
+	cache := MakeNamespaceInfoCache(cs, time.Minute)
+	for i := 0; i < 3; i++ {
+		if info, err := cache.Get("NS"); err != nil || info.ChunkSize != 1048576 {
+			t.Fatal("Unexpected namespace info: ", info, err)
+		}
+	}
+	if cs.calls != 1 {
+		t.Fatal("Cache not used: ", cs.calls)
+	}
+	cache.Invalidate("NS")
+	cache.Get("NS")
+	if cs.calls != 2 {
+		t.Fatal("Cache not invalidated: ", cs.calls)
+	}
+}
+
+// Blocks the fetches until released, and counts them
+type slowConscience struct {
+	Conscience
+	lock    sync.Mutex
+	calls   int
+	release chan struct{}
+}
+
+func (cs *slowConscience) GetNamespaceInfo(ns string) (NamespaceInfo, error) {
+	cs.lock.Lock()
+	cs.calls++
+	cs.lock.Unlock()
+	<-cs.release
+	return NamespaceInfo{Name: ns, ChunkSize: 1048576}, nil
+}
+
+func TestNsInfo_CacheSingleFlight(t *testing.T) {
+	cs := &slowConscience{release: make(chan struct{})}
+	cache := MakeNamespaceInfoCache(cs, time.Minute)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if info, err := cache.Get("NS"); err != nil || info.ChunkSize != 1048576 {
+				t.Error("Unexpected namespace info: ", info, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(cs.release)
+	wg.Wait()
+	if cs.calls != 1 {
+		t.Fatal("Concurrent misses not merged: ", cs.calls)
+	}
+}
+
+var errTestGenerate = errors.New("generate")
+
+type failingConscience struct {
+	Conscience
+}
+
+func (cs *failingConscience) GetNamespaceInfo(ns string) (NamespaceInfo, error) {
+	return NamespaceInfo{}, errors.New("conscience down")
+}
+
+// Only tells the upload went past the namespace info
+type generatingContainer struct {
+	Container
+}
+
+func (c *generatingContainer) GenerateContentWithPolicy(n ObjectName, size uint64, policy string, auto bool) (Content, error) {
+	return Content{}, errTestGenerate
+}
+
+func TestNsInfo_UploadWithoutConscience(t *testing.T) {
+	n := FlatName{N: "NS", A: "ACCT", U: "JFS", P: "x"}
+	src := bytes.NewReader([]byte("x"))
+
+	cli := &objectStorageClient{container: &generatingContainer{},
+		nsinfo: MakeNamespaceInfoCache(&failingConscience{}, time.Minute)}
+	if err := cli.PutContent(&n, 1, false, src); err != errTestGenerate {
+		t.Fatal("Upload stopped by the conscience: ", err)
+	}
+	if err := cli.PutContentWithPolicy(&n, 1, "PLOP", false, src); err != errTestGenerate {
+		t.Fatal("Policy checked without the namespace info: ", err)
+	}
+
+	cli.nsinfo = MakeNamespaceInfoCache(&countingConscience{}, time.Minute)
+	cli.nsinfo.entries["NS"] = nsInfoEntry{
+		info:     NamespaceInfo{StoragePolicies: map[string]string{"SINGLE": "NONE:NONE"}},
+		deadline: time.Now().Add(time.Minute),
+	}
+	if err := cli.PutContentWithPolicy(&n, 1, "PLOP", false, src); err != ErrorInvalidPolicy {
+		t.Fatal("Unknown policy accepted: ", err)
+	}
+}
+
+// Prepares a single metachunk, whatever the size
+type preparingContainer struct {
+	Container
+}
+
+func (c *preparingContainer) GenerateContentWithPolicy(n ObjectName, size uint64, policy string, auto bool) (Content, error) {
+	content := Content{Chunks: []Chunk{{Url: "http://127.0.0.1:1/0", Position: "0", Size: 8}}}
+	content.Header = ContentHeader{Id: "0123", Version: 1, Size: size, ChunkMethod: "plain/nb_copy=1"}
+	return content, nil
+}
+
+func TestNsInfo_UploadShortLayout(t *testing.T) {
+	n := FlatName{N: "NS", A: "ACCT", U: "JFS", P: "x"}
+	src := bytes.NewReader(make([]byte, 8))
+
+	cli := &objectStorageClient{container: &preparingContainer{},
+		nsinfo: MakeNamespaceInfoCache(&countingConscience{}, time.Minute)}
+	cli.nsinfo.entries["NS"] = nsInfoEntry{
+		info:     NamespaceInfo{ChunkSize: 4},
+		deadline: time.Now().Add(time.Minute),
+	}
+	err := cli.PutContent(&n, 8, false, src)
+	if _, ok := err.(LayoutError); !ok {
+		t.Fatal("Tail of the data not uploaded: ", err)
+	}
+}
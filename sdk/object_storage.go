@@ -17,8 +17,10 @@
 package oio
 
 import (
+	"crypto/md5"
 	"encoding/hex"
 	"errors"
+	"hash"
 	"io"
 	"strconv"
 	"strings"
@@ -33,6 +35,22 @@ var (
 type objectStorageClient struct {
 	directory Directory
 	container Container
+
+	// Optional, when set the uploads are sized with the chunk size of the
+	// namespace.
+	nsinfo *NamespaceInfoCache
+}
+
+// Computes the MD5 of the content while it is read, once and in order
+type hashingReader struct {
+	io.ReadSeeker
+	h hash.Hash
+}
+
+func (r *hashingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadSeeker.Read(p)
+	r.h.Write(p[:n])
+	return n, err
 }
 
 func (cli *objectStorageClient) DeleteContent(n ObjectName) error {
@@ -40,6 +58,14 @@ func (cli *objectStorageClient) DeleteContent(n ObjectName) error {
 	return err
 }
 
+func (cli *objectStorageClient) StatContent(n ObjectName) (ContentHeader, error) {
+	return cli.container.StatContent(n)
+}
+
+func (cli *objectStorageClient) HasContent(n ObjectName) (bool, error) {
+	return cli.container.HasContent(n)
+}
+
 func (cli *objectStorageClient) GetContent(n ObjectName) (io.ReadCloser, error) {
 	content, err := cli.container.GetContent(n)
 	if err != nil {
@@ -55,17 +81,54 @@ func (cli *objectStorageClient) GetContent(n ObjectName) (io.ReadCloser, error)
 	}
 	content.Chunks = rawx_chunks
 
-	return makeChunksDownload(content.Chunks)
+	// Only the replicated contents can be read yet
+	cm, err := ParseChunkMethod(content.Header.ChunkMethod)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case cm.IsEc():
+		return nil, errECNotImplemented
+	case !cm.IsPlain():
+		return nil, errorNotImplemented
+	}
+
+	return makeChunksDownload(content.Chunks, cm)
 }
 
 func (cli *objectStorageClient) PutContent(n ObjectName, size uint64, auto bool, src io.ReadSeeker) error {
+	return cli.PutContentWithPolicy(n, size, "", auto, src)
+}
+
+func (cli *objectStorageClient) PutContentWithPolicy(n ObjectName, size uint64, policy string, auto bool, src io.ReadSeeker) error {
+	return cli.PutContentWithProperties(n, size, policy, nil, auto, src)
+}
+
+func (cli *objectStorageClient) PutContentWithProperties(n ObjectName, size uint64, policy string, props map[string]string, auto bool, src io.ReadSeeker) error {
 	if src == nil {
 		panic("Invalid input")
 	}
 
 	var err error
+	var chunk_size uint64 = 0
+
+	// The namespace info is optional: when the conscience cannot tell, the
+	// chunk size is guessed from the chunks proposed by the proxy, and the
+	// proxy checks the policy.
+	if cli.nsinfo != nil {
+		if info, err := cli.nsinfo.Get(n.NS()); err == nil {
+			if len(policy) > 0 && len(info.StoragePolicies) > 0 {
+				if _, ok := info.StoragePolicies[policy]; !ok {
+					return ErrorInvalidPolicy
+				}
+			}
+			if info.ChunkSize > 0 {
+				chunk_size = uint64(info.ChunkSize)
+			}
+		}
+	}
 
-	content, err := cli.container.GenerateContent(n, size, auto)
+	content, err := cli.container.GenerateContentWithPolicy(n, size, policy, auto)
 	if err != nil {
 		return err
 	}
@@ -73,6 +136,18 @@ func (cli *objectStorageClient) PutContent(n ObjectName, size uint64, auto bool,
 	if err != nil {
 		return err
 	}
+	cm, err := ParseChunkMethod(content.Header.ChunkMethod)
+	if err != nil {
+		return err
+	}
+	// The older proxies do not tell the chunk method, the count of replicas
+	// is then taken from the chunks of the first metachunk.
+	if len(content.Header.ChunkMethod) <= 0 && len(mcSet) > 0 && !mcSet[0].ec {
+		cm.Params["nb_copy"] = strconv.Itoa(len(mcSet[0].data))
+	}
+	if err = checkLayout(mcSet, cm, true); err != nil {
+		return err
+	}
 
 	// If an explicit Id has been provided, it must supersede the ID
 	// generated by the proxy
@@ -94,35 +169,44 @@ func (cli *objectStorageClient) PutContent(n ObjectName, size uint64, auto bool,
 		}
 	}
 
-	// Alert if RAIN is used (NYI)
-	for _, mc := range mcSet {
-		if len(mc.parity) > 0 {
-			return errECNotImplemented
-		}
+	// Only the replicated contents can be written yet
+	switch {
+	case cm.IsEc():
+		return errECNotImplemented
+	case !cm.IsPlain():
+		return errorNotImplemented
 	}
 
-	// Patch the chunks'es size
-	// TODO get the chunk_size from somewhere reliable (i.e. the config)
+	// Patch the chunks'es size, with the chunk size of the namespace if
+	// known, or guessed from the places proposed by the proxy.
 	var offset uint64 = 0
-	chunk_size := maxSize(&content.Chunks)
+	if chunk_size == 0 {
+		chunk_size = maxSize(&content.Chunks)
+	}
 	remaining := size
-	for i, _ := range mcSet {
+	for i := range mcSet {
 		mc := &(mcSet[i])
 		mc.meta_size = decRet(&remaining, chunk_size)
 		mc.offset = offset
 		offset = offset + mc.meta_size
-		for ii, _ := range (*mc).data {
+		for ii := range (*mc).data {
 			(*mc).data[ii].Size = mc.meta_size
 		}
 	}
+	// Too few metachunks prepared for the chunk size, the tail of the data
+	// would not be uploaded
+	if remaining != 0 {
+		return LayoutError{strconv.Itoa(len(mcSet)), "missing metachunk"}
+	}
 
-	cid := strings.ToUpper(hex.EncodeToString(ComputeUserId(n)))
+	cid := string(ComputeContainerId(n))
+	hashed := &hashingReader{ReadSeeker: src, h: md5.New()}
 
 	// upload each meta-chunk
-	for i, _ := range mcSet {
+	for i := range mcSet {
 		mc := &(mcSet[i])
 		pp := makePolyPut()
-		for _, chunk := range content.Chunks {
+		for _, chunk := range mc.data {
 			pp.addTarget(chunk.Url)
 		}
 		pp.addHeader("X-oio-req-id", "0")
@@ -136,15 +220,21 @@ func (cli *objectStorageClient) PutContent(n ObjectName, size uint64, auto bool,
 		pp.addHeader(RAWX_HEADER_PREFIX+"content-chunk-method", content.Header.ChunkMethod)
 		pp.addHeader(RAWX_HEADER_PREFIX+"content-mime-type", content.Header.MimeType)
 		pp.addHeader(RAWX_HEADER_PREFIX+"chunk-pos", strconv.Itoa(i))
+		pp.addHeader(RAWX_HEADER_PREFIX+"chunk-size", strconv.FormatUint(mc.meta_size, 10))
 		// the chunk-id is set by the "polyput" itself, because it varies
 		// for each chunk
-		r := makeSliceReader(src, mc.meta_size)
+		r := makeSliceReader(hashed, mc.meta_size)
 		err = pp.do(&r)
 		if err != nil {
 			return err
 		}
 	}
 
+	content.Header.Hash = strings.ToUpper(hex.EncodeToString(hashed.h.Sum(nil)))
+	content.Properties = make([]Property, 0, len(props))
+	for k, v := range props {
+		content.Properties = append(content.Properties, Property{Key: k, Value: v})
+	}
 	err = cli.container.PutContent(n, content, auto)
 	if err != nil {
 		return err
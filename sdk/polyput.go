@@ -21,7 +21,6 @@ import (
 	"io"
 	"net"
 	"net/http"
-	"path/filepath"
 	"sync"
 	"time"
 )
@@ -78,6 +77,10 @@ func (pp *polyPut) do(src polyPutSource) error {
 
 	// create sub requests
 	for _, url := range pp.urls {
+		chunk, err := ParseChunkUrl(url)
+		if err != nil {
+			return err
+		}
 		sub := &subReq{
 			req:   nil,
 			err:   nil,
@@ -95,7 +98,7 @@ func (pp *polyPut) do(src polyPutSource) error {
 			sub.req.Header.Set(kv.key, kv.value)
 		}
 
-		sub.req.Header.Set(RAWX_HEADER_PREFIX+"chunk-id", filepath.Base(url))
+		sub.req.Header.Set(RAWX_HEADER_PREFIX+"chunk-id", string(chunk.Id))
 		subs = append(subs, sub)
 	}
 
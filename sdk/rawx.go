@@ -0,0 +1,311 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2017-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oio
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// The headers of the attributes of a chunk, as named by the rawx
+const (
+	RawxHeaderAlias       = "X-Oio-Alias"
+	RawxHeaderPolicy      = "X-Oio-Chunk-Meta-Content-Storage-Policy"
+	RawxHeaderMimeType    = "X-Oio-Chunk-Meta-Content-Mime-Type"
+	RawxHeaderChunkMethod = "X-Oio-Chunk-Meta-Content-Chunk-Method"
+	RawxHeaderChunkId     = "X-Oio-Chunk-Meta-Chunk-Id"
+	RawxHeaderChunkSize   = "X-Oio-Chunk-Meta-Chunk-Size"
+	RawxHeaderChunkPos    = "X-Oio-Chunk-Meta-Chunk-Pos"
+	RawxHeaderChunkHash   = "X-Oio-Chunk-Meta-Chunk-Hash"
+	rawxHeaderTruncated   = "X-Oio-List-Truncated"
+)
+
+// RawxError reports a failed request to a rawx, with the reason the rawx
+// gave, if any.
+type RawxError struct {
+	Status  int
+	Message string
+}
+
+func (e RawxError) Error() string {
+	return fmt.Sprintf("Rawx error: (%d) %s", e.Status, e.Message)
+}
+
+// ChunkMeta gathers the attributes saved with a chunk
+type ChunkMeta struct {
+	Id          ChunkId
+	Position    string
+	Size        uint64
+	Hash        string
+	Alias       string
+	Policy      string
+	MimeType    string
+	ChunkMethod string
+}
+
+// ChunkListing is a page of the chunks held by a rawx. When Truncated is
+// set, the next page starts after the last id.
+type ChunkListing struct {
+	Ids       []ChunkId
+	Truncated bool
+}
+
+// RawxClient talks directly to the rawx services, chunk by chunk. The
+// chunks are designated by their URL, and the services by their address.
+type RawxClient struct {
+	ns     string
+	config Config
+}
+
+// Builds a client to the rawx services of the namespace. The configuration
+// provides the timeouts.
+func MakeRawxClient(ns string, cfg Config) (*RawxClient, error) {
+	return &RawxClient{ns: ns, config: cfg}, nil
+}
+
+func (cli *RawxClient) do(req *http.Request) (*http.Response, error) {
+	rep, err := makeHttpClient(cli.ns, cli.config).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if rep.StatusCode/100 == 2 {
+		return rep, nil
+	}
+	defer rep.Body.Close()
+	io.Copy(ioutil.Discard, rep.Body)
+	if rep.StatusCode == http.StatusNotFound {
+		return nil, ErrorNotFound
+	}
+	return nil, RawxError{Status: rep.StatusCode, Message: rep.Header.Get("X-Error")}
+}
+
+func chunkRequest(method, chunkUrl string, body io.Reader) (*http.Request, error) {
+	if _, err := ParseChunkUrl(chunkUrl); err != nil {
+		return nil, err
+	}
+	return http.NewRequest(method, chunkUrl, body)
+}
+
+func readChunkMeta(h http.Header) (ChunkMeta, error) {
+	var meta ChunkMeta
+	var err error
+	if v := h.Get(RawxHeaderChunkId); len(v) > 0 {
+		if meta.Id, err = ParseChunkId(v); err != nil {
+			return meta, err
+		}
+	}
+	if v := h.Get(RawxHeaderChunkSize); len(v) > 0 {
+		if meta.Size, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return meta, fmt.Errorf("Invalid chunk size [%s]", v)
+		}
+	}
+	meta.Position = h.Get(RawxHeaderChunkPos)
+	meta.Hash = h.Get(RawxHeaderChunkHash)
+	meta.Alias = h.Get(RawxHeaderAlias)
+	meta.Policy = h.Get(RawxHeaderPolicy)
+	meta.MimeType = h.Get(RawxHeaderMimeType)
+	meta.ChunkMethod = h.Get(RawxHeaderChunkMethod)
+	return meta, nil
+}
+
+func writeChunkMeta(h http.Header, meta ChunkMeta) {
+	set := func(k, v string) {
+		if len(v) > 0 {
+			h.Set(k, v)
+		}
+	}
+	set(RawxHeaderChunkId, string(meta.Id))
+	set(RawxHeaderChunkSize, strconv.FormatUint(meta.Size, 10))
+	set(RawxHeaderChunkPos, meta.Position)
+	set(RawxHeaderChunkHash, meta.Hash)
+	set(RawxHeaderAlias, meta.Alias)
+	set(RawxHeaderPolicy, meta.Policy)
+	set(RawxHeaderMimeType, meta.MimeType)
+	set(RawxHeaderChunkMethod, meta.ChunkMethod)
+}
+
+// Uploads the <meta.Size> bytes of <in> as the chunk at the given URL, with
+// the attributes in <meta>. The id is deduced from the URL when absent. The
+// rawx requires the size, the position, the policy, the chunk method and the
+// mime type. Returns the MD5 of the data, computed by the rawx.
+func (cli *RawxClient) PutChunk(chunkUrl string, meta ChunkMeta, in io.Reader) (string, error) {
+	cu, err := ParseChunkUrl(chunkUrl)
+	if err != nil {
+		return "", err
+	}
+	if len(meta.Id) <= 0 {
+		meta.Id = cu.Id
+	} else if meta.Id != cu.Id {
+		return "", ErrorInvalidChunkId
+	}
+	req, err := http.NewRequest("PUT", chunkUrl, in)
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = int64(meta.Size)
+	req.Header.Set("Content-Type", "octet/stream")
+	writeChunkMeta(req.Header, meta)
+	rep, err := cli.do(req)
+	if err != nil {
+		return "", err
+	}
+	rep.Body.Close()
+	return rep.Header.Get("chunkhash"), nil
+}
+
+// Downloads the chunk at the given URL, from <offset> and at most <size>
+// bytes, or up to the end when <size> is zero.
+func (cli *RawxClient) GetChunk(chunkUrl string, offset, size uint64) (io.ReadCloser, ChunkMeta, error) {
+	req, err := chunkRequest("GET", chunkUrl, nil)
+	if err != nil {
+		return nil, ChunkMeta{}, err
+	}
+	if size > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+size-1))
+	} else if offset > 0 {
+		// The rawx needs the last byte of the range
+		meta, err := cli.HeadChunk(chunkUrl)
+		if err != nil {
+			return nil, meta, err
+		}
+		if offset >= meta.Size {
+			return ioutil.NopCloser(strings.NewReader("")), meta, nil
+		}
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, meta.Size-1))
+	}
+	rep, err := cli.do(req)
+	if err != nil {
+		return nil, ChunkMeta{}, err
+	}
+	meta, err := readChunkMeta(rep.Header)
+	if err != nil {
+		rep.Body.Close()
+		return nil, meta, err
+	}
+	return rep.Body, meta, nil
+}
+
+// Returns the attributes of the chunk at the given URL. When the attributes
+// miss the size, the size of the data is reported.
+func (cli *RawxClient) HeadChunk(chunkUrl string) (ChunkMeta, error) {
+	req, err := chunkRequest("HEAD", chunkUrl, nil)
+	if err != nil {
+		return ChunkMeta{}, err
+	}
+	rep, err := cli.do(req)
+	if err != nil {
+		return ChunkMeta{}, err
+	}
+	rep.Body.Close()
+	meta, err := readChunkMeta(rep.Header)
+	if err == nil && len(rep.Header.Get(RawxHeaderChunkSize)) <= 0 && rep.ContentLength > 0 {
+		meta.Size = uint64(rep.ContentLength)
+	}
+	return meta, err
+}
+
+// Removes the chunk at the given URL
+func (cli *RawxClient) DeleteChunk(chunkUrl string) error {
+	req, err := chunkRequest("DELETE", chunkUrl, nil)
+	if err != nil {
+		return err
+	}
+	rep, err := cli.do(req)
+	if err == nil {
+		rep.Body.Close()
+	}
+	return err
+}
+
+// Lists at most <max> chunks of the rawx at <addr>, whose id is greater than
+// <marker> and starts with <prefix>. The rawx applies its default maximum
+// when <max> is zero.
+func (cli *RawxClient) ListChunks(addr, marker, prefix string, max int) (ChunkListing, error) {
+	out := ChunkListing{Ids: make([]ChunkId, 0)}
+	q := url.Values{}
+	if len(marker) > 0 {
+		q.Set("marker", marker)
+	}
+	if len(prefix) > 0 {
+		q.Set("prefix", prefix)
+	}
+	if max > 0 {
+		q.Set("max", strconv.Itoa(max))
+	}
+	req, err := http.NewRequest("GET", "http://"+addr+"/list?"+q.Encode(), nil)
+	if err != nil {
+		return out, err
+	}
+	rep, err := cli.do(req)
+	if err != nil {
+		return out, err
+	}
+	defer rep.Body.Close()
+
+	scanner := bufio.NewScanner(rep.Body)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); len(line) > 0 {
+			id, err := ParseChunkId(line)
+			if err != nil {
+				return out, err
+			}
+			out.Ids = append(out.Ids, id)
+		}
+	}
+	out.Truncated, _ = strconv.ParseBool(rep.Header.Get(rawxHeaderTruncated))
+	return out, scanner.Err()
+}
+
+// Returns the counters and timers of the rawx at <addr>, by name, e.g.
+// "counter.rep.hits".
+func (cli *RawxClient) Stat(addr string) (map[string]uint64, error) {
+	req, err := http.NewRequest("GET", "http://"+addr+"/stat", nil)
+	if err != nil {
+		return nil, err
+	}
+	rep, err := cli.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer rep.Body.Close()
+	return ParseRawxStats(rep.Body)
+}
+
+// Parses the "timer.NAME VALUE" and "counter.NAME VALUE" lines of the /stat
+// of a rawx.
+func ParseRawxStats(in io.Reader) (map[string]uint64, error) {
+	out := make(map[string]uint64)
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		tokens := strings.Fields(scanner.Text())
+		if len(tokens) != 2 {
+			continue
+		}
+		v, err := strconv.ParseUint(tokens[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid stat [%s]", tokens[0])
+		}
+		out[tokens[0]] = v
+	}
+	return out, scanner.Err()
+}
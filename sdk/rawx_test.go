@@ -0,0 +1,83 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2017-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oio
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRawx_ChunkMeta(t *testing.T) {
+	meta := ChunkMeta{Id: testChunkId, Position: "0", Size: 12, Policy: "SINGLE",
+		MimeType: "octet/stream", ChunkMethod: "plain/nb_copy=1"}
+	h := http.Header{}
+	writeChunkMeta(h, meta)
+	if h.Get("X-oio-chunk-meta-chunk-pos") != "0" || len(h.Get(RawxHeaderAlias)) > 0 {
+		t.Fatal("Bad headers: ", h)
+	}
+	got, err := readChunkMeta(h)
+	if err != nil || got != meta {
+		t.Fatal("Bad meta: ", got, err)
+	}
+	h.Set(RawxHeaderChunkSize, "x")
+	if _, err = readChunkMeta(h); err == nil {
+		t.Fatal("Invalid size accepted")
+	}
+}
+
+func TestRawx_ListAndStat(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rep http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/list":
+			if req.URL.Query().Get("max") != "1" {
+				rep.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			rep.Header().Set("X-Oio-List-Truncated", "true")
+			rep.Write([]byte(string(testChunkId) + "\n"))
+		case "/stat":
+			rep.Write([]byte("timer.rep.hits 0\ncounter.rep.hits 12\n"))
+		default:
+			rep.Header().Set("X-Error", "Chunk already exists")
+			rep.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer srv.Close()
+	addr := strings.TrimPrefix(srv.URL, "http://")
+	cli, _ := MakeRawxClient("NS", MakeStaticConfig())
+
+	l, err := cli.ListChunks(addr, "", "", 1)
+	if err != nil || !l.Truncated || len(l.Ids) != 1 || l.Ids[0] != testChunkId {
+		t.Fatal("Bad listing: ", l, err)
+	}
+	stats, err := cli.Stat(addr)
+	if err != nil || stats["counter.rep.hits"] != 12 || len(stats) != 2 {
+		t.Fatal("Bad stats: ", stats, err)
+	}
+	err = cli.DeleteChunk(srv.URL + "/" + string(testChunkId))
+	if re, ok := err.(RawxError); !ok || re.Status != http.StatusForbidden || re.Message != "Chunk already exists" {
+		t.Fatal("Bad error: ", err)
+	}
+	if err = cli.DeleteChunk(srv.URL + "/XYZ"); err != ErrorInvalidChunkId {
+		t.Fatal("Invalid chunk URL accepted: ", err)
+	}
+	if _, err = ParseRawxStats(strings.NewReader("counter.rep.hits x\n")); err == nil {
+		t.Fatal("Invalid stat accepted")
+	}
+}
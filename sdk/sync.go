@@ -0,0 +1,594 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2017-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oio
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The property of the contents holding the modification time of the local
+// file, in nanoseconds since the epoch.
+const SyncMtimeKey = "mtime"
+
+// The prefix of the temporary files of the downloads, ignored by the walk
+const syncTempPrefix = ".oio-sync-"
+
+var errSyncCorrupted = errors.New("Downloaded data does not match the content")
+
+type SyncAction string
+
+const (
+	SyncCopy   SyncAction = "copy"
+	SyncDelete SyncAction = "delete"
+)
+
+// SyncOptions tunes a synchronization. The globs follow path.Match and are
+// matched against the relative path and against its last element.
+type SyncOptions struct {
+	// Remove the items of the destination missing in the source
+	Delete bool
+	// Only tell what would be done
+	DryRun bool
+	// How many transfers run at once, 0 for a default value
+	Concurrency int
+	// When not empty, only the paths matching one of them are considered
+	Include []string
+	// The paths matching one of them are neither copied nor deleted
+	Exclude []string
+	// Local file caching the hashes of the local files and recording the
+	// transfers as they complete, so that an interrupted synchronization
+	// resumes without hashing the files again. Empty for none.
+	Manifest string
+	// Storage policy of the uploaded contents
+	Policy string
+}
+
+// SyncOp tells a change brought to the destination by a synchronization.
+// The reason of a copy is "new", "size" or "hash", and "extraneous" for a
+// deletion.
+type SyncOp struct {
+	Path   string
+	Action SyncAction
+	Reason string
+	Size   uint64
+	Err    error
+}
+
+// Syncer mirrors a local directory and the contents of a container whose
+// path starts with a prefix, in either direction. A file and a content are
+// the same when their sizes match and either the modification time of the
+// file is the one saved in the SyncMtimeKey property of the content, or
+// their MD5 hashes match.
+type Syncer struct {
+	storage   ObjectStorage
+	container Container
+	name      ContainerName
+	prefix    string
+	dir       string
+	opts      SyncOptions
+}
+
+// Builds a Syncer between the local directory <dir> and the contents of
+// <n> under <prefix>. A non-empty prefix is a directory, a '/' is appended
+// when missing.
+func MakeSyncer(s ObjectStorage, c Container, n ContainerName, prefix, dir string, opts SyncOptions) *Syncer {
+	if len(prefix) > 0 && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultBulkConcurrency
+	}
+	return &Syncer{storage: s, container: c, name: n, prefix: prefix, dir: dir, opts: opts}
+}
+
+// Uploads the new and changed local files. The changes are sorted by path.
+// An error is only returned when the synchronization could not start, the
+// failures of the transfers are in the changes.
+func (s *Syncer) Upload() ([]SyncOp, error) {
+	return s.sync(true)
+}
+
+// Downloads the new and changed contents, the reverse of Upload()
+func (s *Syncer) Download() ([]SyncOp, error) {
+	return s.sync(false)
+}
+
+type localFile struct {
+	size  uint64
+	mtime int64
+}
+
+func (s *Syncer) objectName(rel string) *FlatName {
+	n := contentName(s.name, s.prefix+rel, 0)
+	return &n
+}
+
+func (s *Syncer) localPath(rel string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(rel))
+}
+
+func (s *Syncer) selected(rel string) bool {
+	match := func(patterns []string) bool {
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, rel); ok {
+				return true
+			}
+			if ok, _ := path.Match(p, path.Base(rel)); ok {
+				return true
+			}
+		}
+		return false
+	}
+	if len(s.opts.Include) > 0 && !match(s.opts.Include) {
+		return false
+	}
+	return !match(s.opts.Exclude)
+}
+
+// Collects the regular files under the directory. A missing directory is
+// empty when it is the destination.
+func (s *Syncer) walk(up bool, manifest string) (map[string]localFile, error) {
+	out := make(map[string]localFile)
+	if _, err := os.Stat(s.dir); os.IsNotExist(err) && !up {
+		return out, nil
+	}
+	err := filepath.Walk(s.dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() || strings.HasPrefix(fi.Name(), syncTempPrefix) {
+			return nil
+		}
+		if abs, _ := filepath.Abs(p); abs == manifest {
+			return nil
+		}
+		rel, err := filepath.Rel(s.dir, p)
+		if err != nil {
+			return err
+		}
+		out[filepath.ToSlash(rel)] = localFile{size: uint64(fi.Size()), mtime: fi.ModTime().UnixNano()}
+		return nil
+	})
+	return out, err
+}
+
+// Collects the contents under the prefix, but the directory markers. A
+// missing container is empty when it is the destination, the uploads
+// create it.
+func (s *Syncer) list(up bool) (map[string]ContentHeader, error) {
+	out := make(map[string]ContentHeader)
+	headers, err := listAll(s.container, s.name, ListParams{Prefix: s.prefix})
+	if err == ErrorNotFound && up {
+		return out, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for _, hdr := range headers {
+		rel := strings.TrimPrefix(hdr.Name, s.prefix)
+		if hdr.Deleted || len(rel) <= 0 || strings.HasSuffix(rel, "/") {
+			continue
+		}
+		out[rel] = hdr
+	}
+	return out, nil
+}
+
+func (s *Syncer) sync(up bool) ([]SyncOp, error) {
+	for _, p := range append(append([]string{}, s.opts.Include...), s.opts.Exclude...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	m, err := openManifest(s.opts.Manifest, s.opts.DryRun)
+	if err != nil {
+		return nil, err
+	}
+	local, err := s.walk(up, m.path)
+	if err != nil {
+		m.close()
+		return nil, err
+	}
+	remote, err := s.list(up)
+	if err != nil {
+		m.close()
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(local)+len(remote))
+	for rel := range local {
+		if s.selected(rel) {
+			paths = append(paths, rel)
+		}
+	}
+	for rel := range remote {
+		if _, ok := local[rel]; !ok && s.selected(rel) {
+			paths = append(paths, rel)
+		}
+	}
+	sort.Strings(paths)
+
+	step := func(rel string) (SyncOp, bool) {
+		lf, lok := local[rel]
+		hdr, rok := remote[rel]
+		if up {
+			return s.stepUp(m, rel, lf, lok, hdr, rok)
+		}
+		return s.stepDown(m, rel, lf, lok, hdr, rok)
+	}
+	ops := s.run(paths, step)
+	return ops, m.close()
+}
+
+// Runs the steps with at most <Concurrency> of them at once, and keeps the
+// changes in the order of the paths.
+func (s *Syncer) run(paths []string, step func(rel string) (SyncOp, bool)) []SyncOp {
+	results := make([]SyncOp, len(paths))
+	changed := make([]bool, len(paths))
+
+	var wg sync.WaitGroup
+	jobs := make(chan int, len(paths))
+	for i := range paths {
+		jobs <- i
+	}
+	close(jobs)
+
+	max := s.opts.Concurrency
+	if max > len(paths) {
+		max = len(paths)
+	}
+	for w := 0; w < max; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i], changed[i] = step(paths[i])
+			}
+		}()
+	}
+	wg.Wait()
+
+	out := make([]SyncOp, 0)
+	for i, op := range results {
+		if changed[i] {
+			out = append(out, op)
+		}
+	}
+	return out
+}
+
+func (s *Syncer) stepUp(m *syncManifest, rel string, lf localFile, lok bool, hdr ContentHeader, rok bool) (SyncOp, bool) {
+	op := SyncOp{Path: rel, Action: SyncCopy, Reason: "new", Size: lf.size}
+	switch {
+	case !lok:
+		if !s.opts.Delete {
+			return op, false
+		}
+		op.Action, op.Reason, op.Size = SyncDelete, "extraneous", hdr.Size
+		if !s.opts.DryRun {
+			op.Err = s.storage.DeleteContent(s.objectName(rel))
+		}
+		return op, true
+	case rok:
+		if op.Reason, op.Err = s.diff(m, rel, lf, hdr, true); op.Err != nil || len(op.Reason) <= 0 {
+			return op, op.Err != nil
+		}
+	}
+	if !s.opts.DryRun {
+		op.Err = s.upload(m, rel, lf)
+	}
+	return op, true
+}
+
+func (s *Syncer) stepDown(m *syncManifest, rel string, lf localFile, lok bool, hdr ContentHeader, rok bool) (SyncOp, bool) {
+	op := SyncOp{Path: rel, Action: SyncCopy, Reason: "new", Size: hdr.Size}
+	switch {
+	case !rok:
+		if !s.opts.Delete {
+			return op, false
+		}
+		op.Action, op.Reason, op.Size = SyncDelete, "extraneous", lf.size
+		if !s.opts.DryRun {
+			op.Err = os.Remove(s.localPath(rel))
+		}
+		return op, true
+	case !fs.ValidPath(rel):
+		op.Err = NameError{Component: "path", Value: rel, Problem: "not a valid local path"}
+		return op, true
+	case lok:
+		if op.Reason, op.Err = s.diff(m, rel, lf, hdr, false); op.Err != nil || len(op.Reason) <= 0 {
+			return op, op.Err != nil
+		}
+	}
+	if !s.opts.DryRun {
+		op.Err = s.download(m, rel, hdr)
+	}
+	return op, true
+}
+
+// Returns the modification time saved in the content, 0 when unknown
+func (s *Syncer) remoteMtime(n ObjectName) (int64, error) {
+	props, err := s.container.GetContentProperties(n)
+	if err != nil {
+		return 0, err
+	}
+	mtime, _ := strconv.ParseInt(props[SyncMtimeKey], 10, 64)
+	return mtime, nil
+}
+
+// Tells why the file and the content differ, or "" when they are the same.
+// When only the hashes match, the modification time of the destination is
+// fixed so that the next synchronization won't hash the file again.
+func (s *Syncer) diff(m *syncManifest, rel string, lf localFile, hdr ContentHeader, up bool) (string, error) {
+	if lf.size != hdr.Size {
+		return "size", nil
+	}
+	n := s.objectName(rel)
+	mtime, err := s.remoteMtime(n)
+	if err != nil {
+		return "", err
+	}
+	if mtime == lf.mtime {
+		return "", nil
+	}
+	sum, err := s.localHash(m, rel, lf)
+	if err != nil {
+		return "", err
+	}
+	if len(hdr.Hash) <= 0 || !strings.EqualFold(sum, hdr.Hash) {
+		return "hash", nil
+	}
+	if s.opts.DryRun {
+		return "", nil
+	}
+	if up {
+		_, err = s.container.SetContentProperties(n, map[string]string{SyncMtimeKey: strconv.FormatInt(lf.mtime, 10)})
+	} else if mtime != 0 {
+		err = s.touch(m, rel, time.Unix(0, mtime), sum)
+	}
+	return "", err
+}
+
+// Returns the MD5 hash of the file, from the manifest when it tells the
+// hash of a file with the same size and modification time.
+func (s *Syncer) localHash(m *syncManifest, rel string, lf localFile) (string, error) {
+	if sum, ok := m.lookup(rel, lf); ok {
+		return sum, nil
+	}
+	f, err := os.Open(s.localPath(rel))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := md5.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	sum := strings.ToUpper(hex.EncodeToString(h.Sum(nil)))
+	return sum, m.record(manifestEntry{Path: rel, Size: lf.size, MTime: lf.mtime, Hash: sum})
+}
+
+// Sets the modification time of the file, and records it with its hash
+func (s *Syncer) touch(m *syncManifest, rel string, mtime time.Time, sum string) error {
+	p := s.localPath(rel)
+	if err := os.Chtimes(p, mtime, mtime); err != nil {
+		return err
+	}
+	fi, err := os.Stat(p)
+	if err != nil {
+		return err
+	}
+	return m.record(manifestEntry{Path: rel, Size: uint64(fi.Size()), MTime: fi.ModTime().UnixNano(), Hash: sum})
+}
+
+func (s *Syncer) upload(m *syncManifest, rel string, lf localFile) error {
+	f, err := os.Open(s.localPath(rel))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	n := s.objectName(rel)
+	if err = s.storage.PutContentWithPolicy(n, lf.size, s.opts.Policy, true, f); err != nil {
+		return err
+	}
+	props := map[string]string{SyncMtimeKey: strconv.FormatInt(lf.mtime, 10)}
+	if _, err = s.container.SetContentProperties(n, props); err != nil {
+		return err
+	}
+	hdr, err := s.container.StatContent(n)
+	if err != nil {
+		return err
+	}
+	return m.record(manifestEntry{Path: rel, Size: lf.size, MTime: lf.mtime, Hash: hdr.Hash})
+}
+
+// Downloads in a temporary file renamed once complete and checked, so that
+// an interrupted download leaves the previous file.
+func (s *Syncer) download(m *syncManifest, rel string, hdr ContentHeader) error {
+	n := s.objectName(rel)
+	mtime, err := s.remoteMtime(n)
+	if err != nil {
+		return err
+	}
+	if mtime == 0 {
+		mtime = time.Unix(int64(hdr.CTime), 0).UnixNano()
+	}
+
+	dst := s.localPath(rel)
+	if err = os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(dst), syncTempPrefix)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	in, err := s.storage.GetContent(n)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	h := md5.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), in)
+	in.Close()
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	sum := strings.ToUpper(hex.EncodeToString(h.Sum(nil)))
+	if uint64(size) != hdr.Size || (len(hdr.Hash) > 0 && !strings.EqualFold(sum, hdr.Hash)) {
+		return errSyncCorrupted
+	}
+	if err = os.Rename(tmp.Name(), dst); err != nil {
+		return err
+	}
+	return s.touch(m, rel, time.Unix(0, mtime), sum)
+}
+
+// A line of the manifest
+type manifestEntry struct {
+	Path  string `json:"path"`
+	Size  uint64 `json:"size"`
+	MTime int64  `json:"mtime"`
+	Hash  string `json:"hash"`
+}
+
+// The manifest is a file of JSON lines, appended as the files are hashed
+// or transferred, and compacted at the end of the synchronization. Without
+// a file, it only caches the hashes during the synchronization.
+type syncManifest struct {
+	lock    sync.Mutex
+	path    string
+	entries map[string]manifestEntry
+	out     *bufio.Writer
+	file    *os.File
+}
+
+// Loads the manifest, and opens it for appending unless <readOnly>. The
+// last line may be truncated by an interruption, it is ignored.
+func openManifest(p string, readOnly bool) (*syncManifest, error) {
+	m := &syncManifest{entries: make(map[string]manifestEntry)}
+	if len(p) <= 0 {
+		return m, nil
+	}
+	var err error
+	if m.path, err = filepath.Abs(p); err != nil {
+		return nil, err
+	}
+	if f, err := os.Open(m.path); err == nil {
+		dec := json.NewDecoder(bufio.NewReader(f))
+		for {
+			var e manifestEntry
+			if dec.Decode(&e) != nil {
+				break
+			}
+			m.entries[e.Path] = e
+		}
+		f.Close()
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if readOnly {
+		return m, nil
+	}
+	if m.file, err = os.OpenFile(m.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644); err != nil {
+		return nil, err
+	}
+	m.out = bufio.NewWriter(m.file)
+	return m, nil
+}
+
+func (m *syncManifest) lookup(rel string, lf localFile) (string, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	e, ok := m.entries[rel]
+	if !ok || e.Size != lf.size || e.MTime != lf.mtime || len(e.Hash) <= 0 {
+		return "", false
+	}
+	return e.Hash, true
+}
+
+func (m *syncManifest) record(e manifestEntry) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.entries[e.Path] = e
+	if m.out == nil {
+		return nil
+	}
+	encoded, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	m.out.Write(encoded)
+	m.out.WriteByte('\n')
+	return m.out.Flush()
+}
+
+// Rewrites the manifest with the latest entry of each path
+func (m *syncManifest) close() error {
+	if m.file == nil {
+		return nil
+	}
+	if err := m.file.Close(); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(m.path), syncTempPrefix)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	paths := make([]string, 0, len(m.entries))
+	for p := range m.entries {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	out := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(out)
+	for _, p := range paths {
+		if err = enc.Encode(m.entries[p]); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err = out.Flush(); err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), m.path)
+}
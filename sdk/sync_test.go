@@ -0,0 +1,209 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2017-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oio_test
+
+import (
+	"bytes"
+	"github.com/jfsmig/oio-go/oiotest"
+	oio "github.com/jfsmig/oio-go/sdk"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type syncTest struct {
+	t         *testing.T
+	ns        *oiotest.Namespace
+	storage   oio.ObjectStorage
+	container oio.Container
+	name      *oio.FlatName
+	root      string
+}
+
+func makeSyncTest(t *testing.T) *syncTest {
+	st := &syncTest{t: t, ns: oiotest.StartNamespace("NS", 3)}
+	st.ns.Container.SetChunkSize(8)
+	st.storage, _ = oio.MakeDefaultObjectStorageClient("NS", st.ns.Config)
+	st.container, _ = oio.MakeContainerClient("NS", st.ns.Config)
+	st.name = &oio.FlatName{N: "NS", A: "ACCT", U: "builds"}
+	var err error
+	if st.root, err = ioutil.TempDir("", "oio-sync-"); err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+func (st *syncTest) close() {
+	os.RemoveAll(st.root)
+	st.ns.Close()
+}
+
+func (st *syncTest) write(rel, data string, mtime time.Time) {
+	p := filepath.Join(st.root, filepath.FromSlash(rel))
+	os.MkdirAll(filepath.Dir(p), 0755)
+	if err := ioutil.WriteFile(p, []byte(data), 0644); err != nil {
+		st.t.Fatal(err)
+	}
+	if err := os.Chtimes(p, mtime, mtime); err != nil {
+		st.t.Fatal(err)
+	}
+}
+
+// Runs the synchronization and checks the changes, as "action:path:reason"
+func (st *syncTest) sync(up bool, dir string, opts oio.SyncOptions, expected ...string) {
+	s := oio.MakeSyncer(st.storage, st.container, st.name, "v1", filepath.Join(st.root, dir), opts)
+	var ops []oio.SyncOp
+	var err error
+	if up {
+		ops, err = s.Upload()
+	} else {
+		ops, err = s.Download()
+	}
+	if err != nil {
+		st.t.Fatal("Sync failed: ", err)
+	}
+	got := make([]string, 0, len(ops))
+	for _, op := range ops {
+		if op.Err != nil {
+			st.t.Fatal("Sync of ", op.Path, " failed: ", op.Err)
+		}
+		got = append(got, string(op.Action)+":"+op.Path+":"+op.Reason)
+	}
+	if strings.Join(got, " ") != strings.Join(expected, " ") {
+		st.t.Fatal("Unexpected changes: ", got, ", expected ", expected)
+	}
+}
+
+func TestSync_Upload(t *testing.T) {
+	st := makeSyncTest(t)
+	defer st.close()
+
+	past := time.Unix(1500000000, 123456789)
+	st.write("src/a.txt", "aaaa", past)
+	st.write("src/dir/b.bin", "bbbbbbbbbbbbbbbbbbbb", past)
+	st.write("src/dir/sub/c", "", past)
+	st.write("src/build.log", "log", past)
+	opts := oio.SyncOptions{Exclude: []string{"*.log"}, Concurrency: 2}
+
+	dry := opts
+	dry.DryRun = true
+	st.sync(true, "src", dry, "copy:a.txt:new", "copy:dir/b.bin:new", "copy:dir/sub/c:new")
+	st.sync(true, "src", dry, "copy:a.txt:new", "copy:dir/b.bin:new", "copy:dir/sub/c:new")
+	st.sync(true, "src", opts, "copy:a.txt:new", "copy:dir/b.bin:new", "copy:dir/sub/c:new")
+	st.sync(true, "src", opts)
+
+	props, err := st.container.GetContentProperties(&oio.FlatName{N: "NS", A: "ACCT", U: "builds", P: "v1/dir/b.bin"})
+	if err != nil || props[oio.SyncMtimeKey] != "1500000000123456789" {
+		t.Fatal("Unexpected properties: ", props, err)
+	}
+
+	// A new mtime alone is saved without any upload
+	now := time.Unix(1600000000, 0)
+	st.write("src/a.txt", "aaaa", now)
+	st.sync(true, "src", opts)
+	st.write("src/a.txt", "AAAA", now.Add(time.Second))
+	st.write("src/dir/b.bin", "b", now)
+	st.sync(true, "src", opts, "copy:a.txt:hash", "copy:dir/b.bin:size")
+
+	// The extraneous contents are deleted on demand, but the excluded ones
+	extra := oio.FlatName{N: "NS", A: "ACCT", U: "builds", P: "v1/old"}
+	excluded := oio.FlatName{N: "NS", A: "ACCT", U: "builds", P: "v1/old.log"}
+	for _, n := range []oio.FlatName{extra, excluded} {
+		if err = st.storage.PutContent(&n, 1, true, bytes.NewReader([]byte("x"))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	st.sync(true, "src", opts)
+	opts.Delete = true
+	st.sync(true, "src", opts, "delete:old:extraneous")
+	if ok, _ := st.storage.HasContent(&excluded); !ok {
+		t.Fatal("Excluded content deleted")
+	}
+
+	// Only the included paths are considered
+	st.write("src/dir/d.txt", "dddd", now)
+	st.sync(true, "src", oio.SyncOptions{Include: []string{"dir/sub/*"}, Delete: true})
+	st.sync(true, "src", oio.SyncOptions{Include: []string{"*.txt"}}, "copy:dir/d.txt:new")
+}
+
+func TestSync_Download(t *testing.T) {
+	st := makeSyncTest(t)
+	defer st.close()
+
+	past := time.Unix(1500000000, 123456789)
+	st.write("src/a.txt", "aaaa", past)
+	st.write("src/dir/b.bin", "bbbbbbbbbbbbbbbbbbbb", past)
+	st.sync(true, "src", oio.SyncOptions{}, "copy:a.txt:new", "copy:dir/b.bin:new")
+
+	st.sync(false, "dst", oio.SyncOptions{}, "copy:a.txt:new", "copy:dir/b.bin:new")
+	st.sync(false, "dst", oio.SyncOptions{})
+	for _, rel := range []string{"a.txt", "dir/b.bin"} {
+		src, _ := ioutil.ReadFile(filepath.Join(st.root, "src", rel))
+		dst, err := ioutil.ReadFile(filepath.Join(st.root, "dst", rel))
+		if err != nil || !bytes.Equal(src, dst) {
+			t.Fatal("Unexpected data of ", rel, ": ", err, string(dst))
+		}
+		fi, err := os.Stat(filepath.Join(st.root, "dst", rel))
+		if err != nil || !fi.ModTime().Equal(past) {
+			t.Fatal("Unexpected mtime of ", rel, ": ", err)
+		}
+	}
+
+	// A local change is overwritten, and the extraneous files deleted
+	st.write("dst/a.txt", "AAAA", time.Now())
+	st.write("dst/extra", "x", time.Now())
+	st.sync(false, "dst", oio.SyncOptions{Delete: true}, "copy:a.txt:hash", "delete:extra:extraneous")
+	if b, _ := ioutil.ReadFile(filepath.Join(st.root, "dst", "a.txt")); string(b) != "aaaa" {
+		t.Fatal("Local change not overwritten: ", string(b))
+	}
+	if _, err := os.Stat(filepath.Join(st.root, "dst", "extra")); !os.IsNotExist(err) {
+		t.Fatal("Extraneous file not deleted: ", err)
+	}
+}
+
+func TestSync_Manifest(t *testing.T) {
+	st := makeSyncTest(t)
+	defer st.close()
+
+	past := time.Unix(1500000000, 0)
+	st.write("src/a.txt", "aaaa", past)
+	st.write("src/b.txt", "bbbb", past)
+	manifest := filepath.Join(st.root, "src", "manifest")
+	opts := oio.SyncOptions{Manifest: manifest}
+	st.sync(true, "src", opts, "copy:a.txt:new", "copy:b.txt:new")
+
+	b, err := ioutil.ReadFile(manifest)
+	if err != nil || strings.Count(string(b), "\n") != 2 {
+		t.Fatal("Unexpected manifest: ", err, string(b))
+	}
+
+	// The hash is taken from the manifest when the file is unchanged, else
+	// it is computed again.
+	n := oio.FlatName{N: "NS", A: "ACCT", U: "builds", P: "v1/a.txt"}
+	st.container.DeleteContentProperties(&n, []string{oio.SyncMtimeKey})
+	n.P = "v1/b.txt"
+	st.container.DeleteContentProperties(&n, []string{oio.SyncMtimeKey})
+	forged := strings.Replace(string(b), `"hash":"`, `"hash":"00`, -1)
+	if err = ioutil.WriteFile(manifest, []byte(forged), 0644); err != nil {
+		t.Fatal(err)
+	}
+	st.write("src/b.txt", "bbbb", past.Add(time.Second))
+	st.sync(true, "src", opts, "copy:a.txt:hash")
+}
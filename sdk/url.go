@@ -0,0 +1,207 @@
+// OpenIO SDS Go client SDK
+// Copyright (C) 2017-2018 OpenIO
+//
+// This library is free software; you can redistribute it and/or
+// modify it under the terms of the GNU Lesser General Public
+// License as published by the Free Software Foundation; either
+// version 3.0 of the License, or (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public
+// License along with this library.
+
+package oio
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The scheme of the URL naming the objects, i.e.
+// oio://NS/ACCOUNT/USER[/TYPE/PATH]?version=N&id=X
+// Each component is escaped, so that a '/' in the path never splits it. A
+// URL with 3 components names a container, an object always comes with the
+// service subtype of its container, empty when there is none (e.g.
+// oio://NS/ACCT/USER//PATH). An empty path names the typed container itself
+// (e.g. oio://NS/ACCT/USER/TYPE/). Any other count of components is rejected,
+// since an unescaped '/' in the path cannot be told from the subtype.
+const NameScheme = "oio"
+
+const namePrefix = NameScheme + "://"
+
+var nsPattern = regexp.MustCompile("^[0-9a-zA-Z]+(\\.[0-9a-zA-Z]+)*$")
+var typePattern = regexp.MustCompile("^[0-9a-zA-Z_.-]*$")
+
+// Returned when a component of a name is missing or badly formed
+type NameError struct {
+	Component string
+	Value     string
+	Problem   string
+}
+
+func (e NameError) Error() string {
+	return fmt.Sprintf("Invalid %s [%s]: %s", e.Component, e.Value, e.Problem)
+}
+
+func hasControlChar(s string) bool {
+	for _, r := range s {
+		if r < 0x20 || r == 0x7F {
+			return true
+		}
+	}
+	return false
+}
+
+func checkComponent(component, value string, mandatory bool) error {
+	if len(value) <= 0 {
+		if mandatory {
+			return NameError{component, value, "empty"}
+		}
+		return nil
+	}
+	if hasControlChar(value) {
+		return NameError{component, value, "forbidden character"}
+	}
+	return nil
+}
+
+// Tells if the string is a valid namespace name, with the same rules than
+// the rawx services.
+func IsValidNamespace(ns string) bool {
+	return nsPattern.MatchString(ns)
+}
+
+// Checks each component of the container name: a namespace, an account and
+// a user, all mandatory, and an optional service subtype.
+func ValidateContainerName(n ContainerName) error {
+	if !IsValidNamespace(n.NS()) {
+		return NameError{"namespace", n.NS(), "bad format"}
+	}
+	if err := checkComponent("account", n.Account(), true); err != nil {
+		return err
+	}
+	if err := checkComponent("user", n.User(), true); err != nil {
+		return err
+	}
+	if !typePattern.MatchString(n.Type()) {
+		return NameError{"type", n.Type(), "forbidden character"}
+	}
+	return nil
+}
+
+// Checks the components of the container, then the path (mandatory) and the
+// content id (optional, hexadecimal).
+func ValidateObjectName(n ObjectName) error {
+	if err := ValidateContainerName(n); err != nil {
+		return err
+	}
+	if err := checkComponent("path", n.Path(), true); err != nil {
+		return err
+	}
+	if !IsHexString(n.Id(), 0) {
+		return NameError{"id", n.Id(), "not hexadecimal"}
+	}
+	return nil
+}
+
+// Returns the URL of the container, or of the object if the name also
+// implements ObjectName and has a path.
+func FormatName(n ContainerName) string {
+	var b bytes.Buffer
+	b.WriteString(namePrefix)
+	b.WriteString(url.PathEscape(n.NS()))
+	b.WriteRune('/')
+	b.WriteString(url.PathEscape(n.Account()))
+	b.WriteRune('/')
+	b.WriteString(url.PathEscape(n.User()))
+
+	on, isObject := n.(ObjectName)
+	if t := n.Type(); len(t) > 0 {
+		b.WriteRune('/')
+		b.WriteString(url.PathEscape(t))
+		b.WriteRune('/')
+	} else if isObject && len(on.Path()) > 0 {
+		b.WriteString("//")
+	}
+	if !isObject {
+		return b.String()
+	}
+
+	b.WriteString(url.PathEscape(on.Path()))
+	query := url.Values{}
+	if v := on.Version(); v != 0 {
+		query.Set("version", strconv.FormatUint(v, 10))
+	}
+	if id := on.Id(); len(id) > 0 {
+		query.Set("id", id)
+	}
+	if len(query) > 0 {
+		b.WriteRune('?')
+		b.WriteString(query.Encode())
+	}
+	return b.String()
+}
+
+// Parses a URL produced by FormatName(). The container's components are
+// validated, the path is left empty when the URL names a container.
+func ParseName(s string) (*FlatName, error) {
+	if !strings.HasPrefix(s, namePrefix) {
+		return nil, NameError{"scheme", s, "expected " + namePrefix}
+	}
+	s = s[len(namePrefix):]
+
+	var rawQuery string
+	if idx := strings.IndexByte(s, '?'); idx >= 0 {
+		s, rawQuery = s[:idx], s[idx+1:]
+	}
+
+	tokens := strings.Split(s, "/")
+	if len(tokens) != 3 && len(tokens) != 5 {
+		return nil, NameError{"url", s, "expected NS/ACCOUNT/USER[/TYPE/PATH], with an escaped PATH and a TYPE maybe empty"}
+	}
+	for i, t := range tokens {
+		var err error
+		if tokens[i], err = url.PathUnescape(t); err != nil {
+			return nil, NameError{"url", t, err.Error()}
+		}
+	}
+
+	n := &FlatName{N: tokens[0], A: tokens[1], U: tokens[2]}
+	if len(tokens) == 5 {
+		n.S, n.P = tokens[3], tokens[4]
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, NameError{"query", rawQuery, err.Error()}
+	}
+	for k := range query {
+		if k != "version" && k != "id" {
+			return nil, NameError{"query", k, "unexpected parameter"}
+		}
+	}
+	if v := query.Get("version"); len(v) > 0 {
+		if n.V, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return nil, NameError{"version", v, "not an integer"}
+		}
+	}
+	n.I = query.Get("id")
+
+	if err = ValidateContainerName(n); err != nil {
+		return nil, err
+	}
+	if len(n.P) > 0 || len(n.I) > 0 || n.V != 0 {
+		if err = ValidateObjectName(n); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}